@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	migration "silverfish/migration"
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+	usecase "silverfish/silverfish/usecase"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// runCommand runs a maintenance command given on the command line
+// instead of serving HTTP, returning the exit code.
+func runCommand(sf *silverfish.Silverfish, args []string) int {
+	switch args[0] {
+	case "import":
+		return importCommand(sf, args[1:])
+	case "backup":
+		return backupCommand(sf, args[1:])
+	case "restore":
+		return restoreCommand(sf, args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %s, expected one of: migrate, import, backup, restore\n", args[0])
+	return 2
+}
+
+// migrateCommand applies pending migrations, or with -status lists
+// every migration and when it was applied. It runs before Silverfish is
+// built, so that nothing touches the collections mid-migration.
+func migrateCommand(db *mongo.Database, args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	status := flags.Bool("status", false, "list migrations instead of applying them")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: silverfish migrate [-status]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	migrator := migration.New(db)
+	if *status {
+		statuses, err := migrator.Status()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedDatetime != nil {
+				applied = status.AppliedDatetime.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%s  %-19s  %s\n", status.ID, applied, status.Description)
+		}
+		return 0
+	}
+	applied, err := migrator.Run()
+	for _, id := range applied {
+		fmt.Fprintf(os.Stderr, "applied %s\n", id)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(applied) == 0 {
+		fmt.Fprintln(os.Stderr, "Nothing to migrate")
+	}
+	return 0
+}
+
+// backupCommand writes a backup archive to a file (or `-` for stdout).
+func backupCommand(sf *silverfish.Silverfish, args []string) int {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: silverfish backup <file|->")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	out := os.Stdout
+	if path := flags.Arg(0); path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	manifest, err := sf.Backup.Export(out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for name, count := range manifest.Collections {
+		fmt.Fprintf(os.Stderr, "%-13s %d\n", name, count)
+	}
+	return 0
+}
+
+// restoreCommand loads a backup archive from a file (or `-` for stdin)
+// and prints the report as JSON.
+func restoreCommand(sf *silverfish.Silverfish, args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	strategy := flags.String("strategy", entity.RestoreMerge, "merge into or replace the current library")
+	dryRun := flags.Bool("dry-run", false, "only report what would change")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: silverfish restore [-strategy merge|replace] [-dry-run] <file|->")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	in := os.Stdin
+	if path := flags.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		in = file
+	}
+	report, err := sf.Backup.Restore(in, *strategy, *dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	js, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(js))
+	return 0
+}
+
+// importCommand adds the books listed in a file (or `-` for stdin) and
+// prints the per-URL report as JSON.
+func importCommand(sf *silverfish.Silverfish, args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "list format: text, csv or json (guessed when empty)")
+	workers := flags.Int("workers", 4, "books crawled at once")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: silverfish import [-format text|csv|json] [-workers N] <file|->")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var data []byte
+	var err error
+	if path := flags.Arg(0); path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	urls, err := usecase.ParseImportList(data, *format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	results := sf.Importer.Run(urls, *workers, func(result entity.JobResult) {
+		fmt.Fprintf(os.Stderr, "%-11s %s %s\n", result.Status, result.Item, result.Error)
+	})
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	js, _ := json.MarshalIndent(map[string]interface{}{
+		"counts":  counts,
+		"results": results,
+	}, "", "  ")
+	fmt.Println(string(js))
+	if counts[entity.ImportFailed] > 0 {
+		return 1
+	}
+	return 0
+}
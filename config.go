@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"os"
 	"strconv"
+	"strings"
+
+	interf "silverfish/silverfish/interface"
+	usecase "silverfish/silverfish/usecase"
 
 	"github.com/sirupsen/logrus"
 )
@@ -17,9 +21,41 @@ type Config struct {
 	Port          string
 	DbHost        string
 	HashSalt      string
-	RecaptchaKey  string
 	AllowOrigin   []string
 	CrawlDuration int
+	// TrustProxy takes the client IP from X-Forwarded-For; only enable it
+	// behind a reverse proxy that sets the header. TrustedProxyHops is
+	// how many proxies in front of us append to it, e.g. 2 for a CDN in
+	// front of nginx.
+	TrustProxy       bool
+	TrustedProxyHops int
+	// AutoMigrate applies pending schema migrations at startup; when off,
+	// run `silverfish migrate` before upgrading.
+	AutoMigrate bool
+
+	CaptchaProvider   string
+	CaptchaSecret     string
+	CaptchaVerifyURL  string
+	CaptchaDifficulty int
+
+	// OIDC single sign-on, enabled by setting OIDC_ISSUER.
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCScopes        string
+	OIDCGroupsClaim   string
+	OIDCAdminGroup    string
+	OIDCAutoProvision bool
+
+	// SMTP relay for password reset mails, enabled by setting SMTP_HOST
+	// and PASSWORD_RESET_URL (the frontend page, `{token}` is replaced).
+	SMTPHost         string
+	SMTPPort         int
+	SMTPUsername     string
+	SMTPPassword     string
+	SMTPFrom         string
+	PasswordResetURL string
 }
 
 func getEnvWithDefault[T int | float64 | bool | string](key string, fallback T) T {
@@ -64,16 +100,39 @@ func NewConfig() *Config {
 	}
 
 	c := &Config{
-		Debug:         getEnvWithDefault("DEBUG", false),
-		SSL:           getEnvWithDefault("SSL", true),
-		SSLPem:        getEnvWithDefault("SSL_PEM", "./server.pem"),
-		SSLKey:        getEnvWithDefault("SSL_KEY", "./server.key"),
-		Port:          getEnvWithDefault("PORT", "8080"),
-		DbHost:        getEnvWithDefault("DB_HOST", "mongo:27017"),
-		HashSalt:      getEnvWithDefault("HASH_SALT", "THIS_IS_A_VERY_COMPLICATED_HASH_SALT_FOR_SILVERFISH_BACKEND"),
-		RecaptchaKey:  os.Getenv("RECAPTCHA_KEY"),
-		AllowOrigin:   allowOrigins,
-		CrawlDuration: getEnvWithDefault("CRAWL_DURATION", 60),
+		Debug:            getEnvWithDefault("DEBUG", false),
+		SSL:              getEnvWithDefault("SSL", true),
+		SSLPem:           getEnvWithDefault("SSL_PEM", "./server.pem"),
+		SSLKey:           getEnvWithDefault("SSL_KEY", "./server.key"),
+		Port:             getEnvWithDefault("PORT", "8080"),
+		DbHost:           getEnvWithDefault("DB_HOST", "mongo:27017"),
+		HashSalt:         getEnvWithDefault("HASH_SALT", "THIS_IS_A_VERY_COMPLICATED_HASH_SALT_FOR_SILVERFISH_BACKEND"),
+		AllowOrigin:      allowOrigins,
+		CrawlDuration:    getEnvWithDefault("CRAWL_DURATION", 60),
+		TrustProxy:       getEnvWithDefault("TRUST_PROXY", false),
+		TrustedProxyHops: getEnvWithDefault("TRUSTED_PROXY_HOPS", 1),
+		AutoMigrate:      getEnvWithDefault("AUTO_MIGRATE", true),
+
+		CaptchaProvider:   os.Getenv("CAPTCHA_PROVIDER"),
+		CaptchaSecret:     os.Getenv("CAPTCHA_SECRET"),
+		CaptchaVerifyURL:  os.Getenv("CAPTCHA_VERIFY_URL"),
+		CaptchaDifficulty: getEnvWithDefault("CAPTCHA_DIFFICULTY", 18),
+
+		OIDCIssuer:        os.Getenv("OIDC_ISSUER"),
+		OIDCClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+		OIDCScopes:        getEnvWithDefault("OIDC_SCOPES", "openid profile email"),
+		OIDCGroupsClaim:   getEnvWithDefault("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCAdminGroup:    os.Getenv("OIDC_ADMIN_GROUP"),
+		OIDCAutoProvision: getEnvWithDefault("OIDC_AUTO_PROVISION", true),
+
+		SMTPHost:         os.Getenv("SMTP_HOST"),
+		SMTPPort:         getEnvWithDefault("SMTP_PORT", 587),
+		SMTPUsername:     os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:     os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:         os.Getenv("SMTP_FROM"),
+		PasswordResetURL: os.Getenv("PASSWORD_RESET_URL"),
 	}
 	if c.Debug {
 		logrus.SetLevel(logrus.DebugLevel)
@@ -82,10 +141,69 @@ func NewConfig() *Config {
 	}
 
 	if c.HashSalt == "THIS_IS_A_VERY_COMPLICATED_HASH_SALT_FOR_SILVERFISH_BACKEND" {
+		// Only verifies legacy SHA-512 passwords; new hashes are argon2id
+		// with per-user salts.
 		logrus.Println("You are using default `hash_salt`, maybe change one?")
 	}
-	if c.RecaptchaKey == "" {
-		logrus.Fatal("env recaptcha_key is needed.")
+	if c.CaptchaProvider == "" {
+		c.CaptchaProvider = "recaptcha"
+	}
+	if c.CaptchaSecret == "" {
+		// RECAPTCHA_KEY predates CAPTCHA_SECRET and is still honoured.
+		c.CaptchaSecret = os.Getenv("RECAPTCHA_KEY")
+	}
+	if c.TrustProxy && c.TrustedProxyHops < 1 {
+		logrus.Fatal("env trusted_proxy_hops should be at least 1 with trust_proxy.")
+	}
+	if !c.TrustProxy {
+		logrus.Println("Trust_proxy is off, behind a reverse proxy all clients share one login throttle bucket.")
+	}
+	if c.OIDCIssuer != "" && (c.OIDCClientID == "" || c.OIDCRedirectURL == "") {
+		logrus.Fatal("env oidc_client_id and oidc_redirect_url are needed with oidc_issuer.")
+	}
+	if c.SMTPHost != "" && (c.SMTPFrom == "" || !strings.Contains(c.PasswordResetURL, "{token}")) {
+		logrus.Fatal("env smtp_from and password_reset_url (with `{token}`) are needed with smtp_host.")
+	}
+	if c.CaptchaProvider == "none" {
+		logrus.Println("Captcha is disabled, register and login are open to bots.")
 	}
 	return c
 }
+
+// ProxyHops export — how many X-Forwarded-For entries, counted from the
+// right, our own proxies added; 0 when the header isn't trusted.
+func (c *Config) ProxyHops() int {
+	if !c.TrustProxy {
+		return 0
+	}
+	return c.TrustedProxyHops
+}
+
+// OIDCConfig export — nil when single sign-on isn't configured.
+func (c *Config) OIDCConfig() *usecase.OIDCConfig {
+	if c.OIDCIssuer == "" {
+		return nil
+	}
+	scopes := strings.Fields(c.OIDCScopes)
+	if len(scopes) == 0 {
+		scopes = []string{"openid"}
+	}
+	return &usecase.OIDCConfig{
+		Issuer:        c.OIDCIssuer,
+		ClientID:      c.OIDCClientID,
+		ClientSecret:  c.OIDCClientSecret,
+		RedirectURL:   c.OIDCRedirectURL,
+		Scopes:        scopes,
+		GroupsClaim:   c.OIDCGroupsClaim,
+		AdminGroup:    c.OIDCAdminGroup,
+		AutoProvision: c.OIDCAutoProvision,
+	}
+}
+
+// Mailer export — nil when no SMTP relay is configured.
+func (c *Config) Mailer() interf.IMailer {
+	if c.SMTPHost == "" {
+		return nil
+	}
+	return usecase.NewSMTPMailer(c.SMTPHost, c.SMTPPort, c.SMTPUsername, c.SMTPPassword, c.SMTPFrom)
+}
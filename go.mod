@@ -4,9 +4,11 @@ go 1.25.0
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.1
+	github.com/andybalholm/cascadia v1.1.0
 	github.com/axgle/mahonia v0.0.0-20180208002826-3358181d7394
 	github.com/go-rod/rod v0.88.2
 	github.com/gorilla/mux v1.7.3
+	github.com/graphql-go/graphql v0.8.1
 	github.com/joho/godotenv v1.5.1
 	github.com/kenshaw/baseconv v0.0.0-20180401001559-5ac6a1b7584c
 	github.com/pkg/errors v0.8.1
@@ -14,11 +16,12 @@ require (
 	github.com/rs/cors v1.6.0
 	github.com/sirupsen/logrus v1.8.3
 	go.mongodb.org/mongo-driver v1.17.9
+	golang.org/x/crypto v0.45.0
+	golang.org/x/net v0.47.0
 	golang.org/x/text v0.31.0
 )
 
 require (
-	github.com/andybalholm/cascadia v1.1.0 // indirect
 	github.com/golang/snappy v0.0.4 // indirect
 	github.com/klauspost/compress v1.16.7 // indirect
 	github.com/montanaflynn/stats v0.7.1 // indirect
@@ -29,8 +32,6 @@ require (
 	github.com/ysmood/goob v0.3.0 // indirect
 	github.com/ysmood/gson v0.6.3 // indirect
 	github.com/ysmood/leakless v0.6.11 // indirect
-	golang.org/x/crypto v0.45.0 // indirect
-	golang.org/x/net v0.47.0 // indirect
 	golang.org/x/sync v0.18.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
 	gopkg.in/sourcemap.v1 v1.0.5 // indirect
@@ -6,38 +6,20 @@ import (
 	"os"
 	"time"
 
+	migration "silverfish/migration"
 	router "silverfish/router"
+	captcha "silverfish/router/captcha"
 	silverfish "silverfish/silverfish"
 	entity "silverfish/silverfish/entity"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/pkg/errors"
-	"github.com/rs/cors"
 	"github.com/sirupsen/logrus"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func ensureSessionIndexes(col *mongo.Collection) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	_, err := col.Indexes().CreateMany(ctx, []mongo.IndexModel{
-		{
-			Keys:    bson.D{{Key: "token", Value: 1}},
-			Options: options.Index().SetUnique(true),
-		},
-		{
-			Keys:    bson.D{{Key: "expireTS", Value: 1}},
-			Options: options.Index().SetExpireAfterSeconds(0),
-		},
-	})
-	if err != nil {
-		logrus.Fatal(errors.Wrap(err, "...while creating session indexes: "))
-	}
-}
-
 func dbInit(mongoHost *string) *mongo.Client {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -73,12 +55,35 @@ func main() {
 	logrus.Print("<- MongoDB inited!")
 	logrus.Print("-> Initing Silverfish ...")
 	db := client.Database("silverfish")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrateCommand(db, os.Args[2:]))
+	}
+	migrator := migration.New(db)
+	if config.AutoMigrate {
+		applied, err := migrator.Run()
+		if err != nil {
+			logrus.Fatal(errors.Wrap(err, "...while migrating: "))
+		}
+		logrus.Printf("..... Applied %d migrations", len(applied))
+	} else if pending, err := migrator.Pending(); err != nil {
+		logrus.Fatal(errors.Wrap(err, "...while checking migrations: "))
+	} else if len(pending) > 0 {
+		logrus.Printf("%d migrations are pending, run `silverfish migrate`: %v", len(pending), pending)
+	}
 	sessionCol := db.Collection("session")
-	ensureSessionIndexes(sessionCol)
 	userInf := entity.NewMongoInf(db.Collection("user"))
 	novelInf := entity.NewMongoInf(db.Collection("novel"))
 	comicInf := entity.NewMongoInf(db.Collection("comic"))
 	sessionInf := entity.NewMongoInf(sessionCol)
+	filterInf := entity.NewMongoInf(db.Collection("filterRule"))
+	filterHistoryInf := entity.NewMongoInf(db.Collection("filterRuleHistory"))
+	loginAttemptInf := entity.NewMongoInf(db.Collection("loginAttempt"))
+	apiTokenInf := entity.NewMongoInf(db.Collection("apiToken"))
+	oidcStateInf := entity.NewMongoInf(db.Collection("oidcState"))
+	passwordResetInf := entity.NewMongoInf(db.Collection("passwordReset"))
+	auditInf := entity.NewMongoInf(db.Collection("audit"))
+	jobInf := entity.NewMongoInf(db.Collection("job"))
+	novelChapterInf := entity.NewMongoInf(db.Collection("novelChapter"))
 	userColCount, _ := userInf.CountDocuments()
 	logrus.Printf("..... User Collection documents count: %d", userColCount)
 	novelColCount, _ := novelInf.CountDocuments()
@@ -87,27 +92,52 @@ func main() {
 	logrus.Printf("..... Comic Collection documents count: %d", comicColCount)
 	logrus.Print("... Collection Infrastructure inited.")
 
-	silverfishInstance := silverfish.New(&config.HashSalt, config.CrawlDuration, userInf, novelInf, comicInf, sessionInf)
+	silverfishInstance := silverfish.New(
+		&config.HashSalt, config.CrawlDuration,
+		userInf, novelInf, comicInf, sessionInf,
+		filterInf, filterHistoryInf,
+		loginAttemptInf, apiTokenInf,
+		oidcStateInf, config.OIDCConfig(),
+		passwordResetInf, config.Mailer(), config.PasswordResetURL,
+		auditInf, jobInf, novelChapterInf,
+	)
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(silverfishInstance, os.Args[1:]))
+	}
+	if err := silverfishInstance.Jobs.AbandonRunning(); err != nil {
+		logrus.Print(errors.Wrap(err, "...while abandoning interrupted jobs: "))
+	}
+	captchaProvider, err := captcha.New(
+		config.CaptchaProvider, config.CaptchaSecret,
+		config.CaptchaVerifyURL, config.CaptchaDifficulty,
+	)
+	if err != nil {
+		logrus.Fatal(errors.Wrap(err, "...while initing captcha: "))
+	}
 	muxRouter := mux.NewRouter()
 	router := router.NewRouter(
-		&config.RecaptchaKey,
+		captchaProvider,
+		config.ProxyHops(),
 		silverfishInstance.Auth,
 		silverfishInstance.Admin,
 		silverfishInstance.User,
 		silverfishInstance.Novel,
 		silverfishInstance.Comic,
+		silverfishInstance.Filter,
+		silverfishInstance.Search,
+		silverfishInstance.Throttle,
+		silverfishInstance.OIDC,
+		silverfishInstance.PasswordReset,
+		silverfishInstance.Audit,
+		silverfishInstance.Jobs,
+		silverfishInstance.Importer,
+		silverfishInstance.Backup,
 	)
 	logrus.Print("... Http Router inited.")
 	router.RouteRegister(muxRouter)
 	logrus.Print("... Http Router registered.")
 
-	handler := cors.New(cors.Options{
-		AllowedOrigins:   config.AllowOrigin,
-		AllowedHeaders:   []string{"Authorization"},
-		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
-		AllowCredentials: false,
-		Debug:            config.Debug,
-	}).Handler(muxRouter)
+	handler := router.CORS(config.AllowOrigin, config.Debug).Handler(muxRouter)
 	logrus.Print("... CORS Origins: ", config.AllowOrigin)
 	logrus.Print("... CORS inited.")
 
@@ -118,6 +148,12 @@ func main() {
 		logrus.Printf("Detected no first user, create with `admin:%s`", *password)
 	}
 
+	go func() {
+		if _, err := silverfishInstance.Search.Reindex(false); err != nil {
+			logrus.Print(errors.Wrap(err, "...while rebuilding search index: "))
+		}
+	}()
+
 	logrus.Print("<- Silverfish inited.")
 
 	logrus.Print("Everything Inited! HooRay!~ Silverfish!")
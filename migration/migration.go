@@ -0,0 +1,176 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrationTimeout bounds one migration; backfills walk whole
+// collections.
+const migrationTimeout = 10 * time.Minute
+
+// Migration export — one schema or data change. Up must be idempotent:
+// it runs again if the process dies before the migration is recorded.
+type Migration struct {
+	ID          string
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// Migrator export — applies migrations in ID order and records each in
+// the `migrations` collection.
+type Migrator struct {
+	db         *mongo.Database
+	col        *mongo.Collection
+	migrations []Migration
+}
+
+// New export — a migrator for every migration Silverfish knows.
+func New(db *mongo.Database) *Migrator {
+	return NewMigrator(db, migrations)
+}
+
+// NewMigrator export
+func NewMigrator(db *mongo.Database, migrations []Migration) *Migrator {
+	m := new(Migrator)
+	m.db = db
+	m.col = db.Collection("migrations")
+	m.migrations = migrations
+	return m
+}
+
+// Validate export — IDs must be unique and in ascending order, so the
+// list reads in the order migrations run.
+func Validate(migrations []Migration) error {
+	for i, migration := range migrations {
+		if migration.ID == "" || migration.Up == nil {
+			return fmt.Errorf("Migration #%d is incomplete", i)
+		}
+		if i > 0 && migrations[i-1].ID >= migration.ID {
+			return fmt.Errorf("Migration %s should come after %s", migrations[i-1].ID, migration.ID)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) applied() (map[string]entity.MigrationRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cursor, err := m.col.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	records := []entity.MigrationRecord{}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	applied := map[string]entity.MigrationRecord{}
+	for _, record := range records {
+		applied[record.ID] = record
+	}
+	return applied, nil
+}
+
+// Status export
+func (m *Migrator) Status() ([]entity.MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := []entity.MigrationStatus{}
+	for _, migration := range m.migrations {
+		status := entity.MigrationStatus{ID: migration.ID, Description: migration.Description}
+		if record, ok := applied[migration.ID]; ok {
+			status.AppliedDatetime = &record.AppliedDatetime
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending export — IDs of migrations not applied yet.
+func (m *Migrator) Pending() ([]string, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	pending := []string{}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.ID]; !ok {
+			pending = append(pending, migration.ID)
+		}
+	}
+	return pending, nil
+}
+
+// Run export — applies pending migrations, stopping at the first
+// failure. Returns the IDs applied.
+func (m *Migrator) Run() ([]string, error) {
+	if err := Validate(m.migrations); err != nil {
+		return nil, err
+	}
+	if err := m.ensureIndex(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	done := []string{}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.ID]; ok {
+			continue
+		}
+		logrus.Printf("..... Migrating %s: %s", migration.ID, migration.Description)
+		ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+		err := migration.Up(ctx, m.db)
+		cancel()
+		if err != nil {
+			return done, fmt.Errorf("Migration %s failed: %s", migration.ID, err.Error())
+		}
+		if err := m.record(migration); err != nil {
+			return done, err
+		}
+		done = append(done, migration.ID)
+	}
+	return done, nil
+}
+
+// record marks `migration` applied. Another instance migrating at the
+// same time may have got there first, which is fine as migrations are
+// idempotent.
+func (m *Migrator) record(migration Migration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := m.col.InsertOne(ctx, &entity.MigrationRecord{
+		ID:              migration.ID,
+		Description:     migration.Description,
+		AppliedDatetime: time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (m *Migrator) ensureIndex() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := m.col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "migrationID", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return errors.New("Failed to index migrations: " + err.Error())
+	}
+	return nil
+}
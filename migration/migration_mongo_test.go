@@ -0,0 +1,151 @@
+//go:build mongo
+
+// Gated by `-tags=mongo`; set SILVERFISH_TEST_DB_HOST (default
+// localhost:27017). Every test runs in a database of its own.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+	host := os.Getenv("SILVERFISH_TEST_DB_HOST")
+	if host == "" {
+		host = "localhost:27017"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://"+host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database(fmt.Sprintf("silverfish_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return db
+}
+
+func TestRunIsIdempotent(t *testing.T) {
+	db := testDatabase(t)
+	runs := map[string]int{}
+	counting := func(id string) Migration {
+		return Migration{id, "count " + id, func(ctx context.Context, db *mongo.Database) error {
+			runs[id]++
+			return nil
+		}}
+	}
+	migrator := NewMigrator(db, []Migration{counting("0001"), counting("0002")})
+	applied, err := migrator.Run()
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("first run: got %v, %v", applied, err)
+	}
+	applied, err = migrator.Run()
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("second run: got %v, %v", applied, err)
+	}
+	if runs["0001"] != 1 || runs["0002"] != 1 {
+		t.Errorf("got runs %v, want each once", runs)
+	}
+}
+
+func TestRunDoesNotRecordFailures(t *testing.T) {
+	db := testDatabase(t)
+	fail := true
+	migrations := []Migration{
+		{"0001", "ok", func(ctx context.Context, db *mongo.Database) error { return nil }},
+		{"0002", "flaky", func(ctx context.Context, db *mongo.Database) error {
+			if fail {
+				return errors.New("boom")
+			}
+			return nil
+		}},
+		{"0003", "after", func(ctx context.Context, db *mongo.Database) error { return nil }},
+	}
+	migrator := NewMigrator(db, migrations)
+	applied, err := migrator.Run()
+	if err == nil || len(applied) != 1 || applied[0] != "0001" {
+		t.Fatalf("got %v, %v; want 0001 applied and an error", applied, err)
+	}
+	pending, _ := migrator.Pending()
+	if len(pending) != 2 || pending[0] != "0002" {
+		t.Errorf("got pending %v, want 0002 and 0003", pending)
+	}
+
+	fail = false
+	applied, err = migrator.Run()
+	if err != nil || len(applied) != 2 {
+		t.Errorf("rerun: got %v, %v", applied, err)
+	}
+}
+
+// TestMigrationsRerun applies every real migration twice; the second
+// pass must succeed too, since a crash before recording reruns one.
+func TestMigrationsRerun(t *testing.T) {
+	db := testDatabase(t)
+	db.Collection("session").InsertMany(context.Background(), []interface{}{
+		bson.M{"token": "plaintext", "account": "a"},
+		bson.M{"tokenHash": "h1", "account": "a"},
+		bson.M{"tokenHash": "h2", "sessionID": "s2", "account": "a"},
+	})
+	if _, err := New(db).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := db.Collection("session").CountDocuments(context.Background(), bson.M{}); count != 1 {
+		t.Errorf("got %d sessions, want only the one with a hash and an ID", count)
+	}
+	for _, migration := range migrations {
+		if err := migration.Up(context.Background(), db); err != nil {
+			t.Errorf("rerunning %s: %v", migration.ID, err)
+		}
+	}
+}
+
+func TestListFieldsCountsBookmarks(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+	db.Collection("user").InsertMany(ctx, []interface{}{
+		bson.M{"account": "a", "bookmark": bson.M{"novel": bson.M{"n1": bson.M{}, "n2": bson.M{}}, "comic": bson.M{"c1": bson.M{}}}},
+		bson.M{"account": "b", "bookmark": bson.M{"novel": bson.M{"n1": bson.M{}}}},
+		bson.M{"account": "c"},
+	})
+	db.Collection("novel").InsertMany(ctx, []interface{}{
+		bson.M{"novelID": "n1"}, bson.M{"novelID": "n2"}, bson.M{"novelID": "n3"},
+		bson.M{"novelID": "n4", "popularity": 5},
+	})
+	db.Collection("comic").InsertOne(ctx, bson.M{"comicID": "c1"})
+	if err := listFields(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	for col, want := range map[string]map[string]int32{
+		"novel": {"n1": 2, "n2": 1, "n3": 0, "n4": 5},
+		"comic": {"c1": 1},
+	} {
+		for id, popularity := range want {
+			book := struct {
+				Popularity int32 `bson:"popularity"`
+			}{}
+			if err := db.Collection(col).FindOne(ctx, bson.M{col + "ID": id}).Decode(&book); err != nil {
+				t.Fatal(err)
+			}
+			if book.Popularity != popularity {
+				t.Errorf("%s %s has popularity %d, want %d", col, id, book.Popularity, popularity)
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package migration
+
+import "testing"
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	if err := Validate(migrations); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateRejectsOutOfOrder(t *testing.T) {
+	noop := migrations[0].Up
+	err := Validate([]Migration{
+		{"0002", "second", noop},
+		{"0001", "first", noop},
+	})
+	if err == nil {
+		t.Fatal("expected out of order migrations to be rejected")
+	}
+	err = Validate([]Migration{
+		{"0001", "first", noop},
+		{"0001", "again", noop},
+	})
+	if err == nil {
+		t.Fatal("expected duplicate migration IDs to be rejected")
+	}
+}
@@ -0,0 +1,435 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	entity "silverfish/silverfish/entity"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrations lists every migration in the order they run. Append new
+// ones at the end with the next ID; never edit or reorder applied ones.
+var migrations = []Migration{
+	{"0001", "Invalidate legacy sessions and index sessions", sessionIndexes},
+	{"0002", "Index login attempts", loginAttemptIndexes},
+	{"0003", "Index API tokens", apiTokenIndexes},
+	{"0004", "Index OIDC states and linked users", oidcIndexes},
+	{"0005", "Unique index on user emails", uniqueEmails},
+	{"0006", "Backfill user roles and disabled flags", userFields},
+	{"0007", "Index password resets", passwordResetIndexes},
+	{"0008", "Index the audit log", auditIndexes},
+	{"0009", "Index jobs", jobIndexes},
+	{"0010", "Index cached novel chapters", novelChapterIndexes},
+	{"0011", "Index book search tokens", searchIndexes},
+	{"0012", "Index and backfill book list fields", listFields},
+	{"0013", "Unique filter rule sets and versions", uniqueFilterVersions},
+	{"0014", "Unique indexes on novelID, comicID and account", uniqueIDIndexes},
+	{"0015", "Move jmd8.com comics to 91jmd.com", jmd8Host},
+}
+
+func isIndexNotFound(err error) bool {
+	cmdErr, ok := err.(mongo.CommandError)
+	return ok && (cmdErr.Name == "IndexNotFound" || cmdErr.Name == "NamespaceNotFound")
+}
+
+func sessionIndexes(ctx context.Context, db *mongo.Database) error {
+	col := db.Collection("session")
+	// Sessions from before tokens were random and stored hashed carry a
+	// plaintext, predictable `token`, and those from before session IDs
+	// have nothing to name them by. Drop both together with the old
+	// token index; their users log in again.
+	result, err := col.DeleteMany(ctx, bson.M{"$or": bson.A{
+		bson.M{"tokenHash": bson.M{"$exists": false}},
+		bson.M{"sessionID": bson.M{"$exists": false}},
+	}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount > 0 {
+		logrus.Printf("..... Invalidated %d legacy sessions", result.DeletedCount)
+	}
+	if _, err := col.Indexes().DropOne(ctx, "token_1"); err != nil && !isIndexNotFound(err) {
+		return err
+	}
+
+	_, err = col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "sessionID", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "account", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expireTS", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+func loginAttemptIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("loginAttempt").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "subject", Value: 1}, {Key: "value", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expireTS", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+func apiTokenIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("apiToken").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "tokenID", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "account", Value: 1}},
+		},
+	})
+	return err
+}
+
+func oidcIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("oidcState").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "state", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expireTS", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = db.Collection("user").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "oidc.issuer", Value: 1}, {Key: "oidc.subject", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"oidc": bson.M{"$exists": true},
+		}),
+	})
+	return err
+}
+
+// uniqueEmails makes set emails unique, since a password reset mail goes
+// to the one account found by address. Accounts without one store "",
+// which the partial index leaves out.
+func uniqueEmails(ctx context.Context, db *mongo.Database) error {
+	col := db.Collection("user")
+	filter := bson.M{"email": bson.M{"$gt": ""}}
+	duplicates, err := findDuplicates(ctx, col, filter, "email")
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("user has duplicate email values, clear all but one first: %s", strings.Join(duplicates, ", "))
+	}
+	_, err = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(filter),
+	})
+	return err
+}
+
+// userFields gives accounts stored before roles existed the role
+// matching their isAdmin flag, and marks them enabled.
+func userFields(ctx context.Context, db *mongo.Database) error {
+	col := db.Collection("user")
+	for role, isAdmin := range map[string]bool{entity.RoleAdmin: true, entity.RoleReader: false} {
+		_, err := col.UpdateMany(ctx, bson.M{
+			"role":    bson.M{"$exists": false},
+			"isAdmin": isAdmin,
+		}, bson.M{"$set": bson.M{"role": role}})
+		if err != nil {
+			return err
+		}
+	}
+	_, err := col.UpdateMany(ctx, bson.M{"disabled": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"disabled": false},
+	})
+	return err
+}
+
+func passwordResetIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("passwordReset").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expireTS", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+func auditIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("audit").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "datetime", Value: -1}}},
+		{Keys: bson.D{{Key: "targetType", Value: 1}, {Key: "targetID", Value: 1}, {Key: "datetime", Value: -1}}},
+	})
+	return err
+}
+
+func jobIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("job").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "jobID", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "createdDatetime", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}}},
+	})
+	return err
+}
+
+func novelChapterIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("novelChapter").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "novelID", Value: 1}, {Key: "index", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func searchIndexes(ctx context.Context, db *mongo.Database) error {
+	for _, name := range []string{"novel", "comic"} {
+		_, err := db.Collection(name).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "searchTokens", Value: 1}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listFields indexes the sort keys of the list endpoints and backfills
+// them on books added before they existed: both datetimes fall back to
+// the last crawl, popularity is counted from the users' bookmarks.
+func listFields(ctx context.Context, db *mongo.Database) error {
+	for name, idKey := range map[string]string{"novel": "novelID", "comic": "comicID"} {
+		col := db.Collection(name)
+		indexes := []mongo.IndexModel{}
+		for _, field := range []string{"title", "author", "lastUpdateDatetime", "addedDatetime", "popularity"} {
+			indexes = append(indexes, mongo.IndexModel{
+				Keys: bson.D{{Key: field, Value: 1}, {Key: idKey, Value: 1}},
+			})
+		}
+		if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+			return err
+		}
+		if err := backfillPopularity(ctx, db.Collection("user"), col, name, idKey); err != nil {
+			return err
+		}
+		for field, value := range map[string]interface{}{
+			"addedDatetime":      "$lastCrawlTime",
+			"lastUpdateDatetime": "$lastCrawlTime",
+		} {
+			_, err := col.UpdateMany(ctx, bson.M{field: bson.M{"$exists": false}}, mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{field: value}}},
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// backfillPopularity gives books of `kind` (novel, comic) without a
+// popularity the number of users bookmarking them, which is what
+// UpdateBookmark and RemoveBookmark keep it at from then on.
+func backfillPopularity(ctx context.Context, users, col *mongo.Collection, kind, idKey string) error {
+	cursor, err := users.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$project", Value: bson.M{"entries": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$bookmark." + kind, bson.M{}}}}}}},
+		{{Key: "$unwind", Value: "$entries"}},
+		{{Key: "$group", Value: bson.M{"_id": "$entries.k", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	counts := []struct {
+		ID    string `bson:"_id"`
+		Count int    `bson:"count"`
+	}{}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return err
+	}
+	models := []mongo.WriteModel{}
+	for _, count := range counts {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{idKey: count.ID, "popularity": bson.M{"$exists": false}}).
+			SetUpdate(bson.M{"$set": bson.M{"popularity": count.Count}}))
+	}
+	if len(models) > 0 {
+		if _, err := col.BulkWrite(ctx, models); err != nil {
+			return err
+		}
+	}
+	_, err = col.UpdateMany(ctx, bson.M{"popularity": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"popularity": 0}})
+	return err
+}
+
+// uniqueFilterVersions keeps one current rule set per fetcher and one
+// history entry per version of it, which UpdateRuleSet relies on to
+// number versions atomically.
+func uniqueFilterVersions(ctx context.Context, db *mongo.Database) error {
+	for _, unique := range []struct {
+		collection string
+		keys       []string
+	}{
+		{"filterRule", []string{"dns"}},
+		{"filterRuleHistory", []string{"dns", "version"}},
+	} {
+		col := db.Collection(unique.collection)
+		duplicates, err := findDuplicates(ctx, col, bson.M{}, unique.keys...)
+		if err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			return fmt.Errorf("%s has duplicate %s values, remove all but one first: %s",
+				unique.collection, strings.Join(unique.keys, ", "), strings.Join(duplicates, ", "))
+		}
+		keys := bson.D{}
+		for _, key := range unique.keys {
+			keys = append(keys, bson.E{Key: key, Value: 1})
+		}
+		_, err = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    keys,
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uniqueIDIndexes makes the keys every lookup goes through unique. A
+// collection already holding duplicates fails the migration with the
+// offending values instead of guessing which copy to drop.
+func uniqueIDIndexes(ctx context.Context, db *mongo.Database) error {
+	for _, unique := range []struct{ collection, key string }{
+		{"novel", "novelID"},
+		{"comic", "comicID"},
+		{"user", "account"},
+	} {
+		col := db.Collection(unique.collection)
+		duplicates, err := findDuplicates(ctx, col, bson.M{}, unique.key)
+		if err != nil {
+			return err
+		}
+		if len(duplicates) > 0 {
+			return fmt.Errorf("%s has duplicate %s values, merge or remove them first: %s",
+				unique.collection, unique.key, strings.Join(duplicates, ", "))
+		}
+		_, err = col.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: unique.key, Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findDuplicates lists up to 20 values of `keys` that more than one
+// document matching `filter` shares.
+func findDuplicates(ctx context.Context, col *mongo.Collection, filter bson.M, keys ...string) ([]string, error) {
+	group := bson.M{}
+	for _, key := range keys {
+		group[key] = "$" + key
+	}
+	cursor, err := col.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{"_id": group, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$limit", Value: 20}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	duplicates := []string{}
+	for cursor.Next(ctx) {
+		var group struct {
+			ID    bson.M `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return nil, err
+		}
+		values := []string{}
+		for _, key := range keys {
+			values = append(values, fmt.Sprint(group.ID[key]))
+		}
+		duplicates = append(duplicates, fmt.Sprintf("%s (%d)", strings.Join(values, "/"), group.Count))
+	}
+	return duplicates, cursor.Err()
+}
+
+// jmd8Host moves comics crawled from jmd8.com, which now 301-redirects,
+// to its canonical 91jmd.com host. Chapter URLs are stored as paths so
+// only the DNS and the book URL change; comic IDs stay as they are.
+func jmd8Host(ctx context.Context, db *mongo.Database) error {
+	col := db.Collection("comic")
+	cursor, err := col.Find(ctx, bson.M{"dns": "jmd8.com"},
+		options.Find().SetProjection(bson.M{"comicID": 1, "url": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	moved := 0
+	for cursor.Next(ctx) {
+		var comic struct {
+			ComicID string `bson:"comicID"`
+			URL     string `bson:"url"`
+		}
+		if err := cursor.Decode(&comic); err != nil {
+			return err
+		}
+		url := strings.Replace(comic.URL, "://jmd8.com", "://91jmd.com", 1)
+		// The same comic may have been added again under the new host.
+		if count, err := col.CountDocuments(ctx, bson.M{"url": url}); err != nil {
+			return err
+		} else if count > 0 {
+			logrus.Printf("..... Comic %s is already on 91jmd.com as %s, leaving it", comic.ComicID, url)
+			continue
+		}
+		_, err := col.UpdateOne(ctx, bson.M{"comicID": comic.ComicID}, bson.M{"$set": bson.M{
+			"dns": "91jmd.com",
+			"url": url,
+		}})
+		if err != nil {
+			return err
+		}
+		moved++
+	}
+	if moved > 0 {
+		logrus.Printf("..... Moved %d comics to 91jmd.com", moved)
+	}
+	return cursor.Err()
+}
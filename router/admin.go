@@ -2,23 +2,39 @@ package router
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
+
 	interf "silverfish/router/interface"
+	middleware "silverfish/router/middleware"
+	openapi "silverfish/router/openapi"
 	"silverfish/silverfish"
 	"silverfish/silverfish/entity"
+	usecase "silverfish/silverfish/usecase"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
 // BlueprintAdmin export
 type BlueprintAdmin struct {
-	auth   *silverfish.Auth
-	admin  *silverfish.Admin
-	novel  *silverfish.Novel
-	comic  *silverfish.Comic
-	router interf.IRouter
-	route  string
+	auth     *silverfish.Auth
+	admin    *silverfish.Admin
+	novel    *silverfish.Novel
+	comic    *silverfish.Comic
+	filter   *silverfish.Filter
+	search   *silverfish.Search
+	throttle *silverfish.Throttle
+	reset    *silverfish.PasswordReset
+	audit    *silverfish.Audit
+	jobs     *silverfish.Jobs
+	importer *silverfish.Importer
+	backup   *silverfish.Backup
+	router   interf.IRouter
+	route    string
 }
 
 // NewBlueprintAdmin export
@@ -27,6 +43,14 @@ func NewBlueprintAdmin(
 	admin *silverfish.Admin,
 	novel *silverfish.Novel,
 	comic *silverfish.Comic,
+	filter *silverfish.Filter,
+	search *silverfish.Search,
+	throttle *silverfish.Throttle,
+	reset *silverfish.PasswordReset,
+	audit *silverfish.Audit,
+	jobs *silverfish.Jobs,
+	importer *silverfish.Importer,
+	backup *silverfish.Backup,
 	router interf.IRouter,
 ) *BlueprintAdmin {
 	bpa := new(BlueprintAdmin)
@@ -34,6 +58,14 @@ func NewBlueprintAdmin(
 	bpa.admin = admin
 	bpa.novel = novel
 	bpa.comic = comic
+	bpa.filter = filter
+	bpa.search = search
+	bpa.throttle = throttle
+	bpa.reset = reset
+	bpa.audit = audit
+	bpa.jobs = jobs
+	bpa.importer = importer
+	bpa.backup = backup
 	bpa.route = "/admin"
 	bpa.router = router
 	return bpa
@@ -42,35 +74,867 @@ func NewBlueprintAdmin(
 // RouteRegister export
 func (bpa *BlueprintAdmin) RouteRegister(parentRouter *mux.Router) {
 	router := parentRouter.PathPrefix(bpa.route).Subrouter()
-	router.HandleFunc("/fetchers", bpa.fetcherList).Methods("GET")
+	router.Handle("/fetchers", middleware.Can(entity.PermissionBookAdd, bpa.fetcherList)).Methods("GET")
+	router.Handle("/sanitizer", middleware.Can(entity.PermissionSystemManage, bpa.sanitizerStats)).Methods("GET")
+	router.Handle("/duplicates", middleware.Can(entity.PermissionBookEdit, bpa.duplicateList)).Methods("GET")
+	router.Handle("/search/reindex", middleware.Can(entity.PermissionSystemManage, bpa.searchReindex)).Methods("POST")
+	router.Handle("/{type:novels|comics}/{bookID}/enabled", middleware.Can(entity.PermissionBookEdit, bpa.bookEnabled)).Methods("POST")
+	router.Handle("/{type:novels|comics}/{bookID}/overrides", middleware.Can(entity.PermissionBookEdit, bpa.bookOverrides)).Methods("POST")
+	router.Handle("/{type:novels|comics}/{bookID}/recrawl", middleware.Can(entity.PermissionBookRecrawl, bpa.bookRecrawl)).Methods("POST")
+	router.Handle("/{type:novels|comics}/{bookID}/chapters/{chapterIndex}/refresh", middleware.Can(entity.PermissionBookRecrawl, bpa.chapterRefresh)).Methods("POST")
+	router.Handle("/sources/{dns}/recrawl", middleware.Can(entity.PermissionBookRecrawl, bpa.sourceRecrawl)).Methods("POST")
+	router.Handle("/import", middleware.Can(entity.PermissionBookAdd, bpa.bookImport)).Methods("POST")
+	router.Handle("/backup", middleware.Can(entity.PermissionSystemManage, bpa.backupExport)).Methods("GET")
+	router.Handle("/restore", middleware.Can(entity.PermissionSystemManage, bpa.backupRestore)).Methods("POST")
+	router.Handle("/audit", middleware.Can(entity.PermissionBookEdit, bpa.auditList)).Methods("GET")
+	router.Handle("/jobs", middleware.Can(entity.PermissionBookRecrawl, bpa.jobList)).Methods("GET")
+	router.Handle("/jobs/{jobID}", middleware.Can(entity.PermissionBookRecrawl, bpa.jobDetail)).Methods("GET")
+	router.Handle("/users", middleware.Can(entity.PermissionUserManage, bpa.userList)).Methods("GET")
+	router.Handle("/users", middleware.Can(entity.PermissionUserManage, bpa.userCreate)).Methods("POST")
+	router.Handle("/users/{account}", middleware.Can(entity.PermissionUserManage, bpa.userDetail)).Methods("GET")
+	router.Handle("/users/{account}", middleware.Can(entity.PermissionUserManage, bpa.userDelete)).Methods("DELETE")
+	router.Handle("/users/{account}/role", middleware.Can(entity.PermissionUserManage, bpa.userRole)).Methods("POST")
+	router.Handle("/users/{account}/disabled", middleware.Can(entity.PermissionUserManage, bpa.userDisabled)).Methods("POST")
+	router.Handle("/users/{account}/bookmarks", middleware.Can(entity.PermissionUserManage, bpa.userBookmarks)).Methods("GET")
+	router.Handle("/users/{account}/sessions", middleware.Can(entity.PermissionUserManage, bpa.userSessionList)).Methods("GET")
+	router.Handle("/users/{account}/sessions", middleware.Can(entity.PermissionUserManage, bpa.userSessionRevokeAll)).Methods("DELETE")
+	router.Handle("/users/{account}/sessions/{sessionID}", middleware.Can(entity.PermissionUserManage, bpa.userSessionRevoke)).Methods("DELETE")
+	router.Handle("/users/{account}/passwordReset", middleware.Can(entity.PermissionUserManage, bpa.userPasswordReset)).Methods("POST")
+	router.Handle("/logins", middleware.Can(entity.PermissionUserManage, bpa.loginAttemptList)).Methods("GET")
+	router.Handle("/logins/unlock", middleware.Can(entity.PermissionUserManage, bpa.loginUnlock)).Methods("POST")
+	router.Handle("/filters", middleware.Can(entity.PermissionFilterManage, bpa.filterList)).Methods("GET")
+	router.Handle("/filters/{dns}", middleware.Can(entity.PermissionFilterManage, bpa.filterRuleSet)).Methods("GET", "POST")
+	router.Handle("/filters/{dns}/history", middleware.Can(entity.PermissionFilterManage, bpa.filterHistory)).Methods("GET")
+	router.Handle("/filters/{dns}/restore", middleware.Can(entity.PermissionFilterManage, bpa.filterRestore)).Methods("POST")
+	router.Handle("/filters/{dns}/preview", middleware.Can(entity.PermissionFilterManage, bpa.filterPreview)).Methods("POST")
+}
+
+// Operations export
+func (bpa *BlueprintAdmin) Operations() []openapi.Operation {
+	book := openapi.OneOf{entity.Novel{}, entity.Comic{}}
+	bookType := openapi.Field("type", "").OneOf("novels", "comics")
+	return openapi.Tag("admin", openapi.Mount(bpa.route, []openapi.Operation{
+		{
+			Method: "GET", Path: "/fetchers", Summary: "The supported source domains",
+			Permission: entity.PermissionBookAdd,
+			Response:   openapi.Object{"fetchers": openapi.Object{"novels": []string{}, "comics": []string{}}},
+		},
+		{
+			Method: "GET", Path: "/sanitizer", Summary: "What the novel sanitizers removed, per domain",
+			Permission: entity.PermissionSystemManage,
+			Response:   openapi.Object{"novels": map[string]map[string]int64{}},
+		},
+		{
+			Method: "GET", Path: "/duplicates", Summary: "Groups of books that look like the same one",
+			Permission: entity.PermissionBookEdit,
+			Response:   openapi.Object{"novels": [][]entity.NovelInfo{}, "comics": [][]entity.ComicInfo{}},
+		},
+		{
+			Method: "POST", Path: "/search/reindex", Summary: "Rebuild the search tokens",
+			Permission: entity.PermissionSystemManage,
+			Form:       []openapi.Param{openapi.Field("force", "Every book, not just those missing tokens").Typed("boolean")},
+			Response:   openapi.Object{"indexed": 0},
+		},
+		{
+			Method: "POST", Path: "/{type}/{bookID}/enabled", Summary: "Show or hide a book",
+			Permission: entity.PermissionBookEdit,
+			PathParams: []openapi.Param{bookType},
+			Form:       []openapi.Param{openapi.Field("enabled", "").Typed("boolean").Require()},
+		},
+		{
+			Method: "POST", Path: "/{type}/{bookID}/overrides", Summary: "Override the metadata of a book",
+			Description: "Sets the fields given; an empty value clears that override.",
+			Permission:  entity.PermissionBookEdit,
+			PathParams:  []openapi.Param{bookType},
+			Form: []openapi.Param{
+				openapi.Field("title", ""),
+				openapi.Field("author", ""),
+				openapi.Field("description", ""),
+				openapi.Field("coverUrl", ""),
+			},
+			Response: book,
+		},
+		{
+			Method: "POST", Path: "/{type}/{bookID}/recrawl", Summary: "Update a book from its source right away",
+			Permission: entity.PermissionBookRecrawl,
+			PathParams: []openapi.Param{bookType},
+			Response:   book,
+		},
+		{
+			Method: "POST", Path: "/{type}/{bookID}/chapters/{chapterIndex}/refresh", Summary: "Fetch a chapter again",
+			Permission: entity.PermissionBookRecrawl,
+			PathParams: []openapi.Param{bookType},
+			Response:   openapi.OneOf{"", []string{}},
+		},
+		{
+			Method: "POST", Path: "/sources/{dns}/recrawl", Summary: "Start a job recrawling every book of a source",
+			Permission: entity.PermissionBookRecrawl,
+			Response:   entity.Job{},
+		},
+		{
+			Method: "POST", Path: "/import", Summary: "Start a job adding every URL of a list",
+			Permission: entity.PermissionBookAdd,
+			Form: []openapi.Param{
+				openapi.Field("file", "The list").Typed("file"),
+				openapi.Field("urls", "The list, when no file is uploaded"),
+				openapi.Field("format", "Guessed when empty").OneOf("text", "csv", "json"),
+			},
+			Response: entity.Job{},
+		},
+		{
+			Method: "GET", Path: "/backup", Summary: "Download the library as a backup archive",
+			Permission:  entity.PermissionSystemManage,
+			ContentType: "application/gzip",
+		},
+		{
+			Method: "POST", Path: "/restore", Summary: "Load a backup archive",
+			Permission: entity.PermissionSystemManage,
+			Form: []openapi.Param{
+				openapi.Field("file", "").Typed("file").Require(),
+				openapi.Field("strategy", "").OneOf(entity.RestoreMerge, entity.RestoreReplace),
+				openapi.Field("dryRun", "Only report what would change").Typed("boolean"),
+			},
+			Response: entity.RestoreReport{},
+		},
+		{
+			Method: "GET", Path: "/audit", Summary: "The latest audit entries",
+			Permission: entity.PermissionBookEdit,
+			Query: []openapi.Param{
+				openapi.Field("targetType", "").OneOf(entity.AuditTargetNovel, entity.AuditTargetComic),
+				openapi.Field("targetID", ""),
+				openapi.Field("limit", "1-1000").Typed("integer"),
+			},
+			Response: []entity.AuditEntry{},
+		},
+		{
+			Method: "GET", Path: "/jobs", Summary: "The latest jobs",
+			Permission: entity.PermissionBookRecrawl,
+			Query:      []openapi.Param{openapi.Field("limit", "1-500").Typed("integer")},
+			Response:   []entity.Job{},
+		},
+		{
+			Method: "GET", Path: "/jobs/{jobID}", Summary: "A job with its per-item results",
+			Permission: entity.PermissionBookRecrawl,
+			Response:   entity.Job{},
+		},
+		{
+			Method: "GET", Path: "/users", Summary: "List accounts",
+			Permission: entity.PermissionUserManage,
+			Query: []openapi.Param{
+				openapi.Field("q", "Account or email"),
+				openapi.Field("role", "").OneOf(entity.Roles...),
+				openapi.Field("disabled", "").Typed("boolean"),
+				openapi.Field("page", "").Typed("integer"),
+				openapi.Field("size", "1-200").Typed("integer"),
+			},
+			Response: entity.UserPage{},
+		},
+		{
+			Method: "POST", Path: "/users", Summary: "Create an account, skipping the captcha",
+			Permission: entity.PermissionUserManage,
+			Form: []openapi.Param{
+				openapi.Field("account", "").Require(),
+				openapi.Field("password", "").Require(),
+				openapi.Field("role", "reader by default").OneOf(entity.Roles...),
+			},
+			Response: entity.User{},
+		},
+		{
+			Method: "GET", Path: "/users/{account}", Summary: "Get an account",
+			Permission: entity.PermissionUserManage,
+			Response:   entity.User{},
+		},
+		{
+			Method: "DELETE", Path: "/users/{account}", Summary: "Delete an account",
+			Permission: entity.PermissionUserManage,
+		},
+		{
+			Method: "POST", Path: "/users/{account}/role", Summary: "Set the role of an account",
+			Permission: entity.PermissionUserManage,
+			Form:       []openapi.Param{openapi.Field("role", "").OneOf(entity.Roles...).Require()},
+		},
+		{
+			Method: "POST", Path: "/users/{account}/disabled", Summary: "Disable or enable an account",
+			Permission: entity.PermissionUserManage,
+			Form:       []openapi.Param{openapi.Field("disabled", "").Typed("boolean").Require()},
+		},
+		{
+			Method: "GET", Path: "/users/{account}/bookmarks", Summary: "The bookmarks of an account",
+			Permission: entity.PermissionUserManage,
+			Response:   entity.Bookmark{},
+		},
+		{
+			Method: "GET", Path: "/users/{account}/sessions", Summary: "The sessions of an account",
+			Permission: entity.PermissionUserManage,
+			Response:   []entity.Session{},
+		},
+		{
+			Method: "DELETE", Path: "/users/{account}/sessions", Summary: "Sign an account out everywhere",
+			Description: "The session making the request survives when it belongs to the account.",
+			Permission:  entity.PermissionUserManage,
+			Response:    openapi.Object{"revoked": 0},
+		},
+		{
+			Method: "DELETE", Path: "/users/{account}/sessions/{sessionID}", Summary: "Sign a session of an account out",
+			Permission: entity.PermissionUserManage,
+		},
+		{
+			Method: "POST", Path: "/users/{account}/passwordReset", Summary: "Issue a password reset token to hand over",
+			Permission: entity.PermissionUserManage,
+			Response:   openapi.Object{"token": "", "expireDatetime": time.Time{}},
+		},
+		{
+			Method: "GET", Path: "/logins", Summary: "Throttled login attempts",
+			Permission: entity.PermissionUserManage,
+			Response:   []entity.LoginAttempt{},
+		},
+		{
+			Method: "POST", Path: "/logins/unlock", Summary: "Clear the login throttle of an account or IP",
+			Permission: entity.PermissionUserManage,
+			Form: []openapi.Param{
+				openapi.Field("account", ""),
+				openapi.Field("ip", "When no account is given"),
+			},
+		},
+		{
+			Method: "GET", Path: "/filters", Summary: "Every domain's filter rules",
+			Permission: entity.PermissionFilterManage,
+			Response:   []entity.FilterRuleSet{},
+		},
+		{
+			Method: "GET", Path: "/filters/{dns}", Summary: "A domain's filter rules",
+			Permission: entity.PermissionFilterManage,
+			Response:   entity.FilterRuleSet{},
+		},
+		{
+			Method: "POST", Path: "/filters/{dns}", Summary: "Replace a domain's filter rules",
+			Permission: entity.PermissionFilterManage,
+			Form:       []openapi.Param{openapi.Field("rules", "JSON array of rules").Require()},
+			Response:   entity.FilterRuleSet{},
+		},
+		{
+			Method: "GET", Path: "/filters/{dns}/history", Summary: "Earlier versions of a domain's filter rules",
+			Permission: entity.PermissionFilterManage,
+			Response:   []entity.FilterRuleSet{},
+		},
+		{
+			Method: "POST", Path: "/filters/{dns}/restore", Summary: "Bring back an earlier version of a domain's filter rules",
+			Permission: entity.PermissionFilterManage,
+			Form:       []openapi.Param{openapi.Field("version", "").Typed("integer").Require()},
+			Response:   entity.FilterRuleSet{},
+		},
+		{
+			Method: "POST", Path: "/filters/{dns}/preview", Summary: "Run filter rules against a sample or a live chapter",
+			Permission: entity.PermissionFilterManage,
+			Form: []openapi.Param{
+				openapi.Field("rules", "JSON array of rules, the domain's current ones by default"),
+				openapi.Field("content", "A pasted sample"),
+				openapi.Field("novelID", "A live chapter, with chapterIndex"),
+				openapi.Field("chapterIndex", "").Typed("integer"),
+			},
+			Response: openapi.Object{"content": "", "filtered": ""},
+		},
+	}))
 }
 
+// maxImportSize caps uploaded import lists.
+const maxImportSize = 4 << 20
+
 // FetcherList export
 func (bpa *BlueprintAdmin) fetcherList(w http.ResponseWriter, r *http.Request) {
-	sessionToken := r.Header.Get("Authorization")
 	w.Header().Set("Content-Type", "application/json")
 
 	switch r.Method {
 	case http.MethodGet:
-
-		session, err := bpa.auth.GetSession(&sessionToken)
-		response := new(entity.APIResponse)
-		if err != nil {
-			response = entity.NewAPIResponse(nil, err)
-		} else if isAdmin, _ := bpa.auth.IsAdmin(session.GetAccount()); isAdmin == false {
-			response = entity.NewAPIResponse(nil, errors.New("Only Admin allowed"))
-		} else {
-			fetcherLists := map[string][]string{
-				"novels": bpa.novel.GetFetcherNameLists(),
-				"comics": bpa.comic.GetFetcherNameLists(),
-			}
-			response = entity.NewAPIResponse(map[string]interface{}{
-				"fetchers": fetcherLists,
-			}, nil)
+		fetcherLists := map[string][]string{
+			"novels": bpa.novel.GetFetcherNameLists(),
+			"comics": bpa.comic.GetFetcherNameLists(),
 		}
+		response := entity.NewAPIResponse(map[string]interface{}{
+			"fetchers": fetcherLists,
+		}, nil)
 		js, _ := json.Marshal(response)
+		w.WriteHeader(response.StatusCode())
 		w.Write(js)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
+
+func (bpa *BlueprintAdmin) sanitizerStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := entity.NewAPIResponse(map[string]interface{}{
+		"novels": bpa.novel.GetSanitizeStats(),
+	}, nil)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) duplicateList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	if novels, err := bpa.novel.GetDuplicates(); err != nil {
+		response = entity.NewAPIResponse(nil, err)
+	} else if comics, err := bpa.comic.GetDuplicates(); err != nil {
+		response = entity.NewAPIResponse(nil, err)
+	} else {
+		response = entity.NewAPIResponse(map[string]interface{}{
+			"novels": novels,
+			"comics": comics,
+		}, nil)
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) searchReindex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	count, err := bpa.search.Reindex(r.FormValue("force") == "true")
+	response := entity.NewAPIResponse(map[string]interface{}{
+		"indexed": count,
+	}, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// userList serves `q` (account or email), `role`, `disabled` (true,
+// false) and `page` / `size`.
+func (bpa *BlueprintAdmin) userList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := &entity.UserQuery{
+		Keyword: r.FormValue("q"),
+		Role:    r.FormValue("role"),
+		Page:    1,
+		Size:    50,
+	}
+	if page, err := strconv.Atoi(r.FormValue("page")); err == nil && page > 0 {
+		query.Page = page
+	}
+	if size, err := strconv.Atoi(r.FormValue("size")); err == nil && size > 0 && size <= 200 {
+		query.Size = size
+	}
+	if disabled, err := strconv.ParseBool(r.FormValue("disabled")); err == nil {
+		query.Disabled = &disabled
+	}
+
+	response := entity.NewAPIResponse(bpa.admin.GetUsers(query))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// userCreate registers `account` with `password` and an optional `role`
+// (reader by default), skipping the captcha.
+func (bpa *BlueprintAdmin) userCreate(w http.ResponseWriter, r *http.Request) {
+	account := r.FormValue("account")
+	password := r.FormValue("password")
+	role := r.FormValue("role")
+	if role == "" {
+		role = entity.RoleReader
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	response := entity.NewAPIResponse(bpa.admin.CreateUser(&account, &password, role, session.GetAccount()))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) userDetail(w http.ResponseWriter, r *http.Request) {
+	account := mux.Vars(r)["account"]
+	w.Header().Set("Content-Type", "application/json")
+
+	response := entity.NewAPIResponse(bpa.admin.GetUser(&account))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) userDelete(w http.ResponseWriter, r *http.Request) {
+	account := mux.Vars(r)["account"]
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	response := entity.NewAPIResponse(nil, bpa.admin.DeleteUser(&account, session.GetAccount()))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// userDisabled sets whether `account` is `disabled` (true, false).
+func (bpa *BlueprintAdmin) userDisabled(w http.ResponseWriter, r *http.Request) {
+	account := mux.Vars(r)["account"]
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	session := middleware.GetCaller(r).Session
+	if disabled, err := strconv.ParseBool(r.FormValue("disabled")); err != nil {
+		response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field disabled should be true or false"))
+	} else {
+		response = entity.NewAPIResponse(nil, bpa.admin.SetDisabled(&account, disabled, session.GetAccount()))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) userBookmarks(w http.ResponseWriter, r *http.Request) {
+	account := mux.Vars(r)["account"]
+	w.Header().Set("Content-Type", "application/json")
+
+	response := entity.NewAPIResponse(bpa.admin.GetUserBookmark(&account))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// userRole sets the `role` (reader, curator, admin) of `account`.
+func (bpa *BlueprintAdmin) userRole(w http.ResponseWriter, r *http.Request) {
+	account := mux.Vars(r)["account"]
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	response := entity.NewAPIResponse(nil, bpa.auth.SetRole(&account, r.FormValue("role"), session.GetAccount()))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// bookEnabled shows or hides a book: `enabled` (true, false).
+func (bpa *BlueprintAdmin) bookEnabled(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	bookID := params["bookID"]
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	session := middleware.GetCaller(r).Session
+	if enabled, err := strconv.ParseBool(r.FormValue("enabled")); err != nil {
+		response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field enabled should be true or false"))
+	} else if params["type"] == "novels" {
+		response = entity.NewAPIResponse(nil, bpa.novel.SetEnable(&bookID, enabled, session.GetAccount()))
+	} else {
+		response = entity.NewAPIResponse(nil, bpa.comic.SetEnable(&bookID, enabled, session.GetAccount()))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// bookOverrides sets the form fields given among title, author,
+// description and coverUrl; an empty value clears that override.
+func (bpa *BlueprintAdmin) bookOverrides(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	bookID := params["bookID"]
+	w.Header().Set("Content-Type", "application/json")
+
+	r.ParseForm()
+	fields := map[string]string{}
+	for _, name := range entity.BookOverrideFields {
+		if values, ok := r.Form[name]; ok && len(values) > 0 {
+			fields[name] = values[0]
+		}
+	}
+
+	response := new(entity.APIResponse)
+	session := middleware.GetCaller(r).Session
+	if len(fields) == 0 {
+		response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field title, author, description or coverUrl should be given"))
+	} else if params["type"] == "novels" {
+		response = entity.NewAPIResponse(bpa.novel.UpdateOverrides(&bookID, fields, session.GetAccount()))
+	} else {
+		response = entity.NewAPIResponse(bpa.comic.UpdateOverrides(&bookID, fields, session.GetAccount()))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// bookRecrawl updates a book from its source right away.
+func (bpa *BlueprintAdmin) bookRecrawl(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	bookID := params["bookID"]
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	session := middleware.GetCaller(r).Session
+	if params["type"] == "novels" {
+		response = entity.NewAPIResponse(bpa.novel.Recrawl(&bookID, session.GetAccount()))
+	} else {
+		response = entity.NewAPIResponse(bpa.comic.Recrawl(&bookID, session.GetAccount()))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// chapterRefresh fetches one chapter again: a comic chapter's images or
+// a novel chapter's cached content.
+func (bpa *BlueprintAdmin) chapterRefresh(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	bookID, chapterIndex := params["bookID"], params["chapterIndex"]
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	session := middleware.GetCaller(r).Session
+	if params["type"] == "novels" {
+		response = entity.NewAPIResponse(bpa.novel.RefreshChapter(&bookID, &chapterIndex, session.GetAccount()))
+	} else {
+		response = entity.NewAPIResponse(bpa.comic.RefreshChapter(&bookID, &chapterIndex, session.GetAccount()))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// sourceRecrawl starts a job recrawling every book of one fetcher
+// domain; poll the returned job at /admin/jobs/{jobID}.
+func (bpa *BlueprintAdmin) sourceRecrawl(w http.ResponseWriter, r *http.Request) {
+	dns := mux.Vars(r)["dns"]
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	session := middleware.GetCaller(r).Session
+	if isNovelSource(bpa.novel, dns) {
+		response = entity.NewAPIResponse(bpa.novel.RecrawlSource(&dns, session.GetAccount()))
+	} else {
+		response = entity.NewAPIResponse(bpa.comic.RecrawlSource(&dns, session.GetAccount()))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// bookImport starts a job adding every URL of an uploaded `file` or the
+// `urls` field, in `format` text, csv or json (guessed when empty). The
+// job's results are the per-URL report.
+func (bpa *BlueprintAdmin) bookImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	session := middleware.GetCaller(r).Session
+	if data, err := importData(r); err != nil {
+		response = entity.NewAPIResponse(nil, err)
+	} else if urls, err := usecase.ParseImportList(data, r.FormValue("format")); err != nil {
+		response = entity.NewAPIResponse(nil, err)
+	} else {
+		response = entity.NewAPIResponse(bpa.importer.Start(urls, session.GetAccount()))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func importData(r *http.Request) ([]byte, error) {
+	r.ParseMultipartForm(maxImportSize)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return []byte(r.FormValue("urls")), nil
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportSize {
+		return nil, silverfish.InvalidArgumentError("Import file too large")
+	}
+	return data, nil
+}
+
+// backupExport downloads the library as a backup archive.
+func (bpa *BlueprintAdmin) backupExport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="silverfish-%s.tar.gz"`, time.Now().Format("20060102-150405")))
+	if _, err := bpa.backup.Export(w); err != nil {
+		// Headers are gone by now; all that's left is to cut the
+		// archive short so the client sees it is broken.
+		logrus.Printf("Failed to export backup: %s", err.Error())
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// backupRestore loads an uploaded backup `file` with `strategy` merge
+// (default) or replace; `dryRun` (true) only reports what would change.
+func (bpa *BlueprintAdmin) backupRestore(w http.ResponseWriter, r *http.Request) {
+	strategy := r.FormValue("strategy")
+	if strategy == "" {
+		strategy = entity.RestoreMerge
+	}
+	dryRun := r.FormValue("dryRun") == "true"
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	if file, _, err := r.FormFile("file"); err != nil {
+		response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field file should be a backup archive"))
+	} else {
+		defer file.Close()
+		response = entity.NewAPIResponse(bpa.backup.Restore(file, strategy, dryRun))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func isNovelSource(novel *silverfish.Novel, dns string) bool {
+	for _, name := range novel.GetFetcherNameLists() {
+		if name == dns {
+			return true
+		}
+	}
+	return false
+}
+
+func (bpa *BlueprintAdmin) jobList(w http.ResponseWriter, r *http.Request) {
+	limit := int64(50)
+	if value, err := strconv.ParseInt(r.FormValue("limit"), 10, 64); err == nil && value > 0 && value <= 500 {
+		limit = value
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	response := entity.NewAPIResponse(bpa.jobs.GetJobs(limit))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) jobDetail(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobID"]
+	w.Header().Set("Content-Type", "application/json")
+
+	response := entity.NewAPIResponse(bpa.jobs.GetJob(&jobID))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// auditList serves the latest `limit` (default 100) audit entries,
+// optionally of one `targetType` (novel, comic) and `targetID`.
+func (bpa *BlueprintAdmin) auditList(w http.ResponseWriter, r *http.Request) {
+	targetType, targetID := r.FormValue("targetType"), r.FormValue("targetID")
+	limit := int64(100)
+	if value, err := strconv.ParseInt(r.FormValue("limit"), 10, 64); err == nil && value > 0 && value <= 1000 {
+		limit = value
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	response := entity.NewAPIResponse(bpa.audit.GetEntries(&targetType, &targetID, limit))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) userSessionList(w http.ResponseWriter, r *http.Request) {
+	account := mux.Vars(r)["account"]
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	sessions, err := bpa.auth.GetSessions(&account)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == session.ID
+	}
+	response := entity.NewAPIResponse(sessions, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) userSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	account, sessionID := params["account"], params["sessionID"]
+	w.Header().Set("Content-Type", "application/json")
+
+	response := entity.NewAPIResponse(nil, bpa.auth.RevokeSession(&account, &sessionID))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// userSessionRevokeAll signs `account` out everywhere; when an admin
+// targets their own account, the session making the request survives.
+func (bpa *BlueprintAdmin) userSessionRevokeAll(w http.ResponseWriter, r *http.Request) {
+	account := mux.Vars(r)["account"]
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	keepID := ""
+	if *session.GetAccount() == account {
+		keepID = session.ID
+	}
+	count, err := bpa.auth.RevokeSessions(&account, &keepID)
+	response := entity.NewAPIResponse(map[string]interface{}{"revoked": count}, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// userPasswordReset issues a one-time reset token for the admin to hand
+// over, e.g. to a user locked out of the bootstrap admin account.
+func (bpa *BlueprintAdmin) userPasswordReset(w http.ResponseWriter, r *http.Request) {
+	account := mux.Vars(r)["account"]
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	response := new(entity.APIResponse)
+	session := middleware.GetCaller(r).Session
+	if token, reset, err := bpa.reset.IssueByAdmin(&account, session.GetAccount()); err != nil {
+		response = entity.NewAPIResponse(nil, err)
+	} else {
+		response = entity.NewAPIResponse(map[string]interface{}{
+			"token":          token,
+			"expireDatetime": reset.ExpireTS,
+		}, nil)
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) loginAttemptList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := entity.NewAPIResponse(bpa.throttle.GetAttempts())
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// loginUnlock clears the throttle of the `account` or `ip` form field.
+func (bpa *BlueprintAdmin) loginUnlock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	session := middleware.GetCaller(r).Session
+	if account := r.FormValue("account"); account != "" {
+		response = entity.NewAPIResponse(nil, bpa.throttle.Unlock(entity.LoginSubjectAccount, &account, session.GetAccount()))
+	} else if ip := r.FormValue("ip"); ip != "" {
+		response = entity.NewAPIResponse(nil, bpa.throttle.Unlock(entity.LoginSubjectIP, &ip, session.GetAccount()))
+	} else {
+		response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field account or ip should not be empty"))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) filterList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	result, err := bpa.filter.GetRuleSets()
+	response := entity.NewAPIResponse(result, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) filterRuleSet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	dns := mux.Vars(r)["dns"]
+
+	response := new(entity.APIResponse)
+	session := middleware.GetCaller(r).Session
+	switch r.Method {
+	case http.MethodGet:
+		result, err := bpa.filter.GetRuleSet(&dns)
+		response = entity.NewAPIResponse(result, err)
+	case http.MethodPost:
+		rules := []entity.FilterRule{}
+		if err := json.Unmarshal([]byte(r.FormValue("rules")), &rules); err != nil {
+			response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field rules should be a JSON array of rules"))
+		} else {
+			result, err := bpa.filter.UpdateRuleSet(&dns, rules, session.GetAccount())
+			response = entity.NewAPIResponse(result, err)
+		}
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) filterHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	dns := mux.Vars(r)["dns"]
+
+	result, err := bpa.filter.GetRuleSetHistory(&dns)
+	response := entity.NewAPIResponse(result, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) filterRestore(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	dns := mux.Vars(r)["dns"]
+
+	response := new(entity.APIResponse)
+	session := middleware.GetCaller(r).Session
+	if version, err := strconv.Atoi(r.FormValue("version")); err != nil {
+		response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field version should be a number"))
+	} else {
+		result, err := bpa.filter.RestoreRuleSet(&dns, version, session.GetAccount())
+		response = entity.NewAPIResponse(result, err)
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// filterPreview runs rules against either a pasted `content` sample or a
+// live chapter (`novelID` + `chapterIndex`). `rules` defaults to the
+// domain's current rule set, so the endpoint also answers "what do the
+// saved rules do to this chapter".
+func (bpa *BlueprintAdmin) filterPreview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	dns := mux.Vars(r)["dns"]
+
+	response := bpa.previewFilter(&dns, r)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAdmin) previewFilter(dns *string, r *http.Request) *entity.APIResponse {
+	rules := []entity.FilterRule{}
+	if rawRules := r.FormValue("rules"); rawRules != "" {
+		if err := json.Unmarshal([]byte(rawRules), &rules); err != nil {
+			return entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field rules should be a JSON array of rules"))
+		}
+	} else {
+		ruleSet, err := bpa.filter.GetRuleSet(dns)
+		if err != nil {
+			return entity.NewAPIResponse(nil, err)
+		}
+		rules = ruleSet.Rules
+	}
+
+	content := r.FormValue("content")
+	if novelID := r.FormValue("novelID"); novelID != "" {
+		chapterIndex := r.FormValue("chapterIndex")
+		record, raw, err := bpa.novel.FetchRawNovelChapter(&novelID, &chapterIndex)
+		if err != nil {
+			return entity.NewAPIResponse(nil, err)
+		} else if record.DNS != *dns {
+			return entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Novel does not belong to this fetcher"))
+		}
+		content = *raw
+	}
+	if content == "" {
+		return entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field content or novelID should not be empty"))
+	}
+
+	filtered, err := bpa.filter.Preview(rules, &content)
+	if err != nil {
+		return entity.NewAPIResponse(nil, err)
+	}
+	return entity.NewAPIResponse(map[string]interface{}{
+		"content":  content,
+		"filtered": filtered,
+	}, nil)
+}
@@ -3,8 +3,11 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	gql "silverfish/router/api/gql"
 	v1 "silverfish/router/api/v1"
+	v2 "silverfish/router/api/v2"
 	interf "silverfish/router/interface"
+	openapi "silverfish/router/openapi"
 	silverfish "silverfish/silverfish"
 
 	"github.com/gorilla/mux"
@@ -15,6 +18,8 @@ type BlueprintAPI struct {
 	auth  *silverfish.Auth
 	route string
 	v1    *v1.BlueprintAPIv1
+	v2    *v2.BlueprintAPIv2
+	gql   *gql.BlueprintGraphQL
 }
 
 // NewBlueprintAPI export
@@ -23,12 +28,17 @@ func NewBlueprintAPI(
 	user *silverfish.User,
 	novel *silverfish.Novel,
 	comic *silverfish.Comic,
+	search *silverfish.Search,
+	jobs *silverfish.Jobs,
+	importer *silverfish.Importer,
 	router interf.IRouter,
 ) *BlueprintAPI {
 	ba := new(BlueprintAPI)
 	ba.auth = auth
 	ba.route = "/api"
-	ba.v1 = v1.NewBlueprintAPIv1(auth, user, novel, comic)
+	ba.v1 = v1.NewBlueprintAPIv1(auth, user, novel, comic, search)
+	ba.v2 = v2.NewBlueprintAPIv2(user, novel, comic, jobs, importer)
+	ba.gql = gql.NewBlueprintGraphQL(user, novel, comic, jobs, importer)
 	return ba
 }
 
@@ -39,12 +49,26 @@ func (ba *BlueprintAPI) RouteRegister(parentRouter *mux.Router) {
 	router.HandleFunc("/", ba.root)
 
 	ba.v1.RouteRegister(router)
+	ba.v2.RouteRegister(router)
+	ba.gql.RouteRegister(router)
+}
+
+// Operations export
+func (ba *BlueprintAPI) Operations() []openapi.Operation {
+	return openapi.Mount(ba.route, []openapi.Operation{
+		{
+			Method: "GET", Summary: "API versions", Tag: "api",
+			Bare:     true,
+			Response: openapi.Object{"v1": true, "v2": true, "Success": true},
+		},
+	}, ba.v1.Operations(), ba.v2.Operations(), ba.gql.Operations())
 }
 
 func (ba *BlueprintAPI) root(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	js, _ := json.Marshal(map[string]bool{
 		"v1":      true,
+		"v2":      true,
 		"Success": true,
 	})
 	w.Write(js)
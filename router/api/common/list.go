@@ -0,0 +1,61 @@
+package common
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	openapi "silverfish/router/openapi"
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+)
+
+// ParseListQuery export — reads the paging options shared by the novel and comic
+// lists: `sort` (title, author, lastUpdate, added, popularity), `order`
+// (asc, desc), `source`, `enabled` (with book:edit only), `updatedSince`
+// (RFC 3339), `cursor` and `limit` (1-100; omitted returns everything).
+func ParseListQuery(r *http.Request) (*entity.ListQuery, error) {
+	params := r.URL.Query()
+	query := &entity.ListQuery{
+		Sort:   params.Get("sort"),
+		Order:  params.Get("order"),
+		Source: params.Get("source"),
+		Cursor: params.Get("cursor"),
+	}
+	if enabled := params.Get("enabled"); enabled != "" {
+		value, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return nil, silverfish.InvalidArgumentError("Invalid enabled")
+		}
+		query.Enabled = &value
+	}
+	if since := params.Get("updatedSince"); since != "" {
+		value, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, silverfish.InvalidArgumentError("Invalid updatedSince")
+		}
+		query.UpdatedSince = value
+	}
+	if limit := params.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > 100 {
+			return nil, silverfish.InvalidArgumentError("Invalid limit")
+		}
+		query.Limit = value
+	}
+	return query, nil
+}
+
+// ListParams export — ParseListQuery's parameters, for the OpenAPI
+// document.
+func ListParams() []openapi.Param {
+	return []openapi.Param{
+		openapi.Field("sort", "").OneOf("title", "author", "lastUpdate", "added", "popularity"),
+		openapi.Field("order", "").OneOf("asc", "desc"),
+		openapi.Field("source", "Fetcher domain"),
+		openapi.Field("enabled", "Hidden books too, with book:edit only").Typed("boolean"),
+		openapi.Field("updatedSince", "RFC 3339 datetime"),
+		openapi.Field("cursor", "The next cursor of the previous page"),
+		openapi.Field("limit", "1-100; omitted returns everything").Typed("integer"),
+	}
+}
@@ -0,0 +1,18 @@
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseListQueryLimit(t *testing.T) {
+	for limit, ok := range map[string]bool{"": true, "1": true, "100": true, "0": false, "101": false, "-1": false, "ten": false} {
+		query, err := ParseListQuery(httptest.NewRequest("GET", "/novels?limit="+limit, nil))
+		if (err == nil) != ok {
+			t.Errorf("limit %q: got %v", limit, err)
+		}
+		if err == nil && limit == "" && query.Limit != 0 {
+			t.Errorf("no limit read as %d", query.Limit)
+		}
+	}
+}
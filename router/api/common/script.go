@@ -0,0 +1,44 @@
+package common
+
+import (
+	"net/http"
+
+	openapi "silverfish/router/openapi"
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+	usecase "silverfish/silverfish/usecase"
+)
+
+// ResolveScript export — picks the Chinese script to render text in: `?script=`
+// wins, then the caller's saved preference, else whatever the upstream
+// served.
+func ResolveScript(r *http.Request, userSer *silverfish.User, session *entity.Session) string {
+	if script := r.URL.Query().Get("script"); script != "" {
+		return usecase.ParseScript(script)
+	}
+	if session == nil {
+		return usecase.ScriptOriginal
+	}
+	preference, err := userSer.GetPreference(session.GetAccount())
+	if err != nil {
+		return usecase.ScriptOriginal
+	}
+	return preference.Script
+}
+
+// ScriptConverter export — returns the text converter for `script`, or nil when
+// nothing needs converting.
+func ScriptConverter(script string) func(string) string {
+	if script == usecase.ScriptOriginal {
+		return nil
+	}
+	return func(text string) string {
+		return usecase.ConvertScript(text, script)
+	}
+}
+
+// ScriptParam export — ResolveScript's `script` parameter, for the
+// OpenAPI document.
+func ScriptParam() openapi.Param {
+	return openapi.Field("script", "Chinese script to render text in, the caller's preference by default").OneOf("hans", "hant")
+}
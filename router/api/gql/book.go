@@ -0,0 +1,289 @@
+package gql
+
+import (
+	"sort"
+	"time"
+
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+)
+
+// Book types, the values of the BookType enum.
+const (
+	bookTypeNovel = "novel"
+	bookTypeComic = "comic"
+)
+
+// book is a novel or comic as the schema serves it, in the caller's
+// script. Books from a list lack Description, Source and URL; their
+// resolvers load the rest of the book when asked for them.
+type book struct {
+	Type               string    `json:"type"`
+	ID                 string    `json:"id"`
+	Title              string    `json:"title"`
+	Author             string    `json:"author"`
+	Description        string    `json:"description"`
+	CoverURL           string    `json:"coverUrl"`
+	Enabled            bool      `json:"enabled"`
+	Source             string    `json:"source"`
+	URL                string    `json:"url"`
+	LastCrawlTime      time.Time `json:"lastCrawlTime"`
+	AddedDatetime      time.Time `json:"addedDatetime"`
+	LastUpdateDatetime time.Time `json:"lastUpdateDatetime"`
+	Popularity         int       `json:"popularity"`
+	listed             bool
+}
+
+// chapter is an entry of a book's chapter list; its content is only
+// fetched when asked for.
+type chapter struct {
+	Index    int    `json:"index"`
+	Title    string `json:"title"`
+	bookType string
+	bookID   string
+}
+
+// bookmark is a BookmarkEntry together with which book it is of.
+type bookmark struct {
+	Type             string    `json:"type"`
+	BookID           string    `json:"bookId"`
+	LastReadIndex    int       `json:"lastReadIndex"`
+	LastReadDatetime time.Time `json:"lastReadDatetime"`
+}
+
+// bookName is the book type as the services name it in errors and
+// bookmarks.
+func bookName(bookType string) string {
+	if bookType == bookTypeNovel {
+		return "Novel"
+	}
+	return "Comic"
+}
+
+func (req *request) converted(text string) string {
+	if req.convert == nil {
+		return text
+	}
+	return req.convert(text)
+}
+
+func (req *request) novelBook(novel *entity.Novel) *book {
+	return &book{
+		Type:               bookTypeNovel,
+		ID:                 novel.NovelID,
+		Title:              req.converted(novel.Title),
+		Author:             req.converted(novel.Author),
+		Description:        req.converted(novel.Description),
+		CoverURL:           novel.CoverURL,
+		Enabled:            novel.IsEnable,
+		Source:             novel.DNS,
+		URL:                novel.URL,
+		LastCrawlTime:      novel.LastCrawlTime,
+		AddedDatetime:      novel.AddedDatetime,
+		LastUpdateDatetime: novel.LastUpdateDatetime,
+		Popularity:         novel.Popularity,
+	}
+}
+
+func (req *request) comicBook(comic *entity.Comic) *book {
+	return &book{
+		Type:               bookTypeComic,
+		ID:                 comic.ComicID,
+		Title:              req.converted(comic.Title),
+		Author:             req.converted(comic.Author),
+		Description:        req.converted(comic.Description),
+		CoverURL:           comic.CoverURL,
+		Enabled:            comic.IsEnable,
+		Source:             comic.DNS,
+		URL:                comic.URL,
+		LastCrawlTime:      comic.LastCrawlTime,
+		AddedDatetime:      comic.AddedDatetime,
+		LastUpdateDatetime: comic.LastUpdateDatetime,
+		Popularity:         comic.Popularity,
+	}
+}
+
+func (req *request) listedNovel(info *entity.NovelInfo) *book {
+	return &book{
+		Type:               bookTypeNovel,
+		ID:                 info.NovelID,
+		Title:              req.converted(info.Title),
+		Author:             req.converted(info.Author),
+		CoverURL:           info.CoverURL,
+		Enabled:            info.IsEnable,
+		LastCrawlTime:      info.LastCrawlTime,
+		AddedDatetime:      info.AddedDatetime,
+		LastUpdateDatetime: info.LastUpdateDatetime,
+		Popularity:         info.Popularity,
+		listed:             true,
+	}
+}
+
+func (req *request) listedComic(info *entity.ComicInfo) *book {
+	return &book{
+		Type:               bookTypeComic,
+		ID:                 info.ComicID,
+		Title:              req.converted(info.Title),
+		Author:             req.converted(info.Author),
+		CoverURL:           info.CoverURL,
+		Enabled:            info.IsEnable,
+		LastCrawlTime:      info.LastCrawlTime,
+		AddedDatetime:      info.AddedDatetime,
+		LastUpdateDatetime: info.LastUpdateDatetime,
+		Popularity:         info.Popularity,
+		listed:             true,
+	}
+}
+
+// visible hides disabled books from callers without book:edit.
+func (req *request) visible(b *book) bool {
+	return b.Enabled || req.caller.Can(entity.PermissionBookEdit)
+}
+
+// fetchNovels is the batchFetch of the novel loader: books without
+// chapters, hidden ones left out for readers.
+func (bpg *BlueprintGraphQL) fetchNovels(req *request) batchFetch {
+	return func(novelIDs []string) (map[string]interface{}, error) {
+		novels, err := bpg.novelSer.GetNovelsByIDs(novelIDs)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]interface{}{}
+		for i := range novels {
+			if b := req.novelBook(&novels[i]); req.visible(b) {
+				result[b.ID] = b
+			}
+		}
+		return result, nil
+	}
+}
+
+// fetchComics is fetchNovels for comics.
+func (bpg *BlueprintGraphQL) fetchComics(req *request) batchFetch {
+	return func(comicIDs []string) (map[string]interface{}, error) {
+		comics, err := bpg.comicSer.GetComicsByIDs(comicIDs)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]interface{}{}
+		for i := range comics {
+			if b := req.comicBook(&comics[i]); req.visible(b) {
+				result[b.ID] = b
+			}
+		}
+		return result, nil
+	}
+}
+
+// fetchChapterTitles is the batchFetch of a chapter loader, over the
+// service's chapter title lookup. Like fetchNovels it leaves hidden
+// books out for readers, so their chapters can't be reached through a
+// bookmark either.
+func fetchChapterTitles(req *request, titlesOf func(shouldFetchDisable bool, bookIDs []string) (map[string][]string, error)) batchFetch {
+	return func(bookIDs []string) (map[string]interface{}, error) {
+		titles, err := titlesOf(req.caller.Can(entity.PermissionBookEdit), bookIDs)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]interface{}{}
+		for bookID, list := range titles {
+			for i := range list {
+				list[i] = req.converted(list[i])
+			}
+			result[bookID] = list
+		}
+		return result, nil
+	}
+}
+
+// loadBook returns a thunk of the book, nil when it doesn't exist or is
+// hidden from the caller.
+func (req *request) loadBook(bookType, bookID string) func() (*book, error) {
+	thunk := req.books[bookType].Load(bookID)
+	return func() (*book, error) {
+		value, err := thunk()
+		if value == nil || err != nil {
+			return nil, err
+		}
+		return value.(*book), nil
+	}
+}
+
+// loadChapters returns a thunk of the book's chapters.
+func (req *request) loadChapters(bookType, bookID string) func() ([]*chapter, error) {
+	thunk := req.chapters[bookType].Load(bookID)
+	return func() ([]*chapter, error) {
+		value, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+		titles, _ := value.([]string)
+		chapters := make([]*chapter, len(titles))
+		for i, title := range titles {
+			chapters[i] = &chapter{Index: i, Title: title, bookType: bookType, bookID: bookID}
+		}
+		return chapters, nil
+	}
+}
+
+// findBook loads one book like the v2 API does, recrawling it when
+// stale, and primes the loaders with it so its chapters cost no second
+// query. Hidden books look missing to readers.
+func (bpg *BlueprintGraphQL) findBook(req *request, bookType, bookID string) (*book, error) {
+	var result *book
+	var titles []string
+	if bookType == bookTypeNovel {
+		novel, err := bpg.novelSer.GetNovelByID(&bookID)
+		if err != nil {
+			return nil, err
+		}
+		result = req.novelBook(novel)
+		for _, chapter := range novel.Chapters {
+			titles = append(titles, req.converted(chapter.Title))
+		}
+	} else {
+		comic, err := bpg.comicSer.GetComicByID(&bookID)
+		if err != nil {
+			return nil, err
+		}
+		result = req.comicBook(comic)
+		for _, chapter := range comic.Chapters {
+			titles = append(titles, req.converted(chapter.Title))
+		}
+	}
+	if !req.visible(result) {
+		return nil, silverfish.NotFoundError("%s not exists", bookName(bookType))
+	}
+	req.books[bookType].Prime(bookID, result)
+	req.chapters[bookType].Prime(bookID, titles)
+	return result, nil
+}
+
+// bookmarkList flattens `entries` of the given type (both when empty)
+// into bookmarks, most recently read first.
+func bookmarkList(entries *entity.Bookmark, bookType string) []*bookmark {
+	result := []*bookmark{}
+	if entries == nil {
+		return result
+	}
+	for entryType, byID := range map[string]map[string]*entity.BookmarkEntry{
+		bookTypeNovel: entries.Novel,
+		bookTypeComic: entries.Comic,
+	} {
+		if bookType != "" && bookType != entryType {
+			continue
+		}
+		for bookID, entry := range byID {
+			result = append(result, &bookmark{
+				Type:             entryType,
+				BookID:           bookID,
+				LastReadIndex:    entry.LastReadIndex,
+				LastReadDatetime: entry.LastReadDatetime,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastReadDatetime.After(result[j].LastReadDatetime)
+	})
+	return result
+}
@@ -0,0 +1,329 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	common "silverfish/router/api/common"
+	middleware "silverfish/router/middleware"
+	openapi "silverfish/router/openapi"
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/gorilla/mux"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxBodySize caps the JSON request body; queries are small.
+const maxBodySize = 1 << 20
+
+type contextKey int
+
+const requestKey contextKey = 0
+
+// BlueprintGraphQL export — one endpoint for the reading client: books,
+// chapters, bookmarks and reading history in a single round trip, with
+// only the fields asked for, plus mutations for bookmarks and curators.
+// Books and chapter lists are loaded in batches, see loader.
+type BlueprintGraphQL struct {
+	userSer     *silverfish.User
+	novelSer    *silverfish.Novel
+	comicSer    *silverfish.Comic
+	jobSer      *silverfish.Jobs
+	importerSer *silverfish.Importer
+	route       string
+	schema      graphql.Schema
+}
+
+// NewBlueprintGraphQL export
+func NewBlueprintGraphQL(
+	userSer *silverfish.User,
+	novelSer *silverfish.Novel,
+	comicSer *silverfish.Comic,
+	jobSer *silverfish.Jobs,
+	importerSer *silverfish.Importer,
+) *BlueprintGraphQL {
+	bpg := new(BlueprintGraphQL)
+	bpg.userSer = userSer
+	bpg.novelSer = novelSer
+	bpg.comicSer = comicSer
+	bpg.jobSer = jobSer
+	bpg.importerSer = importerSer
+	bpg.route = "/graphql"
+	bpg.schema = bpg.newSchema()
+	return bpg
+}
+
+// RouteRegister export
+func (bpg *BlueprintGraphQL) RouteRegister(parentRouter *mux.Router) {
+	parentRouter.HandleFunc(bpg.route, bpg.serve).Methods("GET", "POST")
+}
+
+// Operations export
+func (bpg *BlueprintGraphQL) Operations() []openapi.Operation {
+	response := openapi.Object{
+		"data": openapi.Object{},
+		"errors": []openapi.Object{{
+			"message":    "",
+			"path":       []string{},
+			"extensions": openapi.Object{"code": ""},
+		}},
+	}
+	return openapi.Tag("graphql", openapi.Mount(bpg.route, []openapi.Operation{
+		{
+			Method: "GET", Summary: "Run a GraphQL query",
+			Description: "Queries only, mutations must be POSTed. Chapter content and images are served for `novel`/`comic { chapter(index) }` only, at most 5 per request. Errors carry the API error code in `extensions.code`.",
+			Auth:        openapi.AuthOptional,
+			Query: []openapi.Param{
+				openapi.Field("query", "").Require(),
+				openapi.Field("variables", "JSON object"),
+				openapi.Field("operationName", ""),
+				common.ScriptParam(),
+			},
+			Bare:     true,
+			Response: response,
+		},
+		{
+			Method: "POST", Summary: "Run a GraphQL query or mutation",
+			Description: "Chapter content and images are served for `novel`/`comic { chapter(index) }` only, at most 5 per request. Errors carry the API error code in `extensions.code`.",
+			Auth:        openapi.AuthOptional,
+			Query:       []openapi.Param{common.ScriptParam()},
+			Body:        graphQLRequest{},
+			Bare:        true,
+			Response:    response,
+		},
+	}))
+}
+
+// graphQLRequest is the body of POST /graphql.
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// request is what resolvers know of the HTTP request, with the loaders
+// shared by every field of one query.
+type request struct {
+	caller   *middleware.Caller
+	convert  func(string) string
+	books    map[string]*loader
+	chapters map[string]*loader
+	// The caller's own bookmarks, loaded once for every Book.bookmark.
+	bookmarkOnce sync.Once
+	bookmarks    *entity.Bookmark
+	bookmarksErr error
+}
+
+func getRequest(ctx context.Context) *request {
+	return ctx.Value(requestKey).(*request)
+}
+
+func (bpg *BlueprintGraphQL) serve(w http.ResponseWriter, r *http.Request) {
+	body := new(graphQLRequest)
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		body.Query, body.OperationName = query.Get("query"), query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &body.Variables); err != nil {
+				writeResult(w, http.StatusBadRequest, errorResult(silverfish.InvalidArgumentError("Invalid variables: %s", err.Error())))
+				return
+			}
+		}
+	} else if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(body); err != nil {
+		writeResult(w, http.StatusBadRequest, errorResult(silverfish.InvalidArgumentError("Invalid request body: %s", err.Error())))
+		return
+	}
+	if body.Query == "" {
+		writeResult(w, http.StatusBadRequest, errorResult(silverfish.InvalidArgumentError("Field query should not be empty")))
+		return
+	}
+	// Queries that don't parse skip these checks, graphql.Do reports why.
+	if document, err := parser.Parse(parser.ParseParams{Source: body.Query}); err == nil {
+		operations := selectedOperations(document, body.OperationName)
+		if r.Method == http.MethodGet && isMutation(operations) {
+			writeResult(w, http.StatusMethodNotAllowed, errorResult(silverfish.InvalidArgumentError("Mutations must be POSTed")))
+			return
+		}
+		if err := checkContent(document, operations); err != nil {
+			writeResult(w, http.StatusBadRequest, errorResult(err))
+			return
+		}
+	}
+
+	caller := middleware.GetCaller(r)
+	req := &request{
+		caller:   caller,
+		convert:  common.ScriptConverter(common.ResolveScript(r, bpg.userSer, caller.Session)),
+		books:    map[string]*loader{},
+		chapters: map[string]*loader{},
+	}
+	req.books[bookTypeNovel] = newLoader(bpg.fetchNovels(req))
+	req.books[bookTypeComic] = newLoader(bpg.fetchComics(req))
+	req.chapters[bookTypeNovel] = newLoader(fetchChapterTitles(req, bpg.novelSer.GetNovelChapterTitles))
+	req.chapters[bookTypeComic] = newLoader(fetchChapterTitles(req, bpg.comicSer.GetComicChapterTitles))
+
+	params := graphql.Params{
+		Schema:         bpg.schema,
+		RequestString:  body.Query,
+		VariableValues: body.Variables,
+		OperationName:  body.OperationName,
+		Context:        context.WithValue(r.Context(), requestKey, req),
+	}
+	result := graphql.Do(params)
+	for i := range result.Errors {
+		result.Errors[i].Extensions = extensions(result.Errors[i])
+	}
+	status := http.StatusOK
+	if result.Data == nil && len(result.Errors) > 0 && len(result.Errors[0].Path) == 0 {
+		// The query didn't parse or validate, so nothing ran; errors
+		// of fields that ran have a path.
+		status = http.StatusBadRequest
+	}
+	writeResult(w, status, result)
+}
+
+// selectedOperations are the operations of `document` graphql.Do may
+// run: the one named `operationName`, or all of them.
+func selectedOperations(document *ast.Document, operationName string) []*ast.OperationDefinition {
+	operations := []*ast.OperationDefinition{}
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok || (operationName != "" && (operation.Name == nil || operation.Name.Value != operationName)) {
+			continue
+		}
+		operations = append(operations, operation)
+	}
+	return operations
+}
+
+// isMutation tells whether any of `operations` is a mutation.
+func isMutation(operations []*ast.OperationDefinition) bool {
+	for _, operation := range operations {
+		if operation.Operation == ast.OperationTypeMutation {
+			return true
+		}
+	}
+	return false
+}
+
+// maxChapterContents caps the chapter contents one request may ask for;
+// each may crawl the book's source.
+const maxChapterContents = 5
+
+// contentPaths are where Chapter.content and Chapter.images may be asked
+// for: one chapter of one book. Anywhere else, e.g. under a book list or
+// the caller's bookmarks, one query could crawl whole sources.
+var contentPaths = map[string]bool{"novel.chapter": true, "comic.chapter": true}
+
+// checkContent rejects `operations` when they ask for chapter content
+// outside contentPaths, or for more than maxChapterContents of it.
+func checkContent(document *ast.Document, operations []*ast.OperationDefinition) error {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+	count := 0
+	var walk func(set *ast.SelectionSet, path string, spread map[string]bool) error
+	walk = func(set *ast.SelectionSet, path string, spread map[string]bool) error {
+		if set == nil {
+			return nil
+		}
+		for _, selection := range set.Selections {
+			switch selection := selection.(type) {
+			case *ast.Field:
+				name := selection.Name.Value
+				if name == "content" || name == "images" {
+					if !contentPaths[path] {
+						return silverfish.InvalidArgumentError("Field %s is only served for novel or comic { chapter(index) }", name)
+					}
+					if count++; count > maxChapterContents {
+						return silverfish.InvalidArgumentError("At most %d chapter contents per request", maxChapterContents)
+					}
+				}
+				fieldPath := name
+				if path != "" {
+					fieldPath = path + "." + name
+				}
+				if err := walk(selection.SelectionSet, fieldPath, spread); err != nil {
+					return err
+				}
+			case *ast.InlineFragment:
+				if err := walk(selection.SelectionSet, path, spread); err != nil {
+					return err
+				}
+			case *ast.FragmentSpread:
+				// Fragment cycles fail validation, don't follow them here.
+				name := selection.Name.Value
+				fragment, ok := fragments[name]
+				if !ok || spread[name] {
+					continue
+				}
+				spread[name] = true
+				err := walk(fragment.SelectionSet, path, spread)
+				delete(spread, name)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, operation := range operations {
+		if err := walk(operation.SelectionSet, "", map[string]bool{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeResult(w http.ResponseWriter, status int, result *graphql.Result) {
+	w.Header().Set("Content-Type", "application/json")
+	js, _ := json.Marshal(result)
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
+func errorResult(err error) *graphql.Result {
+	formatted := gqlerrors.FormatError(err)
+	formatted.Extensions = extensions(formatted)
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{formatted}}
+}
+
+// extensions gives a GraphQL error the code the REST API would answer
+// with, see entity.NewAPIResponse. Errors of the query itself, which
+// wrap no error of ours, are invalid arguments.
+func extensions(formatted gqlerrors.FormattedError) map[string]interface{} {
+	var err error = formatted
+	for unwrapped := true; unwrapped; {
+		switch wrapper := err.(type) {
+		case gqlerrors.FormattedError:
+			err = wrapper.OriginalError()
+		case *gqlerrors.Error:
+			err = wrapper.OriginalError
+		default:
+			unwrapped = false
+		}
+		if err == nil {
+			return map[string]interface{}{"code": entity.ErrorInvalidArgument}
+		}
+	}
+	code := entity.ErrorInternal
+	var coded entity.CodedError
+	if errors.As(err, &coded) {
+		code = coded.ErrorCode()
+	} else if errors.Is(err, mongo.ErrNoDocuments) {
+		code = entity.ErrorNotFound
+	}
+	return map[string]interface{}{"code": code}
+}
@@ -0,0 +1,69 @@
+package gql
+
+import (
+	"sync"
+)
+
+// batchFetch loads the values of many keys at once; keys without a value
+// are left out of the result.
+type batchFetch func(keys []string) (map[string]interface{}, error)
+
+// loader batches loads DataLoader style. Load only queues the key and
+// hands out a thunk; graphql-go resolves the thunks of a query level
+// after resolving every field of that level, so the first thunk called
+// fetches all keys queued by its siblings in one go. Results are kept
+// for the rest of the request.
+type loader struct {
+	fetch   batchFetch
+	mutex   sync.Mutex
+	pending []string
+	queued  map[string]bool
+	values  map[string]interface{}
+	errs    map[string]error
+}
+
+func newLoader(fetch batchFetch) *loader {
+	l := new(loader)
+	l.fetch = fetch
+	l.queued = map[string]bool{}
+	l.values = map[string]interface{}{}
+	l.errs = map[string]error{}
+	return l
+}
+
+// Load returns a thunk of the value of `key`, nil when there is none.
+func (l *loader) Load(key string) func() (interface{}, error) {
+	l.mutex.Lock()
+	if !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	l.mutex.Unlock()
+
+	return func() (interface{}, error) {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending = nil
+			values, err := l.fetch(keys)
+			for _, k := range keys {
+				if err != nil {
+					l.errs[k] = err
+				} else if value, ok := values[k]; ok {
+					l.values[k] = value
+				}
+			}
+		}
+		return l.values[key], l.errs[key]
+	}
+}
+
+// Prime stores a value loaded some other way, e.g. with a whole book.
+func (l *loader) Prime(key string, value interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.queued[key] = true
+	l.values[key] = value
+	delete(l.errs, key)
+}
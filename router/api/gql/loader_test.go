@@ -0,0 +1,79 @@
+package gql
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+// TestLoaderBatchesSiblings runs a list query whose items each load a
+// key, the way bookmarks load their books, and expects one fetch.
+func TestLoaderBatchesSiblings(t *testing.T) {
+	fetches := [][]string{}
+	names := newLoader(func(keys []string) (map[string]interface{}, error) {
+		fetches = append(fetches, append([]string{}, keys...))
+		result := map[string]interface{}{}
+		for _, key := range keys {
+			if key != "missing" {
+				result[key] = "name of " + key
+			}
+		}
+		return result, nil
+	})
+
+	itemType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Item",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return names.Load(p.Source.(string)), nil
+				},
+			},
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"items": &graphql.Field{
+					Type: graphql.NewList(itemType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return []string{"a", "b", "a", "missing"}, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := graphql.Do(graphql.Params{Schema: schema, RequestString: "{ items { name } }"})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+	want := map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"name": "name of a"},
+		map[string]interface{}{"name": "name of b"},
+		map[string]interface{}{"name": "name of a"},
+		map[string]interface{}{"name": nil},
+	}}
+	if !reflect.DeepEqual(result.Data, want) {
+		t.Errorf("got %v, want %v", result.Data, want)
+	}
+	if len(fetches) != 1 {
+		t.Fatalf("fetched %d times, want once: %v", len(fetches), fetches)
+	}
+	sort.Strings(fetches[0])
+	if !reflect.DeepEqual(fetches[0], []string{"a", "b", "missing"}) {
+		t.Errorf("fetched %v, want each key once", fetches[0])
+	}
+
+	// Loaded keys are kept for the rest of the request.
+	if value, _ := names.Load("b")(); value != "name of b" || len(fetches) != 1 {
+		t.Errorf("reloading b fetched again or got %v", value)
+	}
+}
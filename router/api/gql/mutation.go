@@ -0,0 +1,260 @@
+package gql
+
+import (
+	"strconv"
+	"time"
+
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/graphql-go/graphql"
+)
+
+// bookArgs are the arguments naming one book.
+func bookArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"type": &graphql.ArgumentConfig{Type: graphql.NewNonNull(bookTypeEnum)},
+		"id":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+	}
+}
+
+// mutation builds the Mutation type: the caller's bookmarks, and the
+// curator actions behind their permissions.
+func (bpg *BlueprintGraphQL) mutation(bookType, bookmarkType *graphql.Object) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"updateBookmark": &graphql.Field{
+				Type:        graphql.NewNonNull(bookmarkType),
+				Description: "Move the caller's bookmark, e.g. to sync reading progress from an e-reader.",
+				Args: graphql.FieldConfigArgument{
+					"type":          &graphql.ArgumentConfig{Type: graphql.NewNonNull(bookTypeEnum)},
+					"id":            &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"lastReadIndex": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: bpg.updateBookmark,
+			},
+			"removeBookmark": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: bookArgs(),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					caller := getRequest(p.Context).caller
+					if err := requireScope(caller, entity.ScopeBookmarkWrite); err != nil {
+						return nil, err
+					}
+					bookType, bookID := p.Args["type"].(string), p.Args["id"].(string)
+					if err := bpg.userSer.RemoveBookmark(bookName(bookType), &bookID, caller.Account()); err != nil {
+						return nil, err
+					}
+					return true, nil
+				},
+			},
+			"addBook": &graphql.Field{
+				Type:        graphql.NewNonNull(bookType),
+				Description: "Add a book from its source URL. Needs book:add.",
+				Args: graphql.FieldConfigArgument{
+					"type": &graphql.ArgumentConfig{Type: graphql.NewNonNull(bookTypeEnum)},
+					"url":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					req := getRequest(p.Context)
+					if err := authorize(req.caller, entity.PermissionBookAdd); err != nil {
+						return nil, err
+					}
+					bookURL := p.Args["url"].(string)
+					if bookURL == "" {
+						return nil, silverfish.InvalidArgumentError("Field url should not be empty")
+					}
+					if p.Args["type"].(string) == bookTypeNovel {
+						novel, _, err := bpg.novelSer.AddNovelByURL(&bookURL)
+						if err != nil {
+							return nil, err
+						}
+						return req.novelBook(novel), nil
+					}
+					comic, _, err := bpg.comicSer.AddComicByURL(&bookURL)
+					if err != nil {
+						return nil, err
+					}
+					return req.comicBook(comic), nil
+				},
+			},
+			"updateBook": &graphql.Field{
+				Type:        graphql.NewNonNull(bookType),
+				Description: "Show or hide a book, or override its metadata; an empty string clears an override. Needs book:edit.",
+				Args: graphql.FieldConfigArgument{
+					"type":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(bookTypeEnum)},
+					"id":          &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"enabled":     &graphql.ArgumentConfig{Type: graphql.Boolean},
+					"title":       &graphql.ArgumentConfig{Type: graphql.String},
+					"author":      &graphql.ArgumentConfig{Type: graphql.String},
+					"description": &graphql.ArgumentConfig{Type: graphql.String},
+					"coverUrl":    &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: bpg.updateBook,
+			},
+			"removeBook": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Needs book:delete.",
+				Args:        bookArgs(),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					req := getRequest(p.Context)
+					if err := authorize(req.caller, entity.PermissionBookDelete); err != nil {
+						return nil, err
+					}
+					bookType, bookID := p.Args["type"].(string), p.Args["id"].(string)
+					if _, err := bpg.findBook(req, bookType, bookID); err != nil {
+						return nil, err
+					}
+					var err error
+					if bookType == bookTypeNovel {
+						err = bpg.novelSer.RemoveNovelByID(&bookID)
+					} else {
+						err = bpg.comicSer.RemoveComicByID(&bookID)
+					}
+					if err != nil {
+						return nil, err
+					}
+					return true, nil
+				},
+			},
+			"recrawlBook": &graphql.Field{
+				Type:        graphql.NewNonNull(bookType),
+				Description: "Refresh a book from its source now. Needs book:recrawl.",
+				Args:        bookArgs(),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					req := getRequest(p.Context)
+					if err := authorize(req.caller, entity.PermissionBookRecrawl); err != nil {
+						return nil, err
+					}
+					bookID := p.Args["id"].(string)
+					if p.Args["type"].(string) == bookTypeNovel {
+						novel, err := bpg.novelSer.Recrawl(&bookID, req.caller.Account())
+						if err != nil {
+							return nil, err
+						}
+						return req.novelBook(novel), nil
+					}
+					comic, err := bpg.comicSer.Recrawl(&bookID, req.caller.Account())
+					if err != nil {
+						return nil, err
+					}
+					return req.comicBook(comic), nil
+				},
+			},
+			"recrawlSource": &graphql.Field{
+				Type:        graphql.NewNonNull(jobType),
+				Description: "Start refreshing every book of a source in the background. Needs book:recrawl.",
+				Args: graphql.FieldConfigArgument{
+					"source": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String), Description: "Fetcher domain"},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					caller := getRequest(p.Context).caller
+					if err := authorize(caller, entity.PermissionBookRecrawl); err != nil {
+						return nil, err
+					}
+					source := p.Args["source"].(string)
+					for _, name := range bpg.novelSer.GetFetcherNameLists() {
+						if name == source {
+							return bpg.novelSer.RecrawlSource(&source, caller.Account())
+						}
+					}
+					for _, name := range bpg.comicSer.GetFetcherNameLists() {
+						if name == source {
+							return bpg.comicSer.RecrawlSource(&source, caller.Account())
+						}
+					}
+					return nil, silverfish.NotFoundError("No such fetcher")
+				},
+			},
+			"importBooks": &graphql.Field{
+				Type:        graphql.NewNonNull(jobType),
+				Description: "Start adding books from their source URLs in the background. Needs book:add.",
+				Args: graphql.FieldConfigArgument{
+					"urls": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					caller := getRequest(p.Context).caller
+					if err := authorize(caller, entity.PermissionBookAdd); err != nil {
+						return nil, err
+					}
+					urls := []string{}
+					for _, value := range p.Args["urls"].([]interface{}) {
+						urls = append(urls, value.(string))
+					}
+					return bpg.importerSer.Start(urls, caller.Account())
+				},
+			},
+		},
+	})
+}
+
+func (bpg *BlueprintGraphQL) updateBookmark(p graphql.ResolveParams) (interface{}, error) {
+	req := getRequest(p.Context)
+	if err := requireScope(req.caller, entity.ScopeBookmarkWrite); err != nil {
+		return nil, err
+	}
+	bookType, bookID := p.Args["type"].(string), p.Args["id"].(string)
+	lastReadIndex := p.Args["lastReadIndex"].(int)
+	if _, err := bpg.findBook(req, bookType, bookID); err != nil {
+		return nil, err
+	}
+	chapters, err := req.loadChapters(bookType, bookID)()
+	if err != nil {
+		return nil, err
+	}
+	if lastReadIndex < 0 || lastReadIndex >= len(chapters) {
+		return nil, silverfish.InvalidArgumentError("Invalid lastReadIndex")
+	}
+	index := strconv.Itoa(lastReadIndex)
+	if err := bpg.userSer.UpdateBookmark(bookName(bookType), &bookID, req.caller.Account(), &index); err != nil {
+		return nil, err
+	}
+	return &bookmark{
+		Type:             bookType,
+		BookID:           bookID,
+		LastReadIndex:    lastReadIndex,
+		LastReadDatetime: time.Now(),
+	}, nil
+}
+
+// updateBook applies the v2 PATCH of a book: `enabled` shows or hides
+// it, the metadata arguments set curator overrides.
+func (bpg *BlueprintGraphQL) updateBook(p graphql.ResolveParams) (interface{}, error) {
+	req := getRequest(p.Context)
+	if err := authorize(req.caller, entity.PermissionBookEdit); err != nil {
+		return nil, err
+	}
+	bookType, bookID := p.Args["type"].(string), p.Args["id"].(string)
+	by := req.caller.Account()
+
+	fields := map[string]string{}
+	for _, name := range []string{"title", "author", "description", "coverUrl"} {
+		if value, ok := p.Args[name].(string); ok {
+			fields[name] = value
+		}
+	}
+	enabled, setEnabled := p.Args["enabled"].(bool)
+	if !setEnabled && len(fields) == 0 {
+		return nil, silverfish.InvalidArgumentError("Argument enabled, title, author, description or coverUrl should be given")
+	}
+	var err error
+	if len(fields) > 0 {
+		if bookType == bookTypeNovel {
+			_, err = bpg.novelSer.UpdateOverrides(&bookID, fields, by)
+		} else {
+			_, err = bpg.comicSer.UpdateOverrides(&bookID, fields, by)
+		}
+	}
+	if err == nil && setEnabled {
+		if bookType == bookTypeNovel {
+			err = bpg.novelSer.SetEnable(&bookID, enabled, by)
+		} else {
+			err = bpg.comicSer.SetEnable(&bookID, enabled, by)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bpg.findBook(req, bookType, bookID)
+}
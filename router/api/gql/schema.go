@@ -0,0 +1,565 @@
+package gql
+
+import (
+	"strconv"
+	"time"
+
+	middleware "silverfish/router/middleware"
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/graphql-go/graphql"
+)
+
+var bookTypeEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "BookType",
+	Values: graphql.EnumValueConfigMap{
+		"NOVEL": &graphql.EnumValueConfig{Value: bookTypeNovel},
+		"COMIC": &graphql.EnumValueConfig{Value: bookTypeComic},
+	},
+})
+
+var jobResultType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "JobResult",
+	Description: "The outcome for one item of a job, e.g. one book.",
+	Fields: graphql.Fields{
+		"item":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"status": &graphql.Field{Type: graphql.String, Resolve: optional(func(r entity.JobResult) string { return r.Status })},
+		"type":   &graphql.Field{Type: graphql.String, Resolve: optional(func(r entity.JobResult) string { return r.Type })},
+		"id":     &graphql.Field{Type: graphql.ID, Resolve: optional(func(r entity.JobResult) string { return r.ID })},
+		"title":  &graphql.Field{Type: graphql.String, Resolve: optional(func(r entity.JobResult) string { return r.Title })},
+		"error":  &graphql.Field{Type: graphql.String, Resolve: optional(func(r entity.JobResult) string { return r.Error })},
+	},
+})
+
+var jobType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Job",
+	Description: "A background job: a source recrawl or an import.",
+	Fields: graphql.Fields{
+		"id":              &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"kind":            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"target":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"status":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"error":           &graphql.Field{Type: graphql.String, Resolve: optional(func(j *entity.Job) string { return j.Error })},
+		"total":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"done":            &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"failed":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"results":         &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(jobResultType)))},
+		"createdBy":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"createdDatetime": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		"finishedDatetime": &graphql.Field{Type: graphql.DateTime, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if finished := p.Source.(*entity.Job).FinishedDatetime; !finished.IsZero() {
+				return finished, nil
+			}
+			return nil, nil
+		}},
+	},
+})
+
+// optional resolves empty strings to null.
+func optional[T any](field func(source T) string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if value := field(p.Source.(T)); value != "" {
+			return value, nil
+		}
+		return nil, nil
+	}
+}
+
+// authorize fails unless the caller is logged in and, when `permission`
+// is set, holds it, like middleware.RequirePermission.
+func authorize(caller *middleware.Caller, permission string) error {
+	if !caller.IsUser() {
+		if caller.Err != nil {
+			return caller.Err
+		}
+		return silverfish.UnauthenticatedError("SessionToken not exists")
+	}
+	if permission != "" && !caller.Can(permission) {
+		return silverfish.ForbiddenError("Permission %s required", permission)
+	}
+	return nil
+}
+
+// requireScope fails unless the caller is logged in with a session or
+// an API token granting `scope`.
+func requireScope(caller *middleware.Caller, scope string) error {
+	if err := authorize(caller, ""); err != nil {
+		return err
+	}
+	if !caller.Session.HasScope(scope) {
+		return silverfish.ForbiddenError("API token lacks scope %s", scope)
+	}
+	return nil
+}
+
+// ownBookmarks loads the caller's bookmarks once per request; nil for
+// anonymous callers.
+func (bpg *BlueprintGraphQL) ownBookmarks(req *request) (*entity.Bookmark, error) {
+	if !req.caller.IsUser() {
+		return nil, nil
+	}
+	req.bookmarkOnce.Do(func() {
+		if err := requireScope(req.caller, entity.ScopeLibraryRead); err != nil {
+			req.bookmarksErr = err
+			return
+		}
+		req.bookmarks, req.bookmarksErr = bpg.userSer.GetUserBookmark(req.caller.Account())
+	})
+	return req.bookmarks, req.bookmarksErr
+}
+
+// newSchema builds the schema. It is static, so failing to build it is a
+// bug.
+func (bpg *BlueprintGraphQL) newSchema() graphql.Schema {
+	var bookType, bookmarkType *graphql.Object
+
+	chapterType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Chapter",
+		Fields: graphql.Fields{
+			"index": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"title": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"content": &graphql.Field{
+				Type:        graphql.String,
+				Description: "The text of a novel chapter, null for comics. Only served for novel { chapter(index) }, a few per request.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					c := p.Source.(*chapter)
+					if c.bookType != bookTypeNovel {
+						return nil, nil
+					}
+					index := strconv.Itoa(c.Index)
+					content, err := bpg.novelSer.GetNovelChapter(&c.bookID, &index)
+					if err != nil {
+						return nil, err
+					}
+					return getRequest(p.Context).converted(*content), nil
+				},
+			},
+			"images": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+				Description: "The image URLs of a comic chapter, null for novels. Only served for comic { chapter(index) }, a few per request.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					c := p.Source.(*chapter)
+					if c.bookType != bookTypeComic {
+						return nil, nil
+					}
+					index := strconv.Itoa(c.Index)
+					return bpg.comicSer.GetComicChapter(&c.bookID, &index)
+				},
+			},
+		},
+	})
+
+	// detail resolves the fields books from a list lack by loading the
+	// rest of the book, batched with the other books of the list.
+	detail := func(field func(b *book) string) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (interface{}, error) {
+			b := p.Source.(*book)
+			if !b.listed {
+				return field(b), nil
+			}
+			thunk := getRequest(p.Context).loadBook(b.Type, b.ID)
+			return func() (interface{}, error) {
+				full, err := thunk()
+				if full == nil || err != nil {
+					return "", err
+				}
+				return field(full), nil
+			}, nil
+		}
+	}
+
+	bookType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Book",
+		Description: "A novel or comic, in the caller's script.",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"type":   &graphql.Field{Type: graphql.NewNonNull(bookTypeEnum)},
+				"id":     &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"title":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"author": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"description": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.String),
+					Resolve: detail(func(b *book) string { return b.Description }),
+				},
+				"coverUrl": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"enabled":  &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"source": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.String),
+					Description: "The fetcher domain.",
+					Resolve:     detail(func(b *book) string { return b.Source }),
+				},
+				"url": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.String),
+					Resolve: detail(func(b *book) string { return b.URL }),
+				},
+				"lastCrawlTime":      &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"addedDatetime":      &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"lastUpdateDatetime": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"popularity":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"chapterCount": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.Int),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						b := p.Source.(*book)
+						thunk := getRequest(p.Context).loadChapters(b.Type, b.ID)
+						return func() (interface{}, error) {
+							chapters, err := thunk()
+							return len(chapters), err
+						}, nil
+					},
+				},
+				"chapters": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(chapterType))),
+					Description: "The chapter list, or the `limit` chapters from `offset`.",
+					Args: graphql.FieldConfigArgument{
+						"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+						"limit":  &graphql.ArgumentConfig{Type: graphql.Int},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						b := p.Source.(*book)
+						offset, _ := p.Args["offset"].(int)
+						limit, hasLimit := p.Args["limit"].(int)
+						if offset < 0 || (hasLimit && limit < 0) {
+							return nil, silverfish.InvalidArgumentError("Invalid offset or limit")
+						}
+						thunk := getRequest(p.Context).loadChapters(b.Type, b.ID)
+						return func() (interface{}, error) {
+							chapters, err := thunk()
+							if err != nil {
+								return nil, err
+							}
+							if offset > len(chapters) {
+								offset = len(chapters)
+							}
+							chapters = chapters[offset:]
+							if hasLimit && limit < len(chapters) {
+								chapters = chapters[:limit]
+							}
+							return chapters, nil
+						}, nil
+					},
+				},
+				"chapter": &graphql.Field{
+					Type: chapterType,
+					Args: graphql.FieldConfigArgument{
+						"index": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						b := p.Source.(*book)
+						index := p.Args["index"].(int)
+						thunk := getRequest(p.Context).loadChapters(b.Type, b.ID)
+						return func() (interface{}, error) {
+							chapters, err := thunk()
+							if err != nil {
+								return nil, err
+							}
+							if index < 0 || index >= len(chapters) {
+								return nil, silverfish.NotFoundError("Chapter not exists")
+							}
+							return chapters[index], nil
+						}, nil
+					},
+				},
+				"bookmark": &graphql.Field{
+					Type:        bookmarkType,
+					Description: "The caller's bookmark of the book.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						b := p.Source.(*book)
+						entries, err := bpg.ownBookmarks(getRequest(p.Context))
+						if err != nil {
+							return nil, err
+						}
+						for _, entry := range bookmarkList(entries, b.Type) {
+							if entry.BookID == b.ID {
+								return entry, nil
+							}
+						}
+						return nil, nil
+					},
+				},
+			}
+		}),
+	})
+
+	bookmarkType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Bookmark",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"type":             &graphql.Field{Type: graphql.NewNonNull(bookTypeEnum)},
+				"bookId":           &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"lastReadIndex":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"lastReadDatetime": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"book": &graphql.Field{
+					Type:        bookType,
+					Description: "Null once the book is removed or hidden.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						entry := p.Source.(*bookmark)
+						thunk := getRequest(p.Context).loadBook(entry.Type, entry.BookID)
+						return func() (interface{}, error) {
+							b, err := thunk()
+							if b == nil {
+								return nil, err
+							}
+							return b, nil
+						}, nil
+					},
+				},
+				"chapter": &graphql.Field{
+					Type:        chapterType,
+					Description: "The chapter last read, null once the book is removed or hidden.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						entry := p.Source.(*bookmark)
+						thunk := getRequest(p.Context).loadChapters(entry.Type, entry.BookID)
+						return func() (interface{}, error) {
+							chapters, err := thunk()
+							if err != nil || entry.LastReadIndex < 0 || entry.LastReadIndex >= len(chapters) {
+								return nil, err
+							}
+							return chapters[entry.LastReadIndex], nil
+						}, nil
+					},
+				},
+			}
+		}),
+	})
+
+	// userBookmarks checks the caller may read `user`'s bookmarks: their
+	// own take the library:read scope, others' are behind user:manage
+	// already.
+	userBookmarks := func(p graphql.ResolveParams, bookType string) ([]*bookmark, error) {
+		user := p.Source.(*entity.User)
+		caller := getRequest(p.Context).caller
+		if account := caller.Account(); account != nil && *account == user.Account {
+			if err := requireScope(caller, entity.ScopeLibraryRead); err != nil {
+				return nil, err
+			}
+		}
+		return bookmarkList(user.Bookmark, bookType), nil
+	}
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"account": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"email":   &graphql.Field{Type: graphql.String, Resolve: optional(func(u *entity.User) string { return u.Email })},
+			"role": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*entity.User).GetRole(), nil
+				},
+			},
+			"registerDatetime":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"lastLoginDatetime": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"bookmarks": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(bookmarkType))),
+				Description: "Most recently read first.",
+				Args: graphql.FieldConfigArgument{
+					"type": &graphql.ArgumentConfig{Type: bookTypeEnum},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					bookType, _ := p.Args["type"].(string)
+					return userBookmarks(p, bookType)
+				},
+			},
+			"readingHistory": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(bookmarkType))),
+				Description: "The `limit` books read last, novels and comics alike.",
+				Args: graphql.FieldConfigArgument{
+					"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					limit := p.Args["limit"].(int)
+					if limit < 1 || limit > 100 {
+						return nil, silverfish.InvalidArgumentError("Invalid limit")
+					}
+					history, err := userBookmarks(p, "")
+					if err == nil && len(history) > limit {
+						history = history[:limit]
+					}
+					return history, err
+				},
+			},
+		},
+	})
+
+	bookPageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "BookPage",
+		Fields: graphql.Fields{
+			"items": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(bookType)))},
+			"total": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"nextCursor": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Null on the last page.",
+				Resolve:     optional(func(page *bookPage) string { return page.NextCursor }),
+			},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"novels": bpg.listField(bookPageType, bookTypeNovel),
+			"comics": bpg.listField(bookPageType, bookTypeComic),
+			"novel":  bpg.bookField(bookType, bookTypeNovel),
+			"comic":  bpg.bookField(bookType, bookTypeComic),
+			"me": &graphql.Field{
+				Type:        userType,
+				Description: "The caller, null when anonymous.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					caller := getRequest(p.Context).caller
+					if !caller.IsUser() {
+						return nil, nil
+					}
+					return bpg.userSer.GetUser(caller.Account())
+				},
+			},
+			"user": &graphql.Field{
+				Type:        userType,
+				Description: "Needs user:manage.",
+				Args: graphql.FieldConfigArgument{
+					"account": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(getRequest(p.Context).caller, entity.PermissionUserManage); err != nil {
+						return nil, err
+					}
+					account := p.Args["account"].(string)
+					return bpg.userSer.GetUser(&account)
+				},
+			},
+			"job": &graphql.Field{
+				Type:        jobType,
+				Description: "Needs book:recrawl, except for the caller's own jobs.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					caller := getRequest(p.Context).caller
+					if err := authorize(caller, ""); err != nil {
+						return nil, err
+					}
+					jobID := p.Args["id"].(string)
+					job, err := bpg.jobSer.GetJob(&jobID)
+					if err != nil {
+						return nil, err
+					}
+					if !caller.Can(entity.PermissionBookRecrawl) && job.CreatedBy != *caller.Account() {
+						return nil, silverfish.ForbiddenError("Permission %s required", entity.PermissionBookRecrawl)
+					}
+					return job, nil
+				},
+			},
+			"jobs": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(jobType))),
+				Description: "The latest `limit` (1-500) jobs. Needs book:recrawl.",
+				Args: graphql.FieldConfigArgument{
+					"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 50},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(getRequest(p.Context).caller, entity.PermissionBookRecrawl); err != nil {
+						return nil, err
+					}
+					limit := p.Args["limit"].(int)
+					if limit < 1 || limit > 500 {
+						return nil, silverfish.InvalidArgumentError("Invalid limit")
+					}
+					jobs, err := bpg.jobSer.GetJobs(int64(limit))
+					if err != nil {
+						return nil, err
+					}
+					result := make([]*entity.Job, len(jobs))
+					for i := range jobs {
+						result[i] = &jobs[i]
+					}
+					return result, nil
+				},
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: bpg.mutation(bookType, bookmarkType),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// bookPage is the BookPage of the novels and comics queries.
+type bookPage struct {
+	Items      []*book `json:"items"`
+	Total      int64   `json:"total"`
+	NextCursor string  `json:"nextCursor"`
+}
+
+// listField is the novels or comics query, with the options of the v2
+// book list; `limit` defaults to 20 here.
+func (bpg *BlueprintGraphQL) listField(bookPageType *graphql.Object, bookType string) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewNonNull(bookPageType),
+		Args: graphql.FieldConfigArgument{
+			"sort":         &graphql.ArgumentConfig{Type: graphql.String, Description: "title, author, lastUpdate, added or popularity"},
+			"order":        &graphql.ArgumentConfig{Type: graphql.String, Description: "asc or desc"},
+			"source":       &graphql.ArgumentConfig{Type: graphql.String, Description: "Fetcher domain"},
+			"enabled":      &graphql.ArgumentConfig{Type: graphql.Boolean, Description: "Hidden books too, with book:edit only"},
+			"updatedSince": &graphql.ArgumentConfig{Type: graphql.DateTime},
+			"cursor":       &graphql.ArgumentConfig{Type: graphql.String, Description: "The nextCursor of the previous page"},
+			"limit":        &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20, Description: "1-100"},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			req := getRequest(p.Context)
+			query := &entity.ListQuery{Limit: p.Args["limit"].(int)}
+			query.Sort, _ = p.Args["sort"].(string)
+			query.Order, _ = p.Args["order"].(string)
+			query.Source, _ = p.Args["source"].(string)
+			query.Cursor, _ = p.Args["cursor"].(string)
+			query.UpdatedSince, _ = p.Args["updatedSince"].(time.Time)
+			if enabled, ok := p.Args["enabled"].(bool); ok {
+				query.Enabled = &enabled
+			}
+			if query.Limit < 1 || query.Limit > 100 {
+				return nil, silverfish.InvalidArgumentError("Invalid limit")
+			}
+
+			canEdit := req.caller.Can(entity.PermissionBookEdit)
+			result := &bookPage{Items: []*book{}}
+			var page *entity.ListPage
+			if bookType == bookTypeNovel {
+				novels, listPage, err := bpg.novelSer.GetNovels(canEdit, query)
+				if err != nil {
+					return nil, err
+				}
+				for i := range *novels {
+					result.Items = append(result.Items, req.listedNovel(&(*novels)[i]))
+				}
+				page = listPage
+			} else {
+				comics, listPage, err := bpg.comicSer.GetComics(canEdit, query)
+				if err != nil {
+					return nil, err
+				}
+				for i := range *comics {
+					result.Items = append(result.Items, req.listedComic(&(*comics)[i]))
+				}
+				page = listPage
+			}
+			result.Total, result.NextCursor = page.Total, page.NextCursor
+			return result, nil
+		},
+	}
+}
+
+// bookField is the novel or comic query.
+func (bpg *BlueprintGraphQL) bookField(bookObject *graphql.Object, bookType string) *graphql.Field {
+	return &graphql.Field{
+		Type: bookObject,
+		Args: graphql.FieldConfigArgument{
+			"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return bpg.findBook(getRequest(p.Context), bookType, p.Args["id"].(string))
+		},
+	}
+}
@@ -0,0 +1,184 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	middleware "silverfish/router/middleware"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+var (
+	anonymous = new(middleware.Caller)
+	reader    = &middleware.Caller{Session: &entity.Session{Account: "reader"}, Role: entity.RoleReader}
+	// scopeless is a reader's API token granting nothing.
+	scopeless = &middleware.Caller{
+		Session: &entity.Session{Account: "reader", APIToken: &entity.APIToken{Account: "reader"}},
+		Role:    entity.RoleReader,
+	}
+)
+
+type testResult struct {
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// serveAs runs `query` through the real schema as `caller`. The test
+// blueprint has no services, so whatever gets past the checks fails with
+// an internal error.
+func serveAs(t *testing.T, caller *middleware.Caller, method, query, operationName string) (int, *testResult) {
+	t.Helper()
+	bpg := NewBlueprintGraphQL(nil, nil, nil, nil, nil)
+	var r *http.Request
+	if method == http.MethodGet {
+		r = httptest.NewRequest(method, "/graphql?script=original&query="+url.QueryEscape(query)+"&operationName="+operationName, nil)
+	} else {
+		js, _ := json.Marshal(graphQLRequest{Query: query, OperationName: operationName})
+		r = httptest.NewRequest(method, "/graphql?script=original", strings.NewReader(string(js)))
+	}
+	w := httptest.NewRecorder()
+	bpg.serve(w, middleware.WithCaller(r, caller))
+	result := new(testResult)
+	if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, result
+}
+
+func errorCodeOf(result *testResult) string {
+	if len(result.Errors) == 0 {
+		return ""
+	}
+	return result.Errors[0].Extensions.Code
+}
+
+func TestFieldsNeedPermissions(t *testing.T) {
+	cases := []struct {
+		query string
+		// caller lacks what the field needs; anonymous callers are
+		// refused too.
+		caller *middleware.Caller
+	}{
+		{`mutation { addBook(type: NOVEL, url: "https://example.com/1") { id } }`, reader},
+		{`mutation { updateBook(type: NOVEL, id: "1", enabled: false) { id } }`, reader},
+		{`mutation { removeBook(type: COMIC, id: "1") }`, reader},
+		{`mutation { recrawlBook(type: NOVEL, id: "1") { id } }`, reader},
+		{`mutation { recrawlSource(source: "example.com") { id } }`, reader},
+		{`mutation { importBooks(urls: ["https://example.com/1"]) { id } }`, reader},
+		{`mutation { updateBookmark(type: NOVEL, id: "1", lastReadIndex: 0) { bookId } }`, scopeless},
+		{`mutation { removeBookmark(type: NOVEL, id: "1") }`, scopeless},
+		{`{ user(account: "someone") { account email } }`, reader},
+		{`{ jobs { id } }`, reader},
+	}
+	for _, tc := range cases {
+		if _, result := serveAs(t, anonymous, http.MethodPost, tc.query, ""); errorCodeOf(result) != entity.ErrorUnauthenticated {
+			t.Errorf("anonymous %s: got %+v", tc.query, result.Errors)
+		}
+		if _, result := serveAs(t, tc.caller, http.MethodPost, tc.query, ""); errorCodeOf(result) != entity.ErrorForbidden {
+			t.Errorf("%s as %s: got %+v", tc.query, tc.caller.Session.Account, result.Errors)
+		}
+	}
+}
+
+func TestGetRejectsMutations(t *testing.T) {
+	cases := []struct {
+		query, operationName string
+	}{
+		{`mutation { removeBook(type: NOVEL, id: "1") }`, ""},
+		{`query Q { me { account } } mutation M { removeBook(type: NOVEL, id: "1") }`, "M"},
+	}
+	for _, tc := range cases {
+		status, result := serveAs(t, reader, http.MethodGet, tc.query, tc.operationName)
+		if status != http.StatusMethodNotAllowed || errorCodeOf(result) != entity.ErrorInvalidArgument {
+			t.Errorf("GET %s answered %d %+v", tc.query, status, result.Errors)
+		}
+	}
+	// The query of the same document may be run with GET.
+	query := `query Q { user(account: "someone") { account } } mutation M { removeBook(type: NOVEL, id: "1") }`
+	if status, result := serveAs(t, anonymous, http.MethodGet, query, "Q"); status != http.StatusOK || errorCodeOf(result) != entity.ErrorUnauthenticated {
+		t.Errorf("GET of query Q answered %d %+v", status, result.Errors)
+	}
+}
+
+func TestContentOnlyForOneChapter(t *testing.T) {
+	cases := []struct {
+		query string
+		ok    bool
+	}{
+		{`{ novel(id: "1") { chapter(index: 0) { content } } }`, true},
+		{`{ comic(id: "1") { title chapter(index: 0) { title images } } }`, true},
+		{`{ a: novel(id: "1") { chapter(index: 0) { ...text } } } fragment text on Chapter { content }`, true},
+		{`{ novel(id: "1") { chapter(index: 0) { ... on Chapter { content } } } }`, true},
+		{`{ novels(limit: 100) { items { chapters { content } } } }`, false},
+		{`{ novel(id: "1") { chapters { content } } }`, false},
+		{`{ me { bookmarks { chapter { content } } } }`, false},
+		{`{ novel(id: "1") { bookmark { chapter { content } } } }`, false},
+		{`{ comics { items { ...page } } } fragment page on Book { chapter(index: 0) { images } }`, false},
+		{`mutation { recrawlBook(type: NOVEL, id: "1") { chapter(index: 0) { content } } }`, false},
+		{`{ a: novel(id: "1") { chapter(index: 0) { content } } b: novel(id: "1") { chapter(index: 1) { content } } }`, true},
+		{`{ novel(id: "1") { a: chapter(index: 0) { content } b: chapter(index: 1) { content } c: chapter(index: 2) { content }
+			d: chapter(index: 3) { content } e: chapter(index: 4) { content } f: chapter(index: 5) { content } } }`, false},
+	}
+	for _, tc := range cases {
+		document, err := parser.Parse(parser.ParseParams{Source: tc.query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkContent(document, selectedOperations(document, "")); (err == nil) != tc.ok {
+			t.Errorf("%s: got %v", tc.query, err)
+		}
+	}
+
+	// Refused before anything runs.
+	status, result := serveAs(t, reader, http.MethodPost, `{ me { bookmarks { chapter { content } } } }`, "")
+	if status != http.StatusBadRequest || errorCodeOf(result) != entity.ErrorInvalidArgument {
+		t.Errorf("answered %d %+v", status, result.Errors)
+	}
+}
+
+// TestHiddenChaptersForReaders resolves Bookmark.chapter of a hidden
+// novel; titles are looked up the way GetNovelChapterTitles does.
+func TestHiddenChaptersForReaders(t *testing.T) {
+	bpg := NewBlueprintGraphQL(nil, nil, nil, nil, nil)
+	resolve := bpg.schema.Type("Bookmark").(*graphql.Object).Fields()["chapter"].Resolve
+	curator := &middleware.Caller{Session: reader.Session, Permissions: []string{entity.PermissionBookEdit}}
+
+	for _, caller := range []*middleware.Caller{reader, curator} {
+		req := &request{caller: caller, chapters: map[string]*loader{}}
+		req.chapters[bookTypeNovel] = newLoader(fetchChapterTitles(req, func(shouldFetchDisable bool, bookIDs []string) (map[string][]string, error) {
+			if !shouldFetchDisable {
+				return map[string][]string{}, nil
+			}
+			return map[string][]string{"hidden": {"one"}}, nil
+		}))
+		thunk, err := resolve(graphql.ResolveParams{
+			Source:  &bookmark{Type: bookTypeNovel, BookID: "hidden"},
+			Context: context.WithValue(context.Background(), requestKey, req),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := thunk.(func() (interface{}, error))()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if caller == reader && value != nil {
+			t.Errorf("reader got chapter %+v", value)
+		}
+		if caller == curator && value == nil {
+			t.Error("curator got no chapter")
+		}
+	}
+}
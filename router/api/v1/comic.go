@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 
+	common "silverfish/router/api/common"
+	middleware "silverfish/router/middleware"
+	openapi "silverfish/router/openapi"
 	silverfish "silverfish/silverfish"
 	entity "silverfish/silverfish/entity"
 
 	"github.com/gorilla/mux"
-	"github.com/pkg/errors"
 )
 
 // BlueprintComicv1 export
@@ -36,65 +38,113 @@ func NewBlueprintComicv1(
 // RouteRegister export
 func (bpc *BlueprintComicv1) RouteRegister(parentRouter *mux.Router) {
 	router := parentRouter.PathPrefix(bpc.route).Subrouter()
-	router.HandleFunc("", bpc.root).Methods("GET", "POST")
-	router.HandleFunc("/", bpc.root).Methods("GET", "POST")
-	router.HandleFunc("/{comicID}", bpc.comic).Methods("GET", "DELETE")
+	router.HandleFunc("", bpc.root).Methods("GET")
+	router.HandleFunc("/", bpc.root).Methods("GET")
+	router.Handle("", middleware.Can(entity.PermissionBookAdd, bpc.add)).Methods("POST")
+	router.Handle("/", middleware.Can(entity.PermissionBookAdd, bpc.add)).Methods("POST")
+	router.HandleFunc("/{comicID}", bpc.comic).Methods("GET")
+	router.Handle("/{comicID}", middleware.Can(entity.PermissionBookDelete, bpc.remove)).Methods("DELETE")
 	router.HandleFunc("/{comicID}/chapter/{chapterIndex}", bpc.chapter).Methods("GET")
 }
 
+// Operations export
+func (bpc *BlueprintComicv1) Operations() []openapi.Operation {
+	return openapi.Mount(bpc.route, []openapi.Operation{
+		{
+			Method: "GET", Summary: "List comics",
+			Description: "With `limit` the page is described by the X-Total-Count and X-Next-Cursor headers.",
+			Auth:        openapi.AuthOptional,
+			Query:       append(common.ListParams(), common.ScriptParam()),
+			Response:    []entity.ComicInfo{},
+		},
+		{
+			Method: "POST", Summary: "Add a comic from its source URL",
+			Permission: entity.PermissionBookAdd,
+			Form:       []openapi.Param{openapi.Field("comic_url", "").Require()},
+			Response:   entity.Comic{},
+		},
+		{
+			Method: "GET", Path: "/{comicID}", Summary: "Get a comic with its chapters",
+			Auth:     openapi.AuthOptional,
+			Query:    []openapi.Param{common.ScriptParam()},
+			Response: entity.Comic{},
+		},
+		{
+			Method: "DELETE", Path: "/{comicID}", Summary: "Remove a comic",
+			Permission: entity.PermissionBookDelete,
+		},
+		{
+			Method: "GET", Path: "/{comicID}/chapter/{chapterIndex}", Summary: "Read a chapter",
+			Description: "The chapter's image URLs. Moves the caller's bookmark there when the session may write bookmarks.",
+			Auth:        openapi.AuthOptional,
+			Query:       []openapi.Param{common.ScriptParam()},
+			Response:    []string{},
+		},
+	})
+}
+
 func (bpc *BlueprintComicv1) root(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	params := mux.Vars(r)
-	isAdmin := false
-	sessionToken := r.Header.Get("Authorization")
-	if sessionToken != "" {
-		session, err := bpc.authSer.GetSession(&sessionToken)
-		if err != nil {
-			isAdmin = false
-		} else if accountIsAdmin, _ := bpc.authSer.IsAdmin(session.GetAccount()); accountIsAdmin == true {
-			isAdmin = true
-		}
-	}
+	caller := middleware.GetCaller(r)
+	canEdit := caller.Can(entity.PermissionBookEdit)
+	convert := common.ScriptConverter(common.ResolveScript(r, bpc.userSer, caller.Session))
 
 	switch r.Method {
 	case http.MethodGet:
 		comicID := params["comicID"]
 		if comicID != "" {
 			result, err := bpc.comicSer.GetComicByID(&comicID)
-			if (err != nil && err.Error() == "not found") ||
-				(result != nil && !result.IsEnable && !isAdmin) {
-				w.WriteHeader(http.StatusNotFound)
-			} else {
-				response := entity.NewAPIResponse(result, err)
-				js, _ := json.Marshal(response)
-				w.Write(js)
+			if result != nil && !result.IsEnable && !canEdit {
+				// Hidden books look missing to readers.
+				result, err = nil, silverfish.NotFoundError("Comic not exists")
+			}
+			if result != nil && convert != nil {
+				result.Convert(convert)
 			}
-		} else {
-			result, err := bpc.comicSer.GetComics(isAdmin)
 			response := entity.NewAPIResponse(result, err)
 			js, _ := json.Marshal(response)
+			w.WriteHeader(response.StatusCode())
 			w.Write(js)
-		}
-	case http.MethodPost:
-		response := new(entity.APIResponse)
-		if !isAdmin {
-			response = entity.NewAPIResponse(nil, errors.New("Only Admin allowed"))
 		} else {
-			comicURL := r.FormValue("comic_url")
-			if comicURL != "" {
-				result, err := bpc.comicSer.AddComicByURL(&comicURL)
-				response = entity.NewAPIResponse(result, err)
-			} else {
-				response = entity.NewAPIResponse(nil, errors.New("Field comic_url should not be empty"))
+			var result *[]entity.ComicInfo
+			var page *entity.ListPage
+			query, err := common.ParseListQuery(r)
+			if err == nil {
+				result, page, err = bpc.comicSer.GetComics(canEdit, query)
+			}
+			writeListHeaders(w, page)
+			if err == nil && convert != nil {
+				for i := range *result {
+					(*result)[i].Convert(convert)
+				}
 			}
+			response := entity.NewAPIResponse(result, err)
+			js, _ := json.Marshal(response)
+			w.WriteHeader(response.StatusCode())
+			w.Write(js)
 		}
-		js, _ := json.Marshal(response)
-		w.Write(js)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
+func (bpc *BlueprintComicv1) add(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	comicURL := r.FormValue("comic_url")
+	if comicURL != "" {
+		result, _, err := bpc.comicSer.AddComicByURL(&comicURL)
+		response = entity.NewAPIResponse(result, err)
+	} else {
+		response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field comic_url should not be empty"))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
 func (bpc *BlueprintComicv1) comic(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	params := mux.Vars(r)
@@ -103,45 +153,39 @@ func (bpc *BlueprintComicv1) comic(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 	}
 
-	isAdmin := false
-	sessionToken := r.Header.Get("Authorization")
-	if sessionToken != "" {
-		session, err := bpc.authSer.GetSession(&sessionToken)
-		if err != nil {
-			isAdmin = false
-		} else if accountIsAdmin, _ := bpc.authSer.IsAdmin(session.GetAccount()); accountIsAdmin == true {
-			isAdmin = true
-		}
-	}
+	caller := middleware.GetCaller(r)
+	canEdit := caller.Can(entity.PermissionBookEdit)
+	convert := common.ScriptConverter(common.ResolveScript(r, bpc.userSer, caller.Session))
 
 	switch r.Method {
 	case http.MethodGet:
 		result, err := bpc.comicSer.GetComicByID(&comicID)
-		if (err != nil && err.Error() == "not found") ||
-			(result != nil && !result.IsEnable && !isAdmin) {
-			w.WriteHeader(http.StatusNotFound)
-		} else {
-			response := entity.NewAPIResponse(result, err)
-			js, _ := json.Marshal(response)
-			w.Write(js)
+		if result != nil && !result.IsEnable && !canEdit {
+			// Hidden books look missing to readers.
+			result, err = nil, silverfish.NotFoundError("Comic not exists")
 		}
-	case http.MethodDelete:
-		response := new(entity.APIResponse)
-		if isAdmin == false {
-			response = entity.NewAPIResponse(nil, errors.New("Only Admin allowed"))
-		} else if comicID != "" {
-			err := bpc.comicSer.RemoveComicByID(&comicID)
-			response = entity.NewAPIResponse(nil, err)
-		} else {
-			response = entity.NewAPIResponse(nil, errors.New("Field comicID should not be empty"))
+		if result != nil && convert != nil {
+			result.Convert(convert)
 		}
+		response := entity.NewAPIResponse(result, err)
 		js, _ := json.Marshal(response)
+		w.WriteHeader(response.StatusCode())
 		w.Write(js)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
+func (bpc *BlueprintComicv1) remove(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	comicID := mux.Vars(r)["comicID"]
+
+	response := entity.NewAPIResponse(nil, bpc.comicSer.RemoveComicByID(&comicID))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
 func (bpc *BlueprintComicv1) chapter(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	params := mux.Vars(r)
@@ -150,17 +194,17 @@ func (bpc *BlueprintComicv1) chapter(w http.ResponseWriter, r *http.Request) {
 	if comicID == "" || chapterIndex == "" {
 		w.WriteHeader(http.StatusBadRequest)
 	}
-	sessionToken := r.Header.Get("Authorization")
-	session, _ := bpc.authSer.GetSession(&sessionToken)
+	session := middleware.GetCaller(r).Session
 
 	switch r.Method {
 	case http.MethodGet:
 		result, err := bpc.comicSer.GetComicChapter(&comicID, &chapterIndex)
 		response := entity.NewAPIResponse(result, err)
-		if err == nil && session != nil {
+		if err == nil && session != nil && session.HasScope(entity.ScopeBookmarkWrite) {
 			go bpc.userSer.UpdateBookmark("Comic", &comicID, session.GetAccount(), &chapterIndex)
 		}
 		js, _ := json.Marshal(response)
+		w.WriteHeader(response.StatusCode())
 		w.Write(js)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -0,0 +1,20 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	entity "silverfish/silverfish/entity"
+)
+
+// writeListHeaders exposes the paging state without touching the v1
+// response envelope.
+func writeListHeaders(w http.ResponseWriter, page *entity.ListPage) {
+	if page == nil {
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+}
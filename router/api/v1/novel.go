@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 
+	common "silverfish/router/api/common"
+	middleware "silverfish/router/middleware"
+	openapi "silverfish/router/openapi"
 	silverfish "silverfish/silverfish"
 	entity "silverfish/silverfish/entity"
 
 	"github.com/gorilla/mux"
-	"github.com/pkg/errors"
 )
 
 // BlueprintNovelv1 export
@@ -36,65 +38,113 @@ func NewBlueprintNovelv1(
 // RouteRegister export
 func (bpn *BlueprintNovelv1) RouteRegister(parentRouter *mux.Router) {
 	router := parentRouter.PathPrefix(bpn.route).Subrouter()
-	router.HandleFunc("", bpn.root).Methods("GET", "POST")
-	router.HandleFunc("/", bpn.root).Methods("GET", "POST")
-	router.HandleFunc("/{novelID}", bpn.novel).Methods("GET", "DELETE")
+	router.HandleFunc("", bpn.root).Methods("GET")
+	router.HandleFunc("/", bpn.root).Methods("GET")
+	router.Handle("", middleware.Can(entity.PermissionBookAdd, bpn.add)).Methods("POST")
+	router.Handle("/", middleware.Can(entity.PermissionBookAdd, bpn.add)).Methods("POST")
+	router.HandleFunc("/{novelID}", bpn.novel).Methods("GET")
+	router.Handle("/{novelID}", middleware.Can(entity.PermissionBookDelete, bpn.remove)).Methods("DELETE")
 	router.HandleFunc("/{novelID}/chapter/{chapterIndex}", bpn.chapter).Methods("GET")
 }
 
+// Operations export
+func (bpn *BlueprintNovelv1) Operations() []openapi.Operation {
+	return openapi.Mount(bpn.route, []openapi.Operation{
+		{
+			Method: "GET", Summary: "List novels",
+			Description: "With `limit` the page is described by the X-Total-Count and X-Next-Cursor headers.",
+			Auth:        openapi.AuthOptional,
+			Query:       append(common.ListParams(), common.ScriptParam()),
+			Response:    []entity.NovelInfo{},
+		},
+		{
+			Method: "POST", Summary: "Add a novel from its source URL",
+			Permission: entity.PermissionBookAdd,
+			Form:       []openapi.Param{openapi.Field("novel_url", "").Require()},
+			Response:   entity.Novel{},
+		},
+		{
+			Method: "GET", Path: "/{novelID}", Summary: "Get a novel with its chapters",
+			Auth:     openapi.AuthOptional,
+			Query:    []openapi.Param{common.ScriptParam()},
+			Response: entity.Novel{},
+		},
+		{
+			Method: "DELETE", Path: "/{novelID}", Summary: "Remove a novel",
+			Permission: entity.PermissionBookDelete,
+		},
+		{
+			Method: "GET", Path: "/{novelID}/chapter/{chapterIndex}", Summary: "Read a chapter",
+			Description: "The chapter text. Moves the caller's bookmark there when the session may write bookmarks.",
+			Auth:        openapi.AuthOptional,
+			Query:       []openapi.Param{common.ScriptParam()},
+			Response:    new(string),
+		},
+	})
+}
+
 func (bpn *BlueprintNovelv1) root(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	params := mux.Vars(r)
-	isAdmin := false
-	sessionToken := r.Header.Get("Authorization")
-	if sessionToken != "" {
-		session, err := bpn.authSer.GetSession(&sessionToken)
-		if err != nil {
-			isAdmin = false
-		} else if accountIsAdmin, _ := bpn.authSer.IsAdmin(session.GetAccount()); accountIsAdmin == true {
-			isAdmin = true
-		}
-	}
+	caller := middleware.GetCaller(r)
+	canEdit := caller.Can(entity.PermissionBookEdit)
+	convert := common.ScriptConverter(common.ResolveScript(r, bpn.userSer, caller.Session))
 
 	switch r.Method {
 	case http.MethodGet:
 		novelID := params["novelID"]
 		if novelID != "" {
 			result, err := bpn.novelSer.GetNovelByID(&novelID)
-			if (err != nil && err.Error() == "not found") ||
-				(result != nil && !result.IsEnable && !isAdmin) {
-				w.WriteHeader(http.StatusNotFound)
-			} else {
-				response := entity.NewAPIResponse(result, err)
-				js, _ := json.Marshal(response)
-				w.Write(js)
+			if result != nil && !result.IsEnable && !canEdit {
+				// Hidden books look missing to readers.
+				result, err = nil, silverfish.NotFoundError("Novel not exists")
+			}
+			if result != nil && convert != nil {
+				result.Convert(convert)
 			}
-		} else {
-			result, err := bpn.novelSer.GetNovels(isAdmin)
 			response := entity.NewAPIResponse(result, err)
 			js, _ := json.Marshal(response)
+			w.WriteHeader(response.StatusCode())
 			w.Write(js)
-		}
-	case http.MethodPost:
-		response := new(entity.APIResponse)
-		if !isAdmin {
-			response = entity.NewAPIResponse(nil, errors.New("Only Admin allowed"))
 		} else {
-			novelURL := r.FormValue("novel_url")
-			if novelURL != "" {
-				result, err := bpn.novelSer.AddNovelByURL(&novelURL)
-				response = entity.NewAPIResponse(result, err)
-			} else {
-				response = entity.NewAPIResponse(nil, errors.New("Field novel_url should not be empty"))
+			var result *[]entity.NovelInfo
+			var page *entity.ListPage
+			query, err := common.ParseListQuery(r)
+			if err == nil {
+				result, page, err = bpn.novelSer.GetNovels(canEdit, query)
+			}
+			writeListHeaders(w, page)
+			if err == nil && convert != nil {
+				for i := range *result {
+					(*result)[i].Convert(convert)
+				}
 			}
+			response := entity.NewAPIResponse(result, err)
+			js, _ := json.Marshal(response)
+			w.WriteHeader(response.StatusCode())
+			w.Write(js)
 		}
-		js, _ := json.Marshal(response)
-		w.Write(js)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
+func (bpn *BlueprintNovelv1) add(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	novelURL := r.FormValue("novel_url")
+	if novelURL != "" {
+		result, _, err := bpn.novelSer.AddNovelByURL(&novelURL)
+		response = entity.NewAPIResponse(result, err)
+	} else {
+		response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field novel_url should not be empty"))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
 func (bpn *BlueprintNovelv1) novel(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	params := mux.Vars(r)
@@ -103,45 +153,39 @@ func (bpn *BlueprintNovelv1) novel(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 	}
 
-	isAdmin := false
-	sessionToken := r.Header.Get("Authorization")
-	if sessionToken != "" {
-		session, err := bpn.authSer.GetSession(&sessionToken)
-		if err != nil {
-			isAdmin = false
-		} else if accountIsAdmin, _ := bpn.authSer.IsAdmin(session.GetAccount()); accountIsAdmin == true {
-			isAdmin = true
-		}
-	}
+	caller := middleware.GetCaller(r)
+	canEdit := caller.Can(entity.PermissionBookEdit)
+	convert := common.ScriptConverter(common.ResolveScript(r, bpn.userSer, caller.Session))
 
 	switch r.Method {
 	case http.MethodGet:
 		result, err := bpn.novelSer.GetNovelByID(&novelID)
-		if (err != nil && err.Error() == "not found") ||
-			(result != nil && !result.IsEnable && !isAdmin) {
-			w.WriteHeader(http.StatusNotFound)
-		} else {
-			response := entity.NewAPIResponse(result, err)
-			js, _ := json.Marshal(response)
-			w.Write(js)
+		if result != nil && !result.IsEnable && !canEdit {
+			// Hidden books look missing to readers.
+			result, err = nil, silverfish.NotFoundError("Novel not exists")
 		}
-	case http.MethodDelete:
-		response := new(entity.APIResponse)
-		if isAdmin == false {
-			response = entity.NewAPIResponse(nil, errors.New("Only Admin allowed"))
-		} else if novelID != "" {
-			err := bpn.novelSer.RemoveNovelByID(&novelID)
-			response = entity.NewAPIResponse(nil, err)
-		} else {
-			response = entity.NewAPIResponse(nil, errors.New("Field novelID should not be empty"))
+		if result != nil && convert != nil {
+			result.Convert(convert)
 		}
+		response := entity.NewAPIResponse(result, err)
 		js, _ := json.Marshal(response)
+		w.WriteHeader(response.StatusCode())
 		w.Write(js)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
+func (bpn *BlueprintNovelv1) remove(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	novelID := mux.Vars(r)["novelID"]
+
+	response := entity.NewAPIResponse(nil, bpn.novelSer.RemoveNovelByID(&novelID))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
 func (bpn *BlueprintNovelv1) chapter(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	params := mux.Vars(r)
@@ -150,17 +194,21 @@ func (bpn *BlueprintNovelv1) chapter(w http.ResponseWriter, r *http.Request) {
 	if novelID == "" || chapterIndex == "" {
 		w.WriteHeader(http.StatusBadRequest)
 	}
-	sessionToken := r.Header.Get("Authorization")
-	session, _ := bpn.authSer.GetSession(&sessionToken)
+	session := middleware.GetCaller(r).Session
 
 	switch r.Method {
 	case http.MethodGet:
 		result, err := bpn.novelSer.GetNovelChapter(&novelID, &chapterIndex)
+		if convert := common.ScriptConverter(common.ResolveScript(r, bpn.userSer, session)); err == nil && convert != nil {
+			converted := convert(*result)
+			result = &converted
+		}
 		response := entity.NewAPIResponse(result, err)
-		if err == nil && session != nil {
+		if err == nil && session != nil && session.HasScope(entity.ScopeBookmarkWrite) {
 			go bpn.userSer.UpdateBookmark("Novel", &novelID, session.GetAccount(), &chapterIndex)
 		}
 		js, _ := json.Marshal(response)
+		w.WriteHeader(response.StatusCode())
 		w.Write(js)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
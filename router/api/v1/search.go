@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	common "silverfish/router/api/common"
+	middleware "silverfish/router/middleware"
+	openapi "silverfish/router/openapi"
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/gorilla/mux"
+)
+
+// BlueprintSearchv1 export
+type BlueprintSearchv1 struct {
+	authSer   *silverfish.Auth
+	userSer   *silverfish.User
+	searchSer *silverfish.Search
+	route     string
+}
+
+// NewBlueprintSearchv1 export
+func NewBlueprintSearchv1(
+	authSer *silverfish.Auth,
+	userSer *silverfish.User,
+	searchSer *silverfish.Search,
+) *BlueprintSearchv1 {
+	bps := new(BlueprintSearchv1)
+	bps.authSer = authSer
+	bps.userSer = userSer
+	bps.searchSer = searchSer
+	bps.route = "/search"
+	return bps
+}
+
+// RouteRegister export
+func (bps *BlueprintSearchv1) RouteRegister(parentRouter *mux.Router) {
+	router := parentRouter.PathPrefix(bps.route).Subrouter()
+	router.HandleFunc("", bps.root).Methods("GET")
+	router.HandleFunc("/", bps.root).Methods("GET")
+}
+
+// Operations export
+func (bps *BlueprintSearchv1) Operations() []openapi.Operation {
+	return openapi.Mount(bps.route, []openapi.Operation{
+		{
+			Method: "GET", Summary: "Search novels and comics",
+			Auth: openapi.AuthOptional,
+			Query: []openapi.Param{
+				openapi.Field("q", "Keywords"),
+				openapi.Field("type", "").OneOf("novel", "comic"),
+				openapi.Field("sort", "").OneOf("relevance", "lastCrawlTime"),
+				openapi.Field("source", "Fetcher domain"),
+				openapi.Field("page", "").Typed("integer"),
+				openapi.Field("size", "1-100").Typed("integer"),
+				openapi.Field("enabled", "With book:edit only").OneOf("true", "false", "all"),
+				common.ScriptParam(),
+			},
+			Response: entity.SearchPage{},
+		},
+	})
+}
+
+// root serves `?q=` with optional `type` (novel, comic), `sort`
+// (relevance, lastCrawlTime), `source` (fetcher domain), `page` / `size`
+// and, with book:edit only, `enabled` (true, false, all).
+func (bps *BlueprintSearchv1) root(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	params := r.URL.Query()
+	caller := middleware.GetCaller(r)
+	canEdit := caller.Can(entity.PermissionBookEdit)
+
+	query := &entity.SearchQuery{
+		Keyword: params.Get("q"),
+		Type:    params.Get("type"),
+		Sort:    params.Get("sort"),
+		Source:  params.Get("source"),
+		Page:    1,
+		Size:    20,
+	}
+	if page, err := strconv.Atoi(params.Get("page")); err == nil && page > 0 {
+		query.Page = page
+	}
+	if size, err := strconv.Atoi(params.Get("size")); err == nil && size > 0 && size <= 100 {
+		query.Size = size
+	}
+	enabled := true
+	query.Enabled = &enabled
+	if canEdit {
+		switch params.Get("enabled") {
+		case "false":
+			enabled = false
+		case "all":
+			query.Enabled = nil
+		}
+	}
+
+	result, err := bps.searchSer.Search(query)
+	if convert := common.ScriptConverter(common.ResolveScript(r, bps.userSer, caller.Session)); err == nil && convert != nil {
+		for i := range result.Results {
+			result.Results[i].Title = convert(result.Results[i].Title)
+			result.Results[i].Author = convert(result.Results[i].Author)
+			result.Results[i].Description = convert(result.Results[i].Description)
+		}
+	}
+	response := entity.NewAPIResponse(result, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
@@ -3,6 +3,7 @@ package v1
 import (
 	"encoding/json"
 	"net/http"
+	openapi "silverfish/router/openapi"
 	silverfish "silverfish/silverfish"
 	"strconv"
 
@@ -16,6 +17,7 @@ type BlueprintAPIv1 struct {
 	route   string
 	comic   *BlueprintComicv1
 	novel   *BlueprintNovelv1
+	search  *BlueprintSearchv1
 }
 
 // NewBlueprintAPIv1 export
@@ -24,6 +26,7 @@ func NewBlueprintAPIv1(
 	user *silverfish.User,
 	novel *silverfish.Novel,
 	comic *silverfish.Comic,
+	search *silverfish.Search,
 ) *BlueprintAPIv1 {
 	ba1 := new(BlueprintAPIv1)
 	ba1.auth = auth
@@ -31,6 +34,7 @@ func NewBlueprintAPIv1(
 	ba1.route = "/" + ba1.version
 	ba1.novel = NewBlueprintNovelv1(auth, user, novel)
 	ba1.comic = NewBlueprintComicv1(auth, user, comic)
+	ba1.search = NewBlueprintSearchv1(auth, user, search)
 	return ba1
 }
 
@@ -47,6 +51,18 @@ func (ba1 *BlueprintAPIv1) RouteRegister(parentRouter *mux.Router) {
 
 	ba1.novel.RouteRegister(router)
 	ba1.comic.RouteRegister(router)
+	ba1.search.RouteRegister(router)
+}
+
+// Operations export
+func (ba1 *BlueprintAPIv1) Operations() []openapi.Operation {
+	return openapi.Tag("v1", openapi.Mount(ba1.route, []openapi.Operation{
+		{
+			Method: "GET", Summary: "API version",
+			Bare:     true,
+			Response: openapi.Object{"version": "v1", "Success": "true"},
+		},
+	}, ba1.novel.Operations(), ba1.comic.Operations(), ba1.search.Operations()))
 }
 
 func (ba1 *BlueprintAPIv1) root(w http.ResponseWriter, r *http.Request) {
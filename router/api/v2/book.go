@@ -0,0 +1,331 @@
+package v2
+
+import (
+	"net/http"
+	"strconv"
+
+	common "silverfish/router/api/common"
+	middleware "silverfish/router/middleware"
+	openapi "silverfish/router/openapi"
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/gorilla/mux"
+)
+
+// BlueprintBookv2 export — novels and comics share one set of handlers;
+// the `type` path variable (novels, comics) picks the service.
+type BlueprintBookv2 struct {
+	userSer  *silverfish.User
+	novelSer *silverfish.Novel
+	comicSer *silverfish.Comic
+	route    string
+}
+
+// NewBlueprintBookv2 export
+func NewBlueprintBookv2(
+	userSer *silverfish.User,
+	novelSer *silverfish.Novel,
+	comicSer *silverfish.Comic,
+) *BlueprintBookv2 {
+	bpb := new(BlueprintBookv2)
+	bpb.userSer = userSer
+	bpb.novelSer = novelSer
+	bpb.comicSer = comicSer
+	bpb.route = "/{type:novels|comics}"
+	return bpb
+}
+
+// RouteRegister export
+func (bpb *BlueprintBookv2) RouteRegister(parentRouter *mux.Router) {
+	router := parentRouter.PathPrefix(bpb.route).Subrouter()
+	router.HandleFunc("", bpb.list).Methods("GET")
+	router.Handle("", middleware.Can(entity.PermissionBookAdd, bpb.create)).Methods("POST")
+	router.HandleFunc("/{bookID}", bpb.detail).Methods("GET")
+	router.Handle("/{bookID}", middleware.Can(entity.PermissionBookEdit, bpb.update)).Methods("PATCH")
+	router.Handle("/{bookID}", middleware.Can(entity.PermissionBookDelete, bpb.remove)).Methods("DELETE")
+	router.HandleFunc("/{bookID}/chapters", bpb.chapterList).Methods("GET")
+	router.HandleFunc("/{bookID}/chapters/{chapterIndex:[0-9]+}", bpb.chapter).Methods("GET")
+}
+
+// Operations export
+func (bpb *BlueprintBookv2) Operations() []openapi.Operation {
+	return openapi.Mount("/{type}", withBookType([]openapi.Operation{
+		{
+			Method: "GET", Summary: "List books",
+			Auth:     openapi.AuthOptional,
+			Query:    append(common.ListParams(), common.ScriptParam()),
+			Response: BookList{},
+		},
+		{
+			Method: "POST", Summary: "Add a book from its source URL",
+			Description: "Answers 201 with the crawled book, or 200 with the book already in the library under that URL.",
+			Permission:  entity.PermissionBookAdd,
+			Body:        BookCreate{},
+			Response:    BookDetail{},
+			Status:      http.StatusCreated,
+		},
+		{
+			Method: "GET", Path: "/{bookID}", Summary: "Get a book",
+			Auth:     openapi.AuthOptional,
+			Query:    []openapi.Param{common.ScriptParam()},
+			Response: BookDetail{},
+		},
+		{
+			Method: "PATCH", Path: "/{bookID}", Summary: "Show, hide or override the metadata of a book",
+			Permission: entity.PermissionBookEdit,
+			Body:       BookPatch{},
+			Response:   BookDetail{},
+		},
+		{
+			Method: "DELETE", Path: "/{bookID}", Summary: "Remove a book",
+			Permission: entity.PermissionBookDelete,
+		},
+		{
+			Method: "GET", Path: "/{bookID}/chapters", Summary: "List the chapters of a book",
+			Auth:     openapi.AuthOptional,
+			Query:    []openapi.Param{common.ScriptParam()},
+			Response: []Chapter{},
+		},
+		{
+			Method: "GET", Path: "/{bookID}/chapters/{chapterIndex}", Summary: "Read a chapter",
+			Description: "Moves the caller's bookmark there when the session may write bookmarks.",
+			Auth:        openapi.AuthOptional,
+			Query:       []openapi.Param{common.ScriptParam()},
+			Response:    ChapterContent{},
+		},
+	}))
+}
+
+// withBookType documents the `type` path variable of `operations`.
+func withBookType(operations []openapi.Operation) []openapi.Operation {
+	for i := range operations {
+		operations[i].PathParams = append(operations[i].PathParams, openapi.Field("type", "").OneOf("novels", "comics"))
+	}
+	return operations
+}
+
+// isNovel tells whether the request is about novels rather than comics.
+func isNovel(r *http.Request) bool {
+	return mux.Vars(r)["type"] == "novels"
+}
+
+// bookName is the book type as the services name it in errors and
+// bookmarks.
+func bookName(r *http.Request) string {
+	if isNovel(r) {
+		return "Novel"
+	}
+	return "Comic"
+}
+
+// find loads the book in the caller's script. Hidden books are only
+// found by callers with book:edit.
+func (bpb *BlueprintBookv2) find(r *http.Request, bookID string) (*BookDetail, error) {
+	caller := middleware.GetCaller(r)
+	convert := common.ScriptConverter(common.ResolveScript(r, bpb.userSer, caller.Session))
+	var detail *BookDetail
+	if isNovel(r) {
+		novel, err := bpb.novelSer.GetNovelByID(&bookID)
+		if err != nil {
+			return nil, err
+		}
+		if convert != nil {
+			novel.Convert(convert)
+		}
+		detail = novelDetail(novel)
+	} else {
+		comic, err := bpb.comicSer.GetComicByID(&bookID)
+		if err != nil {
+			return nil, err
+		}
+		if convert != nil {
+			comic.Convert(convert)
+		}
+		detail = comicDetail(comic)
+	}
+	if !detail.Enabled && !caller.Can(entity.PermissionBookEdit) {
+		// Hidden books look missing to readers.
+		return nil, silverfish.NotFoundError("%s not exists", bookName(r))
+	}
+	return detail, nil
+}
+
+// list takes the v1 list options as query parameters, see
+// common.ParseListQuery.
+func (bpb *BlueprintBookv2) list(w http.ResponseWriter, r *http.Request) {
+	caller := middleware.GetCaller(r)
+	canEdit := caller.Can(entity.PermissionBookEdit)
+	convert := common.ScriptConverter(common.ResolveScript(r, bpb.userSer, caller.Session))
+
+	query, err := common.ParseListQuery(r)
+	if err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	result := &BookList{Items: []Book{}}
+	var page *entity.ListPage
+	if isNovel(r) {
+		var novels *[]entity.NovelInfo
+		novels, page, err = bpb.novelSer.GetNovels(canEdit, query)
+		for i := 0; err == nil && i < len(*novels); i++ {
+			if convert != nil {
+				(*novels)[i].Convert(convert)
+			}
+			result.Items = append(result.Items, novelBook(&(*novels)[i]))
+		}
+	} else {
+		var comics *[]entity.ComicInfo
+		comics, page, err = bpb.comicSer.GetComics(canEdit, query)
+		for i := 0; err == nil && i < len(*comics); i++ {
+			if convert != nil {
+				(*comics)[i].Convert(convert)
+			}
+			result.Items = append(result.Items, comicBook(&(*comics)[i]))
+		}
+	}
+	if err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	result.Total, result.NextCursor = page.Total, page.NextCursor
+	writeResponse(w, http.StatusOK, result, nil)
+}
+
+func (bpb *BlueprintBookv2) create(w http.ResponseWriter, r *http.Request) {
+	body := new(BookCreate)
+	if err := decodeBody(r, body); err != nil {
+		writeResponse(w, http.StatusCreated, nil, err)
+		return
+	}
+	if body.URL == "" {
+		writeResponse(w, http.StatusCreated, nil, silverfish.InvalidArgumentError("Field url should not be empty"))
+		return
+	}
+	var detail interface{}
+	var added bool
+	var err error
+	if isNovel(r) {
+		var novel *entity.Novel
+		if novel, added, err = bpb.novelSer.AddNovelByURL(&body.URL); err == nil {
+			detail = novelDetail(novel)
+		}
+	} else {
+		var comic *entity.Comic
+		if comic, added, err = bpb.comicSer.AddComicByURL(&body.URL); err == nil {
+			detail = comicDetail(comic)
+		}
+	}
+	// A book already in the library is returned as it is.
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	writeResponse(w, status, detail, err)
+}
+
+func (bpb *BlueprintBookv2) detail(w http.ResponseWriter, r *http.Request) {
+	detail, err := bpb.find(r, mux.Vars(r)["bookID"])
+	writeResponse(w, http.StatusOK, detail, err)
+}
+
+// update applies a BookPatch: `enabled` shows or hides the book, the
+// metadata fields set curator overrides.
+func (bpb *BlueprintBookv2) update(w http.ResponseWriter, r *http.Request) {
+	bookID := mux.Vars(r)["bookID"]
+	by := middleware.GetCaller(r).Account()
+
+	patch := new(BookPatch)
+	if err := decodeBody(r, patch); err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	fields := patch.overrides()
+	if patch.Enabled == nil && len(fields) == 0 {
+		writeResponse(w, http.StatusOK, nil, silverfish.InvalidArgumentError("Field enabled, title, author, description or coverUrl should be given"))
+		return
+	}
+	var err error
+	if len(fields) > 0 {
+		if isNovel(r) {
+			_, err = bpb.novelSer.UpdateOverrides(&bookID, fields, by)
+		} else {
+			_, err = bpb.comicSer.UpdateOverrides(&bookID, fields, by)
+		}
+	}
+	if err == nil && patch.Enabled != nil {
+		if isNovel(r) {
+			err = bpb.novelSer.SetEnable(&bookID, *patch.Enabled, by)
+		} else {
+			err = bpb.comicSer.SetEnable(&bookID, *patch.Enabled, by)
+		}
+	}
+	if err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	detail, err := bpb.find(r, bookID)
+	writeResponse(w, http.StatusOK, detail, err)
+}
+
+func (bpb *BlueprintBookv2) remove(w http.ResponseWriter, r *http.Request) {
+	bookID := mux.Vars(r)["bookID"]
+	if _, err := bpb.find(r, bookID); err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	if isNovel(r) {
+		writeResponse(w, http.StatusOK, nil, bpb.novelSer.RemoveNovelByID(&bookID))
+	} else {
+		writeResponse(w, http.StatusOK, nil, bpb.comicSer.RemoveComicByID(&bookID))
+	}
+}
+
+func (bpb *BlueprintBookv2) chapterList(w http.ResponseWriter, r *http.Request) {
+	detail, err := bpb.find(r, mux.Vars(r)["bookID"])
+	if err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, detail.Chapters, nil)
+}
+
+// chapter serves one chapter's content and, for callers allowed to,
+// moves their bookmark there like reading it in v1 does.
+func (bpb *BlueprintBookv2) chapter(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	bookID, chapterIndex := params["bookID"], params["chapterIndex"]
+	session := middleware.GetCaller(r).Session
+
+	detail, err := bpb.find(r, bookID)
+	if err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	index, err := strconv.Atoi(chapterIndex)
+	if err != nil || index >= len(detail.Chapters) {
+		writeResponse(w, http.StatusOK, nil, silverfish.NotFoundError("Chapter not exists"))
+		return
+	}
+	result := &ChapterContent{Chapter: detail.Chapters[index]}
+	if isNovel(r) {
+		var content *string
+		content, err = bpb.novelSer.GetNovelChapter(&bookID, &chapterIndex)
+		if err == nil {
+			result.Content = *content
+			if convert := common.ScriptConverter(common.ResolveScript(r, bpb.userSer, session)); convert != nil {
+				result.Content = convert(result.Content)
+			}
+		}
+	} else {
+		result.Images, err = bpb.comicSer.GetComicChapter(&bookID, &chapterIndex)
+	}
+	if err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	if session != nil && session.HasScope(entity.ScopeBookmarkWrite) {
+		go bpb.userSer.UpdateBookmark(bookName(r), &bookID, session.GetAccount(), &chapterIndex)
+	}
+	writeResponse(w, http.StatusOK, result, nil)
+}
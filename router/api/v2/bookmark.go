@@ -0,0 +1,152 @@
+package v2
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	middleware "silverfish/router/middleware"
+	openapi "silverfish/router/openapi"
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/gorilla/mux"
+)
+
+// BlueprintBookmarkv2 export — the caller's own bookmarks.
+type BlueprintBookmarkv2 struct {
+	userSer *silverfish.User
+	book    *BlueprintBookv2
+	route   string
+}
+
+// NewBlueprintBookmarkv2 export
+func NewBlueprintBookmarkv2(
+	userSer *silverfish.User,
+	book *BlueprintBookv2,
+) *BlueprintBookmarkv2 {
+	bpb := new(BlueprintBookmarkv2)
+	bpb.userSer = userSer
+	bpb.book = book
+	bpb.route = "/bookmarks"
+	return bpb
+}
+
+// RouteRegister export
+func (bpb *BlueprintBookmarkv2) RouteRegister(parentRouter *mux.Router) {
+	router := parentRouter.PathPrefix(bpb.route).Subrouter()
+	router.Handle("", middleware.RequireUser(http.HandlerFunc(bpb.list))).Methods("GET")
+	router.Handle("/{type:novels|comics}/{bookID}", middleware.RequireUser(http.HandlerFunc(bpb.put))).Methods("PUT")
+	router.Handle("/{type:novels|comics}/{bookID}", middleware.RequireUser(http.HandlerFunc(bpb.remove))).Methods("DELETE")
+}
+
+// Operations export
+func (bpb *BlueprintBookmarkv2) Operations() []openapi.Operation {
+	return openapi.Mount(bpb.route, []openapi.Operation{
+		{
+			Method: "GET", Summary: "List the caller's bookmarks, most recently read first",
+			Auth:     openapi.AuthUser,
+			Response: []Bookmark{},
+		},
+	}, withBookType([]openapi.Operation{
+		{
+			Method: "PUT", Path: "/{type}/{bookID}", Summary: "Move a bookmark",
+			Auth:     openapi.AuthUser,
+			Body:     BookmarkUpdate{},
+			Response: Bookmark{},
+		},
+		{
+			Method: "DELETE", Path: "/{type}/{bookID}", Summary: "Remove a bookmark",
+			Auth: openapi.AuthUser,
+		},
+	}))
+}
+
+func errMissingScope(scope string) error {
+	return silverfish.ForbiddenError("API token lacks scope %s", scope)
+}
+
+// list serves the bookmarks, most recently read first.
+func (bpb *BlueprintBookmarkv2) list(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetCaller(r).Session
+	if !session.HasScope(entity.ScopeLibraryRead) {
+		writeResponse(w, http.StatusOK, nil, errMissingScope(entity.ScopeLibraryRead))
+		return
+	}
+	bookmark, err := bpb.userSer.GetUserBookmark(session.GetAccount())
+	if err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	result := []Bookmark{}
+	if bookmark != nil {
+		for bookType, entries := range map[string]map[string]*entity.BookmarkEntry{
+			BookTypeNovel: bookmark.Novel,
+			BookTypeComic: bookmark.Comic,
+		} {
+			for bookID, entry := range entries {
+				result = append(result, Bookmark{
+					Type:             bookType,
+					BookID:           bookID,
+					LastReadIndex:    entry.LastReadIndex,
+					LastReadDatetime: entry.LastReadDatetime,
+				})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastReadDatetime.After(result[j].LastReadDatetime)
+	})
+	writeResponse(w, http.StatusOK, result, nil)
+}
+
+// put moves the bookmark to `lastReadIndex`, e.g. to sync reading
+// progress from an e-reader.
+func (bpb *BlueprintBookmarkv2) put(w http.ResponseWriter, r *http.Request) {
+	bookID := mux.Vars(r)["bookID"]
+	session := middleware.GetCaller(r).Session
+	if !session.HasScope(entity.ScopeBookmarkWrite) {
+		writeResponse(w, http.StatusOK, nil, errMissingScope(entity.ScopeBookmarkWrite))
+		return
+	}
+	body := new(BookmarkUpdate)
+	if err := decodeBody(r, body); err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	if body.LastReadIndex == nil {
+		writeResponse(w, http.StatusOK, nil, silverfish.InvalidArgumentError("Field lastReadIndex should not be empty"))
+		return
+	}
+	detail, err := bpb.book.find(r, bookID)
+	if err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	if *body.LastReadIndex < 0 || *body.LastReadIndex >= len(detail.Chapters) {
+		writeResponse(w, http.StatusOK, nil, silverfish.InvalidArgumentError("Invalid lastReadIndex"))
+		return
+	}
+	index := strconv.Itoa(*body.LastReadIndex)
+	if err := bpb.userSer.UpdateBookmark(bookName(r), &bookID, session.GetAccount(), &index); err != nil {
+		writeResponse(w, http.StatusOK, nil, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, &Bookmark{
+		Type:             detail.Type,
+		BookID:           bookID,
+		LastReadIndex:    *body.LastReadIndex,
+		LastReadDatetime: time.Now(),
+	}, nil)
+}
+
+func (bpb *BlueprintBookmarkv2) remove(w http.ResponseWriter, r *http.Request) {
+	bookID := mux.Vars(r)["bookID"]
+	session := middleware.GetCaller(r).Session
+	if !session.HasScope(entity.ScopeBookmarkWrite) {
+		writeResponse(w, http.StatusOK, nil, errMissingScope(entity.ScopeBookmarkWrite))
+		return
+	}
+	writeResponse(w, http.StatusOK, nil, bpb.userSer.RemoveBookmark(bookName(r), &bookID, session.GetAccount()))
+}
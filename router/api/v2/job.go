@@ -0,0 +1,149 @@
+package v2
+
+import (
+	"net/http"
+	"strconv"
+
+	middleware "silverfish/router/middleware"
+	openapi "silverfish/router/openapi"
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/gorilla/mux"
+)
+
+// BlueprintJobv2 export — background jobs: source recrawls and imports.
+type BlueprintJobv2 struct {
+	novelSer    *silverfish.Novel
+	comicSer    *silverfish.Comic
+	jobSer      *silverfish.Jobs
+	importerSer *silverfish.Importer
+	route       string
+}
+
+// NewBlueprintJobv2 export
+func NewBlueprintJobv2(
+	novelSer *silverfish.Novel,
+	comicSer *silverfish.Comic,
+	jobSer *silverfish.Jobs,
+	importerSer *silverfish.Importer,
+) *BlueprintJobv2 {
+	bpj := new(BlueprintJobv2)
+	bpj.novelSer = novelSer
+	bpj.comicSer = comicSer
+	bpj.jobSer = jobSer
+	bpj.importerSer = importerSer
+	bpj.route = "/jobs"
+	return bpj
+}
+
+// RouteRegister export
+func (bpj *BlueprintJobv2) RouteRegister(parentRouter *mux.Router) {
+	router := parentRouter.PathPrefix(bpj.route).Subrouter()
+	router.Handle("", middleware.Can(entity.PermissionBookRecrawl, bpj.list)).Methods("GET")
+	router.Handle("", middleware.RequireUser(http.HandlerFunc(bpj.create))).Methods("POST")
+	router.Handle("/{jobID}", middleware.RequireUser(http.HandlerFunc(bpj.detail))).Methods("GET")
+}
+
+// Operations export
+func (bpj *BlueprintJobv2) Operations() []openapi.Operation {
+	return openapi.Mount(bpj.route, []openapi.Operation{
+		{
+			Method: "GET", Summary: "List the latest jobs",
+			Permission: entity.PermissionBookRecrawl,
+			Query:      []openapi.Param{openapi.Field("limit", "1-500").Typed("integer")},
+			Response:   []entity.Job{},
+		},
+		{
+			Method: "POST", Summary: "Start a job",
+			Description: "Recrawling a source takes book:recrawl, importing book:add.",
+			Auth:        openapi.AuthUser,
+			Body:        JobCreate{},
+			Response:    entity.Job{},
+			Status:      http.StatusAccepted,
+		},
+		{
+			Method: "GET", Path: "/{jobID}", Summary: "Get a job with its per-item results",
+			Description: "Takes book:recrawl, except for the caller's own jobs.",
+			Auth:        openapi.AuthUser,
+			Response:    entity.Job{},
+		},
+	})
+}
+
+// list serves the latest `limit` (1-500, default 50) jobs.
+func (bpj *BlueprintJobv2) list(w http.ResponseWriter, r *http.Request) {
+	limit := int64(50)
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 1 || parsed > 500 {
+			writeResponse(w, http.StatusOK, nil, silverfish.InvalidArgumentError("Invalid limit"))
+			return
+		}
+		limit = parsed
+	}
+	result, err := bpj.jobSer.GetJobs(limit)
+	if err == nil && result == nil {
+		result = []entity.Job{}
+	}
+	writeResponse(w, http.StatusOK, result, err)
+}
+
+// create starts a JobCreate. Recrawling a source takes book:recrawl,
+// importing book:add.
+func (bpj *BlueprintJobv2) create(w http.ResponseWriter, r *http.Request) {
+	caller := middleware.GetCaller(r)
+	body := new(JobCreate)
+	if err := decodeBody(r, body); err != nil {
+		writeResponse(w, http.StatusAccepted, nil, err)
+		return
+	}
+
+	var job *entity.Job
+	var err error
+	switch body.Kind {
+	case entity.JobKindRecrawlSource:
+		switch {
+		case !caller.Can(entity.PermissionBookRecrawl):
+			err = silverfish.ForbiddenError("Permission %s required", entity.PermissionBookRecrawl)
+		case body.Source == "":
+			err = silverfish.InvalidArgumentError("Field source should not be empty")
+		case hasFetcher(bpj.novelSer.GetFetcherNameLists(), body.Source):
+			job, err = bpj.novelSer.RecrawlSource(&body.Source, caller.Account())
+		case hasFetcher(bpj.comicSer.GetFetcherNameLists(), body.Source):
+			job, err = bpj.comicSer.RecrawlSource(&body.Source, caller.Account())
+		default:
+			err = silverfish.NotFoundError("No such fetcher")
+		}
+	case entity.JobKindImport:
+		if !caller.Can(entity.PermissionBookAdd) {
+			err = silverfish.ForbiddenError("Permission %s required", entity.PermissionBookAdd)
+		} else {
+			job, err = bpj.importerSer.Start(body.URLs, caller.Account())
+		}
+	default:
+		err = silverfish.InvalidArgumentError("Unknown kind, should be one of %s, %s", entity.JobKindRecrawlSource, entity.JobKindImport)
+	}
+	writeResponse(w, http.StatusAccepted, job, err)
+}
+
+// detail serves a job to book:recrawl holders and to whoever started it,
+// e.g. a curator following their import.
+func (bpj *BlueprintJobv2) detail(w http.ResponseWriter, r *http.Request) {
+	caller := middleware.GetCaller(r)
+	jobID := mux.Vars(r)["jobID"]
+	job, err := bpj.jobSer.GetJob(&jobID)
+	if err == nil && !caller.Can(entity.PermissionBookRecrawl) && job.CreatedBy != *caller.Account() {
+		job, err = nil, silverfish.ForbiddenError("Permission %s required", entity.PermissionBookRecrawl)
+	}
+	writeResponse(w, http.StatusOK, job, err)
+}
+
+func hasFetcher(names []string, dns string) bool {
+	for _, name := range names {
+		if name == dns {
+			return true
+		}
+	}
+	return false
+}
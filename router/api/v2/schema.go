@@ -0,0 +1,177 @@
+package v2
+
+import (
+	"time"
+
+	entity "silverfish/silverfish/entity"
+)
+
+// Book types, the `type` of a Book and of a Bookmark.
+const (
+	BookTypeNovel = "novel"
+	BookTypeComic = "comic"
+)
+
+// Version export
+type Version struct {
+	Version string `json:"version"`
+}
+
+// Book export — a novel or comic as listed.
+type Book struct {
+	Type               string    `json:"type"`
+	ID                 string    `json:"id"`
+	Title              string    `json:"title"`
+	Author             string    `json:"author"`
+	Description        string    `json:"description"`
+	CoverURL           string    `json:"coverUrl"`
+	Enabled            bool      `json:"enabled"`
+	LastCrawlTime      time.Time `json:"lastCrawlTime"`
+	AddedDatetime      time.Time `json:"addedDatetime"`
+	LastUpdateDatetime time.Time `json:"lastUpdateDatetime"`
+	Popularity         int       `json:"popularity"`
+}
+
+// BookDetail export — a Book with where it comes from and its chapters.
+type BookDetail struct {
+	Book
+	Source    string                `json:"source"`
+	URL       string                `json:"url"`
+	Chapters  []Chapter             `json:"chapters"`
+	Overrides *entity.BookOverrides `json:"overrides,omitempty"`
+}
+
+// BookList export — one page of books; NextCursor is empty on the last.
+type BookList struct {
+	Items      []Book `json:"items"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Chapter export
+type Chapter struct {
+	Index int    `json:"index"`
+	Title string `json:"title"`
+}
+
+// ChapterContent export — a novel chapter has Content, a comic chapter
+// Images.
+type ChapterContent struct {
+	Chapter
+	Content string   `json:"content,omitempty"`
+	Images  []string `json:"images,omitempty"`
+}
+
+// Bookmark export
+type Bookmark struct {
+	Type             string    `json:"type"`
+	BookID           string    `json:"bookID"`
+	LastReadIndex    int       `json:"lastReadIndex"`
+	LastReadDatetime time.Time `json:"lastReadDatetime"`
+}
+
+// BookCreate export — the body of POST /novels and /comics.
+type BookCreate struct {
+	URL string `json:"url"`
+}
+
+// BookPatch export — the body of PATCH on a book. Omitted fields are
+// left alone; an empty metadata field clears its override.
+type BookPatch struct {
+	Enabled     *bool   `json:"enabled"`
+	Title       *string `json:"title"`
+	Author      *string `json:"author"`
+	Description *string `json:"description"`
+	CoverURL    *string `json:"coverUrl"`
+}
+
+// BookmarkUpdate export — the body of PUT on a bookmark.
+type BookmarkUpdate struct {
+	LastReadIndex *int `json:"lastReadIndex"`
+}
+
+// JobCreate export — the body of POST /jobs: a recrawlSource job needs
+// the Source domain, an import job the URLs.
+type JobCreate struct {
+	Kind   string   `json:"kind"`
+	Source string   `json:"source"`
+	URLs   []string `json:"urls"`
+}
+
+// overrides maps the metadata fields set in the patch to their values.
+func (patch *BookPatch) overrides() map[string]string {
+	fields := map[string]string{}
+	for name, value := range map[string]*string{
+		"title":       patch.Title,
+		"author":      patch.Author,
+		"description": patch.Description,
+		"coverUrl":    patch.CoverURL,
+	} {
+		if value != nil {
+			fields[name] = *value
+		}
+	}
+	return fields
+}
+
+func novelBook(info *entity.NovelInfo) Book {
+	return Book{
+		Type:               BookTypeNovel,
+		ID:                 info.NovelID,
+		Title:              info.Title,
+		Author:             info.Author,
+		Description:        info.Description,
+		CoverURL:           info.CoverURL,
+		Enabled:            info.IsEnable,
+		LastCrawlTime:      info.LastCrawlTime,
+		AddedDatetime:      info.AddedDatetime,
+		LastUpdateDatetime: info.LastUpdateDatetime,
+		Popularity:         info.Popularity,
+	}
+}
+
+func comicBook(info *entity.ComicInfo) Book {
+	return Book{
+		Type:               BookTypeComic,
+		ID:                 info.ComicID,
+		Title:              info.Title,
+		Author:             info.Author,
+		Description:        info.Description,
+		CoverURL:           info.CoverURL,
+		Enabled:            info.IsEnable,
+		LastCrawlTime:      info.LastCrawlTime,
+		AddedDatetime:      info.AddedDatetime,
+		LastUpdateDatetime: info.LastUpdateDatetime,
+		Popularity:         info.Popularity,
+	}
+}
+
+func novelDetail(novel *entity.Novel) *BookDetail {
+	info := novel.GetNovelInfo()
+	info.Description = novel.Description
+	detail := &BookDetail{
+		Book:      novelBook(info),
+		Source:    novel.DNS,
+		URL:       novel.URL,
+		Chapters:  make([]Chapter, len(novel.Chapters)),
+		Overrides: novel.Overrides,
+	}
+	for i, chapter := range novel.Chapters {
+		detail.Chapters[i] = Chapter{Index: i, Title: chapter.Title}
+	}
+	return detail
+}
+
+func comicDetail(comic *entity.Comic) *BookDetail {
+	detail := &BookDetail{
+		Book:      comicBook(comic.GetComicInfo()),
+		Source:    comic.DNS,
+		URL:       comic.URL,
+		Chapters:  make([]Chapter, len(comic.Chapters)),
+		Overrides: comic.Overrides,
+	}
+	for i, chapter := range comic.Chapters {
+		detail.Chapters[i] = Chapter{Index: i, Title: chapter.Title}
+	}
+	return detail
+}
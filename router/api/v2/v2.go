@@ -0,0 +1,103 @@
+package v2
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	openapi "silverfish/router/openapi"
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBodySize caps JSON request bodies; an import list is the largest.
+const maxBodySize = 4 << 20
+
+// BlueprintAPIv2 export — the resource-oriented API: JSON bodies in and
+// out, one path per resource and PATCH for partial updates. It shares
+// the services with v1, which stays as it is for the existing frontend.
+type BlueprintAPIv2 struct {
+	version  string
+	route    string
+	book     *BlueprintBookv2
+	bookmark *BlueprintBookmarkv2
+	job      *BlueprintJobv2
+}
+
+// NewBlueprintAPIv2 export
+func NewBlueprintAPIv2(
+	user *silverfish.User,
+	novel *silverfish.Novel,
+	comic *silverfish.Comic,
+	jobs *silverfish.Jobs,
+	importer *silverfish.Importer,
+) *BlueprintAPIv2 {
+	ba2 := new(BlueprintAPIv2)
+	ba2.version = "v2"
+	ba2.route = "/" + ba2.version
+	ba2.book = NewBlueprintBookv2(user, novel, comic)
+	ba2.bookmark = NewBlueprintBookmarkv2(user, ba2.book)
+	ba2.job = NewBlueprintJobv2(novel, comic, jobs, importer)
+	return ba2
+}
+
+// GetVersion export
+func (ba2 *BlueprintAPIv2) GetVersion() string {
+	return ba2.version
+}
+
+// RouteRegister export
+func (ba2 *BlueprintAPIv2) RouteRegister(parentRouter *mux.Router) {
+	router := parentRouter.PathPrefix(ba2.route).Subrouter()
+	router.HandleFunc("", ba2.root).Methods("GET")
+	router.HandleFunc("/", ba2.root).Methods("GET")
+
+	ba2.book.RouteRegister(router)
+	ba2.bookmark.RouteRegister(router)
+	ba2.job.RouteRegister(router)
+}
+
+// Operations export
+func (ba2 *BlueprintAPIv2) Operations() []openapi.Operation {
+	return openapi.Tag("v2", openapi.Mount(ba2.route, []openapi.Operation{
+		{
+			Method: "GET", Summary: "API version",
+			Response: Version{},
+		},
+	}, ba2.book.Operations(), ba2.bookmark.Operations(), ba2.job.Operations()))
+}
+
+func (ba2 *BlueprintAPIv2) root(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, http.StatusOK, &Version{Version: ba2.GetVersion()}, nil)
+}
+
+// writeResponse sends `data` in the usual envelope with `status`, or
+// the error's own status when `err` is set.
+func writeResponse(w http.ResponseWriter, status int, data interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	response := entity.NewAPIResponse(data, err)
+	if err == nil {
+		w.WriteHeader(status)
+	} else {
+		w.WriteHeader(response.StatusCode())
+	}
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+// decodeBody reads the JSON request body into `v`, rejecting unknown
+// fields so typos don't pass silently.
+func decodeBody(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return silverfish.InvalidArgumentError("Request body should not be empty")
+		}
+		return silverfish.InvalidArgumentError("Invalid request body: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package v2
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	middleware "silverfish/router/middleware"
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/gorilla/mux"
+)
+
+func testCaller(permissions []string, scopes ...string) *middleware.Caller {
+	caller := &middleware.Caller{Session: &entity.Session{Account: "tester"}, Permissions: permissions}
+	if len(scopes) > 0 {
+		caller.Session.APIToken = &entity.APIToken{Account: "tester", Scopes: scopes}
+	}
+	return caller
+}
+
+// TestRequestChecks covers what is refused before any service is asked;
+// the services are nil here.
+func TestRequestChecks(t *testing.T) {
+	muxRouter := mux.NewRouter()
+	NewBlueprintAPIv2(nil, nil, nil, nil, nil).RouteRegister(muxRouter)
+	reader := testCaller(nil)
+	editor := testCaller([]string{entity.PermissionBookAdd, entity.PermissionBookEdit, entity.PermissionBookRecrawl})
+	readOnly := testCaller(nil, entity.ScopeLibraryRead)
+	writeOnly := testCaller(nil, entity.ScopeBookmarkWrite)
+
+	cases := []struct {
+		name   string
+		caller *middleware.Caller
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"patch anonymously", nil, "PATCH", "/v2/novels/n", `{"title":"x"}`, http.StatusUnauthorized, entity.ErrorUnauthenticated},
+		{"patch without book:edit", reader, "PATCH", "/v2/novels/n", `{"title":"x"}`, http.StatusForbidden, entity.ErrorForbidden},
+		{"empty patch", editor, "PATCH", "/v2/novels/n", `{}`, http.StatusBadRequest, entity.ErrorInvalidArgument},
+		{"misspelt patch field", editor, "PATCH", "/v2/comics/c", `{"titel":"x"}`, http.StatusBadRequest, entity.ErrorInvalidArgument},
+		{"create without body", editor, "POST", "/v2/novels", ``, http.StatusBadRequest, entity.ErrorInvalidArgument},
+		{"create without url", editor, "POST", "/v2/comics", `{"url":""}`, http.StatusBadRequest, entity.ErrorInvalidArgument},
+		{"bookmarks without library:read", writeOnly, "GET", "/v2/bookmarks", ``, http.StatusForbidden, entity.ErrorForbidden},
+		{"bookmark put without bookmark:write", readOnly, "PUT", "/v2/bookmarks/novels/n", `{"lastReadIndex":1}`, http.StatusForbidden, entity.ErrorForbidden},
+		{"bookmark put without index", reader, "PUT", "/v2/bookmarks/novels/n", `{}`, http.StatusBadRequest, entity.ErrorInvalidArgument},
+		{"bookmark delete without bookmark:write", readOnly, "DELETE", "/v2/bookmarks/comics/c", ``, http.StatusForbidden, entity.ErrorForbidden},
+		{"jobs anonymously", nil, "GET", "/v2/jobs", ``, http.StatusUnauthorized, entity.ErrorUnauthenticated},
+		{"jobs limit too low", editor, "GET", "/v2/jobs?limit=0", ``, http.StatusBadRequest, entity.ErrorInvalidArgument},
+		{"jobs limit too high", editor, "GET", "/v2/jobs?limit=501", ``, http.StatusBadRequest, entity.ErrorInvalidArgument},
+		{"recrawl without book:recrawl", reader, "POST", "/v2/jobs", `{"kind":"recrawlSource","source":"example.com"}`, http.StatusForbidden, entity.ErrorForbidden},
+		{"recrawl without source", editor, "POST", "/v2/jobs", `{"kind":"recrawlSource"}`, http.StatusBadRequest, entity.ErrorInvalidArgument},
+		{"import without book:add", reader, "POST", "/v2/jobs", `{"kind":"import","urls":["https://example.com"]}`, http.StatusForbidden, entity.ErrorForbidden},
+		{"unknown job kind", editor, "POST", "/v2/jobs", `{"kind":"reindex"}`, http.StatusBadRequest, entity.ErrorInvalidArgument},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		if c.caller != nil {
+			r = middleware.WithCaller(r, c.caller)
+		}
+		w := httptest.NewRecorder()
+		muxRouter.ServeHTTP(w, r)
+		var response struct {
+			Fail bool              `json:"fail"`
+			Data map[string]string `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if w.Code != c.status || !response.Fail || response.Data["code"] != c.code {
+			t.Errorf("%s: got %d %s, want %d %s", c.name, w.Code, w.Body.String(), c.status, c.code)
+		}
+	}
+}
+
+func TestRoot(t *testing.T) {
+	muxRouter := mux.NewRouter()
+	NewBlueprintAPIv2(nil, nil, nil, nil, nil).RouteRegister(muxRouter)
+	for _, path := range []string{"/v2", "/v2/"} {
+		w := httptest.NewRecorder()
+		muxRouter.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"version":"v2"`) {
+			t.Errorf("%s: got %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestWriteResponse(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{nil, http.StatusCreated},
+		{silverfish.NotFoundError("Novel not exists"), http.StatusNotFound},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		writeResponse(w, http.StatusCreated, &Version{Version: "v2"}, c.err)
+		if w.Code != c.status || w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%v: got %d %s", c.err, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	cases := []struct {
+		body string
+		ok   bool
+	}{
+		{`{"lastReadIndex":3}`, true},
+		{``, false},
+		{`{"lastReadIndex":"3"}`, false},
+		{`{"lastReadIndex":3,"extra":1}`, false},
+		{`[`, false},
+	}
+	for _, c := range cases {
+		body := new(BookmarkUpdate)
+		err := decodeBody(httptest.NewRequest("PUT", "/", strings.NewReader(c.body)), body)
+		if (err == nil) != c.ok {
+			t.Errorf("%q: got %v", c.body, err)
+		}
+		if c.ok && (body.LastReadIndex == nil || *body.LastReadIndex != 3) {
+			t.Errorf("%q: decoded %+v", c.body, body)
+		}
+	}
+}
+
+func TestBookPatchOverrides(t *testing.T) {
+	title, empty := "New title", ""
+	enabled := true
+	patch := &BookPatch{Enabled: &enabled, Title: &title, CoverURL: &empty}
+	want := map[string]string{"title": "New title", "coverUrl": ""}
+	if got := patch.overrides(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := (&BookPatch{Enabled: &enabled}).overrides(); len(got) != 0 {
+		t.Errorf("enabled alone: got %v", got)
+	}
+}
@@ -3,29 +3,44 @@ package router
 import (
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	interf "silverfish/router/interface"
+	middleware "silverfish/router/middleware"
+	openapi "silverfish/router/openapi"
 	"silverfish/silverfish"
 	"silverfish/silverfish/entity"
 
 	"github.com/gorilla/mux"
-	"github.com/sirupsen/logrus"
 )
 
 // BlueprintAuth export
 type BlueprintAuth struct {
-	auth   *silverfish.Auth
-	router interf.IRouter
-	route  string
+	auth     *silverfish.Auth
+	throttle *silverfish.Throttle
+	oidc     *silverfish.OIDC
+	reset    *silverfish.PasswordReset
+	router   interf.IRouter
+	route    string
 }
 
 // NewBlueprintAuth export
 func NewBlueprintAuth(
 	auth *silverfish.Auth,
+	throttle *silverfish.Throttle,
+	oidc *silverfish.OIDC,
+	reset *silverfish.PasswordReset,
 	router interf.IRouter,
 ) *BlueprintAuth {
 	bpa := new(BlueprintAuth)
 	bpa.auth = auth
+	bpa.throttle = throttle
+	bpa.oidc = oidc
+	bpa.reset = reset
 	bpa.route = "/auth"
 	bpa.router = router
 	return bpa
@@ -38,7 +53,162 @@ func (bpa *BlueprintAuth) RouterRegiter(parentRouter *mux.Router) {
 	router.HandleFunc("/register", bpa.register).Methods("POST")
 	router.HandleFunc("/login", bpa.login).Methods("POST")
 	router.HandleFunc("/logout", bpa.logout).Methods("GET")
-	router.HandleFunc("/isAdmin", bpa.isAdmin).Methods("GET")
+	router.Handle("/isAdmin", middleware.RequireUser(http.HandlerFunc(bpa.isAdmin))).Methods("GET")
+	router.Handle("/permissions", middleware.RequireUser(http.HandlerFunc(bpa.permissions))).Methods("GET")
+	router.HandleFunc("/captcha", bpa.captcha).Methods("GET")
+	router.Handle("/sessions", middleware.RequireLogin(http.HandlerFunc(bpa.sessionList))).Methods("GET")
+	router.Handle("/sessions", middleware.RequireLogin(http.HandlerFunc(bpa.sessionRevokeOthers))).Methods("DELETE")
+	router.Handle("/sessions/{sessionID}", middleware.RequireLogin(http.HandlerFunc(bpa.sessionRevoke))).Methods("DELETE")
+	router.Handle("/tokens", middleware.RequireLogin(http.HandlerFunc(bpa.tokenList))).Methods("GET")
+	router.Handle("/tokens", middleware.RequireLogin(http.HandlerFunc(bpa.tokenCreate))).Methods("POST")
+	router.Handle("/tokens/{tokenID}", middleware.RequireLogin(http.HandlerFunc(bpa.tokenRevoke))).Methods("DELETE")
+	router.Handle("/password", middleware.RequireLogin(http.HandlerFunc(bpa.passwordChange))).Methods("POST")
+	router.HandleFunc("/password/forgot", bpa.passwordForgot).Methods("POST")
+	router.HandleFunc("/password/reset", bpa.passwordReset).Methods("POST")
+	router.HandleFunc("/oidc", bpa.oidcStatus).Methods("GET")
+	router.HandleFunc("/oidc/authorize", bpa.oidcAuthorize).Methods("GET")
+	router.HandleFunc("/oidc/callback", bpa.oidcCallback).Methods("POST")
+}
+
+// Operations export
+func (bpa *BlueprintAuth) Operations() []openapi.Operation {
+	login := openapi.Object{
+		"session": openapi.Object{"id": "", "token": "", "expireDatetime": time.Time{}},
+		"user":    entity.User{},
+	}
+	captcha := []openapi.Param{
+		openapi.Field("captchaToken", "See GET /auth/captcha"),
+		openapi.Field("recaptchaToken", "captchaToken for clients predating pluggable providers"),
+	}
+	return openapi.Tag("auth", openapi.Mount(bpa.route, []openapi.Operation{
+		{
+			Method: "GET", Path: "/status", Summary: "Whether the `Authorization` token is valid",
+			Response: true,
+		},
+		{
+			Method: "POST", Path: "/register", Summary: "Create an account and log in",
+			Form: append([]openapi.Param{
+				openapi.Field("account", "").Require(),
+				openapi.Field("password", "").Require(),
+			}, captcha...),
+			Response: login,
+		},
+		{
+			Method: "POST", Path: "/login", Summary: "Log in",
+			Description: "Repeated failures are throttled with 429 and a Retry-After header.",
+			Form: append([]openapi.Param{
+				openapi.Field("account", "").Require(),
+				openapi.Field("password", "").Require(),
+				openapi.Field("keepLogin", "A week long session instead of an hour").Typed("boolean"),
+			}, captcha...),
+			Response: login,
+		},
+		{
+			Method: "GET", Path: "/logout", Summary: "End the `Authorization` session",
+		},
+		{
+			Method: "GET", Path: "/isAdmin", Summary: "Whether the caller is an admin",
+			Description: "Superseded by /auth/permissions.",
+			Auth:        openapi.AuthUser,
+			Response:    openapi.Object{"isAdmin": true},
+		},
+		{
+			Method: "GET", Path: "/permissions", Summary: "The caller's role and permissions",
+			Auth:     openapi.AuthUser,
+			Response: openapi.Object{"role": "", "permissions": []string{}},
+		},
+		{
+			Method: "GET", Path: "/captcha", Summary: "The captcha provider, and a challenge for proof-of-work",
+			Response: map[string]interface{}{},
+		},
+		{
+			Method: "GET", Path: "/sessions", Summary: "List the account's sessions",
+			Auth:     openapi.AuthLogin,
+			Response: []entity.Session{},
+		},
+		{
+			Method: "DELETE", Path: "/sessions", Summary: "Sign out everywhere but here",
+			Auth:     openapi.AuthLogin,
+			Response: openapi.Object{"revoked": 0},
+		},
+		{
+			Method: "DELETE", Path: "/sessions/{sessionID}", Summary: "Sign a session out",
+			Auth: openapi.AuthLogin,
+		},
+		{
+			Method: "GET", Path: "/tokens", Summary: "List the account's API tokens",
+			Auth:     openapi.AuthLogin,
+			Response: []entity.APIToken{},
+		},
+		{
+			Method: "POST", Path: "/tokens", Summary: "Create an API token",
+			Description: "The token itself is only ever shown in this response.",
+			Auth:        openapi.AuthLogin,
+			Form: []openapi.Param{
+				openapi.Field("name", "").Require(),
+				openapi.Field("scopes", "Comma separated, of "+strings.Join(entity.APITokenScopes, ", ")).Require(),
+				openapi.Field("expireDays", "Never expires when omitted").Typed("integer"),
+			},
+			Response: openapi.Object{"token": "", "apiToken": entity.APIToken{}},
+		},
+		{
+			Method: "DELETE", Path: "/tokens/{tokenID}", Summary: "Revoke an API token",
+			Auth: openapi.AuthLogin,
+		},
+		{
+			Method: "POST", Path: "/password", Summary: "Change the password",
+			Description: "Signs the account out everywhere except the caller.",
+			Auth:        openapi.AuthLogin,
+			Form: []openapi.Param{
+				openapi.Field("oldPassword", "").Require(),
+				openapi.Field("newPassword", "").Require(),
+			},
+		},
+		{
+			Method: "POST", Path: "/password/forgot", Summary: "Mail a password reset link",
+			Description: "Answers the same whether or not the address belongs to an account.",
+			Form:        append([]openapi.Param{openapi.Field("email", "").Require()}, captcha...),
+		},
+		{
+			Method: "POST", Path: "/password/reset", Summary: "Set a new password with a reset token",
+			Form: []openapi.Param{
+				openapi.Field("token", "").Require(),
+				openapi.Field("newPassword", "").Require(),
+			},
+		},
+		{
+			Method: "GET", Path: "/oidc", Summary: "Whether single sign-on is enabled",
+			Response: openapi.Object{"enabled": true, "issuer": ""},
+		},
+		{
+			Method: "GET", Path: "/oidc/authorize", Summary: "The identity provider URL to send the browser to",
+			Description: "With a login session the identity is linked to that account instead of signing in.",
+			Auth:        openapi.AuthOptional,
+			Response:    openapi.Object{"url": ""},
+		},
+		{
+			Method: "POST", Path: "/oidc/callback", Summary: "Log in with what the identity provider redirected back with",
+			Form: []openapi.Param{
+				openapi.Field("state", "").Require(),
+				openapi.Field("code", "").Require(),
+				openapi.Field("keepLogin", "").Typed("boolean"),
+			},
+			Response: login,
+		},
+	}))
+}
+
+// captcha tells clients which provider to render; for proof-of-work it
+// also hands out a fresh challenge.
+func (bpa *BlueprintAuth) captcha(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	result, err := bpa.router.CaptchaChallenge()
+	response := entity.NewAPIResponse(result, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
 }
 
 func (bpa *BlueprintAuth) status(w http.ResponseWriter, r *http.Request) {
@@ -51,29 +221,28 @@ func (bpa *BlueprintAuth) status(w http.ResponseWriter, r *http.Request) {
 		Data:    result,
 	}
 	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
 	w.Write(js)
 }
 
 func (bpa *BlueprintAuth) register(w http.ResponseWriter, r *http.Request) {
 	account := r.FormValue("account")
 	password := r.FormValue("password")
-	recaptchaToken := r.FormValue("recaptchaToken")
 	w.Header().Set("Content-Type", "application/json")
 
 	response := new(entity.APIResponse)
-	if recaptchaToken == "" {
-		response = entity.NewAPIResponse(nil, errors.New("Missing recaptcha token"))
-	} else if res, err := bpa.router.VerifyRecaptcha(&recaptchaToken); res == false {
-		logrus.Info(err)
-		response = entity.NewAPIResponse(nil, errors.New("Recaptcha verify failed"))
+	if res, err := bpa.router.VerifyCaptcha(r); res == false {
+		response = entity.NewAPIResponse(nil, err)
 	} else {
 		user, err := bpa.auth.Register(false, &account, &password)
 		if err != nil {
 			response = entity.NewAPIResponse(nil, err)
 		} else {
-			session := bpa.auth.InsertSession(user, false)
+			userAgent, clientIP := r.UserAgent(), bpa.router.ClientIP(r)
+			session := bpa.auth.InsertSession(user, false, &userAgent, &clientIP)
 			response = entity.NewAPIResponse(map[string]interface{}{
 				"session": map[string]interface{}{
+					"id":             session.ID,
 					"token":          session.GetToken(),
 					"expireDatetime": session.GetExpireTS(),
 				},
@@ -82,6 +251,7 @@ func (bpa *BlueprintAuth) register(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
 	w.Write(js)
 }
 
@@ -89,22 +259,31 @@ func (bpa *BlueprintAuth) login(w http.ResponseWriter, r *http.Request) {
 	keepLogin := r.FormValue("keepLogin")
 	account := r.FormValue("account")
 	password := r.FormValue("password")
-	recaptchaToken := r.FormValue("recaptchaToken")
+	clientIP := bpa.router.ClientIP(r)
 	w.Header().Set("Content-Type", "application/json")
 
 	response := new(entity.APIResponse)
-	if recaptchaToken == "" {
-		response = entity.NewAPIResponse(nil, errors.New("Missing recaptcha token"))
-	} else if res, err := bpa.router.VerifyRecaptcha(&recaptchaToken); res == false {
-		logrus.Info(err)
-		response = entity.NewAPIResponse(nil, errors.New("Recaptcha verify failed"))
+	if err := bpa.throttle.Check(&account, &clientIP); err != nil {
+		var throttleErr *silverfish.ThrottleError
+		if errors.As(err, &throttleErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttleErr.RetryAfter.Seconds()))))
+		}
+		response = entity.NewAPIResponse(nil, err)
+	} else if res, err := bpa.router.VerifyCaptcha(r); res == false {
+		response = entity.NewAPIResponse(nil, err)
 	} else {
 		user, err := bpa.auth.Login(&account, &password)
 		if err != nil {
+			if isCredentialFailure(err) {
+				bpa.throttle.Fail(&account, &clientIP)
+			}
 			response = entity.NewAPIResponse(nil, err)
 		} else {
-			session := *bpa.auth.InsertSession(user, keepLogin == "true")
+			bpa.throttle.Succeed(&account)
+			userAgent := r.UserAgent()
+			session := *bpa.auth.InsertSession(user, keepLogin == "true", &userAgent, &clientIP)
 			sessionRtn := map[string]interface{}{
+				"id":             session.ID,
 				"token":          session.GetToken(),
 				"expireDatetime": session.GetExpireTS(),
 			}
@@ -116,9 +295,16 @@ func (bpa *BlueprintAuth) login(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
 	w.Write(js)
 }
 
+// isCredentialFailure — only a wrong account or password counts towards the
+// login throttle; a database error isn't guessing.
+func isCredentialFailure(err error) bool {
+	return errors.Is(err, silverfish.ErrAccountNotExists) || errors.Is(err, silverfish.ErrWrongPassword)
+}
+
 func (bpa *BlueprintAuth) logout(w http.ResponseWriter, r *http.Request) {
 	sessionToken := r.Header.Get("Authorization")
 	w.Header().Set("Content-Type", "application/json")
@@ -126,23 +312,253 @@ func (bpa *BlueprintAuth) logout(w http.ResponseWriter, r *http.Request) {
 	bpa.auth.KillSession(&sessionToken)
 	response := entity.NewAPIResponse(nil, nil)
 	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
 	w.Write(js)
 }
 
 func (bpa *BlueprintAuth) isAdmin(w http.ResponseWriter, r *http.Request) {
-	sessionToken := r.Header.Get("Authorization")
 	w.Header().Set("Content-Type", "application/json")
 
-	session, err := bpa.auth.GetSession(&sessionToken)
+	response := entity.NewAPIResponse(map[string]interface{}{
+		"isAdmin": middleware.GetCaller(r).IsAdmin(),
+	}, nil)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// permissions supersedes isAdmin: the caller's role and what it may do.
+func (bpa *BlueprintAuth) permissions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	caller := middleware.GetCaller(r)
+	response := entity.NewAPIResponse(map[string]interface{}{
+		"role":        caller.Role,
+		"permissions": caller.Permissions,
+	}, nil)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAuth) sessionList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	sessions, err := bpa.auth.GetSessions(session.GetAccount())
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == session.ID
+	}
+	response := entity.NewAPIResponse(sessions, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAuth) sessionRevoke(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["sessionID"]
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	response := entity.NewAPIResponse(nil, bpa.auth.RevokeSession(session.GetAccount(), &sessionID))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// sessionRevokeOthers signs the caller out everywhere but here.
+func (bpa *BlueprintAuth) sessionRevokeOthers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	count, err := bpa.auth.RevokeSessions(session.GetAccount(), &session.ID)
+	response := entity.NewAPIResponse(map[string]interface{}{"revoked": count}, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAuth) tokenList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	response := entity.NewAPIResponse(bpa.auth.GetAPITokens(session.GetAccount()))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// tokenCreate takes `name`, comma separated `scopes` and optionally
+// `expireDays`. The token itself is only ever shown in this response.
+func (bpa *BlueprintAuth) tokenCreate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	response := new(entity.APIResponse)
+	name := strings.TrimSpace(r.FormValue("name"))
+	scopes := parseScopes(r.FormValue("scopes"))
+	ttl := time.Duration(0)
+	if expireDays := r.FormValue("expireDays"); expireDays != "" {
+		days, err := strconv.Atoi(expireDays)
+		if err != nil || days < 0 {
+			days = -1
+		}
+		ttl = time.Duration(days) * 24 * time.Hour
+	}
+	if ttl < 0 {
+		response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Invalid expireDays"))
+	} else if apiToken, token, err := bpa.auth.CreateAPIToken(session.GetAccount(), &name, scopes, ttl); err != nil {
+		response = entity.NewAPIResponse(nil, err)
+	} else {
+		response = entity.NewAPIResponse(map[string]interface{}{
+			"token":    token,
+			"apiToken": apiToken,
+		}, nil)
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// parseScopes splits comma separated scopes, skipping blanks; unknown
+// ones are left for CreateAPIToken to refuse.
+func parseScopes(raw string) []string {
+	scopes := []string{}
+	for _, scope := range strings.Split(raw, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func (bpa *BlueprintAuth) tokenRevoke(w http.ResponseWriter, r *http.Request) {
+	tokenID := mux.Vars(r)["tokenID"]
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	response := entity.NewAPIResponse(nil, bpa.auth.RevokeAPIToken(session.GetAccount(), &tokenID))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAuth) oidcStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := entity.NewAPIResponse(map[string]interface{}{
+		"enabled": bpa.oidc.IsEnabled(),
+		"issuer":  bpa.oidc.GetIssuer(),
+	}, nil)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// oidcAuthorize returns the IdP URL to send the browser to. Called with a
+// login session it links the identity to that account instead of
+// signing in.
+func (bpa *BlueprintAuth) oidcAuthorize(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
 	response := new(entity.APIResponse)
-	if err != nil {
+	var linkAccount *string
+	if r.Header.Get("Authorization") != "" {
+		caller := middleware.GetCaller(r)
+		err := caller.Err
+		if err == nil && caller.Session.IsAPIToken() {
+			err = silverfish.ForbiddenError("API tokens are not allowed here")
+		}
+		if err != nil {
+			response = entity.NewAPIResponse(nil, err)
+			js, _ := json.Marshal(response)
+			w.WriteHeader(response.StatusCode())
+			w.Write(js)
+			return
+		}
+		linkAccount = caller.Account()
+	}
+	authURL, err := bpa.oidc.Authorize(linkAccount)
+	response = entity.NewAPIResponse(map[string]interface{}{"url": authURL}, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// oidcCallback takes the `code` and `state` the IdP redirected back with
+// and answers like login.
+func (bpa *BlueprintAuth) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	keepLogin := r.FormValue("keepLogin")
+	state := r.FormValue("state")
+	code := r.FormValue("code")
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	if state == "" || code == "" {
+		response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field state and code should not be empty"))
+	} else if user, err := bpa.oidc.Callback(&state, &code); err != nil {
 		response = entity.NewAPIResponse(nil, err)
 	} else {
-		isAdmin, _ := bpa.auth.IsAdmin(session.GetAccount())
+		userAgent, clientIP := r.UserAgent(), bpa.router.ClientIP(r)
+		session := bpa.auth.InsertSession(user, keepLogin == "true", &userAgent, &clientIP)
 		response = entity.NewAPIResponse(map[string]interface{}{
-			"isAdmin": isAdmin,
+			"session": map[string]interface{}{
+				"id":             session.ID,
+				"token":          session.GetToken(),
+				"expireDatetime": session.GetExpireTS(),
+			},
+			"user": user,
 		}, nil)
 	}
 	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// passwordChange signs the account out everywhere except the caller.
+func (bpa *BlueprintAuth) passwordChange(w http.ResponseWriter, r *http.Request) {
+	oldPassword := r.FormValue("oldPassword")
+	newPassword := r.FormValue("newPassword")
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	err := bpa.auth.ChangePassword(session.GetAccount(), &oldPassword, &newPassword, &session.ID)
+	response := entity.NewAPIResponse(nil, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// passwordForgot mails a reset link; it answers the same whether or not
+// the address belongs to an account.
+func (bpa *BlueprintAuth) passwordForgot(w http.ResponseWriter, r *http.Request) {
+	email := r.FormValue("email")
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	if res, err := bpa.router.VerifyCaptcha(r); res == false {
+		response = entity.NewAPIResponse(nil, err)
+	} else {
+		response = entity.NewAPIResponse(nil, bpa.reset.Request(&email))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpa *BlueprintAuth) passwordReset(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+	newPassword := r.FormValue("newPassword")
+	w.Header().Set("Content-Type", "application/json")
+
+	response := new(entity.APIResponse)
+	if token == "" {
+		response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field token should not be empty"))
+	} else {
+		response = entity.NewAPIResponse(nil, bpa.reset.Reset(&token, &newPassword))
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
 	w.Write(js)
 }
@@ -0,0 +1,44 @@
+package router
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"silverfish/silverfish"
+)
+
+func TestIsCredentialFailure(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{silverfish.ErrAccountNotExists, true},
+		{silverfish.ErrWrongPassword, true},
+		{silverfish.ForbiddenError("Account disabled"), false},
+		{errors.New("server selection timeout"), false},
+	}
+	for _, c := range cases {
+		if got := isCredentialFailure(c.err); got != c.want {
+			t.Errorf("%v: got %t, want %t", c.err, got, c.want)
+		}
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want []string
+	}{
+		{"", []string{}},
+		{"library:read", []string{"library:read"}},
+		{" library:read , bookmark:write,", []string{"library:read", "bookmark:write"}},
+		{",, ,", []string{}},
+		{"admin,unknown", []string{"admin", "unknown"}},
+	}
+	for _, c := range cases {
+		if got := parseScopes(c.raw); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.raw, got, c.want)
+		}
+	}
+}
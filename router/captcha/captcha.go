@@ -0,0 +1,41 @@
+package captcha
+
+import (
+	"errors"
+	"fmt"
+
+	interf "silverfish/router/interface"
+)
+
+// Provider names accepted by New.
+const (
+	ProviderRecaptcha = "recaptcha"
+	ProviderHCaptcha  = "hcaptcha"
+	ProviderTurnstile = "turnstile"
+	ProviderPoW       = "pow"
+	ProviderNone      = "none"
+)
+
+var (
+	errMissingToken = errors.New("Missing captcha token")
+	errVerifyFailed = errors.New("Captcha verify failed")
+)
+
+// New export — `verifyURL` overrides the siteverify endpoint of the
+// hosted providers (empty keeps the vendor's), `difficulty` is the
+// leading zero bits a proof-of-work solution needs.
+func New(provider, secret, verifyURL string, difficulty int) (interf.ICaptchaProvider, error) {
+	switch provider {
+	case ProviderRecaptcha, ProviderHCaptcha, ProviderTurnstile:
+		if secret == "" {
+			return nil, fmt.Errorf("captcha provider %s needs a secret", provider)
+		}
+		return NewSiteVerify(provider, secret, verifyURL), nil
+	case ProviderPoW:
+		return NewProofOfWork(secret, difficulty)
+	case ProviderNone:
+		return NewNone(), nil
+	default:
+		return nil, fmt.Errorf("unknown captcha provider %s", provider)
+	}
+}
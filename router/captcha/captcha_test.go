@@ -0,0 +1,73 @@
+package captcha
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestSiteVerifyAgainstLocalStandIn(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		success := r.PostForm.Get("secret") == "secret" && r.PostForm.Get("response") == "good"
+		codes := []string{}
+		if !success {
+			codes = append(codes, "invalid-input-response")
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": success, "error-codes": codes})
+	}))
+	defer server.Close()
+
+	for _, name := range []string{ProviderRecaptcha, ProviderHCaptcha, ProviderTurnstile} {
+		provider, err := New(name, "secret", server.URL, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := "127.0.0.1"
+		for token, want := range map[string]bool{"good": true, "bad": false, "": false} {
+			if ok, _ := provider.Verify(&token, &ip); ok != want {
+				t.Errorf("%s: Verify(%q) = %v, want %v", name, token, ok, want)
+			}
+		}
+	}
+	if _, err := New(ProviderRecaptcha, "", "", 0); err == nil {
+		t.Error("hosted provider without a secret should be rejected")
+	}
+}
+
+func TestProofOfWork(t *testing.T) {
+	provider, err := New(ProviderPoW, "", "", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, _ := provider.Challenge()
+	prefix := challenge["challenge"].(string) + ":"
+	token := ""
+	for nonce := 0; ; nonce++ {
+		token = prefix + strconv.Itoa(nonce)
+		if leadingZeroBits(sha256.Sum256([]byte(token))) >= 8 {
+			break
+		}
+	}
+	if ok, err := provider.Verify(&token, nil); !ok {
+		t.Fatalf("solved challenge rejected: %v", err)
+	}
+	if ok, _ := provider.Verify(&token, nil); ok {
+		t.Error("replayed solution accepted")
+	}
+	forged := "AAAA.AAAA:1"
+	if ok, _ := provider.Verify(&forged, nil); ok {
+		t.Error("forged challenge accepted")
+	}
+}
+
+func TestNone(t *testing.T) {
+	provider, _ := New(ProviderNone, "", "", 0)
+	empty := ""
+	if ok, _ := provider.Verify(&empty, nil); !ok {
+		t.Error("none should accept everything")
+	}
+}
@@ -0,0 +1,18 @@
+package captcha
+
+// None export — accepts every request, for local and private deployments.
+type None struct{}
+
+// NewNone export
+func NewNone() *None { return new(None) }
+
+// Name export
+func (n *None) Name() string { return ProviderNone }
+
+// Challenge export
+func (n *None) Challenge() (map[string]interface{}, error) {
+	return map[string]interface{}{"provider": ProviderNone}, nil
+}
+
+// Verify export
+func (n *None) Verify(token, remoteIP *string) (bool, error) { return true, nil }
@@ -0,0 +1,132 @@
+package captcha
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+)
+
+const powChallengeTTL = 5 * time.Minute
+
+// ProofOfWork export — a captcha that needs no third party. The server
+// hands out an HMAC-signed challenge; the client searches for a nonce so
+// that sha256("<challenge>:<nonce>") starts with `difficulty` zero bits
+// and sends back "<challenge>:<nonce>" as its token. Challenges are
+// stateless until solved; solved ones are remembered until they expire
+// so a solution can't be replayed.
+type ProofOfWork struct {
+	key        []byte
+	difficulty int
+	mutex      sync.Mutex
+	used       map[string]time.Time
+}
+
+// NewProofOfWork export — without a `secret` a random key is generated,
+// which invalidates outstanding challenges on restart.
+func NewProofOfWork(secret string, difficulty int) (*ProofOfWork, error) {
+	if difficulty < 1 || difficulty > 32 {
+		return nil, errors.New("proof-of-work difficulty should be between 1 and 32")
+	}
+	pow := new(ProofOfWork)
+	pow.key = []byte(secret)
+	if secret == "" {
+		pow.key = make([]byte, 32)
+		if _, err := rand.Read(pow.key); err != nil {
+			return nil, err
+		}
+	}
+	pow.difficulty = difficulty
+	pow.used = map[string]time.Time{}
+	return pow, nil
+}
+
+// Name export
+func (pow *ProofOfWork) Name() string { return ProviderPoW }
+
+// Challenge export
+func (pow *ProofOfWork) Challenge() (map[string]interface{}, error) {
+	payload := make([]byte, 24)
+	if _, err := rand.Read(payload[:16]); err != nil {
+		return nil, err
+	}
+	expire := time.Now().Add(powChallengeTTL)
+	binary.BigEndian.PutUint64(payload[16:], uint64(expire.Unix()))
+	challenge := base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(pow.sign(payload))
+	return map[string]interface{}{
+		"provider":       ProviderPoW,
+		"algorithm":      "sha256",
+		"challenge":      challenge,
+		"difficulty":     pow.difficulty,
+		"expireDatetime": expire,
+	}, nil
+}
+
+func (pow *ProofOfWork) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, pow.key)
+	mac.Write(payload)
+	return mac.Sum(nil)[:16]
+}
+
+// Verify export
+func (pow *ProofOfWork) Verify(token, remoteIP *string) (bool, error) {
+	if *token == "" {
+		return false, errMissingToken
+	}
+	split := strings.LastIndex(*token, ":")
+	if split < 0 {
+		return false, errVerifyFailed
+	}
+	challenge := (*token)[:split]
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 2 {
+		return false, errVerifyFailed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(payload) != 24 {
+		return false, errVerifyFailed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, pow.sign(payload)) {
+		return false, errVerifyFailed
+	}
+	expire := time.Unix(int64(binary.BigEndian.Uint64(payload[16:])), 0)
+	if time.Now().After(expire) {
+		return false, errors.New("Captcha challenge expired")
+	}
+	if leadingZeroBits(sha256.Sum256([]byte(*token))) < pow.difficulty {
+		return false, errVerifyFailed
+	}
+
+	pow.mutex.Lock()
+	defer pow.mutex.Unlock()
+	now := time.Now()
+	for key, expireAt := range pow.used {
+		if now.After(expireAt) {
+			delete(pow.used, key)
+		}
+	}
+	if _, ok := pow.used[challenge]; ok {
+		return false, errVerifyFailed
+	}
+	pow.used[challenge] = expire
+	return true, nil
+}
+
+func leadingZeroBits(sum [32]byte) int {
+	count := 0
+	for _, b := range sum {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
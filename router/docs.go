@@ -0,0 +1,45 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+
+	openapi "silverfish/router/openapi"
+)
+
+// Operations export — every route RouteRegister adds; a test keeps the
+// two in step.
+func (rr *Router) Operations() []openapi.Operation {
+	return openapi.Mount("", openapi.Tag("root", []openapi.Operation{
+		{
+			Method: "GET", Path: "/", Summary: "Health check",
+			Bare:     true,
+			Response: openapi.Object{"Success": true},
+		},
+		{
+			Method: "GET", Path: "/api/openapi.json", Summary: "This document",
+			Bare:     true,
+			Response: openapi.Schema{"type": "object"},
+		},
+		{
+			Method: "GET", Path: "/api/docs", Summary: "Swagger UI for this document",
+			ContentType: "text/html",
+		},
+	}), rr.auth.Operations(), rr.admin.Operations(), rr.user.Operations(), rr.api.Operations())
+}
+
+// OpenAPI export — the OpenAPI 3 document of the whole API.
+func (rr *Router) OpenAPI() *openapi.Document {
+	return openapi.Build("Silverfish", "1.0.0", rr.Operations())
+}
+
+func (rr *Router) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	js, _ := json.Marshal(rr.OpenAPI())
+	w.Write(js)
+}
+
+func (rr *Router) swaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(openapi.SwaggerUI)
+}
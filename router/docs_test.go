@@ -0,0 +1,183 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"silverfish/router/middleware"
+	"silverfish/router/openapi"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	routeVarPattern = regexp.MustCompile(`\{([^}:]+):[^}]*\}`)
+	refPattern      = regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`)
+)
+
+// specPath turns a mux path template into the document's form: no
+// variable patterns, and "/novels/" the same route as "/novels".
+func specPath(template string) string {
+	path := routeVarPattern.ReplaceAllString(template, "{$1}")
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+func newTestRouter() (*Router, *mux.Router) {
+	rr := NewRouter(nil, 0, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	muxRouter := mux.NewRouter()
+	rr.RouteRegister(muxRouter)
+	return rr, muxRouter
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	rr, muxRouter := newTestRouter()
+	doc := rr.OpenAPI()
+
+	registered := map[string]bool{}
+	err := muxRouter.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if err := route.GetError(); err != nil {
+			t.Errorf("route failed to register: %s", err.Error())
+			return nil
+		}
+		if route.GetHandler() == nil {
+			// A PathPrefix subrouter, its routes are walked on their own.
+			return nil
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Routes without a method matcher are documented as GET.
+			methods = []string{"GET"}
+		}
+		for _, method := range methods {
+			path := specPath(template)
+			registered[method+" "+path] = true
+			if !doc.Has(method, path) {
+				t.Errorf("%s %s is registered but missing from the OpenAPI document", method, template)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			if !registered[strings.ToUpper(method)+" "+path] {
+				t.Errorf("%s %s is documented but not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// admitted reports whether `handler` lets `caller` through its
+// middleware. The test router has no services, so a handler that gets
+// that far panics or answers something other than 401 and 403.
+func admitted(handler http.Handler, method string, caller *middleware.Caller) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = true
+		}
+	}()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, middleware.WithCaller(httptest.NewRequest(method, "/", nil), caller))
+	return w.Code != http.StatusUnauthorized && w.Code != http.StatusForbidden
+}
+
+// testCaller is a logged-in caller holding `permissions`, through an
+// API token with every scope when `apiToken`.
+func testCaller(permissions []string, apiToken bool) *middleware.Caller {
+	caller := &middleware.Caller{Session: &entity.Session{Account: "tester"}, Permissions: permissions}
+	if apiToken {
+		caller.Session.APIToken = &entity.APIToken{Account: "tester", Scopes: entity.APITokenScopes}
+	}
+	return caller
+}
+
+// TestOpenAPIAuthMatchesMiddleware runs every route as different callers
+// and checks it admits exactly those its Operation documents.
+func TestOpenAPIAuthMatchesMiddleware(t *testing.T) {
+	rr, muxRouter := newTestRouter()
+	operations := map[string]openapi.Operation{}
+	for _, operation := range rr.Operations() {
+		operations[operation.Method+" "+operation.Path] = operation
+	}
+	everything := []string{
+		entity.PermissionBookAdd, entity.PermissionBookRecrawl, entity.PermissionBookEdit, entity.PermissionBookDelete,
+		entity.PermissionFilterManage, entity.PermissionUserManage, entity.PermissionSystemManage,
+	}
+
+	muxRouter.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		handler := route.GetHandler()
+		template, err := route.GetPathTemplate()
+		if handler == nil || err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"GET"}
+		}
+		for _, method := range methods {
+			operation, ok := operations[method+" "+specPath(template)]
+			if !ok {
+				// Reported by TestOpenAPIDescribesEveryRoute.
+				continue
+			}
+			name := method + " " + template
+			anonymous := admitted(handler, method, new(middleware.Caller))
+			switch {
+			case operation.Permission != "":
+				if anonymous {
+					t.Errorf("%s needs %s but admits anonymous callers", name, operation.Permission)
+				}
+				if admitted(handler, method, testCaller(nil, false)) {
+					t.Errorf("%s needs %s but admits users without it", name, operation.Permission)
+				}
+				if !admitted(handler, method, testCaller([]string{operation.Permission}, false)) {
+					t.Errorf("%s needs %s but refuses users with it", name, operation.Permission)
+				}
+			case operation.Auth == openapi.AuthUser || operation.Auth == openapi.AuthLogin:
+				if anonymous {
+					t.Errorf("%s is documented %s but admits anonymous callers", name, operation.Auth)
+				}
+				if !admitted(handler, method, testCaller(nil, false)) {
+					t.Errorf("%s is documented %s but refuses users", name, operation.Auth)
+				}
+				if token := admitted(handler, method, testCaller(everything, true)); token != (operation.Auth == openapi.AuthUser) {
+					t.Errorf("%s is documented %s but admitting API tokens is %v", name, operation.Auth, token)
+				}
+			default:
+				if !anonymous {
+					t.Errorf("%s is documented public but refuses anonymous callers", name)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func TestOpenAPIReferencesResolve(t *testing.T) {
+	rr, _ := newTestRouter()
+	doc := rr.OpenAPI()
+	js, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, match := range refPattern.FindAllStringSubmatch(string(js), -1) {
+		if _, ok := doc.Components.Schemas[match[1]]; !ok {
+			t.Errorf("schema %s is referenced but not defined", match[1])
+		}
+	}
+}
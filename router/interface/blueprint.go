@@ -3,12 +3,16 @@ package interf
 import (
 	"net/http"
 
+	openapi "silverfish/router/openapi"
+
 	"github.com/gorilla/mux"
 )
 
 // IBlueprint export
 type IBlueprint interface {
 	RouteRegister(*mux.Router)
+	// Operations describes the routes RouteRegister adds.
+	Operations() []openapi.Operation
 }
 
 // IBlueprintAPI export
@@ -0,0 +1,12 @@
+package interf
+
+// ICaptchaProvider export
+type ICaptchaProvider interface {
+	// Name is the provider's config value, e.g. `recaptcha` or `pow`.
+	Name() string
+	// Challenge is what a client needs before it can produce a token.
+	Challenge() (map[string]interface{}, error)
+	// Verify reports whether `token` proves a human (or enough work).
+	// The error is safe to show to the client.
+	Verify(token, remoteIP *string) (bool, error)
+}
@@ -1,6 +1,10 @@
 package interf
 
+import "net/http"
+
 // IRouter export
 type IRouter interface {
-	VerifyRecaptcha(token *string) (bool, error)
+	VerifyCaptcha(r *http.Request) (bool, error)
+	CaptchaChallenge() (map[string]interface{}, error)
+	ClientIP(r *http.Request) string
 }
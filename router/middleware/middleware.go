@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	silverfish "silverfish/silverfish"
+	entity "silverfish/silverfish/entity"
+
+	"github.com/gorilla/mux"
+)
+
+type contextKey int
+
+const callerKey contextKey = 0
+
+// Caller export — who made the request, resolved once per request by
+// Authenticate. A request without a valid `Authorization` header gets a
+// Caller without a session.
+type Caller struct {
+	Session     *entity.Session
+	Role        string
+	Permissions []string
+	// Err is why the `Authorization` header was rejected, if it was.
+	Err error
+}
+
+// IsUser export
+func (c *Caller) IsUser() bool {
+	return c.Session != nil
+}
+
+// IsAdmin export — by role; API tokens without the admin scope are not.
+func (c *Caller) IsAdmin() bool {
+	return c.IsUser() && c.Role == entity.RoleAdmin && c.Session.HasScope(entity.ScopeAdmin)
+}
+
+// Can export
+func (c *Caller) Can(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Account export — nil for anonymous callers.
+func (c *Caller) Account() *string {
+	if !c.IsUser() {
+		return nil
+	}
+	return c.Session.GetAccount()
+}
+
+// GetCaller export
+func GetCaller(r *http.Request) *Caller {
+	if caller, ok := r.Context().Value(callerKey).(*Caller); ok {
+		return caller
+	}
+	return new(Caller)
+}
+
+// WithCaller export — `r` as made by `caller`, as Authenticate would
+// leave it.
+func WithCaller(r *http.Request, caller *Caller) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), callerKey, caller))
+}
+
+// Authenticate export — resolves the `Authorization` header into the
+// request's Caller. It never rejects a request by itself; routes state
+// what they need with RequireUser, RequireLogin or RequirePermission.
+func Authenticate(auth *silverfish.Auth) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			caller := new(Caller)
+			if token := r.Header.Get("Authorization"); token != "" {
+				session, err := auth.GetSession(&token)
+				if err == nil {
+					caller.Role, caller.Permissions, err = auth.GetPermissions(session)
+				}
+				if err != nil {
+					caller.Err = err
+				} else {
+					caller.Session = session
+				}
+			}
+			next.ServeHTTP(w, WithCaller(r, caller))
+		})
+	}
+}
+
+// RequireUser export — answers 401 unless the caller is logged in.
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if caller := GetCaller(r); !caller.IsUser() {
+			WriteError(w, unauthenticated(caller))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireLogin export — RequireUser, but API tokens are refused with 403
+// too, so a leaked token can't mint more tokens or sign its owner out.
+func RequireLogin(next http.Handler) http.Handler {
+	return RequireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetCaller(r).Session.IsAPIToken() {
+			WriteError(w, silverfish.ForbiddenError("API tokens are not allowed here"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// RequirePermission export — answers 401 for anonymous callers and 403
+// unless the caller holds `permission`.
+func RequirePermission(permission string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			caller := GetCaller(r)
+			if !caller.IsUser() {
+				WriteError(w, unauthenticated(caller))
+				return
+			}
+			if !caller.Can(permission) {
+				WriteError(w, silverfish.ForbiddenError("Permission %s required", permission))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func unauthenticated(caller *Caller) error {
+	if caller.Err != nil {
+		return caller.Err
+	}
+	return silverfish.UnauthenticatedError("SessionToken not exists")
+}
+
+// WriteError export — answers with `err` in the usual envelope.
+func WriteError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	response := entity.NewAPIResponse(nil, err)
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+// Can export — `handler` behind RequirePermission(`permission`).
+func Can(permission string, handler http.HandlerFunc) http.Handler {
+	return RequirePermission(permission)(handler)
+}
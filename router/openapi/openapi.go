@@ -0,0 +1,344 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	entity "silverfish/silverfish/entity"
+)
+
+// Auth requirements of an Operation, mirroring the router middleware.
+const (
+	// AuthNone routes ignore the `Authorization` header.
+	AuthNone = ""
+	// AuthOptional routes answer anonymous callers too, but what they
+	// answer depends on who asks, e.g. hidden books for curators.
+	AuthOptional = "optional"
+	// AuthUser routes are behind middleware.RequireUser.
+	AuthUser = "user"
+	// AuthLogin routes are behind middleware.RequireLogin: sessions
+	// only, API tokens are refused.
+	AuthLogin = "login"
+)
+
+// securityScheme names the `Authorization` header in the document.
+const securityScheme = "session"
+
+// Param export — a path, query or form parameter. Type is a JSON schema
+// type (string by default), or `file` for an upload.
+type Param struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Enum        []string
+}
+
+// Field export
+func Field(name, description string) Param {
+	return Param{Name: name, Description: description}
+}
+
+// Typed export — the parameter as another JSON schema type.
+func (p Param) Typed(typ string) Param {
+	p.Type = typ
+	return p
+}
+
+// Require export
+func (p Param) Require() Param {
+	p.Required = true
+	return p
+}
+
+// OneOf export
+func (p Param) OneOf(values ...string) Param {
+	p.Enum = values
+	return p
+}
+
+// Object export — an inline response or body object, property name →
+// sample value whose type gives the property's schema.
+type Object map[string]interface{}
+
+// OneOf export — a response or body that is one of the samples, e.g. a
+// novel or a comic depending on the path.
+type OneOf []interface{}
+
+// Operation export — what the document says about one route. Path uses
+// the OpenAPI `{name}` form; path parameters not listed in PathParams
+// are documented as plain strings.
+type Operation struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Description string
+	Auth        string
+	// Permission, when set, implies AuthUser.
+	Permission string
+	PathParams []Param
+	Query      []Param
+	Form       []Param
+	// Body is a sample of the JSON request body.
+	Body interface{}
+	// Response is a sample of the envelope's `data`; nil for none.
+	Response interface{}
+	// Bare responses are not wrapped in the API envelope.
+	Bare bool
+	// Status is the success status, 200 when zero.
+	Status int
+	// ContentType is set for responses other than JSON.
+	ContentType string
+}
+
+// Mount export — prefixes the operations' paths, like mounting a
+// blueprint's routes under its parent's.
+func Mount(prefix string, groups ...[]Operation) []Operation {
+	operations := []Operation{}
+	for _, group := range groups {
+		for _, operation := range group {
+			operation.Path = prefix + operation.Path
+			operations = append(operations, operation)
+		}
+	}
+	return operations
+}
+
+// Tag export — files the operations without a tag under `tag`.
+func Tag(tag string, operations []Operation) []Operation {
+	for i := range operations {
+		if operations[i].Tag == "" {
+			operations[i].Tag = tag
+		}
+	}
+	return operations
+}
+
+// Document export — an OpenAPI 3 document.
+type Document struct {
+	OpenAPI    string                               `json:"openapi"`
+	Info       map[string]string                    `json:"info"`
+	Paths      map[string]map[string]*pathOperation `json:"paths"`
+	Components components                           `json:"components"`
+}
+
+type components struct {
+	Schemas         map[string]Schema `json:"schemas"`
+	SecuritySchemes map[string]Schema `json:"securitySchemes"`
+}
+
+type pathOperation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []Schema              `json:"parameters,omitempty"`
+	RequestBody Schema                `json:"requestBody,omitempty"`
+	Responses   map[string]Schema     `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Permission  string                `json:"x-permission,omitempty"`
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)\}`)
+
+// Build export — the document describing `operations`.
+func Build(title, version string, operations []Operation) *Document {
+	schemas := newSchemaSet()
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    map[string]string{"title": title, "version": version},
+		Paths:   map[string]map[string]*pathOperation{},
+		Components: components{
+			Schemas: schemas.schemas,
+			SecuritySchemes: map[string]Schema{securityScheme: {
+				"type":        "apiKey",
+				"in":          "header",
+				"name":        "Authorization",
+				"description": "A session token from /auth/login or an API token from /auth/tokens, sent as is.",
+			}},
+		},
+	}
+	schemas.schemas["Error"] = errorSchema()
+	for _, operation := range operations {
+		if doc.Paths[operation.Path] == nil {
+			doc.Paths[operation.Path] = map[string]*pathOperation{}
+		}
+		doc.Paths[operation.Path][strings.ToLower(operation.Method)] = operation.build(schemas)
+	}
+	return doc
+}
+
+// Has export — whether the document describes `method` on `path`.
+func (doc *Document) Has(method, path string) bool {
+	_, ok := doc.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+func (operation *Operation) build(schemas *schemaSet) *pathOperation {
+	result := &pathOperation{
+		Summary:     operation.Summary,
+		Description: operation.Description,
+		Parameters:  []Schema{},
+		Responses:   map[string]Schema{},
+		Permission:  operation.Permission,
+	}
+	if operation.Tag != "" {
+		result.Tags = []string{operation.Tag}
+	}
+
+	for _, match := range pathParamPattern.FindAllStringSubmatch(operation.Path, -1) {
+		param := Field(match[1], "")
+		for _, declared := range operation.PathParams {
+			if declared.Name == param.Name {
+				param = declared
+			}
+		}
+		result.Parameters = append(result.Parameters, param.build("path", true))
+	}
+	for _, param := range operation.Query {
+		result.Parameters = append(result.Parameters, param.build("query", param.Required))
+	}
+
+	if len(operation.Form) > 0 {
+		contentType := "application/x-www-form-urlencoded"
+		properties, required := Schema{}, []string{}
+		for _, param := range operation.Form {
+			if param.Type == "file" {
+				contentType = "multipart/form-data"
+			}
+			properties[param.Name] = param.schema()
+			if param.Required {
+				required = append(required, param.Name)
+			}
+		}
+		body := Schema{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			body["required"] = required
+		}
+		result.RequestBody = Schema{"content": Schema{contentType: Schema{"schema": body}}}
+	} else if operation.Body != nil {
+		result.RequestBody = Schema{
+			"required": true,
+			"content":  Schema{"application/json": Schema{"schema": schemas.of(operation.Body)}},
+		}
+	}
+
+	status := operation.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	var content Schema
+	switch {
+	case operation.Bare && operation.Response == nil:
+		// An empty body.
+	case operation.ContentType != "":
+		content = Schema{operation.ContentType: Schema{"schema": Schema{"type": "string", "format": "binary"}}}
+	case operation.Bare:
+		content = Schema{"application/json": Schema{"schema": schemas.of(operation.Response)}}
+	default:
+		content = Schema{"application/json": Schema{"schema": envelope(schemas.of(operation.Response))}}
+	}
+	result.Responses[strconv.Itoa(status)] = Schema{"description": http.StatusText(status)}
+	if content != nil {
+		result.Responses[strconv.Itoa(status)]["content"] = content
+	}
+	result.Responses["default"] = errorResponse("Failure, see the error code")
+
+	auth := operation.Auth
+	if operation.Permission != "" {
+		auth = AuthUser
+		result.Responses["403"] = errorResponse("Permission " + operation.Permission + " required")
+	}
+	switch auth {
+	case AuthOptional:
+		result.Security = []map[string][]string{{}, {securityScheme: {}}}
+	case AuthUser, AuthLogin:
+		result.Security = []map[string][]string{{securityScheme: {}}}
+		result.Responses["401"] = errorResponse("Not logged in")
+	}
+	if auth == AuthLogin {
+		result.Responses["403"] = errorResponse("API tokens are not allowed here")
+	}
+	return result
+}
+
+func (p Param) schema() Schema {
+	schema := Schema{"type": "string"}
+	switch p.Type {
+	case "", "string":
+	case "file":
+		schema["format"] = "binary"
+	default:
+		schema["type"] = p.Type
+	}
+	if len(p.Enum) > 0 {
+		schema["enum"] = p.Enum
+	}
+	if p.Description != "" {
+		schema["description"] = p.Description
+	}
+	return schema
+}
+
+func (p Param) build(in string, required bool) Schema {
+	param := Schema{"name": p.Name, "in": in, "schema": p.schema()}
+	if required {
+		param["required"] = true
+	}
+	if p.Description != "" {
+		param["description"] = p.Description
+	}
+	return param
+}
+
+// envelope wraps `data` in the entity.APIResponse every JSON handler
+// answers with.
+func envelope(data Schema) Schema {
+	return Schema{
+		"type": "object",
+		"properties": Schema{
+			"success": Schema{"type": "boolean", "enum": []bool{true}},
+			"fail":    Schema{"type": "boolean", "enum": []bool{false}},
+			"data":    data,
+		},
+	}
+}
+
+func errorSchema() Schema {
+	codes := []string{
+		entity.ErrorNotFound,
+		entity.ErrorForbidden,
+		entity.ErrorUnauthenticated,
+		entity.ErrorInvalidArgument,
+		entity.ErrorUpstream,
+		entity.ErrorTooManyRequests,
+		entity.ErrorInternal,
+	}
+	sort.Strings(codes)
+	return Schema{
+		"type": "object",
+		"properties": Schema{
+			"success": Schema{"type": "boolean", "enum": []bool{false}},
+			"fail":    Schema{"type": "boolean", "enum": []bool{true}},
+			"data": Schema{
+				"type": "object",
+				"properties": Schema{
+					"reason": Schema{"type": "string", "description": "Human-readable, may change."},
+					"code":   Schema{"type": "string", "enum": codes, "description": "Stable, branch on this."},
+				},
+			},
+		},
+	}
+}
+
+func errorResponse(description string) Schema {
+	return Schema{
+		"description": description,
+		"content": Schema{"application/json": Schema{
+			"schema": Schema{"$ref": "#/components/schemas/Error"},
+		}},
+	}
+}
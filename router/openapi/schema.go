@@ -0,0 +1,132 @@
+package openapi
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema export — a JSON schema, or any other object of the document.
+type Schema map[string]interface{}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaSet derives schemas from Go types, sharing named structs as
+// components the way encoding/json would marshal them.
+type schemaSet struct {
+	schemas map[string]Schema
+}
+
+func newSchemaSet() *schemaSet {
+	set := new(schemaSet)
+	set.schemas = map[string]Schema{}
+	return set
+}
+
+// of is the schema of the sample `value`; nil stands for `null`.
+func (set *schemaSet) of(value interface{}) Schema {
+	switch value := value.(type) {
+	case nil:
+		return Schema{"nullable": true, "description": "Always null."}
+	case Object:
+		return set.object(value)
+	case OneOf:
+		schemas := []Schema{}
+		for _, sample := range value {
+			schemas = append(schemas, set.of(sample))
+		}
+		return Schema{"oneOf": schemas}
+	case Schema:
+		return value
+	}
+	return set.ofType(reflect.TypeOf(value))
+}
+
+func (set *schemaSet) object(object Object) Schema {
+	properties := Schema{}
+	for name, value := range object {
+		properties[name] = set.of(value)
+	}
+	return Schema{"type": "object", "properties": properties}
+}
+
+func (set *schemaSet) ofType(t reflect.Type) Schema {
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return set.ofType(t.Elem())
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": set.ofType(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": set.ofType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return set.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := set.schemas[name]; !ok {
+			// Reserve the name first, types may refer to themselves.
+			set.schemas[name] = Schema{}
+			set.schemas[name] = set.structSchema(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	}
+	return Schema{}
+}
+
+// structSchema follows encoding/json: `json` tag names, `-` skipped,
+// untagged embedded structs flattened.
+func (set *schemaSet) structSchema(t reflect.Type) Schema {
+	properties := Schema{}
+	set.addFields(t, properties)
+	return Schema{"type": "object", "properties": properties}
+}
+
+func (set *schemaSet) addFields(t reflect.Type, properties Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				set.addFields(embedded, properties)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = set.ofType(field.Type)
+	}
+}
+
+// componentName qualifies the type with its package, as e.g. both the
+// entity and the v2 package have a Bookmark.
+func componentName(t reflect.Type) string {
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
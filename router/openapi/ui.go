@@ -0,0 +1,9 @@
+package openapi
+
+import _ "embed"
+
+// SwaggerUI export — a page rendering the document next to it, at
+// `openapi.json`, with Swagger UI loaded from a CDN.
+//
+//go:embed swagger.html
+var SwaggerUI []byte
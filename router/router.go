@@ -2,50 +2,82 @@ package router
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"net"
 	"net/http"
 	"strings"
 
 	api "silverfish/router/api"
 	interf "silverfish/router/interface"
+	middleware "silverfish/router/middleware"
 	silverfish "silverfish/silverfish"
-	entity "silverfish/silverfish/entity"
 
 	"github.com/gorilla/mux"
-	"github.com/pkg/errors"
+	"github.com/rs/cors"
 )
 
 // Router export
 type Router struct {
-	recaptchaPrivateKey *string
-	auth                *BlueprintAuth
-	admin               *BlueprintAdmin
-	user                *BlueprintUser
-	api                 interf.IBlueprint
+	captcha   interf.ICaptchaProvider
+	proxyHops int
+	authSer   *silverfish.Auth
+	auth      *BlueprintAuth
+	admin     *BlueprintAdmin
+	user      *BlueprintUser
+	api       interf.IBlueprint
 }
 
 // NewRouter export
 func NewRouter(
-	recaptchaPrivateKey *string,
+	captcha interf.ICaptchaProvider,
+	proxyHops int,
 	auth *silverfish.Auth,
 	admin *silverfish.Admin,
 	user *silverfish.User,
 	novel *silverfish.Novel,
 	comic *silverfish.Comic,
+	filter *silverfish.Filter,
+	search *silverfish.Search,
+	throttle *silverfish.Throttle,
+	oidc *silverfish.OIDC,
+	passwordReset *silverfish.PasswordReset,
+	audit *silverfish.Audit,
+	jobs *silverfish.Jobs,
+	importer *silverfish.Importer,
+	backup *silverfish.Backup,
 ) *Router {
 	rr := new(Router)
-	rr.recaptchaPrivateKey = recaptchaPrivateKey
-	rr.auth = NewBlueprintAuth(auth, rr)
-	rr.admin = NewBlueprintAdmin(auth, admin, novel, comic, rr)
+	rr.captcha = captcha
+	rr.proxyHops = proxyHops
+	rr.authSer = auth
+	rr.auth = NewBlueprintAuth(auth, throttle, oidc, passwordReset, rr)
+	rr.admin = NewBlueprintAdmin(auth, admin, novel, comic, filter, search, throttle, passwordReset, audit, jobs, importer, backup, rr)
 	rr.user = NewBlueprintUser(auth, user, rr)
-	rr.api = api.NewBlueprintAPI(auth, user, novel, comic, rr)
+	rr.api = api.NewBlueprintAPI(auth, user, novel, comic, search, jobs, importer, rr)
 	return rr
 }
 
+// CORS export — the cross-origin policy for `origins`: every method and
+// request header the APIs take, and the v1 list paging headers, see
+// api/v1/list.go.
+func (rr *Router) CORS(origins []string, debug bool) *cors.Cors {
+	return cors.New(cors.Options{
+		AllowedOrigins:   origins,
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		ExposedHeaders:   []string{"X-Total-Count", "X-Next-Cursor"},
+		AllowCredentials: false,
+		Debug:            debug,
+	})
+}
+
 // RouteRegister export
 func (rr *Router) RouteRegister(parentRouter *mux.Router) {
 	router := parentRouter
+	router.Use(middleware.Authenticate(rr.authSer))
 	router.HandleFunc("/", rr.root)
+	router.HandleFunc("/api/openapi.json", rr.openAPI).Methods("GET")
+	router.HandleFunc("/api/docs", rr.swaggerUI).Methods("GET")
 
 	rr.auth.RouterRegiter(router)
 	rr.admin.RouteRegister(router)
@@ -53,20 +85,51 @@ func (rr *Router) RouteRegister(parentRouter *mux.Router) {
 	rr.api.RouteRegister(router)
 }
 
-// VerifyRecaptcha export
-func (rr *Router) VerifyRecaptcha(token *string) (bool, error) {
-	url := fmt.Sprintf(
-		"https://www.google.com/recaptcha/api/siteverify?secret=%s&response=%s",
-		*rr.recaptchaPrivateKey,
-		*token)
-	res, err := http.Get(url)
+// VerifyCaptcha export — the token comes from the `captchaToken` form
+// field, or `recaptchaToken` for clients predating pluggable providers.
+func (rr *Router) VerifyCaptcha(r *http.Request) (bool, error) {
+	token := r.FormValue("captchaToken")
+	if token == "" {
+		token = r.FormValue("recaptchaToken")
+	}
+	remoteIP := rr.ClientIP(r)
+	ok, err := rr.captcha.Verify(&token, &remoteIP)
+	if !ok {
+		if err == nil {
+			err = errors.New("Captcha verify failed")
+		}
+		return false, silverfish.ForbiddenError(err.Error())
+	}
+	return true, nil
+}
+
+// ClientIP export — behind `proxyHops` trusted proxies, the
+// X-Forwarded-For entry the outermost of them added; entries left of it
+// come from the client and can be anything. The peer address otherwise.
+func (rr *Router) ClientIP(r *http.Request) string {
+	if rr.proxyHops > 0 {
+		entries := []string{}
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, entry := range strings.Split(header, ",") {
+				if entry = strings.TrimSpace(entry); entry != "" {
+					entries = append(entries, entry)
+				}
+			}
+		}
+		if len(entries) >= rr.proxyHops {
+			return entries[len(entries)-rr.proxyHops]
+		}
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		return false, err
+		return r.RemoteAddr
 	}
-	defer res.Body.Close()
-	result := new(entity.RecaptchaResponse)
-	json.NewDecoder(res.Body).Decode(&result)
-	return result.Success, errors.New(strings.Join(result.ErrorCodes, " , "))
+	return ip
+}
+
+// CaptchaChallenge export
+func (rr *Router) CaptchaChallenge() (map[string]interface{}, error) {
+	return rr.captcha.Challenge()
 }
 
 func (rr *Router) root(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,70 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		name      string
+		proxyHops int
+		forwarded []string
+		want      string
+	}{
+		{"header ignored without a proxy", 0, []string{"203.0.113.9"}, "192.0.2.1"},
+		{"no header", 1, nil, "192.0.2.1"},
+		{"one proxy", 1, []string{"203.0.113.9"}, "203.0.113.9"},
+		{"spoofed entries skipped", 1, []string{"10.0.0.1, 198.51.100.7,203.0.113.9"}, "203.0.113.9"},
+		{"two proxies", 2, []string{"10.0.0.1, 203.0.113.9, 198.51.100.2"}, "203.0.113.9"},
+		{"repeated headers", 2, []string{"10.0.0.1", "203.0.113.9", "198.51.100.2"}, "203.0.113.9"},
+		{"chain shorter than the proxies", 2, []string{"203.0.113.9"}, "192.0.2.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := &Router{proxyHops: tc.proxyHops}
+			r := httptest.NewRequest("POST", "/auth/login", nil)
+			r.RemoteAddr = "192.0.2.1:4321"
+			for _, value := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := rr.ClientIP(r); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	rr := NewRouter(nil, 0, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler := rr.CORS([]string{"https://reader.example.com"}, false).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Total-Count", "42")
+	}))
+
+	// The v2 edits and JSON bodies pass the preflight.
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		r := httptest.NewRequest("OPTIONS", "/api/v2/novels/1", nil)
+		r.Header.Set("Origin", "https://reader.example.com")
+		r.Header.Set("Access-Control-Request-Method", method)
+		r.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if got := w.Header().Get("Access-Control-Allow-Methods"); got != method {
+			t.Errorf("preflight for %s allowed %q", method, got)
+		}
+		if got := strings.ToLower(w.Header().Get("Access-Control-Allow-Headers")); got != "authorization, content-type" {
+			t.Errorf("preflight for %s allowed headers %q", method, got)
+		}
+	}
+
+	// Browsers may read the list paging headers.
+	r := httptest.NewRequest("GET", "/api/v1/novels?limit=20", nil)
+	r.Header.Set("Origin", "https://reader.example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Total-Count, X-Next-Cursor" {
+		t.Errorf("exposed %q", got)
+	}
+}
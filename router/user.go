@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	interf "silverfish/router/interface"
+	middleware "silverfish/router/middleware"
+	openapi "silverfish/router/openapi"
 	silverfish "silverfish/silverfish"
 	entity "silverfish/silverfish/entity"
 
@@ -35,27 +37,129 @@ func NewBlueprintUser(
 
 // RouteRegister export
 func (bpu *BlueprintUser) RouteRegister(parentRouter *mux.Router) {
+	// The old plural path, outside the subrouter: mux paths start with a
+	// slash.
+	parentRouter.Handle(bpu.route+"s/bookmark", middleware.RequireUser(http.HandlerFunc(bpu.bookmark))).Methods("GET")
 	router := parentRouter.PathPrefix(bpu.route).Subrouter()
 	router.HandleFunc("", bpu.root)
 	router.HandleFunc("/", bpu.root)
-	router.HandleFunc("/bookmark", bpu.bookmark).Methods("GET")
-	router.HandleFunc("s/bookmark", bpu.bookmark).Methods("GET")
+	router.Handle("/bookmark", middleware.RequireUser(http.HandlerFunc(bpu.bookmark))).Methods("GET", "POST")
+	router.Handle("/preference", middleware.RequireUser(http.HandlerFunc(bpu.preference))).Methods("GET")
+	router.Handle("/preference", middleware.RequireLogin(http.HandlerFunc(bpu.preference))).Methods("POST")
+	router.Handle("/email", middleware.RequireLogin(http.HandlerFunc(bpu.email))).Methods("POST")
+}
+
+// Operations export
+func (bpu *BlueprintUser) Operations() []openapi.Operation {
+	return openapi.Tag("user", openapi.Mount(bpu.route, []openapi.Operation{
+		{
+			Method: "GET", Summary: "Empty response",
+			Bare: true,
+		},
+		{
+			Method: "GET", Path: "/bookmark", Summary: "The caller's bookmarks",
+			Auth:     openapi.AuthUser,
+			Response: entity.Bookmark{},
+		},
+		{
+			Method: "GET", Path: "s/bookmark", Summary: "The caller's bookmarks",
+			Description: "The same as GET /user/bookmark, kept for older clients.",
+			Auth:        openapi.AuthUser,
+			Response:    entity.Bookmark{},
+		},
+		{
+			Method: "POST", Path: "/bookmark", Summary: "Sync reading progress, e.g. from an e-reader",
+			Auth: openapi.AuthUser,
+			Form: []openapi.Param{
+				openapi.Field("type", "").OneOf("novel", "comic").Require(),
+				openapi.Field("id", "").Require(),
+				openapi.Field("index", "Chapter index").Typed("integer").Require(),
+			},
+		},
+		{
+			Method: "GET", Path: "/preference", Summary: "The caller's preferences",
+			Auth:     openapi.AuthUser,
+			Response: entity.Preference{},
+		},
+		{
+			Method: "POST", Path: "/preference", Summary: "Update the caller's preferences",
+			Auth:     openapi.AuthLogin,
+			Form:     []openapi.Param{openapi.Field("script", "").OneOf("hans", "hant")},
+			Response: entity.Preference{},
+		},
+		{
+			Method: "POST", Path: "/email", Summary: "Set the address password reset mails go to",
+			Auth: openapi.AuthLogin,
+			Form: []openapi.Param{openapi.Field("email", "Empty removes it")},
+		},
+	}))
 }
 
 func (bpu *BlueprintUser) root(w http.ResponseWriter, r *http.Request) {}
 
 func (bpu *BlueprintUser) bookmark(w http.ResponseWriter, r *http.Request) {
-	sessionToken := r.Header.Get("Authorization")
 	w.Header().Set("Content-Type", "application/json")
 
-	session, err := bpu.auth.GetSession(&sessionToken)
+	session := middleware.GetCaller(r).Session
 	response := new(entity.APIResponse)
-	if err != nil {
-		response = entity.NewAPIResponse(nil, err)
+	if r.Method == http.MethodGet {
+		if !session.HasScope(entity.ScopeLibraryRead) {
+			response = entity.NewAPIResponse(nil, errMissingScope(entity.ScopeLibraryRead))
+		} else {
+			result, err := bpu.user.GetUserBookmark(session.GetAccount())
+			response = entity.NewAPIResponse(result, err)
+		}
 	} else {
-		result, err := bpu.user.GetUserBookmark(session.GetAccount())
+		// Syncs reading progress from elsewhere, e.g. an e-reader:
+		// `type` (novel, comic), `id` and chapter `index`.
+		bookID, index := r.FormValue("id"), r.FormValue("index")
+		bookType := map[string]string{"novel": "Novel", "comic": "Comic"}[r.FormValue("type")]
+		if !session.HasScope(entity.ScopeBookmarkWrite) {
+			response = entity.NewAPIResponse(nil, errMissingScope(entity.ScopeBookmarkWrite))
+		} else if bookType == "" || bookID == "" || index == "" {
+			response = entity.NewAPIResponse(nil, silverfish.InvalidArgumentError("Field type, id and index should not be empty"))
+		} else {
+			response = entity.NewAPIResponse(nil, bpu.user.UpdateBookmark(bookType, &bookID, session.GetAccount(), &index))
+		}
+	}
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func (bpu *BlueprintUser) preference(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	response := new(entity.APIResponse)
+	switch {
+	case r.Method == http.MethodGet && !session.HasScope(entity.ScopeLibraryRead):
+		response = entity.NewAPIResponse(nil, errMissingScope(entity.ScopeLibraryRead))
+	case r.Method == http.MethodGet:
+		result, err := bpu.user.GetPreference(session.GetAccount())
+		response = entity.NewAPIResponse(result, err)
+	case r.Method == http.MethodPost:
+		result, err := bpu.user.UpdatePreference(session.GetAccount(), &entity.Preference{
+			Script: r.FormValue("script"),
+		})
 		response = entity.NewAPIResponse(result, err)
 	}
 	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
 	w.Write(js)
 }
+
+func (bpu *BlueprintUser) email(w http.ResponseWriter, r *http.Request) {
+	email := r.FormValue("email")
+	w.Header().Set("Content-Type", "application/json")
+
+	session := middleware.GetCaller(r).Session
+	response := entity.NewAPIResponse(nil, bpu.user.UpdateEmail(session.GetAccount(), &email))
+	js, _ := json.Marshal(response)
+	w.WriteHeader(response.StatusCode())
+	w.Write(js)
+}
+
+func errMissingScope(scope string) error {
+	return silverfish.ForbiddenError("API token lacks scope %s", scope)
+}
@@ -1,17 +1,158 @@
 package silverfish
 
 import (
+	"errors"
+	"regexp"
+
 	"silverfish/silverfish/entity"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Admin export
 type Admin struct {
+	auth    *Auth
 	userInf *entity.MongoInf
 }
 
 // NewAdmin export
-func NewAdmin(userInf *entity.MongoInf) *Admin {
+func NewAdmin(auth *Auth, userInf *entity.MongoInf) *Admin {
 	a := new(Admin)
+	a.auth = auth
 	a.userInf = userInf
 	return a
 }
+
+// userListFields leaves out password hashes and bookmarks.
+var userListFields = bson.M{"password": 0, "bookmark": 0}
+
+// GetUsers export — newest registrations first.
+func (a *Admin) GetUsers(query *entity.UserQuery) (*entity.UserPage, error) {
+	selector := bson.M{}
+	if query.Keyword != "" {
+		pattern := bson.M{"$regex": regexp.QuoteMeta(query.Keyword), "$options": "i"}
+		selector["$or"] = bson.A{bson.M{"account": pattern}, bson.M{"email": pattern}}
+	}
+	if query.Role != "" {
+		if !entity.IsRole(query.Role) {
+			return nil, InvalidArgumentError("Unknown role %s", query.Role)
+		}
+		selector["role"] = query.Role
+	}
+	if query.Disabled != nil {
+		selector["disabled"] = *query.Disabled
+	}
+	total, err := a.userInf.Count(selector)
+	if err != nil {
+		return nil, err
+	}
+	result, err := a.userInf.FindPage(selector, userListFields,
+		bson.D{{Key: "registerDatetime", Value: -1}, {Key: "account", Value: 1}},
+		int64((query.Page-1)*query.Size), int64(query.Size), &[]entity.User{})
+	if err != nil {
+		return nil, err
+	}
+	return &entity.UserPage{
+		Total: total,
+		Page:  query.Page,
+		Size:  query.Size,
+		Users: *result.(*[]entity.User),
+	}, nil
+}
+
+// GetUser export — without the password hash.
+func (a *Admin) GetUser(account *string) (*entity.User, error) {
+	result, err := a.userInf.FindSelectOne(bson.M{"account": *account}, bson.M{"password": 0}, &entity.User{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Account not exists")
+		}
+		return nil, err
+	}
+	user := result.(*entity.User)
+	user.Role = user.GetRole()
+	return user, nil
+}
+
+// CreateUser export — registers `account` without captcha, e.g. on a
+// private instance with registration closed.
+func (a *Admin) CreateUser(account, password *string, role string, by *string) (*entity.User, error) {
+	if *account == "" {
+		return nil, InvalidArgumentError("Field account should not be empty")
+	}
+	if !entity.IsRole(role) {
+		return nil, InvalidArgumentError("Unknown role %s", role)
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+	user, err := a.auth.Register(role == entity.RoleAdmin, account, password)
+	if err != nil {
+		return nil, err
+	}
+	if role == entity.RoleCurator {
+		if err := a.auth.SetRole(account, role, by); err != nil {
+			return nil, err
+		}
+		user.SetRole(role)
+	}
+	logrus.Printf("Account %s (%s) created by %s", *account, role, *by)
+	return user, nil
+}
+
+// SetDisabled export — disabled accounts can't sign in, and disabling
+// one signs it out everywhere and revokes its API tokens.
+func (a *Admin) SetDisabled(account *string, disabled bool, by *string) error {
+	if *account == *by {
+		return ForbiddenError("Admins can't disable themselves")
+	}
+	if _, err := a.GetUser(account); err != nil {
+		return err
+	}
+	if err := a.userInf.Update(bson.M{"account": *account}, bson.M{
+		"$set": bson.M{"disabled": disabled},
+	}); err != nil {
+		return err
+	}
+	if disabled {
+		if err := a.auth.signOut(account); err != nil {
+			return err
+		}
+	}
+	logrus.Printf("Account %s disabled=%t by %s", *account, disabled, *by)
+	return nil
+}
+
+// DeleteUser export — removes the account with its sessions and API
+// tokens.
+func (a *Admin) DeleteUser(account *string, by *string) error {
+	if *account == *by {
+		return ForbiddenError("Admins can't delete themselves")
+	}
+	result, err := a.userInf.RemoveAll(bson.M{"account": *account})
+	if err != nil {
+		return err
+	}
+	if result.(*mongo.DeleteResult).DeletedCount == 0 {
+		return NotFoundError("Account not exists")
+	}
+	if err := a.auth.signOut(account); err != nil {
+		return err
+	}
+	logrus.Printf("Account %s deleted by %s", *account, *by)
+	return nil
+}
+
+// GetUserBookmark export
+func (a *Admin) GetUserBookmark(account *string) (*entity.Bookmark, error) {
+	result, err := a.userInf.FindSelectOne(bson.M{"account": *account}, bson.M{"bookmark": 1}, &entity.User{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Account not exists")
+		}
+		return nil, err
+	}
+	return result.(*entity.User).Bookmark, nil
+}
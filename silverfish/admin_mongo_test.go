@@ -0,0 +1,122 @@
+//go:build mongo
+
+package silverfish
+
+import (
+	"testing"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetUsers(t *testing.T) {
+	db := testDatabase(t)
+	auth, userInf := testAuth(db), testInf(db, "user")
+	admin := NewAdmin(auth, userInf)
+	password := "password"
+	for i, account := range []string{"alice", "bob", "carol"} {
+		auth.Register(account == "alice", &account, &password)
+		userInf.Update(bson.M{"account": account}, bson.M{"$set": bson.M{
+			"registerDatetime": time.Now().Add(time.Duration(i) * time.Minute),
+		}})
+	}
+	userInf.Update(bson.M{"account": "bob"}, bson.M{"$set": bson.M{"email": "bob@example.com", "disabled": true}})
+	disabled, enabled := true, false
+
+	cases := []struct {
+		name  string
+		query entity.UserQuery
+		want  []string
+		total int64
+	}{
+		{"newest first", entity.UserQuery{}, []string{"carol", "bob", "alice"}, 3},
+		{"second page", entity.UserQuery{Page: 2, Size: 2}, []string{"alice"}, 3},
+		{"keyword matches email", entity.UserQuery{Keyword: "EXAMPLE"}, []string{"bob"}, 1},
+		{"keyword is no pattern", entity.UserQuery{Keyword: "a.*"}, []string{}, 0},
+		{"role", entity.UserQuery{Role: entity.RoleAdmin}, []string{"alice"}, 1},
+		{"disabled", entity.UserQuery{Disabled: &disabled}, []string{"bob"}, 1},
+		{"enabled", entity.UserQuery{Disabled: &enabled}, []string{"carol", "alice"}, 2},
+	}
+	for _, c := range cases {
+		if c.query.Page == 0 {
+			c.query.Page, c.query.Size = 1, 10
+		}
+		page, err := admin.GetUsers(&c.query)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		got := []string{}
+		for _, user := range page.Users {
+			if user.Password != "" {
+				t.Errorf("%s: password hash of %s listed", c.name, user.Account)
+			}
+			got = append(got, user.Account)
+		}
+		if page.Total != c.total || len(got) != len(c.want) {
+			t.Errorf("%s: got %v of %d, want %v of %d", c.name, got, page.Total, c.want, c.total)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+	if _, err := admin.GetUsers(&entity.UserQuery{Role: "owner", Page: 1, Size: 10}); errorCode(err) != entity.ErrorInvalidArgument {
+		t.Errorf("unknown role: got %v, want InvalidArgumentError", err)
+	}
+}
+
+func TestSetDisabledAndDeleteUser(t *testing.T) {
+	db := testDatabase(t)
+	auth, userInf := testAuth(db), testInf(db, "user")
+	admin := NewAdmin(auth, userInf)
+	by, password := "admin", "password"
+	for _, account := range []string{"reader", "writer"} {
+		auth.Register(false, &account, &password)
+		user, _ := auth.Login(&account, &password)
+		auth.InsertSession(user, false, new(string), new(string))
+		auth.CreateAPIToken(&account, &account, []string{entity.ScopeLibraryRead}, 0)
+	}
+	signedIn := func(account string) bool {
+		sessions, _ := auth.GetSessions(&account)
+		tokens, _ := auth.GetAPITokens(&account)
+		return len(sessions) > 0 || len(tokens) > 0
+	}
+
+	reader, writer, nobody := "reader", "writer", "nobody"
+	if err := admin.SetDisabled(&reader, true, &by); err != nil {
+		t.Fatal(err)
+	}
+	if signedIn(reader) {
+		t.Error("a disabled account keeps its sessions or API tokens")
+	}
+	if _, err := auth.Login(&reader, &password); errorCode(err) != entity.ErrorForbidden {
+		t.Errorf("login while disabled: got %v, want ForbiddenError", err)
+	}
+	if err := admin.SetDisabled(&reader, false, &by); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Login(&reader, &password); err != nil {
+		t.Errorf("login after enabling: %v", err)
+	}
+	if err := admin.SetDisabled(&nobody, true, &by); errorCode(err) != entity.ErrorNotFound {
+		t.Errorf("disabling an unknown account: got %v, want NotFoundError", err)
+	}
+
+	if err := admin.DeleteUser(&writer, &by); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.GetUser(&writer); errorCode(err) != entity.ErrorNotFound {
+		t.Errorf("deleted account: got %v, want NotFoundError", err)
+	}
+	if signedIn(writer) {
+		t.Error("a deleted account keeps its sessions or API tokens")
+	}
+	if err := admin.DeleteUser(&writer, &by); errorCode(err) != entity.ErrorNotFound {
+		t.Errorf("deleting twice: got %v, want NotFoundError", err)
+	}
+}
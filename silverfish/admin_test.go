@@ -0,0 +1,19 @@
+package silverfish
+
+import (
+	"testing"
+
+	entity "silverfish/silverfish/entity"
+)
+
+func TestAdminSelfActions(t *testing.T) {
+	// Refused before the database is touched.
+	admin := NewAdmin(nil, nil)
+	account := "admin"
+	if err := admin.SetDisabled(&account, true, &account); errorCode(err) != entity.ErrorForbidden {
+		t.Errorf("disabling oneself: got %v, want ForbiddenError", err)
+	}
+	if err := admin.DeleteUser(&account, &account); errorCode(err) != entity.ErrorForbidden {
+		t.Errorf("deleting oneself: got %v, want ForbiddenError", err)
+	}
+}
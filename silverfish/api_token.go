@@ -0,0 +1,123 @@
+package silverfish
+
+import (
+	"sort"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxAPITokensPerAccount = 20
+
+// findAPITokenSession resolves an API token into a session limited to
+// the token's scopes. Expired tokens are removed on sight.
+func (a *Auth) findAPITokenSession(token *string) (*entity.Session, error) {
+	result, err := a.apiTokenInf.FindOne(bson.M{"tokenHash": *SHA256Str(token)}, &entity.APIToken{})
+	if err != nil {
+		return nil, err
+	}
+	apiToken := result.(*entity.APIToken)
+	if apiToken.IsExpired() {
+		a.apiTokenInf.Remove(bson.M{"tokenID": apiToken.ID})
+		return nil, UnauthenticatedError("API token expired")
+	}
+	apiToken.LastUsedDatetime = time.Now()
+	a.apiTokenInf.Update(bson.M{"tokenID": apiToken.ID}, bson.M{
+		"$set": bson.M{"lastUsedDatetime": apiToken.LastUsedDatetime},
+	})
+	return &entity.Session{
+		ID:         "token:" + apiToken.ID,
+		Token:      *token,
+		TokenHash:  apiToken.TokenHash,
+		Account:    apiToken.Account,
+		LoginTS:    apiToken.CreatedDatetime,
+		LastSeenTS: apiToken.LastUsedDatetime,
+		ExpireTS:   apiToken.ExpireDatetime,
+		APIToken:   apiToken,
+	}, nil
+}
+
+// CreateAPIToken export — `ttl` of 0 never expires. The plain token is
+// only returned here; afterwards just its hash is known.
+func (a *Auth) CreateAPIToken(account, name *string, scopes []string, ttl time.Duration) (*entity.APIToken, *string, error) {
+	if *name == "" {
+		return nil, nil, InvalidArgumentError("Field name should not be empty")
+	}
+	if len(scopes) == 0 {
+		return nil, nil, InvalidArgumentError("Field scopes should not be empty")
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, s := range entity.APITokenScopes {
+			known = known || s == scope
+		}
+		if !known {
+			return nil, nil, InvalidArgumentError("Unknown scope %s", scope)
+		}
+		if scope == entity.ScopeAdmin {
+			if role, _ := a.GetRole(account); role != entity.RoleCurator && role != entity.RoleAdmin {
+				return nil, nil, ForbiddenError("Scope admin needs a curator or admin account")
+			}
+		}
+	}
+	count, err := a.apiTokenInf.Count(bson.M{"account": *account})
+	if err != nil {
+		return nil, nil, err
+	}
+	if count >= maxAPITokensPerAccount {
+		return nil, nil, InvalidArgumentError("At most %d API tokens per account", maxAPITokensPerAccount)
+	}
+
+	token := entity.APITokenPrefix + *RandomToken()
+	apiToken := &entity.APIToken{
+		ID:              *RandomStr(24),
+		Name:            *name,
+		Account:         *account,
+		TokenHash:       *SHA256Str(&token),
+		Hint:            token[len(token)-4:],
+		Scopes:          scopes,
+		CreatedDatetime: time.Now(),
+	}
+	if ttl > 0 {
+		apiToken.ExpireDatetime = apiToken.CreatedDatetime.Add(ttl)
+	}
+	if err := a.apiTokenInf.Insert(apiToken); err != nil {
+		return nil, nil, err
+	}
+	return apiToken, &token, nil
+}
+
+// GetAPITokens export — newest first.
+func (a *Auth) GetAPITokens(account *string) ([]entity.APIToken, error) {
+	result, err := a.apiTokenInf.FindAll(bson.M{"account": *account}, &[]entity.APIToken{})
+	if err != nil {
+		return nil, err
+	}
+	tokens := *result.(*[]entity.APIToken)
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedDatetime.After(tokens[j].CreatedDatetime) })
+	return tokens, nil
+}
+
+// RevokeAPIToken export — only tokens of `account` can be revoked.
+func (a *Auth) RevokeAPIToken(account, tokenID *string) error {
+	result, err := a.apiTokenInf.RemoveAll(bson.M{"account": *account, "tokenID": *tokenID})
+	if err != nil {
+		return err
+	}
+	if result.(*mongo.DeleteResult).DeletedCount == 0 {
+		return NotFoundError("API token not exists")
+	}
+	return nil
+}
+
+// IsAdminSession export — an admin account, and for API tokens one with
+// the admin scope.
+func (a *Auth) IsAdminSession(session *entity.Session) (bool, error) {
+	if !session.HasScope(entity.ScopeAdmin) {
+		return false, nil
+	}
+	return a.IsAdmin(session.GetAccount())
+}
@@ -0,0 +1,93 @@
+//go:build mongo
+
+package silverfish
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCreateAPIToken(t *testing.T) {
+	db := testDatabase(t)
+	auth := testAuth(db)
+	reader, admin, password, name, empty := "reader", "admin", "password", "script", ""
+	auth.Register(false, &reader, &password)
+	auth.Register(true, &admin, &password)
+	read := []string{entity.ScopeLibraryRead}
+
+	cases := []struct {
+		name    string
+		account *string
+		tokName *string
+		scopes  []string
+		code    string
+	}{
+		{"no name", &reader, &empty, read, entity.ErrorInvalidArgument},
+		{"no scopes", &reader, &name, []string{}, entity.ErrorInvalidArgument},
+		{"unknown scope", &reader, &name, []string{"library:write"}, entity.ErrorInvalidArgument},
+		{"admin scope of a reader", &reader, &name, []string{entity.ScopeAdmin}, entity.ErrorForbidden},
+	}
+	for _, c := range cases {
+		if _, _, err := auth.CreateAPIToken(c.account, c.tokName, c.scopes, 0); errorCode(err) != c.code {
+			t.Errorf("%s: got %v, want %s", c.name, err, c.code)
+		}
+	}
+
+	apiToken, token, err := auth.CreateAPIToken(&admin, &name, []string{entity.ScopeAdmin, entity.ScopeLibraryRead}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(*token, entity.APITokenPrefix) || apiToken.TokenHash != *SHA256Str(token) || !strings.HasSuffix(*token, apiToken.Hint) {
+		t.Errorf("token %q doesn't match %+v", *token, apiToken)
+	}
+	session, err := auth.GetSession(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.IsAPIToken() || *session.GetAccount() != admin || !session.HasScope(entity.ScopeAdmin) || session.HasScope(entity.ScopeBookmarkWrite) {
+		t.Errorf("token session: %+v", session)
+	}
+
+	for i := 1; i < maxAPITokensPerAccount; i++ {
+		auth.CreateAPIToken(&admin, &name, read, 0)
+	}
+	if _, _, err := auth.CreateAPIToken(&admin, &name, read, 0); errorCode(err) != entity.ErrorInvalidArgument {
+		t.Errorf("token over the limit: got %v, want InvalidArgumentError", err)
+	}
+}
+
+func TestAPITokenExpiryAndRevoke(t *testing.T) {
+	db := testDatabase(t)
+	auth, apiTokenInf := testAuth(db), testInf(db, "apiToken")
+	reader, other, password, name := "reader", "other", "password", "script"
+	auth.Register(false, &reader, &password)
+	read := []string{entity.ScopeLibraryRead}
+
+	expiring, token, _ := auth.CreateAPIToken(&reader, &name, read, time.Hour)
+	if expiring.ExpireDatetime.IsZero() {
+		t.Error("a token with a ttl never expires")
+	}
+	apiTokenInf.Update(bson.M{"tokenID": expiring.ID}, bson.M{"$set": bson.M{"expireDatetime": time.Now().Add(-time.Second)}})
+	if _, err := auth.GetSession(token); err == nil {
+		t.Error("expired token accepted")
+	}
+	if count, _ := apiTokenInf.Count(bson.M{"tokenID": expiring.ID}); count != 0 {
+		t.Error("expired token isn't removed")
+	}
+
+	apiToken, token, _ := auth.CreateAPIToken(&reader, &name, read, 0)
+	if err := auth.RevokeAPIToken(&other, &apiToken.ID); errorCode(err) != entity.ErrorNotFound {
+		t.Errorf("revoking another account's token: got %v, want NotFoundError", err)
+	}
+	if err := auth.RevokeAPIToken(&reader, &apiToken.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.GetSession(token); err == nil {
+		t.Error("revoked token accepted")
+	}
+}
@@ -0,0 +1,55 @@
+package silverfish
+
+import (
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Audit export
+type Audit struct {
+	auditInf *entity.MongoInf
+}
+
+// NewAudit export
+func NewAudit(auditInf *entity.MongoInf) *Audit {
+	a := new(Audit)
+	a.auditInf = auditInf
+	return a
+}
+
+// Record export — failures are logged rather than failing the change
+// being recorded.
+func (a *Audit) Record(account *string, action, targetType, targetID string, changes map[string]entity.AuditChange) {
+	entry := &entity.AuditEntry{
+		TargetType: targetType,
+		TargetID:   targetID,
+		Action:     action,
+		Changes:    changes,
+		Account:    *account,
+		Datetime:   time.Now(),
+	}
+	if err := a.auditInf.Insert(entry); err != nil {
+		logrus.Printf("Failed to record %s of %s %s by %s: %s", action, targetType, targetID, *account, err.Error())
+	}
+}
+
+// GetEntries export — newest first; empty `targetType` / `targetID`
+// match every target.
+func (a *Audit) GetEntries(targetType, targetID *string, limit int64) ([]entity.AuditEntry, error) {
+	selector := bson.M{}
+	if *targetType != "" {
+		selector["targetType"] = *targetType
+	}
+	if *targetID != "" {
+		selector["targetID"] = *targetID
+	}
+	result, err := a.auditInf.FindPage(selector, bson.M{}, bson.D{{Key: "datetime", Value: -1}}, 0, limit, &[]entity.AuditEntry{})
+	if err != nil {
+		return nil, err
+	}
+	return *result.(*[]entity.AuditEntry), nil
+}
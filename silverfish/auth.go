@@ -2,99 +2,178 @@ package silverfish
 
 import (
 	"errors"
+	"sort"
+	"strings"
 	"time"
 
 	entity "silverfish/silverfish/entity"
 
+	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Login failures caused by the credentials themselves, which the login
+// throttle counts; any other error isn't someone guessing.
+var (
+	ErrAccountNotExists = UnauthenticatedError("Account not exists")
+	ErrWrongPassword    = UnauthenticatedError("Account or Password wrong")
 )
 
 // Auth export
 type Auth struct {
 	hashSalt    *string
-	sessionSalt *string
 	userInf     *entity.MongoInf
 	sessionInf  *entity.MongoInf
+	apiTokenInf *entity.MongoInf
 }
 
-// NewAuth export
-func NewAuth(hashSalt *string, userInf, sessionInf *entity.MongoInf) *Auth {
-	saltTmp := "SILVERFISH"
+// NewAuth export — `hashSalt` is only used to verify passwords still
+// stored with the legacy SHA-512 scheme.
+func NewAuth(hashSalt *string, userInf, sessionInf, apiTokenInf *entity.MongoInf) *Auth {
 	a := new(Auth)
 	a.hashSalt = hashSalt
 	a.userInf = userInf
 	a.sessionInf = sessionInf
-	a.sessionSalt = &saltTmp
+	a.apiTokenInf = apiTokenInf
 	return a
 }
 
 func (a *Auth) findSession(token *string) (*entity.Session, error) {
-	result, err := a.sessionInf.FindOne(bson.M{"token": *token}, &entity.Session{})
+	if strings.HasPrefix(*token, entity.APITokenPrefix) {
+		return a.findAPITokenSession(token)
+	}
+	result, err := a.sessionInf.FindOne(bson.M{"tokenHash": *SHA256Str(token)}, &entity.Session{})
 	if err != nil {
 		return nil, err
 	}
-	return result.(*entity.Session), nil
+	session := result.(*entity.Session)
+	session.Token = *token
+	return session, nil
 }
 
 // GetSession export
 func (a *Auth) GetSession(sessionToken *string) (*entity.Session, error) {
 	session, err := a.findSession(sessionToken)
 	if err != nil {
-		return nil, errors.New("SessionToken not exists")
+		return nil, UnauthenticatedError("SessionToken not exists")
+	}
+	if session.IsAPIToken() {
+		return session, nil
 	}
 	session.KeepAlive()
-	a.sessionInf.Update(bson.M{"token": *sessionToken}, session)
+	a.sessionInf.Update(bson.M{"tokenHash": session.TokenHash}, session)
 	return session, nil
 }
 
 // InsertSession export
-func (a *Auth) InsertSession(user *entity.User, keepLogin bool) *entity.Session {
-	payload := user.Account + time.Now().String()
-	sessionToken := SHA512Str(&payload, a.sessionSalt)
-	session := entity.NewSession(keepLogin, sessionToken, user)
+func (a *Auth) InsertSession(user *entity.User, keepLogin bool, userAgent, ip *string) *entity.Session {
+	sessionToken := RandomToken()
+	session := entity.NewSession(keepLogin, RandomStr(24), sessionToken, SHA256Str(sessionToken), user, userAgent, ip)
 	a.sessionInf.Insert(session)
 	return session
 }
 
+// GetSessions export — the active sessions of `account`, most recently
+// seen first.
+func (a *Auth) GetSessions(account *string) ([]entity.Session, error) {
+	result, err := a.sessionInf.FindAll(bson.M{
+		"account":  *account,
+		"expireTS": bson.M{"$gt": time.Now()},
+	}, &[]entity.Session{})
+	if err != nil {
+		return nil, err
+	}
+	sessions := *result.(*[]entity.Session)
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeenTS.After(sessions[j].LastSeenTS) })
+	return sessions, nil
+}
+
+// RevokeSession export — only sessions of `account` can be revoked.
+func (a *Auth) RevokeSession(account, sessionID *string) error {
+	result, err := a.sessionInf.RemoveAll(bson.M{"account": *account, "sessionID": *sessionID})
+	if err != nil {
+		return err
+	}
+	if result.(*mongo.DeleteResult).DeletedCount == 0 {
+		return NotFoundError("Session not exists")
+	}
+	return nil
+}
+
+// RevokeSessions export — every session of `account` except `keepID`
+// (may be empty). Returns how many were revoked.
+func (a *Auth) RevokeSessions(account, keepID *string) (int64, error) {
+	selector := bson.M{"account": *account}
+	if keepID != nil && *keepID != "" {
+		selector["sessionID"] = bson.M{"$ne": *keepID}
+	}
+	result, err := a.sessionInf.RemoveAll(selector)
+	if err != nil {
+		return 0, err
+	}
+	return result.(*mongo.DeleteResult).DeletedCount, nil
+}
+
+// signOut revokes every session and API token of `account`.
+func (a *Auth) signOut(account *string) error {
+	if _, err := a.RevokeSessions(account, nil); err != nil {
+		return err
+	}
+	_, err := a.apiTokenInf.RemoveAll(bson.M{"account": *account})
+	return err
+}
+
 // IsTokenValid export
 func (a *Auth) IsTokenValid(sessionToken *string) bool {
 	session, err := a.findSession(sessionToken)
 	if err != nil {
 		return false
 	}
+	if session.IsAPIToken() {
+		return true
+	}
 	if session.IsExpired() {
-		a.sessionInf.Remove(bson.M{"token": *sessionToken})
+		a.sessionInf.Remove(bson.M{"tokenHash": session.TokenHash})
 		return false
 	}
 	session.KeepAlive()
-	a.sessionInf.Update(bson.M{"token": *sessionToken}, session)
+	a.sessionInf.Update(bson.M{"tokenHash": session.TokenHash}, session)
 	return true
 }
 
 // KillSession export
 func (a *Auth) KillSession(sessionToken *string) bool {
-	err := a.sessionInf.Remove(bson.M{"token": *sessionToken})
+	err := a.sessionInf.Remove(bson.M{"tokenHash": *SHA256Str(sessionToken)})
 	return err == nil
 }
 
 // Register export
 func (a *Auth) Register(isAdmin bool, account, password *string) (*entity.User, error) {
-	hashedPassword := SHA512Str(password, a.hashSalt)
 	_, err := a.userInf.FindOne(bson.M{"account": *account}, &entity.User{})
 	if err != nil {
-		if err.Error() == "not found" {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			hashedPassword, err := HashPassword(password)
+			if err != nil {
+				return nil, err
+			}
 			registerTime := time.Now()
 			user := &entity.User{
-				IsAdmin:           isAdmin,
 				Account:           *account,
 				Password:          *hashedPassword,
 				RegisterDatetime:  registerTime,
 				LastLoginDatetime: registerTime,
 				Bookmark:          &entity.Bookmark{},
 			}
+			if isAdmin {
+				user.SetRole(entity.RoleAdmin)
+			} else {
+				user.SetRole(entity.RoleReader)
+			}
 			a.userInf.Upsert(bson.M{"account": *account}, user)
 			return &entity.User{
 				IsAdmin:           user.IsAdmin,
+				Role:              user.Role,
 				Account:           user.Account,
 				RegisterDatetime:  user.RegisterDatetime,
 				LastLoginDatetime: user.LastLoginDatetime,
@@ -103,40 +182,54 @@ func (a *Auth) Register(isAdmin bool, account, password *string) (*entity.User,
 		}
 		return nil, err
 	}
-	return nil, errors.New("account exists")
+	return nil, InvalidArgumentError("account exists")
 }
 
 // Login export
 func (a *Auth) Login(account, password *string) (*entity.User, error) {
-	hashedPassword := SHA512Str(password, a.hashSalt)
 	result, err := a.userInf.FindOne(bson.M{"account": *account}, &entity.User{})
 	if err != nil {
-		if err.Error() == "not found" {
-			return nil, errors.New("Account not exists")
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrAccountNotExists
 		}
 		return nil, err
 	}
 	user := result.(*entity.User)
-	if user.Password != *hashedPassword {
-		return nil, errors.New("Account or Password wrong")
+	ok, rehash := VerifyPassword(password, &user.Password, a.hashSalt)
+	if !ok {
+		return nil, ErrWrongPassword
+	}
+	if user.Disabled {
+		return nil, ForbiddenError("Account disabled")
+	}
+	if rehash {
+		// Accounts still on the legacy SHA-512 scheme (or older argon2
+		// parameters) move to the current hash on their next login.
+		if hashedPassword, err := HashPassword(password); err == nil {
+			user.Password = *hashedPassword
+		} else {
+			logrus.Printf("Failed to rehash password of %s: %s", user.Account, err.Error())
+		}
 	}
 
 	user.LastLoginDatetime = time.Now()
 	a.userInf.Upsert(bson.M{"account": account}, user)
 	return &entity.User{
 		IsAdmin:           user.IsAdmin,
+		Role:              user.GetRole(),
 		Account:           user.Account,
 		RegisterDatetime:  user.RegisterDatetime,
 		LastLoginDatetime: user.LastLoginDatetime,
 		Bookmark:          user.Bookmark,
+		Preference:        user.Preference,
 	}, nil
 }
 
 // IsAdmin export
 func (a *Auth) IsAdmin(account *string) (bool, error) {
-	result, err := a.userInf.FindOne(bson.M{"account": account}, &entity.User{})
+	role, err := a.GetRole(account)
 	if err != nil {
-		return false, errors.New("Account not exists")
+		return false, err
 	}
-	return result.(*entity.User).IsAdmin, nil
+	return role == entity.RoleAdmin, nil
 }
@@ -0,0 +1,99 @@
+//go:build mongo
+
+package silverfish
+
+import (
+	"testing"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSessionTokenStoredHashed(t *testing.T) {
+	db := testDatabase(t)
+	auth, sessionInf := testAuth(db), testInf(db, "session")
+	account, password := "reader", "password"
+	user, _ := auth.Register(false, &account, &password)
+
+	session := auth.InsertSession(user, false, new(string), new(string))
+	token := *session.GetToken()
+	if other := auth.InsertSession(user, false, new(string), new(string)); *other.GetToken() == token {
+		t.Error("two sessions share a token")
+	}
+	stored, err := sessionInf.FindOne(bson.M{"sessionID": session.ID}, &bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*stored.(*bson.M))["tokenHash"] != *SHA256Str(&token) {
+		t.Error("the token hash isn't stored")
+	}
+	for _, value := range *stored.(*bson.M) {
+		if value == token {
+			t.Error("the plain token is stored")
+		}
+	}
+
+	found, err := auth.GetSession(&token)
+	if err != nil || found.ID != session.ID || *found.GetToken() != token {
+		t.Errorf("got %+v, %v", found, err)
+	}
+	if !auth.KillSession(&token) {
+		t.Fatal("logout failed")
+	}
+	if _, err := auth.GetSession(&token); errorCode(err) != entity.ErrorUnauthenticated {
+		t.Errorf("after logout: got %v, want UnauthenticatedError", err)
+	}
+}
+
+func TestSessionListAndRevoke(t *testing.T) {
+	db := testDatabase(t)
+	auth, sessionInf := testAuth(db), testInf(db, "session")
+	password, agent, ip := "password", "reader app", "192.0.2.1"
+	reader, other := "reader", "other"
+	readerUser, _ := auth.Register(false, &reader, &password)
+	otherUser, _ := auth.Register(false, &other, &password)
+
+	first := auth.InsertSession(readerUser, false, &agent, &ip)
+	second := auth.InsertSession(readerUser, true, &agent, &ip)
+	third := auth.InsertSession(readerUser, false, &agent, &ip)
+	foreign := auth.InsertSession(otherUser, false, &agent, &ip)
+	sessionInf.Update(bson.M{"sessionID": second.ID}, bson.M{"$set": bson.M{"lastSeenTS": time.Now().Add(time.Minute)}})
+	expired := auth.InsertSession(readerUser, false, &agent, &ip)
+	sessionInf.Update(bson.M{"sessionID": expired.ID}, bson.M{"$set": bson.M{"expireTS": time.Now().Add(-time.Minute)}})
+
+	// Active sessions of the account only, most recently seen first.
+	sessions, err := auth.GetSessions(&reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 || sessions[0].ID != second.ID {
+		t.Fatalf("got %+v", sessions)
+	}
+	if sessions[0].UserAgent != agent || sessions[0].IP != ip || !sessions[0].KeepLogin {
+		t.Errorf("session details: %+v", sessions[0])
+	}
+
+	// Sessions of another account look the same as unknown ones.
+	if err := auth.RevokeSession(&reader, &foreign.ID); errorCode(err) != entity.ErrorNotFound {
+		t.Errorf("revoking another account's session: got %v, want NotFoundError", err)
+	}
+	if err := auth.RevokeSession(&reader, &first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.RevokeSession(&reader, &first.ID); errorCode(err) != entity.ErrorNotFound {
+		t.Errorf("revoking twice: got %v, want NotFoundError", err)
+	}
+
+	count, err := auth.RevokeSessions(&reader, &third.ID)
+	if err != nil || count != 2 {
+		t.Errorf("revoked %d, %v; want the second and the expired session", count, err)
+	}
+	if _, err := auth.GetSession(third.GetToken()); err != nil {
+		t.Errorf("the kept session is gone: %v", err)
+	}
+	if _, err := auth.GetSession(foreign.GetToken()); err != nil {
+		t.Errorf("another account's session is gone: %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+package silverfish
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	backupManifestName = "manifest.json"
+	// maxBackupLine bounds one document; cached chapters can be long.
+	maxBackupLine = 64 << 20
+	// restoreMarker tags the documents a replacing restore wrote, so the
+	// rest can be deleted once all are in.
+	restoreMarker = "_restoredBy"
+)
+
+// backupCollection is one collection of the archive; `keys` identify a
+// document when merging.
+type backupCollection struct {
+	name string
+	inf  *entity.MongoInf
+	keys []string
+}
+
+// Backup export — moves a library between deployments: users with their
+// password hashes and bookmarks, novels, comics and cached chapters.
+// Sessions and API tokens are left behind.
+type Backup struct {
+	auth        *Auth
+	userInf     *entity.MongoInf
+	collections []backupCollection
+}
+
+// NewBackup export
+func NewBackup(auth *Auth, userInf, novelInf, comicInf, novelChapterInf *entity.MongoInf) *Backup {
+	b := new(Backup)
+	b.auth = auth
+	b.userInf = userInf
+	b.collections = []backupCollection{
+		{name: "user", inf: userInf, keys: []string{"account"}},
+		{name: "novel", inf: novelInf, keys: []string{"novelID"}},
+		{name: "comic", inf: comicInf, keys: []string{"comicID"}},
+		{name: "novelChapter", inf: novelChapterInf, keys: []string{"novelID", "index"}},
+	}
+	return b
+}
+
+// Export export — writes the archive, a gzipped tarball, to `w`.
+func (b *Backup) Export(w io.Writer) (*entity.BackupManifest, error) {
+	manifest := &entity.BackupManifest{
+		Format:          entity.BackupFormat,
+		Version:         entity.BackupVersion,
+		CreatedDatetime: time.Now(),
+		Collections:     map[string]int64{},
+	}
+	// Tar headers need sizes up front, so collections are dumped to
+	// temporary files before the archive is written.
+	files := map[string]*os.File{}
+	defer func() {
+		for _, file := range files {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	for _, collection := range b.collections {
+		file, err := os.CreateTemp("", "silverfish-backup-*.jsonl")
+		if err != nil {
+			return nil, err
+		}
+		files[collection.name] = file
+		writer := bufio.NewWriter(file)
+		count := int64(0)
+		err = collection.inf.Each(bson.M{}, func(raw bson.Raw) error {
+			doc := bson.D{}
+			if err := bson.Unmarshal(raw, &doc); err != nil {
+				return err
+			}
+			line, err := bson.MarshalExtJSON(withoutID(doc), false, false)
+			if err != nil {
+				return err
+			}
+			count++
+			writer.Write(line)
+			return writer.WriteByte('\n')
+		})
+		if err == nil {
+			err = writer.Flush()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to export %s: %w", collection.name, err)
+		}
+		manifest.Collections[collection.name] = count
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	js, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := writeTarEntry(archive, backupManifestName, int64(len(js)), strings.NewReader(string(js))); err != nil {
+		return nil, err
+	}
+	for _, collection := range b.collections {
+		file := files[collection.name]
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if err := writeTarEntry(archive, collection.name+".jsonl", info.Size(), file); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+// Restore export — loads an archive written by Export with `strategy`
+// merge or replace. The whole archive is checked before anything is
+// written; a dry run stops there and reports what would change. A
+// restore leaving no enabled admin is refused, and accounts it removes
+// or changes the password, role or disabled flag of are signed out.
+func (b *Backup) Restore(r io.Reader, strategy string, dryRun bool) (*entity.RestoreReport, error) {
+	if strategy != entity.RestoreMerge && strategy != entity.RestoreReplace {
+		return nil, InvalidArgumentError("Unknown strategy %s", strategy)
+	}
+	// Two passes over the archive, so keep a copy of it.
+	file, err := os.CreateTemp("", "silverfish-restore-*.tar.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+	if _, err := io.Copy(file, r); err != nil {
+		return nil, err
+	}
+
+	var users map[string]*entity.User
+	report, err := b.restorePass(file, strategy, false, func(collection string, doc bson.M) error {
+		if collection != "user" {
+			return nil
+		}
+		if users == nil {
+			users = map[string]*entity.User{}
+		}
+		user := new(entity.User)
+		raw, err := bson.Marshal(doc)
+		if err == nil {
+			err = bson.Unmarshal(raw, user)
+		}
+		if err != nil {
+			return InvalidArgumentError("Invalid user %v: %s", doc["account"], err.Error())
+		}
+		users[user.Account] = user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if users != nil {
+		if report.SignedOut, err = b.checkUsers(users, strategy); err != nil {
+			return nil, err
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+	signedOut := report.SignedOut
+	report, err = b.restorePass(file, strategy, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	report.SignedOut = signedOut
+	for i := range signedOut {
+		if err := b.auth.signOut(&signedOut[i]); err != nil {
+			return nil, err
+		}
+	}
+	logrus.Printf("Restored backup of %s (%s), signed out %d accounts",
+		report.Manifest.CreatedDatetime.Format(time.RFC3339), strategy, len(signedOut))
+	return report, nil
+}
+
+// checkUsers compares the restored `users` to the stored ones: it fails
+// unless an enabled admin is left, and returns the accounts to sign out.
+func (b *Backup) checkUsers(users map[string]*entity.User, strategy string) ([]string, error) {
+	result, err := b.userInf.FindSelectAll(bson.M{}, bson.M{
+		"account": 1, "password": 1, "role": 1, "isAdmin": 1, "disabled": 1,
+	}, &[]entity.User{})
+	if err != nil {
+		return nil, err
+	}
+	after := map[string]*entity.User{}
+	signedOut := []string{}
+	for _, stored := range *result.(*[]entity.User) {
+		restored, ok := users[stored.Account]
+		switch {
+		case !ok && strategy == entity.RestoreMerge:
+			stored := stored
+			after[stored.Account] = &stored
+		case !ok:
+			signedOut = append(signedOut, stored.Account)
+		case restored.Password != stored.Password || restored.GetRole() != stored.GetRole() || restored.Disabled && !stored.Disabled:
+			signedOut = append(signedOut, stored.Account)
+		}
+	}
+	for account, user := range users {
+		after[account] = user
+	}
+	for _, user := range after {
+		if user.GetRole() == entity.RoleAdmin && !user.Disabled {
+			sort.Strings(signedOut)
+			return signedOut, nil
+		}
+	}
+	return nil, InvalidArgumentError("Restore would leave no enabled admin")
+}
+
+func (b *Backup) restorePass(file *os.File, strategy string, apply bool, visit func(collection string, doc bson.M) error) (*entity.RestoreReport, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, InvalidArgumentError("Backup is not a gzipped tarball")
+	}
+	defer gz.Close()
+	archive := tar.NewReader(gz)
+
+	header, err := archive.Next()
+	if err != nil || header.Name != backupManifestName {
+		return nil, InvalidArgumentError("Backup should start with %s", backupManifestName)
+	}
+	manifest := &entity.BackupManifest{}
+	if err := json.NewDecoder(archive).Decode(manifest); err != nil {
+		return nil, InvalidArgumentError("Invalid manifest: %s", err.Error())
+	}
+	if manifest.Format != entity.BackupFormat {
+		return nil, InvalidArgumentError("Not a Silverfish backup")
+	}
+	if manifest.Version < 1 || manifest.Version > entity.BackupVersion {
+		return nil, InvalidArgumentError("Unsupported backup version %d", manifest.Version)
+	}
+
+	report := &entity.RestoreReport{
+		DryRun:      !apply,
+		Strategy:    strategy,
+		Manifest:    manifest,
+		Collections: map[string]*entity.RestoreStats{},
+	}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, InvalidArgumentError("Backup is damaged: %s", err.Error())
+		}
+		collection, err := b.findCollection(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		var visitDoc func(doc bson.M) error
+		if visit != nil {
+			visitDoc = func(doc bson.M) error { return visit(collection.name, doc) }
+		}
+		stats, err := restoreCollection(archive, collection, strategy, apply, visitDoc)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to restore %s: %w", collection.name, err)
+		}
+		if expected := manifest.Collections[collection.name]; stats.Total != expected {
+			return nil, InvalidArgumentError("Backup of %s holds %d documents, manifest says %d", collection.name, stats.Total, expected)
+		}
+		report.Collections[collection.name] = stats
+	}
+	return report, nil
+}
+
+func (b *Backup) findCollection(entryName string) (*backupCollection, error) {
+	for i := range b.collections {
+		if b.collections[i].name+".jsonl" == entryName {
+			return &b.collections[i], nil
+		}
+	}
+	return nil, InvalidArgumentError("Unknown backup entry %s", entryName)
+}
+
+// restoreCollection upserts every document of the archive entry by its
+// keys. Replacing then deletes what the archive didn't hold, so a restore
+// failing halfway leaves the old documents in place rather than an empty
+// collection. Problems with the archive itself are InvalidArgumentErrors.
+func restoreCollection(r io.Reader, collection *backupCollection, strategy string, apply bool, visit func(doc bson.M) error) (*entity.RestoreStats, error) {
+	stats := &entity.RestoreStats{}
+	marker := ""
+	if strategy == entity.RestoreReplace && apply {
+		marker = *RandomToken()
+		defer collection.inf.UpdateAll(bson.M{restoreMarker: bson.M{"$exists": true}}, bson.M{
+			"$unset": bson.M{restoreMarker: ""},
+		})
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxBackupLine)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		doc := bson.M{}
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), false, &doc); err != nil {
+			return nil, InvalidArgumentError("line %d: %s", stats.Total+1, err.Error())
+		}
+		delete(doc, "_id")
+		delete(doc, restoreMarker)
+		selector := bson.M{}
+		for _, key := range collection.keys {
+			value, ok := doc[key]
+			if !ok {
+				return nil, InvalidArgumentError("line %d: missing %s", stats.Total+1, key)
+			}
+			selector[key] = value
+		}
+		stats.Total++
+		if visit != nil {
+			if err := visit(doc); err != nil {
+				return nil, err
+			}
+		}
+
+		found, err := collection.inf.Count(selector)
+		if err != nil {
+			return nil, err
+		}
+		if found > 0 {
+			stats.Updated++
+		} else {
+			stats.Inserted++
+		}
+		if apply {
+			if marker != "" {
+				doc[restoreMarker] = marker
+			}
+			if _, err := collection.inf.Upsert(selector, doc); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, InvalidArgumentError(err.Error())
+	}
+
+	if strategy == entity.RestoreReplace {
+		if !apply {
+			existing, err := collection.inf.Count(bson.M{})
+			if err != nil {
+				return nil, err
+			}
+			stats.Deleted = existing - stats.Updated
+			return stats, nil
+		}
+		result, err := collection.inf.RemoveAll(bson.M{restoreMarker: bson.M{"$ne": marker}})
+		if err != nil {
+			return nil, err
+		}
+		stats.Deleted = result.(*mongo.DeleteResult).DeletedCount
+	}
+	return stats, nil
+}
+
+func withoutID(doc bson.D) bson.D {
+	kept := bson.D{}
+	for _, element := range doc {
+		if element.Key != "_id" {
+			kept = append(kept, element)
+		}
+	}
+	return kept
+}
+
+func writeTarEntry(archive *tar.Writer, name string, size int64, r io.Reader) error {
+	if err := archive.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(archive, r)
+	return err
+}
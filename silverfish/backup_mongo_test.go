@@ -0,0 +1,137 @@
+//go:build mongo
+
+package silverfish
+
+import (
+	"bytes"
+	"testing"
+
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	db := testDatabase(t)
+	userInf, novelInf, comicInf, chapterInf := testInf(db, "user"), testInf(db, "novel"), testInf(db, "comic"), testInf(db, "novelChapter")
+	backup := NewBackup(testAuth(db), userInf, novelInf, comicInf, chapterInf)
+	novelInf.Insert(
+		bson.M{"novelID": "n1", "title": "龍族", "chapters": bson.A{bson.M{"title": "一", "url": "/1"}}},
+		bson.M{"novelID": "n2", "title": "斗破苍穹"},
+	)
+	chapterInf.Insert(bson.M{"novelID": "n1", "index": 0, "content": "<p>a</p>"})
+	account, password, admin := "reader", "password", "admin"
+	testAuth(db).Register(false, &account, &password)
+	testAuth(db).Register(true, &admin, &password)
+
+	archive := &bytes.Buffer{}
+	manifest, err := backup.Export(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Collections["novel"] != 2 || manifest.Collections["novelChapter"] != 1 || manifest.Collections["user"] != 2 {
+		t.Fatalf("got manifest %+v", manifest.Collections)
+	}
+
+	// After the backup: one novel changes, one is added, one removed.
+	novelInf.Update(bson.M{"novelID": "n1"}, bson.M{"$set": bson.M{"title": "changed"}})
+	novelInf.Remove(bson.M{"novelID": "n2"})
+	novelInf.Insert(bson.M{"novelID": "n3", "title": "new"})
+	saved := archive.Bytes()
+
+	report, err := backup.Restore(bytes.NewReader(saved), entity.RestoreReplace, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := report.Collections["novel"]; *stats != (entity.RestoreStats{Total: 2, Inserted: 1, Updated: 1, Deleted: 1}) {
+		t.Errorf("dry run: got %+v", *stats)
+	}
+	if count, _ := novelInf.Count(bson.M{}); count != 2 {
+		t.Fatal("dry run wrote")
+	}
+
+	report, err = backup.Restore(bytes.NewReader(saved), entity.RestoreReplace, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := report.Collections["novel"]; *stats != (entity.RestoreStats{Total: 2, Inserted: 1, Updated: 1, Deleted: 1}) {
+		t.Errorf("restore: got %+v", *stats)
+	}
+	novels, _ := novelInf.FindAll(bson.M{}, &[]bson.M{})
+	titles := map[string]interface{}{}
+	for _, novel := range *novels.(*[]bson.M) {
+		titles[novel["novelID"].(string)] = novel["title"]
+		if _, ok := novel[restoreMarker]; ok {
+			t.Errorf("%s keeps the restore marker", novel["novelID"])
+		}
+	}
+	if len(titles) != 2 || titles["n1"] != "龍族" || titles["n2"] != "斗破苍穹" {
+		t.Errorf("got novels %v", titles)
+	}
+	if _, err := testAuth(db).Login(&account, &password); err != nil {
+		t.Errorf("restored account can't log in: %v", err)
+	}
+
+	// A broken archive leaves the collections as they were.
+	truncated := saved[:len(saved)/2]
+	if _, err := backup.Restore(bytes.NewReader(truncated), entity.RestoreReplace, false); err == nil {
+		t.Error("truncated archive accepted")
+	}
+	if count, _ := novelInf.Count(bson.M{}); count != 2 {
+		t.Errorf("got %d novels after a failed restore", count)
+	}
+}
+
+func TestRestoreGuardsAccounts(t *testing.T) {
+	db := testDatabase(t)
+	auth, userInf, sessionInf := testAuth(db), testInf(db, "user"), testInf(db, "session")
+	backup := NewBackup(auth, userInf, testInf(db, "novel"), testInf(db, "comic"), testInf(db, "novelChapter"))
+	admin, reader, late, password := "admin", "reader", "late", "password"
+	auth.Register(true, &admin, &password)
+	auth.Register(false, &reader, &password)
+	archive := &bytes.Buffer{}
+	if _, err := backup.Export(archive); err != nil {
+		t.Fatal(err)
+	}
+
+	// After the backup: an account is added, another changes password.
+	auth.Register(false, &late, &password)
+	for _, account := range []string{admin, reader, late} {
+		user, _ := auth.Login(&account, &password)
+		auth.InsertSession(user, false, new(string), new(string))
+	}
+	auth.CreateAPIToken(&late, &late, []string{entity.ScopeLibraryRead}, 0)
+	changed := "changed password"
+	auth.setPassword(&reader, &changed)
+
+	report, err := backup.Restore(bytes.NewReader(archive.Bytes()), entity.RestoreReplace, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.SignedOut) != 2 || report.SignedOut[0] != late || report.SignedOut[1] != reader {
+		t.Errorf("signed out %v, want late and reader", report.SignedOut)
+	}
+	for account, want := range map[string]int64{admin: 1, reader: 0, late: 0} {
+		if count, _ := sessionInf.Count(bson.M{"account": account}); count != want {
+			t.Errorf("%s has %d sessions, want %d", account, count, want)
+		}
+	}
+	if count, _ := testInf(db, "apiToken").Count(bson.M{"account": late}); count != 0 {
+		t.Error("the removed account keeps its API token")
+	}
+
+	// An archive without an enabled admin is refused, writing nothing.
+	userInf.Update(bson.M{"account": admin}, bson.M{"$set": bson.M{"disabled": true}})
+	archive.Reset()
+	backup.Export(archive)
+	userInf.Update(bson.M{"account": admin}, bson.M{"$set": bson.M{"disabled": false}})
+	userInf.Remove(bson.M{"account": reader})
+	for _, strategy := range []string{entity.RestoreReplace, entity.RestoreMerge} {
+		if _, err := backup.Restore(bytes.NewReader(archive.Bytes()), strategy, false); errorCode(err) != entity.ErrorInvalidArgument {
+			t.Errorf("%s without an admin: got %v", strategy, err)
+		}
+	}
+	if count, _ := userInf.Count(bson.M{"account": reader}); count != 0 {
+		t.Error("a refused restore wrote")
+	}
+}
@@ -0,0 +1,63 @@
+package silverfish
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	entity "silverfish/silverfish/entity"
+)
+
+// testArchive builds a backup archive of the given entries, in order.
+func testArchive(t *testing.T, entries ...[2]string) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	archive := tar.NewWriter(gz)
+	for _, entry := range entries {
+		if err := writeTarEntry(archive, entry[0], int64(len(entry[1])), strings.NewReader(entry[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive.Close()
+	gz.Close()
+	return buf
+}
+
+func TestRestoreRejectsInvalidArchives(t *testing.T) {
+	manifest := `{"format": "silverfish-backup", "version": 1, "collections": {}}`
+	cases := []struct {
+		name    string
+		archive *bytes.Buffer
+	}{
+		{"not gzip", bytes.NewBufferString("user.jsonl")},
+		{"no manifest", testArchive(t, [2]string{"user.jsonl", "{}\n"})},
+		{"bad manifest", testArchive(t, [2]string{backupManifestName, "{"})},
+		{"other format", testArchive(t, [2]string{backupManifestName, `{"format": "mongodump", "version": 1}`})},
+		{"newer version", testArchive(t, [2]string{backupManifestName, `{"format": "silverfish-backup", "version": 2}`})},
+		{"unknown entry", testArchive(t, [2]string{backupManifestName, manifest}, [2]string{"session.jsonl", "{}\n"})},
+	}
+	whole := testArchive(t, [2]string{backupManifestName, manifest}).Bytes()
+	cases = append(cases, struct {
+		name    string
+		archive *bytes.Buffer
+	}{"truncated", bytes.NewBuffer(whole[:len(whole)/2])})
+	backup := NewBackup(nil, nil, nil, nil, nil)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := backup.Restore(tc.archive, entity.RestoreMerge, true); errorCode(err) != entity.ErrorInvalidArgument {
+				t.Errorf("got %v, want InvalidArgumentError", err)
+			}
+		})
+	}
+
+	if _, err := backup.Restore(testArchive(t, [2]string{backupManifestName, manifest}), "overwrite", true); errorCode(err) != entity.ErrorInvalidArgument {
+		t.Errorf("unknown strategy: got %v, want InvalidArgumentError", err)
+	}
+	report, err := backup.Restore(testArchive(t, [2]string{backupManifestName, manifest}), entity.RestoreReplace, true)
+	if err != nil || !report.DryRun || len(report.Collections) != 0 {
+		t.Errorf("empty backup: got %+v, %v", report, err)
+	}
+}
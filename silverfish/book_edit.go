@@ -0,0 +1,70 @@
+package silverfish
+
+import (
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// recrawlInterval spaces out the books of a source recrawl.
+const recrawlInterval = 2 * time.Second
+
+// mergeOverrides applies the requested `fields` (name → value, empty to
+// clear) to `current` and reports what changed. `cleared` tells whether
+// any override was removed, i.e. the crawled value has to come back.
+func mergeOverrides(current *entity.BookOverrides, fields map[string]string) (
+	merged *entity.BookOverrides, changes map[string]entity.AuditChange, cleared bool, err error) {
+	merged = &entity.BookOverrides{}
+	if current != nil {
+		*merged = *current
+	}
+	changes = map[string]entity.AuditChange{}
+	for name, value := range fields {
+		from, err := merged.Get(name)
+		if err != nil {
+			return nil, nil, false, InvalidArgumentError(err.Error())
+		}
+		if from == value {
+			continue
+		}
+		merged.Set(name, value)
+		changes[name] = entity.AuditChange{From: from, To: value}
+		cleared = cleared || value == ""
+	}
+	if merged.IsEmpty() {
+		merged = nil
+	}
+	return merged, changes, cleared, nil
+}
+
+// overridesUpdate writes the merged overrides and the metadata they
+// decide, and nothing else of the book: a crawl may be saving its
+// chapters at the same time. Clearing an override resets the last crawl
+// time, so the next crawl brings back the source's value.
+func overridesUpdate(overrides *entity.BookOverrides,
+	title, author, description, coverURL string, searchTokens []string, cleared bool) bson.M {
+	set := bson.M{
+		"title":        title,
+		"author":       author,
+		"description":  description,
+		"coverUrl":     coverURL,
+		"searchTokens": searchTokens,
+	}
+	if cleared {
+		set["lastCrawlTime"] = time.Time{}
+	}
+	if overrides == nil {
+		return bson.M{"$set": set, "$unset": bson.M{"overrides": ""}}
+	}
+	set["overrides"] = overrides
+	return bson.M{"$set": set}
+}
+
+func enableAction(enable bool) string {
+	if enable {
+		return entity.AuditActionEnable
+	}
+	return entity.AuditActionDisable
+}
@@ -0,0 +1,130 @@
+package silverfish
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMergeOverrides(t *testing.T) {
+	cases := []struct {
+		name    string
+		current *entity.BookOverrides
+		fields  map[string]string
+		want    *entity.BookOverrides
+		changes map[string]entity.AuditChange
+		cleared bool
+	}{
+		{
+			"set on a book without overrides",
+			nil,
+			map[string]string{"title": "A"},
+			&entity.BookOverrides{Title: "A"},
+			map[string]entity.AuditChange{"title": {From: "", To: "A"}},
+			false,
+		},
+		{
+			"other overrides kept",
+			&entity.BookOverrides{Title: "A", Author: "B"},
+			map[string]string{"coverUrl": "https://example.com/c.jpg"},
+			&entity.BookOverrides{Title: "A", Author: "B", CoverURL: "https://example.com/c.jpg"},
+			map[string]entity.AuditChange{"coverUrl": {From: "", To: "https://example.com/c.jpg"}},
+			false,
+		},
+		{
+			"unchanged value is no change",
+			&entity.BookOverrides{Title: "A"},
+			map[string]string{"title": "A"},
+			&entity.BookOverrides{Title: "A"},
+			map[string]entity.AuditChange{},
+			false,
+		},
+		{
+			"clearing one",
+			&entity.BookOverrides{Title: "A", Description: "D"},
+			map[string]string{"description": ""},
+			&entity.BookOverrides{Title: "A"},
+			map[string]entity.AuditChange{"description": {From: "D", To: ""}},
+			true,
+		},
+		{
+			"clearing the last one",
+			&entity.BookOverrides{Author: "B"},
+			map[string]string{"author": ""},
+			nil,
+			map[string]entity.AuditChange{"author": {From: "B", To: ""}},
+			true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var before entity.BookOverrides
+			if tc.current != nil {
+				before = *tc.current
+			}
+			merged, changes, cleared, err := mergeOverrides(tc.current, tc.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(merged, tc.want) {
+				t.Errorf("merged %+v, want %+v", merged, tc.want)
+			}
+			if !reflect.DeepEqual(changes, tc.changes) {
+				t.Errorf("changes %+v, want %+v", changes, tc.changes)
+			}
+			if cleared != tc.cleared {
+				t.Errorf("cleared %v, want %v", cleared, tc.cleared)
+			}
+			if tc.current != nil && *tc.current != before {
+				t.Error("current overrides modified")
+			}
+		})
+	}
+
+	if _, _, _, err := mergeOverrides(nil, map[string]string{"chapters": "x"}); errorCode(err) != entity.ErrorInvalidArgument {
+		t.Errorf("unknown field: got %v, want InvalidArgumentError", err)
+	}
+}
+
+func TestBookOverridesApply(t *testing.T) {
+	title, author, description, coverURL := "crawled title", "crawled author", "crawled", "https://source/c.jpg"
+	var none *entity.BookOverrides
+	none.Apply(&title, &author, &description, &coverURL)
+	if title != "crawled title" || author != "crawled author" {
+		t.Error("nil overrides changed the book")
+	}
+
+	overrides := &entity.BookOverrides{Title: "curated title", CoverURL: "https://cdn/c.jpg"}
+	overrides.Apply(&title, &author, &description, &coverURL)
+	got := []string{title, author, description, coverURL}
+	want := []string{"curated title", "crawled author", "crawled", "https://cdn/c.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestOverridesUpdate(t *testing.T) {
+	overrides := &entity.BookOverrides{Title: "T"}
+	update := overridesUpdate(overrides, "T", "A", "D", "C", []string{"t"}, false)
+	set := update["$set"].(bson.M)
+	for _, field := range []string{"overrides", "title", "author", "description", "coverUrl", "searchTokens"} {
+		if _, ok := set[field]; !ok {
+			t.Errorf("%s not set", field)
+		}
+	}
+	if len(set) != 6 || update["$unset"] != nil {
+		t.Errorf("update touches more than the overridden fields: %v", update)
+	}
+
+	update = overridesUpdate(nil, "T", "A", "D", "C", []string{"t"}, true)
+	if update["$unset"].(bson.M)["overrides"] == nil {
+		t.Error("empty overrides not unset")
+	}
+	if update["$set"].(bson.M)["lastCrawlTime"] != (time.Time{}) {
+		t.Error("clearing an override should reset the last crawl")
+	}
+}
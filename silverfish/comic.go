@@ -5,16 +5,20 @@ import (
 	"fmt"
 	"silverfish/silverfish/entity"
 	interf "silverfish/silverfish/interface"
+	usecase "silverfish/silverfish/usecase"
 	"strconv"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Comic export
 type Comic struct {
 	auth          *Auth
+	audit         *Audit
+	jobs          *Jobs
 	comicInf      *entity.MongoInf
 	comicFetchers map[string]interf.IComicFetcher
 	crawlDuration int
@@ -23,12 +27,16 @@ type Comic struct {
 // NewComic export
 func NewComic(
 	auth *Auth,
+	audit *Audit,
+	jobs *Jobs,
 	comicInf *entity.MongoInf,
 	comicFetchers map[string]interf.IComicFetcher,
 	crawlDuration int,
 ) *Comic {
 	c := new(Comic)
 	c.auth = auth
+	c.audit = audit
+	c.jobs = jobs
 	c.comicInf = comicInf
 	c.comicFetchers = comicFetchers
 	c.crawlDuration = crawlDuration
@@ -44,46 +52,235 @@ func (c *Comic) GetFetcherNameLists() []string {
 	return names
 }
 
-// GetComics export
-func (c *Comic) GetComics(shouldFetchDisable bool) (*[]entity.ComicInfo, error) {
-	selector := bson.M{"isEnable": true}
-	if shouldFetchDisable == true {
-		selector = nil
+// GetComics export — filters, sort and paging are pushed down to Mongo.
+// The returned page carries the total match count and, when more
+// results follow, the cursor of the next page.
+func (c *Comic) GetComics(shouldFetchDisable bool, query *entity.ListQuery) (*[]entity.ComicInfo, *entity.ListPage, error) {
+	plan, err := newListPlan(query, shouldFetchDisable, "comicID")
+	if err != nil {
+		return nil, nil, err
+	}
+	total, err := c.comicInf.Count(plan.filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := c.comicInf.FindPage(plan.selector, bson.M{
+		"isEnable": 1, "comicID": 1, "coverUrl": 1, "title": 1, "author": 1, "lastCrawlTime": 1,
+		"addedDatetime": 1, "lastUpdateDatetime": 1, "popularity": 1}, plan.sort, 0, plan.fetchLimit(), &[]entity.ComicInfo{})
+	if err != nil {
+		return nil, nil, err
+	}
+	comics := result.(*[]entity.ComicInfo)
+	page := &entity.ListPage{Total: total}
+	if plan.limit > 0 && len(*comics) > plan.limit {
+		*comics = (*comics)[:plan.limit]
+		page.NextCursor, err = plan.cursorAfter((*comics)[plan.limit-1])
+		if err != nil {
+			return nil, nil, err
+		}
 	}
-	result, err := c.comicInf.FindSelectAll(selector, bson.M{
-		"isEnable": 1, "comicID": 1, "coverUrl": 1, "title": 1, "author": 1, "lastCrawlTime": 1}, &[]entity.ComicInfo{})
-	return result.(*[]entity.ComicInfo), err
+	return comics, page, nil
+}
+
+// GetDuplicates export — groups of comics whose title and author match
+// once script, case and punctuation are normalized away, e.g. the same
+// book added from a Simplified and a Traditional source.
+func (c *Comic) GetDuplicates() ([][]entity.ComicInfo, error) {
+	result, _, err := c.GetComics(true, &entity.ListQuery{})
+	if err != nil {
+		return nil, err
+	}
+	groups := map[string][]entity.ComicInfo{}
+	keys := []string{}
+	for _, info := range *result {
+		key := usecase.NormalizeTitle(info.Title) + "/" + usecase.NormalizeTitle(info.Author)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], info)
+	}
+	duplicates := [][]entity.ComicInfo{}
+	for _, key := range keys {
+		if len(groups[key]) > 1 {
+			duplicates = append(duplicates, groups[key])
+		}
+	}
+	return duplicates, nil
 }
 
 // GetComicByID export
 func (c *Comic) GetComicByID(comicID *string) (*entity.Comic, error) {
 	result, err := c.comicInf.FindOne(bson.M{"comicID": *comicID}, &entity.Comic{})
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Comic not exists")
+		}
 		return nil, err
 	}
 
 	comic := result.(*entity.Comic)
 	if time.Since(comic.LastCrawlTime).Hours() > 24 {
-		lastCrawlTime := comic.LastCrawlTime
-		comic, err = c.comicFetchers[comic.DNS].UpdateComicInfo(comic)
-		if err != nil {
-			logrus.Print(err.Error())
-			return nil, err
-		}
-		c.comicInf.Update(bson.M{"comicID": *comicID}, comic)
-		logrus.Printf("Updated comic <comic_id: %s, title: %s> since %s", comic.ComicID, comic.Title, lastCrawlTime)
+		return c.refresh(comic)
 	}
 
 	return result.(*entity.Comic), nil
 }
 
+// GetComicsByIDs export — many comics in one query, without their
+// chapters and as stored, i.e. not recrawled when stale. IDs not in the
+// library are left out.
+func (c *Comic) GetComicsByIDs(comicIDs []string) ([]entity.Comic, error) {
+	result, err := c.comicInf.FindSelectAll(bson.M{"comicID": bson.M{"$in": comicIDs}},
+		bson.M{"chapters": 0, "searchTokens": 0}, &[]entity.Comic{})
+	if err != nil {
+		return nil, err
+	}
+	return *result.(*[]entity.Comic), nil
+}
+
+// GetComicChapterTitles export — the chapter titles of many comics in
+// one query, keyed by comic ID. Hidden comics are left out unless
+// `shouldFetchDisable`.
+func (c *Comic) GetComicChapterTitles(shouldFetchDisable bool, comicIDs []string) (map[string][]string, error) {
+	filter := bson.M{"comicID": bson.M{"$in": comicIDs}}
+	if !shouldFetchDisable {
+		filter["isEnable"] = true
+	}
+	result, err := c.comicInf.FindSelectAll(filter,
+		bson.M{"comicID": 1, "chapters.title": 1}, &[]entity.Comic{})
+	if err != nil {
+		return nil, err
+	}
+	titles := map[string][]string{}
+	for _, comic := range *result.(*[]entity.Comic) {
+		titles[comic.ComicID] = make([]string, len(comic.Chapters))
+		for i, chapter := range comic.Chapters {
+			titles[comic.ComicID][i] = chapter.Title
+		}
+	}
+	return titles, nil
+}
+
+// refresh crawls `comic` from its source again and stores the result.
+func (c *Comic) refresh(comic *entity.Comic) (*entity.Comic, error) {
+	fetcher, ok := c.comicFetchers[comic.DNS]
+	if !ok {
+		return nil, NotFoundError("No such fetcher")
+	}
+	lastCrawlTime := comic.LastCrawlTime
+	chapterCount := len(comic.Chapters)
+	isEnable := comic.IsEnable
+	comic, err := fetcher.UpdateComicInfo(comic)
+	if err != nil {
+		logrus.Print(err.Error())
+		return nil, UpstreamError(err)
+	}
+	// Fetchers rebuild the info from the source page; keep the
+	// library's visibility and curator edits.
+	comic.IsEnable = isEnable
+	comic.ApplyOverrides()
+	comic.SearchTokens = usecase.SearchTokens(comic.Title, comic.Author, comic.Description)
+	if len(comic.Chapters) > chapterCount {
+		comic.LastUpdateDatetime = time.Now()
+	}
+	if err := c.comicInf.Update(bson.M{"comicID": comic.ComicID}, comic); err != nil {
+		return nil, err
+	}
+	logrus.Printf("Updated comic <comic_id: %s, title: %s> since %s", comic.ComicID, comic.Title, lastCrawlTime)
+	return comic, nil
+}
+
+// Recrawl export — updates the comic from its source now instead of
+// waiting a day.
+func (c *Comic) Recrawl(comicID *string, by *string) (*entity.Comic, error) {
+	result, err := c.comicInf.FindOne(bson.M{"comicID": *comicID}, &entity.Comic{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Comic not exists")
+		}
+		return nil, err
+	}
+	comic, err := c.refresh(result.(*entity.Comic))
+	if err != nil {
+		return nil, err
+	}
+	c.audit.Record(by, entity.AuditActionRecrawl, entity.AuditTargetComic, *comicID, nil)
+	return comic, nil
+}
+
+// RecrawlSource export — recrawls every comic from `dns` in a background
+// job, pausing between books to go easy on the source.
+func (c *Comic) RecrawlSource(dns *string, by *string) (*entity.Job, error) {
+	if _, ok := c.comicFetchers[*dns]; !ok {
+		return nil, NotFoundError("No such fetcher")
+	}
+	return c.jobs.Start(entity.JobKindRecrawlSource, *dns, by, func(progress *JobProgress) error {
+		result, err := c.comicInf.FindSelectAll(bson.M{"dns": *dns}, bson.M{"comicID": 1, "title": 1}, &[]entity.Comic{})
+		if err != nil {
+			return err
+		}
+		comics := *result.(*[]entity.Comic)
+		progress.SetTotal(len(comics))
+		for i, comic := range comics {
+			if i > 0 {
+				time.Sleep(recrawlInterval)
+			}
+			err := runRecovered(func() error {
+				_, err := c.Recrawl(&comic.ComicID, by)
+				return err
+			})
+			progress.Step(comic.Title, comic.ComicID, err)
+		}
+		return nil
+	})
+}
+
+// RefreshChapter export — fetches a chapter's images again, e.g. once
+// the stored URLs went stale. The old list is kept if fetching fails.
+func (c *Comic) RefreshChapter(comicID, chapterIndex *string, by *string) ([]string, error) {
+	index, err := strconv.Atoi(*chapterIndex)
+	if err != nil {
+		return nil, InvalidArgumentError("Invalid chapter index")
+	}
+	result, err := c.comicInf.FindOne(bson.M{"comicID": *comicID}, &entity.Comic{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Comic not exists")
+		}
+		return nil, err
+	}
+	record := result.(*entity.Comic)
+	fetcher, ok := c.comicFetchers[record.DNS]
+	if !ok {
+		return nil, NotFoundError("No such fetcher")
+	}
+	if index < 0 || index >= len(record.Chapters) {
+		return nil, InvalidArgumentError("Wrong Index")
+	}
+	imgURL, err := fetcher.FetchComicChapter(record, index)
+	if err != nil {
+		logrus.Print(err.Error())
+		return nil, UpstreamError(err)
+	}
+	if err := c.comicInf.Update(bson.M{"comicID": record.ComicID}, bson.M{
+		"$set": bson.M{fmt.Sprintf("chapters.%d.imageUrl", index): imgURL},
+	}); err != nil {
+		return nil, err
+	}
+	logrus.Printf("Refreshed <comic:%s> chapter <index: %d/ title: %s>", record.Title, index, record.Chapters[index].Title)
+	c.audit.Record(by, entity.AuditActionRefresh, entity.AuditTargetComic, *comicID, map[string]entity.AuditChange{
+		"chapter": {To: *chapterIndex},
+	})
+	return imgURL, nil
+}
+
 // RemoveComicByID export
 func (c *Comic) RemoveComicByID(comicID *string) error {
 	err := c.comicInf.Remove(bson.M{"comicID": *comicID})
 	if err != nil {
 		return err
 	}
-	err = c.auth.userInf.Update(bson.M{
+	_, err = c.auth.userInf.UpdateAll(bson.M{
 		fmt.Sprintf(`bookmark.comic.%s`, *comicID): bson.M{
 			"$exists": true,
 		},
@@ -92,52 +289,74 @@ func (c *Comic) RemoveComicByID(comicID *string) error {
 			fmt.Sprintf(`bookmark.comic.%s`, *comicID): "",
 		},
 	})
-	if err != nil && err.Error() != "not found" {
-		return err
+	return err
+}
+
+// AddComicByURL export — `added` is false when the comic was in the library
+// already.
+func (c *Comic) AddComicByURL(comicURL *string) (record *entity.Comic, added bool, err error) {
+	return c.addComicByURL(comicURL)
+}
+
+// IsSupported export — whether a fetcher handles `comicURL`.
+func (c *Comic) IsSupported(comicURL *string) bool {
+	for _, fetcher := range c.comicFetchers {
+		if fetcher.Match(comicURL) {
+			return true
+		}
 	}
-	return nil
+	return false
 }
 
-// AddComicByURL export
-func (c *Comic) AddComicByURL(comicURL *string) (*entity.Comic, error) {
-	result, err := c.comicInf.FindOne(bson.M{"comicURL": *comicURL}, &entity.Comic{})
-	if err != nil {
-		for _, v := range c.comicFetchers {
-			if v.Match(comicURL) {
-				record, err := v.CrawlComic(comicURL)
-				if err != nil {
-					logrus.Print(err.Error())
-					return nil, err
-				}
-				c.comicInf.Upsert(bson.M{"comicID": record.ComicID}, record)
-				return record, nil
+// addComicByURL crawls the comic at `comicURL` into the library; `added` is
+// false when it was there already.
+func (c *Comic) addComicByURL(comicURL *string) (*entity.Comic, bool, error) {
+	result, err := c.comicInf.FindOne(bson.M{"url": *comicURL}, &entity.Comic{})
+	if err == nil {
+		return result.(*entity.Comic), false, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, false, err
+	}
+	for _, v := range c.comicFetchers {
+		if v.Match(comicURL) {
+			record, err := v.CrawlComic(comicURL)
+			if err != nil {
+				logrus.Print(err.Error())
+				return nil, false, UpstreamError(err)
 			}
+			record.SearchTokens = usecase.SearchTokens(record.Title, record.Author, record.Description)
+			record.AddedDatetime = time.Now()
+			record.LastUpdateDatetime = record.AddedDatetime
+			c.comicInf.Upsert(bson.M{"comicID": record.ComicID}, record)
+			return record, true, nil
 		}
-		return nil, errors.New("No suit fetcher")
 	}
-
-	return result.(*entity.Comic), nil
+	return nil, false, InvalidArgumentError("No suit fetcher")
 }
 
 // GetComicChapter export
 func (c *Comic) GetComicChapter(comicID, chapterIndex *string) ([]string, error) {
 	index, err := strconv.Atoi(*chapterIndex)
 	if err != nil {
-		return nil, errors.New("Invalid chapter index")
+		return nil, InvalidArgumentError("Invalid chapter index")
 	}
 	query, err := c.comicInf.FindOne(bson.M{"comicID": comicID}, &entity.Comic{})
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Comic not exists")
+		}
 		return nil, err
 	}
 	record := query.(*entity.Comic)
 	if index < 0 || index >= len((*record).Chapters) {
-		return nil, errors.New("Wrong Index")
+		return nil, InvalidArgumentError("Wrong Index")
 	} else if val, ok := c.comicFetchers[(*record).DNS]; ok {
 		if len(record.Chapters[index].ImageURL) == 0 {
 			imgURL, err := val.FetchComicChapter(record, index)
 			if err != nil {
 				logrus.Print(err.Error())
-				return nil, err
+				return nil, UpstreamError(err)
 			}
 			record.Chapters[index].ImageURL = imgURL
 			c.comicInf.Update(bson.M{"comicID": record.ComicID}, record)
@@ -146,5 +365,59 @@ func (c *Comic) GetComicChapter(comicID, chapterIndex *string) ([]string, error)
 		return record.Chapters[index].ImageURL, nil
 	}
 
-	return nil, errors.New("No such fetcher'")
+	return nil, NotFoundError("No such fetcher")
+}
+
+// SetEnable export — shows or hides the comic for readers.
+func (c *Comic) SetEnable(comicID *string, enable bool, by *string) error {
+	result, err := c.comicInf.FindSelectOne(bson.M{"comicID": *comicID}, bson.M{"isEnable": 1}, &entity.Comic{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return NotFoundError("Comic not exists")
+		}
+		return err
+	}
+	if result.(*entity.Comic).IsEnable == enable {
+		return nil
+	}
+	if err := c.comicInf.Update(bson.M{"comicID": *comicID}, bson.M{
+		"$set": bson.M{"isEnable": enable},
+	}); err != nil {
+		return err
+	}
+	c.audit.Record(by, enableAction(enable), entity.AuditTargetComic, *comicID, nil)
+	return nil
+}
+
+// UpdateOverrides export — `fields` maps override names (title, author,
+// description, coverUrl) to values; an empty value clears the override
+// and the crawled value returns on the next update, which is due at once.
+func (c *Comic) UpdateOverrides(comicID *string, fields map[string]string, by *string) (*entity.Comic, error) {
+	result, err := c.comicInf.FindOne(bson.M{"comicID": *comicID}, &entity.Comic{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Comic not exists")
+		}
+		return nil, err
+	}
+	comic := result.(*entity.Comic)
+	overrides, changes, cleared, err := mergeOverrides(comic.Overrides, fields)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return comic, nil
+	}
+	comic.Overrides = overrides
+	comic.ApplyOverrides()
+	comic.SearchTokens = usecase.SearchTokens(comic.Title, comic.Author, comic.Description)
+	if cleared {
+		comic.LastCrawlTime = time.Time{}
+	}
+	update := overridesUpdate(overrides, comic.Title, comic.Author, comic.Description, comic.CoverURL, comic.SearchTokens, cleared)
+	if err := c.comicInf.Update(bson.M{"comicID": *comicID}, update); err != nil {
+		return nil, err
+	}
+	c.audit.Record(by, entity.AuditActionOverride, entity.AuditTargetComic, *comicID, changes)
+	return comic, nil
 }
@@ -1,20 +1,59 @@
 package entity
 
+import (
+	"errors"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Error codes export — stable and machine-readable, unlike messages;
+// clients branch on these.
+const (
+	ErrorNotFound        = "not_found"
+	ErrorForbidden       = "forbidden"
+	ErrorUnauthenticated = "unauthenticated"
+	ErrorInvalidArgument = "invalid_argument"
+	ErrorUpstream        = "upstream_failure"
+	ErrorTooManyRequests = "too_many_requests"
+	// ErrorInternal is every error without a code of its own.
+	ErrorInternal = "internal"
+)
+
+// CodedError export — implemented by errors that carry a stable code and
+// the HTTP status to answer with.
+type CodedError interface {
+	error
+	ErrorCode() string
+	HTTPStatus() int
+}
+
 // APIResponse export
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Fail    bool        `json:"fail"`
 	Data    interface{} `json:"data"`
+	status  int
 }
 
-// NewAPIResponse export
+// NewAPIResponse export — a failed response carries the error message as
+// `reason` and its code as `code`.
 func NewAPIResponse(data interface{}, err error) *APIResponse {
 	if err != nil {
+		code, status := ErrorInternal, http.StatusInternalServerError
+		var coded CodedError
+		if errors.As(err, &coded) {
+			code, status = coded.ErrorCode(), coded.HTTPStatus()
+		} else if errors.Is(err, mongo.ErrNoDocuments) {
+			code, status = ErrorNotFound, http.StatusNotFound
+		}
 		return &APIResponse{
 			Fail: true,
 			Data: map[string]string{
 				"reason": err.Error(),
+				"code":   code,
 			},
+			status: status,
 		}
 	}
 	return &APIResponse{
@@ -22,3 +61,11 @@ func NewAPIResponse(data interface{}, err error) *APIResponse {
 		Data:    data,
 	}
 }
+
+// StatusCode export — the HTTP status to send the response with.
+func (res *APIResponse) StatusCode() int {
+	if res.status == 0 {
+		return http.StatusOK
+	}
+	return res.status
+}
@@ -0,0 +1,49 @@
+package entity
+
+import "time"
+
+// APITokenPrefix marks API tokens, telling them apart from session
+// tokens in the Authorization header.
+const APITokenPrefix = "sfp_"
+
+// API token scopes. ScopeAdmin lets a token act with the permissions of
+// its account's role; without it a token has none.
+const (
+	ScopeLibraryRead   = "library:read"
+	ScopeBookmarkWrite = "bookmark:write"
+	ScopeAdmin         = "admin"
+)
+
+// APITokenScopes export
+var APITokenScopes = []string{ScopeLibraryRead, ScopeBookmarkWrite, ScopeAdmin}
+
+// APIToken export — a long-lived, named credential for scripts and
+// reader apps. Like sessions only a hash of the token is stored; Hint is
+// its last characters so users can tell tokens apart.
+type APIToken struct {
+	ID               string    `json:"id" bson:"tokenID"`
+	Name             string    `json:"name" bson:"name"`
+	Account          string    `json:"account" bson:"account"`
+	TokenHash        string    `json:"-" bson:"tokenHash"`
+	Hint             string    `json:"hint" bson:"hint"`
+	Scopes           []string  `json:"scopes" bson:"scopes"`
+	CreatedDatetime  time.Time `json:"createdDatetime" bson:"createdDatetime"`
+	LastUsedDatetime time.Time `json:"lastUsedDatetime" bson:"lastUsedDatetime"`
+	// ExpireDatetime is zero for tokens that never expire.
+	ExpireDatetime time.Time `json:"expireDatetime" bson:"expireDatetime"`
+}
+
+// IsExpired export
+func (t *APIToken) IsExpired() bool {
+	return !t.ExpireDatetime.IsZero() && time.Now().After(t.ExpireDatetime)
+}
+
+// HasScope export
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
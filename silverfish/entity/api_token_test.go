@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHasScope(t *testing.T) {
+	token := &APIToken{Scopes: []string{ScopeLibraryRead}}
+	login := &Session{}
+	scoped := &Session{APIToken: token}
+	for _, scope := range APITokenScopes {
+		want := scope == ScopeLibraryRead
+		if got := token.HasScope(scope); got != want {
+			t.Errorf("token %s: got %t, want %t", scope, got, want)
+		}
+		if got := scoped.HasScope(scope); got != want {
+			t.Errorf("token session %s: got %t, want %t", scope, got, want)
+		}
+		if !login.HasScope(scope) {
+			t.Errorf("login session lacks %s", scope)
+		}
+	}
+}
+
+func TestAPITokenExpiry(t *testing.T) {
+	if (&APIToken{}).IsExpired() {
+		t.Error("a token without expiry expired")
+	}
+	if !(&APIToken{ExpireDatetime: time.Now().Add(-time.Second)}).IsExpired() {
+		t.Error("a past expiry isn't expired")
+	}
+	if (&APIToken{ExpireDatetime: time.Now().Add(time.Hour)}).IsExpired() {
+		t.Error("a future expiry is expired")
+	}
+}
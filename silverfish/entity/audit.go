@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+// Audit target types
+const (
+	AuditTargetNovel = "novel"
+	AuditTargetComic = "comic"
+)
+
+// Audit actions
+const (
+	AuditActionEnable   = "enable"
+	AuditActionDisable  = "disable"
+	AuditActionOverride = "override"
+	AuditActionRecrawl  = "recrawl"
+	AuditActionRefresh  = "refreshChapter"
+)
+
+// AuditChange export
+type AuditChange struct {
+	From string `json:"from" bson:"from"`
+	To   string `json:"to" bson:"to"`
+}
+
+// AuditEntry export — who changed what, e.g. a curator hiding a book or
+// overriding its title.
+type AuditEntry struct {
+	TargetType string                 `json:"targetType" bson:"targetType"`
+	TargetID   string                 `json:"targetID" bson:"targetID"`
+	Action     string                 `json:"action" bson:"action"`
+	Changes    map[string]AuditChange `json:"changes,omitempty" bson:"changes,omitempty"`
+	Account    string                 `json:"account" bson:"account"`
+	Datetime   time.Time              `json:"datetime" bson:"datetime"`
+}
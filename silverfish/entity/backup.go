@@ -0,0 +1,50 @@
+package entity
+
+import "time"
+
+// BackupFormat marks Silverfish backup archives; BackupVersion is bumped
+// whenever their layout changes.
+const (
+	BackupFormat  = "silverfish-backup"
+	BackupVersion = 1
+)
+
+// Restore strategies
+const (
+	// RestoreMerge upserts archived documents over existing ones and
+	// leaves everything else in place.
+	RestoreMerge = "merge"
+	// RestoreReplace also deletes the documents the archive doesn't
+	// hold, once the archived ones are in.
+	RestoreReplace = "replace"
+)
+
+// BackupManifest export — `manifest.json`, the first entry of a backup
+// archive. Each collection follows as `<name>.jsonl`, one document per
+// line in relaxed extended JSON.
+type BackupManifest struct {
+	Format          string           `json:"format"`
+	Version         int              `json:"version"`
+	CreatedDatetime time.Time        `json:"createdDatetime"`
+	Collections     map[string]int64 `json:"collections"`
+}
+
+// RestoreStats export — per collection; for dry runs, what would happen.
+type RestoreStats struct {
+	Total    int64 `json:"total"`
+	Inserted int64 `json:"inserted"`
+	Updated  int64 `json:"updated"`
+	Deleted  int64 `json:"deleted"`
+}
+
+// RestoreReport export
+type RestoreReport struct {
+	DryRun      bool                     `json:"dryRun"`
+	Strategy    string                   `json:"strategy"`
+	Manifest    *BackupManifest          `json:"manifest"`
+	Collections map[string]*RestoreStats `json:"collections"`
+	// SignedOut are the accounts the restore removes or whose password,
+	// role or disabled flag it changes; their sessions and API tokens
+	// are revoked.
+	SignedOut []string `json:"signedOut"`
+}
@@ -0,0 +1,73 @@
+package entity
+
+import "fmt"
+
+// BookOverrideFields export — the metadata curators can override.
+var BookOverrideFields = []string{"title", "author", "description", "coverUrl"}
+
+// BookOverrides export — metadata set by curators in place of what the
+// source reports. Empty fields keep the crawled value, and as overrides
+// are applied after every crawl they survive UpdateNovelInfo and
+// UpdateComicInfo.
+type BookOverrides struct {
+	Title       string `json:"title,omitempty" bson:"title,omitempty"`
+	Author      string `json:"author,omitempty" bson:"author,omitempty"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	CoverURL    string `json:"coverUrl,omitempty" bson:"coverUrl,omitempty"`
+}
+
+func (o *BookOverrides) field(name string) (*string, error) {
+	switch name {
+	case "title":
+		return &o.Title, nil
+	case "author":
+		return &o.Author, nil
+	case "description":
+		return &o.Description, nil
+	case "coverUrl":
+		return &o.CoverURL, nil
+	}
+	return nil, fmt.Errorf("Unknown field %s", name)
+}
+
+// Get export
+func (o *BookOverrides) Get(name string) (string, error) {
+	field, err := o.field(name)
+	if err != nil {
+		return "", err
+	}
+	return *field, nil
+}
+
+// Set export — an empty value clears the override.
+func (o *BookOverrides) Set(name, value string) error {
+	field, err := o.field(name)
+	if err != nil {
+		return err
+	}
+	*field = value
+	return nil
+}
+
+// IsEmpty export
+func (o *BookOverrides) IsEmpty() bool {
+	return *o == BookOverrides{}
+}
+
+// Apply export — replaces the given crawled fields with the overridden
+// ones.
+func (o *BookOverrides) Apply(title, author, description, coverURL *string) {
+	if o == nil {
+		return
+	}
+	for _, pair := range [][2]*string{
+		{title, &o.Title},
+		{author, &o.Author},
+		{description, &o.Description},
+		{coverURL, &o.CoverURL},
+	} {
+		if *pair[1] != "" {
+			*pair[0] = *pair[1]
+		}
+	}
+}
@@ -4,13 +4,16 @@ import "time"
 
 // ComicInfo export
 type ComicInfo struct {
-	IsEnable      bool      `json:"isEnable" bson:"isEnable"`
-	ComicID       string    `json:"comicID" bson:"comicID"`
-	Title         string    `json:"title" bson:"title"`
-	Author        string    `json:"author" bson:"author"`
-	Description   string    `json:"description" bson:"description"`
-	CoverURL      string    `json:"coverUrl" bson:"coverUrl"`
-	LastCrawlTime time.Time `json:"lastCrawlTime" bson:"lastCrawlTime"`
+	IsEnable           bool      `json:"isEnable" bson:"isEnable"`
+	ComicID            string    `json:"comicID" bson:"comicID"`
+	Title              string    `json:"title" bson:"title"`
+	Author             string    `json:"author" bson:"author"`
+	Description        string    `json:"description" bson:"description"`
+	CoverURL           string    `json:"coverUrl" bson:"coverUrl"`
+	LastCrawlTime      time.Time `json:"lastCrawlTime" bson:"lastCrawlTime"`
+	AddedDatetime      time.Time `json:"addedDatetime" bson:"addedDatetime"`
+	LastUpdateDatetime time.Time `json:"lastUpdateDatetime" bson:"lastUpdateDatetime"`
+	Popularity         int       `json:"popularity" bson:"popularity"`
 }
 
 // Comic export
@@ -25,6 +28,15 @@ type Comic struct {
 	CoverURL      string         `json:"coverUrl" bson:"coverUrl"`
 	Chapters      []ComicChapter `json:"chapters" bson:"chapters"`
 	LastCrawlTime time.Time      `json:"lastCrawlTime" bson:"lastCrawlTime"`
+	SearchTokens  []string       `json:"-" bson:"searchTokens"`
+	// AddedDatetime is when the book entered the library,
+	// LastUpdateDatetime when a crawl last found new chapters and
+	// Popularity how many users bookmarked it.
+	AddedDatetime      time.Time `json:"addedDatetime" bson:"addedDatetime"`
+	LastUpdateDatetime time.Time `json:"lastUpdateDatetime" bson:"lastUpdateDatetime"`
+	Popularity         int       `json:"popularity" bson:"popularity"`
+	// Overrides is curator-set metadata, see ApplyOverrides.
+	Overrides *BookOverrides `json:"overrides,omitempty" bson:"overrides,omitempty"`
 }
 
 // ComicChapter export
@@ -37,17 +49,21 @@ type ComicChapter struct {
 // GetComicInfo export
 func (comic *Comic) GetComicInfo() *ComicInfo {
 	return &ComicInfo{
-		IsEnable:      comic.IsEnable,
-		ComicID:       comic.ComicID,
-		Title:         comic.Title,
-		Author:        comic.Author,
-		Description:   comic.Description,
-		CoverURL:      comic.CoverURL,
-		LastCrawlTime: comic.LastCrawlTime,
+		IsEnable:           comic.IsEnable,
+		ComicID:            comic.ComicID,
+		Title:              comic.Title,
+		Author:             comic.Author,
+		Description:        comic.Description,
+		CoverURL:           comic.CoverURL,
+		LastCrawlTime:      comic.LastCrawlTime,
+		AddedDatetime:      comic.AddedDatetime,
+		LastUpdateDatetime: comic.LastUpdateDatetime,
+		Popularity:         comic.Popularity,
 	}
 }
 
-// SetComicInfo export
+// SetComicInfo export — fetchers build the info from the source page, so
+// library bookkeeping (added/update datetimes, popularity) is left alone.
 func (comic *Comic) SetComicInfo(info *ComicInfo) {
 	comic.IsEnable = info.IsEnable
 	comic.ComicID = info.ComicID
@@ -57,3 +73,27 @@ func (comic *Comic) SetComicInfo(info *ComicInfo) {
 	comic.CoverURL = info.CoverURL
 	comic.LastCrawlTime = info.LastCrawlTime
 }
+
+// Convert export — rewrites the human-readable text fields with
+// `convert`, e.g. to render a book in another Chinese script.
+func (info *ComicInfo) Convert(convert func(string) string) {
+	info.Title = convert(info.Title)
+	info.Author = convert(info.Author)
+	info.Description = convert(info.Description)
+}
+
+// Convert export
+func (comic *Comic) Convert(convert func(string) string) {
+	comic.Title = convert(comic.Title)
+	comic.Author = convert(comic.Author)
+	comic.Description = convert(comic.Description)
+	for i := range comic.Chapters {
+		comic.Chapters[i].Title = convert(comic.Chapters[i].Title)
+	}
+}
+
+// ApplyOverrides export — called after every crawl so curator edits win
+// over the source's metadata.
+func (comic *Comic) ApplyOverrides() {
+	comic.Overrides.Apply(&comic.Title, &comic.Author, &comic.Description, &comic.CoverURL)
+}
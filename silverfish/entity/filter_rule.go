@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+// Filter rule types
+const (
+	FilterRuleSelector  = "selector"
+	FilterRuleLiteral   = "literal"
+	FilterRuleRegex     = "regex"
+	FilterRuleParagraph = "paragraph"
+)
+
+// FilterRule export — Count caps how many matches a literal or regex
+// rule replaces, first ones first; 0 replaces every match.
+type FilterRule struct {
+	Type        string `json:"type" bson:"type"`
+	Pattern     string `json:"pattern" bson:"pattern"`
+	Replacement string `json:"replacement" bson:"replacement"`
+	Count       int    `json:"count,omitempty" bson:"count,omitempty"`
+	Comment     string `json:"comment" bson:"comment"`
+}
+
+// FilterRuleSet export
+type FilterRuleSet struct {
+	DNS             string       `json:"dns" bson:"dns"`
+	Version         int          `json:"version" bson:"version"`
+	Rules           []FilterRule `json:"rules" bson:"rules"`
+	UpdatedBy       string       `json:"updatedBy" bson:"updatedBy"`
+	UpdatedDatetime time.Time    `json:"updatedDatetime" bson:"updatedDatetime"`
+}
@@ -0,0 +1,51 @@
+package entity
+
+import "time"
+
+// Job statuses
+const (
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+)
+
+// Job kinds
+const (
+	JobKindRecrawlSource = "recrawlSource"
+	JobKindImport        = "import"
+)
+
+// Import outcomes, the Status of an import job's results.
+const (
+	ImportAdded       = "added"
+	ImportDuplicate   = "duplicate"
+	ImportUnsupported = "unsupported"
+	ImportFailed      = "failed"
+)
+
+// JobResult export — the outcome for one item of a job, e.g. one book.
+type JobResult struct {
+	Item   string `json:"item" bson:"item"`
+	Status string `json:"status,omitempty" bson:"status,omitempty"`
+	Type   string `json:"type,omitempty" bson:"type,omitempty"`
+	ID     string `json:"id,omitempty" bson:"id,omitempty"`
+	Title  string `json:"title,omitempty" bson:"title,omitempty"`
+	Error  string `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// Job export — a long-running admin task running in the background.
+// Progress is written as it goes so clients can poll it.
+type Job struct {
+	ID               string      `json:"id" bson:"jobID"`
+	Kind             string      `json:"kind" bson:"kind"`
+	Target           string      `json:"target" bson:"target"`
+	Status           string      `json:"status" bson:"status"`
+	Error            string      `json:"error,omitempty" bson:"error,omitempty"`
+	Total            int         `json:"total" bson:"total"`
+	Done             int         `json:"done" bson:"done"`
+	Failed           int         `json:"failed" bson:"failed"`
+	Results          []JobResult `json:"results" bson:"results"`
+	CreatedBy        string      `json:"createdBy" bson:"createdBy"`
+	CreatedDatetime  time.Time   `json:"createdDatetime" bson:"createdDatetime"`
+	FinishedDatetime time.Time   `json:"finishedDatetime" bson:"finishedDatetime"`
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// ListQuery export — options of the novel / comic list endpoints. A zero
+// Limit returns every match, which keeps the v1 list response unchanged
+// for clients that don't page.
+type ListQuery struct {
+	Sort         string
+	Order        string
+	Source       string
+	Enabled      *bool
+	UpdatedSince time.Time
+	Cursor       string
+	Limit        int
+}
+
+// ListPage export
+type ListPage struct {
+	Total      int64
+	NextCursor string
+}
@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// Login attempt subjects.
+const (
+	LoginSubjectAccount = "account"
+	LoginSubjectIP      = "ip"
+)
+
+// LoginAttempt export — failed logins of one account or one client IP
+// since its last successful login (accounts) or within the last day.
+type LoginAttempt struct {
+	Subject     string    `json:"subject" bson:"subject"`
+	Value       string    `json:"value" bson:"value"`
+	Failures    int       `json:"failures" bson:"failures"`
+	LastFailure time.Time `json:"lastFailure" bson:"lastFailure"`
+	LockedUntil time.Time `json:"lockedUntil" bson:"lockedUntil"`
+	ExpireTS    time.Time `json:"expireTS" bson:"expireTS"`
+}
+
+// IsLocked export
+func (la *LoginAttempt) IsLocked() bool { return time.Now().Before(la.LockedUntil) }
@@ -53,6 +53,13 @@ func (mi *MongoInf) Update(selector, update interface{}) error {
 	return err
 }
 
+// UpdateAll return the info and error; `update` must use operators.
+func (mi *MongoInf) UpdateAll(selector, update interface{}) (interface{}, error) {
+	ctx, cancel := ctxTimeout()
+	defer cancel()
+	return mi.col.UpdateMany(ctx, selector, update)
+}
+
 // Upsert export
 func (mi *MongoInf) Upsert(selector, update interface{}) (interface{}, error) {
 	ctx, cancel := ctxTimeout()
@@ -99,6 +106,26 @@ func (mi *MongoInf) FindOne(key, res interface{}) (interface{}, error) {
 	return res, err
 }
 
+// FindOneAndUpdate export — applies `update` (operators) to the first
+// match in one atomic step and decodes the document as it is afterwards;
+// with `upsert` a missing document is created from the selector first.
+func (mi *MongoInf) FindOneAndUpdate(key, update interface{}, upsert bool, res interface{}) (interface{}, error) {
+	ctx, cancel := ctxTimeout()
+	defer cancel()
+	opts := options.FindOneAndUpdate().SetUpsert(upsert).SetReturnDocument(options.After)
+	err := mi.col.FindOneAndUpdate(ctx, key, update, opts).Decode(res)
+	return res, err
+}
+
+// FindOneAndDelete export — removes the first match and decodes it, so
+// of two concurrent callers only one gets the document.
+func (mi *MongoInf) FindOneAndDelete(key, res interface{}) (interface{}, error) {
+	ctx, cancel := ctxTimeout()
+	defer cancel()
+	err := mi.col.FindOneAndDelete(ctx, key).Decode(res)
+	return res, err
+}
+
 // FindAll get every match query result
 func (mi *MongoInf) FindAll(key, res interface{}) (interface{}, error) {
 	ctx, cancel := ctxTimeout()
@@ -133,9 +160,55 @@ func (mi *MongoInf) FindSelectAll(key, sel, res interface{}) (interface{}, error
 	return res, err
 }
 
+// Each export — streams every match to `fn` without the usual timeout,
+// for walks over whole collections such as backups. Stops at the first
+// error `fn` returns.
+func (mi *MongoInf) Each(key interface{}, fn func(doc bson.Raw) error) error {
+	ctx := context.Background()
+	cursor, err := mi.col.Find(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		if err := fn(cursor.Current); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
 // CountDocuments export — replaces direct collection counting from main.go.
 func (mi *MongoInf) CountDocuments() (int64, error) {
 	ctx, cancel := ctxTimeout()
 	defer cancel()
 	return mi.col.CountDocuments(ctx, bson.M{})
 }
+
+// FindPage export — FindSelectAll with sort, skip and limit pushed down
+// to Mongo. A zero limit returns every match.
+func (mi *MongoInf) FindPage(key, sel, sort interface{}, skip, limit int64, res interface{}) (interface{}, error) {
+	ctx, cancel := ctxTimeout()
+	defer cancel()
+	opts := options.Find().SetProjection(sel).SetSort(sort)
+	if skip > 0 {
+		opts.SetSkip(skip)
+	}
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	cursor, err := mi.col.Find(ctx, key, opts)
+	if err != nil {
+		return res, err
+	}
+	defer cursor.Close(ctx)
+	err = cursor.All(ctx, res)
+	return res, err
+}
+
+// Count export
+func (mi *MongoInf) Count(key interface{}) (int64, error) {
+	ctx, cancel := ctxTimeout()
+	defer cancel()
+	return mi.col.CountDocuments(ctx, key)
+}
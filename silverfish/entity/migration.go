@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// MigrationRecord export — a migration applied to the database.
+type MigrationRecord struct {
+	ID              string    `json:"id" bson:"migrationID"`
+	Description     string    `json:"description" bson:"description"`
+	AppliedDatetime time.Time `json:"appliedDatetime" bson:"appliedDatetime"`
+}
+
+// MigrationStatus export — a known migration and when, if at all, it
+// was applied.
+type MigrationStatus struct {
+	ID              string     `json:"id"`
+	Description     string     `json:"description"`
+	AppliedDatetime *time.Time `json:"appliedDatetime"`
+}
@@ -4,13 +4,16 @@ import "time"
 
 // NovelInfo export
 type NovelInfo struct {
-	IsEnable      bool      `json:"isEnable" bson:"isEnable"`
-	NovelID       string    `json:"novelID" bson:"novelID"`
-	Title         string    `json:"title" bson:"title"`
-	Author        string    `json:"author" bson:"author"`
-	Description   string    `json:"description" bson:"description"`
-	CoverURL      string    `json:"coverUrl" bson:"coverUrl"`
-	LastCrawlTime time.Time `json:"lastCrawlTime" bson:"lastCrawlTime"`
+	IsEnable           bool      `json:"isEnable" bson:"isEnable"`
+	NovelID            string    `json:"novelID" bson:"novelID"`
+	Title              string    `json:"title" bson:"title"`
+	Author             string    `json:"author" bson:"author"`
+	Description        string    `json:"description" bson:"description"`
+	CoverURL           string    `json:"coverUrl" bson:"coverUrl"`
+	LastCrawlTime      time.Time `json:"lastCrawlTime" bson:"lastCrawlTime"`
+	AddedDatetime      time.Time `json:"addedDatetime" bson:"addedDatetime"`
+	LastUpdateDatetime time.Time `json:"lastUpdateDatetime" bson:"lastUpdateDatetime"`
+	Popularity         int       `json:"popularity" bson:"popularity"`
 }
 
 // Novel export
@@ -25,6 +28,15 @@ type Novel struct {
 	CoverURL      string         `json:"coverUrl" bson:"coverUrl"`
 	Chapters      []NovelChapter `json:"chapters" bson:"chapters"`
 	LastCrawlTime time.Time      `json:"lastCrawlTime" bson:"lastCrawlTime"`
+	SearchTokens  []string       `json:"-" bson:"searchTokens"`
+	// AddedDatetime is when the book entered the library,
+	// LastUpdateDatetime when a crawl last found new chapters and
+	// Popularity how many users bookmarked it.
+	AddedDatetime      time.Time `json:"addedDatetime" bson:"addedDatetime"`
+	LastUpdateDatetime time.Time `json:"lastUpdateDatetime" bson:"lastUpdateDatetime"`
+	Popularity         int       `json:"popularity" bson:"popularity"`
+	// Overrides is curator-set metadata, see ApplyOverrides.
+	Overrides *BookOverrides `json:"overrides,omitempty" bson:"overrides,omitempty"`
 }
 
 // NovelChapter export
@@ -36,16 +48,20 @@ type NovelChapter struct {
 // GetNovelInfo export
 func (novel *Novel) GetNovelInfo() *NovelInfo {
 	return &NovelInfo{
-		IsEnable:      novel.IsEnable,
-		NovelID:       novel.NovelID,
-		Title:         novel.Title,
-		Author:        novel.Author,
-		CoverURL:      novel.CoverURL,
-		LastCrawlTime: novel.LastCrawlTime,
+		IsEnable:           novel.IsEnable,
+		NovelID:            novel.NovelID,
+		Title:              novel.Title,
+		Author:             novel.Author,
+		CoverURL:           novel.CoverURL,
+		LastCrawlTime:      novel.LastCrawlTime,
+		AddedDatetime:      novel.AddedDatetime,
+		LastUpdateDatetime: novel.LastUpdateDatetime,
+		Popularity:         novel.Popularity,
 	}
 }
 
-// SetNovelInfo export
+// SetNovelInfo export — fetchers build the info from the source page, so
+// library bookkeeping (added/update datetimes, popularity) is left alone.
 func (novel *Novel) SetNovelInfo(info *NovelInfo) {
 	novel.IsEnable = info.IsEnable
 	novel.NovelID = info.NovelID
@@ -54,3 +70,27 @@ func (novel *Novel) SetNovelInfo(info *NovelInfo) {
 	novel.CoverURL = info.CoverURL
 	novel.LastCrawlTime = info.LastCrawlTime
 }
+
+// Convert export — rewrites the human-readable text fields with
+// `convert`, e.g. to render a book in another Chinese script.
+func (info *NovelInfo) Convert(convert func(string) string) {
+	info.Title = convert(info.Title)
+	info.Author = convert(info.Author)
+	info.Description = convert(info.Description)
+}
+
+// Convert export
+func (novel *Novel) Convert(convert func(string) string) {
+	novel.Title = convert(novel.Title)
+	novel.Author = convert(novel.Author)
+	novel.Description = convert(novel.Description)
+	for i := range novel.Chapters {
+		novel.Chapters[i].Title = convert(novel.Chapters[i].Title)
+	}
+}
+
+// ApplyOverrides export — called after every crawl so curator edits win
+// over the source's metadata.
+func (novel *Novel) ApplyOverrides() {
+	novel.Overrides.Apply(&novel.Title, &novel.Author, &novel.Description, &novel.CoverURL)
+}
@@ -0,0 +1,15 @@
+package entity
+
+import "time"
+
+// NovelChapterCache export — a chapter's content as the fetcher returned
+// it, before filter rules and sanitizing, so rule changes still apply.
+// URL ties the entry to the chapter it was fetched from in case the
+// chapter list shifts.
+type NovelChapterCache struct {
+	NovelID         string    `bson:"novelID"`
+	Index           int       `bson:"index"`
+	URL             string    `bson:"url"`
+	Content         string    `bson:"content"`
+	FetchedDatetime time.Time `bson:"fetchedDatetime"`
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// OIDCState export — one pending authorization request, kept until the
+// IdP redirects back (or ExpireTS passes). LinkAccount is set when a
+// logged-in user started the flow to link their account.
+type OIDCState struct {
+	State        string    `bson:"state"`
+	Nonce        string    `bson:"nonce"`
+	CodeVerifier string    `bson:"codeVerifier"`
+	LinkAccount  string    `bson:"linkAccount"`
+	ExpireTS     time.Time `bson:"expireTS"`
+}
+
+// OIDCIdentity export — the IdP identity an account is linked to.
+type OIDCIdentity struct {
+	Issuer         string    `json:"issuer" bson:"issuer"`
+	Subject        string    `json:"subject" bson:"subject"`
+	Email          string    `json:"email" bson:"email"`
+	LinkedDatetime time.Time `json:"linkedDatetime" bson:"linkedDatetime"`
+}
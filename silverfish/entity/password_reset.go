@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// PasswordReset export — a one-time reset token, stored hashed like
+// session tokens. IssuedBy is the admin who issued it, or "email" for
+// self-service requests.
+type PasswordReset struct {
+	TokenHash string    `bson:"tokenHash"`
+	Account   string    `bson:"account"`
+	IssuedBy  string    `bson:"issuedBy"`
+	ExpireTS  time.Time `bson:"expireTS"`
+}
+
+// IsExpired export
+func (pr *PasswordReset) IsExpired() bool { return !time.Now().Before(pr.ExpireTS) }
@@ -1,6 +1,7 @@
 package entity
 
-// RecaptchaResponse export
+// RecaptchaResponse export — the siteverify answer, same shape for
+// reCAPTCHA, hCaptcha and Turnstile.
 type RecaptchaResponse struct {
 	Success    bool     `json:"success"`
 	ErrorCodes []string `json:"error-codes"`
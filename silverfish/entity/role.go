@@ -0,0 +1,57 @@
+package entity
+
+// Roles, from least to most privileged.
+const (
+	RoleReader  = "reader"
+	RoleCurator = "curator"
+	RoleAdmin   = "admin"
+)
+
+// Permissions granted through roles. Reading the library and keeping
+// bookmarks need no permission, any signed-in account may do both.
+const (
+	// PermissionBookAdd allows adding books and listing fetchers.
+	PermissionBookAdd = "book:add"
+	// PermissionBookRecrawl allows refreshing books from their source.
+	PermissionBookRecrawl = "book:recrawl"
+	// PermissionBookEdit allows seeing and toggling disabled books.
+	PermissionBookEdit = "book:edit"
+	// PermissionBookDelete allows removing books.
+	PermissionBookDelete = "book:delete"
+	// PermissionFilterManage allows editing content filter rules.
+	PermissionFilterManage = "filter:manage"
+	// PermissionUserManage allows managing accounts, their roles and
+	// sessions, and the login throttle.
+	PermissionUserManage = "user:manage"
+	// PermissionSystemManage allows maintenance such as reindexing.
+	PermissionSystemManage = "system:manage"
+)
+
+// Roles export
+var Roles = []string{RoleReader, RoleCurator, RoleAdmin}
+
+// RolePermissions export
+var RolePermissions = map[string][]string{
+	RoleReader: {},
+	RoleCurator: {
+		PermissionBookAdd,
+		PermissionBookRecrawl,
+		PermissionBookEdit,
+		PermissionFilterManage,
+	},
+	RoleAdmin: {
+		PermissionBookAdd,
+		PermissionBookRecrawl,
+		PermissionBookEdit,
+		PermissionBookDelete,
+		PermissionFilterManage,
+		PermissionUserManage,
+		PermissionSystemManage,
+	},
+}
+
+// IsRole export
+func IsRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
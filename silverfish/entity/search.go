@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// SearchResult export
+type SearchResult struct {
+	Type          string    `json:"type" bson:"-"`
+	ID            string    `json:"id" bson:"-"`
+	NovelID       string    `json:"-" bson:"novelID"`
+	ComicID       string    `json:"-" bson:"comicID"`
+	IsEnable      bool      `json:"isEnable" bson:"isEnable"`
+	DNS           string    `json:"dns" bson:"dns"`
+	Title         string    `json:"title" bson:"title"`
+	Author        string    `json:"author" bson:"author"`
+	Description   string    `json:"description" bson:"description"`
+	CoverURL      string    `json:"coverUrl" bson:"coverUrl"`
+	LastCrawlTime time.Time `json:"lastCrawlTime" bson:"lastCrawlTime"`
+	SearchTokens  []string  `json:"-" bson:"searchTokens"`
+	Score         float64   `json:"score" bson:"-"`
+}
+
+// SearchPage export
+type SearchPage struct {
+	Total   int            `json:"total"`
+	Page    int            `json:"page"`
+	Size    int            `json:"size"`
+	Results []SearchResult `json:"results"`
+}
+
+// SearchQuery export
+type SearchQuery struct {
+	Keyword string
+	Type    string
+	Sort    string
+	Enabled *bool
+	Source  string
+	Page    int
+	Size    int
+}
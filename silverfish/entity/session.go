@@ -2,21 +2,37 @@ package entity
 
 import "time"
 
-// Session export
+// Session export — only the hash of the token is stored; Token itself is
+// known just to the session returned by login and to requests carrying it.
 type Session struct {
-	KeepLogin bool      `json:"keepLogin" bson:"keepLogin"`
-	Token     string    `json:"token" bson:"token"`
-	Account   string    `json:"account" bson:"account"`
-	LoginTS   time.Time `json:"loginTS" bson:"loginTS"`
-	ExpireTS  time.Time `json:"expireTS" bson:"expireTS"`
+	ID         string    `json:"id" bson:"sessionID"`
+	KeepLogin  bool      `json:"keepLogin" bson:"keepLogin"`
+	Token      string    `json:"-" bson:"-"`
+	TokenHash  string    `json:"-" bson:"tokenHash"`
+	Account    string    `json:"account" bson:"account"`
+	UserAgent  string    `json:"userAgent" bson:"userAgent"`
+	IP         string    `json:"ip" bson:"ip"`
+	LoginTS    time.Time `json:"loginTS" bson:"loginTS"`
+	LastSeenTS time.Time `json:"lastSeenTS" bson:"lastSeenTS"`
+	ExpireTS   time.Time `json:"expireTS" bson:"expireTS"`
+	// Current marks the caller's own session in session lists.
+	Current bool `json:"current" bson:"-"`
+	// APIToken is set when the request authenticated with an API token
+	// instead of logging in; the session is then limited to its scopes.
+	APIToken *APIToken `json:"-" bson:"-"`
 }
 
-// NewSession export
-func NewSession(keepLogin bool, token *string, user *User) *Session {
+// NewSession export — `id` names the session in lists and revocations;
+// unlike the token it is not a secret.
+func NewSession(keepLogin bool, id, token, tokenHash *string, user *User, userAgent, ip *string) *Session {
 	s := &Session{
+		ID:        *id,
 		KeepLogin: keepLogin,
 		Token:     *token,
+		TokenHash: *tokenHash,
 		Account:   user.Account,
+		UserAgent: *userAgent,
+		IP:        *ip,
 		LoginTS:   time.Now(),
 	}
 	s.refreshExpiry()
@@ -24,6 +40,7 @@ func NewSession(keepLogin bool, token *string, user *User) *Session {
 }
 
 func (s *Session) refreshExpiry() {
+	s.LastSeenTS = time.Now()
 	if s.KeepLogin {
 		s.ExpireTS = time.Now().Add(time.Hour * 24 * 7)
 	} else {
@@ -45,3 +62,11 @@ func (s *Session) KeepAlive() { s.refreshExpiry() }
 
 // IsExpired export
 func (s *Session) IsExpired() bool { return time.Now().After(s.ExpireTS) }
+
+// IsAPIToken export
+func (s *Session) IsAPIToken() bool { return s.APIToken != nil }
+
+// HasScope export — login sessions carry every scope.
+func (s *Session) HasScope(scope string) bool {
+	return s.APIToken == nil || s.APIToken.HasScope(scope)
+}
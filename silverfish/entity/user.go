@@ -4,10 +4,58 @@ import "time"
 
 // User export
 type User struct {
-	IsAdmin           bool      `json:"isAdmin" bson:"isAdmin"`
-	Account           string    `json:"account" bson:"account"`
-	Password          string    `json:"password" bson:"password"`
-	RegisterDatetime  time.Time `json:"registerDatetime" bson:"registerDatetime"`
-	LastLoginDatetime time.Time `json:"lastLoginDatetime" bson:"lastLoginDatetime"`
-	Bookmark          *Bookmark `json:"bookmark" bson:"bookmark"`
+	// IsAdmin mirrors Role == RoleAdmin for clients predating roles.
+	IsAdmin           bool       `json:"isAdmin" bson:"isAdmin"`
+	Role              string     `json:"role" bson:"role"`
+	Disabled          bool       `json:"disabled" bson:"disabled"`
+	Account           string     `json:"account" bson:"account"`
+	Password          string     `json:"password" bson:"password"`
+	Email             string     `json:"email" bson:"email"`
+	RegisterDatetime  time.Time  `json:"registerDatetime" bson:"registerDatetime"`
+	LastLoginDatetime time.Time  `json:"lastLoginDatetime" bson:"lastLoginDatetime"`
+	Bookmark          *Bookmark  `json:"bookmark" bson:"bookmark"`
+	Preference        Preference `json:"preference" bson:"preference"`
+	// OIDC is set for accounts signing in through the identity provider.
+	OIDC *OIDCIdentity `json:"oidc,omitempty" bson:"oidc,omitempty"`
+}
+
+// UserQuery export — filters of the admin user list. Keyword matches
+// account or email.
+type UserQuery struct {
+	Keyword  string
+	Role     string
+	Disabled *bool
+	Page     int
+	Size     int
+}
+
+// UserPage export
+type UserPage struct {
+	Total int64  `json:"total"`
+	Page  int    `json:"page"`
+	Size  int    `json:"size"`
+	Users []User `json:"users"`
+}
+
+// Preference export
+type Preference struct {
+	Script string `json:"script" bson:"script"`
+}
+
+// GetRole export — accounts stored before roles existed fall back on
+// their IsAdmin flag.
+func (u *User) GetRole() string {
+	if IsRole(u.Role) {
+		return u.Role
+	}
+	if u.IsAdmin {
+		return RoleAdmin
+	}
+	return RoleReader
+}
+
+// SetRole export — keeps IsAdmin in step with the role.
+func (u *User) SetRole(role string) {
+	u.Role = role
+	u.IsAdmin = role == RoleAdmin
 }
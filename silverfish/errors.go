@@ -0,0 +1,81 @@
+package silverfish
+
+import (
+	"fmt"
+	"net/http"
+
+	entity "silverfish/silverfish/entity"
+)
+
+var errorStatus = map[string]int{
+	entity.ErrorNotFound:        http.StatusNotFound,
+	entity.ErrorForbidden:       http.StatusForbidden,
+	entity.ErrorUnauthenticated: http.StatusUnauthorized,
+	entity.ErrorInvalidArgument: http.StatusBadRequest,
+	entity.ErrorUpstream:        http.StatusBadGateway,
+	entity.ErrorTooManyRequests: http.StatusTooManyRequests,
+}
+
+// Error export — a domain error with one of the entity.Error* codes. The
+// message stays what clients saw before codes existed.
+type Error struct {
+	Code    string
+	Message string
+	Cause   error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap export
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// ErrorCode export
+func (e *Error) ErrorCode() string {
+	return e.Code
+}
+
+// HTTPStatus export
+func (e *Error) HTTPStatus() int {
+	return errorStatus[e.Code]
+}
+
+func newError(code, format string, args []interface{}) *Error {
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+	return &Error{Code: code, Message: message}
+}
+
+// NotFoundError export
+func NotFoundError(format string, args ...interface{}) error {
+	return newError(entity.ErrorNotFound, format, args)
+}
+
+// ForbiddenError export — the caller is known but may not do this.
+func ForbiddenError(format string, args ...interface{}) error {
+	return newError(entity.ErrorForbidden, format, args)
+}
+
+// UnauthenticatedError export — the caller has to (re-)authenticate.
+func UnauthenticatedError(format string, args ...interface{}) error {
+	return newError(entity.ErrorUnauthenticated, format, args)
+}
+
+// InvalidArgumentError export
+func InvalidArgumentError(format string, args ...interface{}) error {
+	return newError(entity.ErrorInvalidArgument, format, args)
+}
+
+// UpstreamError export — a source site or identity provider failed;
+// keeps `cause` for errors.Is and errors.As.
+func UpstreamError(cause error) error {
+	if cause == nil {
+		return nil
+	}
+	return &Error{Code: entity.ErrorUpstream, Message: cause.Error(), Cause: cause}
+}
@@ -0,0 +1,44 @@
+package silverfish
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	entity "silverfish/silverfish/entity"
+)
+
+// errorCode is the entity.Error* code of `err`, "" for untyped errors.
+func errorCode(err error) string {
+	var typed *Error
+	if errors.As(err, &typed) {
+		return typed.Code
+	}
+	return ""
+}
+
+func TestErrorCodes(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   string
+		status int
+	}{
+		{NotFoundError("Novel not exists"), entity.ErrorNotFound, http.StatusNotFound},
+		{InvalidArgumentError("Unknown role %s", "owner"), entity.ErrorInvalidArgument, http.StatusBadRequest},
+		{fmt.Errorf("restoring: %w", ForbiddenError("No")), entity.ErrorForbidden, http.StatusForbidden},
+		{errors.New("untyped"), "", 0},
+	}
+	for _, tc := range cases {
+		if got := errorCode(tc.err); got != tc.code {
+			t.Errorf("%v: got code %q, want %q", tc.err, got, tc.code)
+		}
+		var typed *Error
+		if errors.As(tc.err, &typed) && typed.HTTPStatus() != tc.status {
+			t.Errorf("%v: got status %d, want %d", tc.err, typed.HTTPStatus(), tc.status)
+		}
+	}
+	if got := InvalidArgumentError("Unknown role %s", "owner").Error(); got != "Unknown role owner" {
+		t.Errorf("got message %q", got)
+	}
+}
@@ -0,0 +1,197 @@
+package silverfish
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+	interf "silverfish/silverfish/interface"
+	usecase "silverfish/silverfish/usecase"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Filter export
+type Filter struct {
+	filterInf        *entity.MongoInf
+	filterHistoryInf *entity.MongoInf
+	defaults         map[string][]entity.FilterRule
+	mutex            sync.Mutex
+	// compiled caches the current rule set of each fetcher domain, so
+	// it holds at most one filter per fetcher. Edits replace the entry.
+	compiled map[string]compiledRuleSet
+}
+
+// ruleSetRecheck is how long Apply trusts a compiled rule set before
+// looking up the stored version again, so edits saved through another
+// instance reach this one too.
+const ruleSetRecheck = time.Minute
+
+type compiledRuleSet struct {
+	version int
+	filter  *usecase.ContentFilter
+	checked time.Time
+}
+
+// NewFilter export
+func NewFilter(
+	filterInf, filterHistoryInf *entity.MongoInf,
+	novelFetchers map[string]interf.INovelFetcher,
+) *Filter {
+	f := new(Filter)
+	f.filterInf = filterInf
+	f.filterHistoryInf = filterHistoryInf
+	f.defaults = map[string][]entity.FilterRule{}
+	for domain, fetcher := range novelFetchers {
+		f.defaults[domain] = fetcher.DefaultFilterRules()
+	}
+	f.compiled = map[string]compiledRuleSet{}
+	return f
+}
+
+// GetRuleSets export
+func (f *Filter) GetRuleSets() ([]*entity.FilterRuleSet, error) {
+	ruleSets := []*entity.FilterRuleSet{}
+	for domain := range f.defaults {
+		ruleSet, err := f.GetRuleSet(&domain)
+		if err != nil {
+			return nil, err
+		}
+		ruleSets = append(ruleSets, ruleSet)
+	}
+	sort.Slice(ruleSets, func(i, j int) bool { return ruleSets[i].DNS < ruleSets[j].DNS })
+	return ruleSets, nil
+}
+
+// GetRuleSet export — falls back to the fetcher's built-in rules as
+// version 0 until an admin saves a rule set for the domain.
+func (f *Filter) GetRuleSet(dns *string) (*entity.FilterRuleSet, error) {
+	defaults, ok := f.defaults[*dns]
+	if !ok {
+		return nil, NotFoundError("No such fetcher")
+	}
+	result, err := f.filterInf.FindOne(bson.M{"dns": *dns}, &entity.FilterRuleSet{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return &entity.FilterRuleSet{
+				DNS:       *dns,
+				Version:   0,
+				Rules:     defaults,
+				UpdatedBy: "default",
+			}, nil
+		}
+		return nil, err
+	}
+	return result.(*entity.FilterRuleSet), nil
+}
+
+// GetRuleSetHistory export — every saved version, newest first.
+func (f *Filter) GetRuleSetHistory(dns *string) ([]entity.FilterRuleSet, error) {
+	if _, ok := f.defaults[*dns]; !ok {
+		return nil, NotFoundError("No such fetcher")
+	}
+	result, err := f.filterHistoryInf.FindAll(bson.M{"dns": *dns}, &[]entity.FilterRuleSet{})
+	if err != nil {
+		return nil, err
+	}
+	history := *result.(*[]entity.FilterRuleSet)
+	sort.Slice(history, func(i, j int) bool { return history[i].Version > history[j].Version })
+	return history, nil
+}
+
+// UpdateRuleSet export — saves `rules` as the next version. The version
+// is taken by the same update that stores the rules, so concurrent edits
+// never share one.
+func (f *Filter) UpdateRuleSet(dns *string, rules []entity.FilterRule, account *string) (*entity.FilterRuleSet, error) {
+	contentFilter, err := usecase.NewContentFilter(rules)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := f.defaults[*dns]; !ok {
+		return nil, NotFoundError("No such fetcher")
+	}
+	result, err := f.filterInf.FindOneAndUpdate(bson.M{"dns": *dns}, bson.M{
+		"$inc": bson.M{"version": 1},
+		"$set": bson.M{"rules": rules, "updatedBy": *account, "updatedDatetime": time.Now()},
+	}, true, &entity.FilterRuleSet{})
+	if err != nil {
+		return nil, err
+	}
+	ruleSet := result.(*entity.FilterRuleSet)
+	if err := f.filterHistoryInf.Insert(ruleSet); err != nil {
+		return nil, err
+	}
+	f.cache(ruleSet.DNS, ruleSet.Version, contentFilter)
+	logrus.Printf("Filter rules of <%s> updated to version %d by %s", *dns, ruleSet.Version, *account)
+	return ruleSet, nil
+}
+
+// RestoreRuleSet export — saves an old version's rules as a new version,
+// so history stays append-only.
+func (f *Filter) RestoreRuleSet(dns *string, version int, account *string) (*entity.FilterRuleSet, error) {
+	if version == 0 {
+		return f.UpdateRuleSet(dns, f.defaults[*dns], account)
+	}
+	result, err := f.filterHistoryInf.FindOne(bson.M{"dns": *dns, "version": version}, &entity.FilterRuleSet{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Version %d not exists", version)
+		}
+		return nil, err
+	}
+	return f.UpdateRuleSet(dns, result.(*entity.FilterRuleSet).Rules, account)
+}
+
+// Preview export — runs `rules` against `content` without saving them.
+func (f *Filter) Preview(rules []entity.FilterRule, content *string) (*string, error) {
+	contentFilter, err := usecase.NewContentFilter(rules)
+	if err != nil {
+		return nil, err
+	}
+	return contentFilter.Apply(content), nil
+}
+
+// Apply export — runs the current rule set of `dns` over a chapter. The
+// rule set is loaded and compiled once, then kept until it is edited;
+// every ruleSetRecheck the stored version is compared to catch edits
+// made through other instances. Rule sets that fail to load or compile
+// leave the content as the last good rule set would, or untouched, and
+// are retried on the next chapter.
+func (f *Filter) Apply(dns *string, content *string) *string {
+	f.mutex.Lock()
+	cached, ok := f.compiled[*dns]
+	f.mutex.Unlock()
+	if ok && time.Since(cached.checked) < ruleSetRecheck {
+		return cached.filter.Apply(content)
+	}
+
+	ruleSet, err := f.GetRuleSet(dns)
+	if err != nil {
+		logrus.Printf("Failed to load filter rules of <%s>: %s", *dns, err.Error())
+	} else if ok && ruleSet.Version == cached.version {
+		f.cache(ruleSet.DNS, ruleSet.Version, cached.filter)
+	} else if contentFilter, err := usecase.NewContentFilter(ruleSet.Rules); err != nil {
+		logrus.Printf("Failed to compile filter rules of <%s@%d>: %s", ruleSet.DNS, ruleSet.Version, err.Error())
+	} else {
+		f.cache(ruleSet.DNS, ruleSet.Version, contentFilter)
+		return contentFilter.Apply(content)
+	}
+	if ok {
+		return cached.filter.Apply(content)
+	}
+	return content
+}
+
+// cache keeps `contentFilter` as checked now, unless a newer version is
+// cached already, e.g. saved while this one was loading.
+func (f *Filter) cache(dns string, version int, contentFilter *usecase.ContentFilter) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if cached, ok := f.compiled[dns]; !ok || cached.version <= version {
+		f.compiled[dns] = compiledRuleSet{version: version, filter: contentFilter, checked: time.Now()}
+	}
+}
@@ -0,0 +1,80 @@
+//go:build mongo
+
+package silverfish
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+	interf "silverfish/silverfish/interface"
+	usecase "silverfish/silverfish/usecase"
+)
+
+func testFilter(t *testing.T) (*Filter, *Filter) {
+	db := testDatabase(t)
+	fetchers := map[string]interf.INovelFetcher{"tw.hjwzw.com": usecase.NewFetcherHjwzw("tw.hjwzw.com")}
+	// Two instances over one database, like two servers.
+	return NewFilter(testInf(db, "filterRule"), testInf(db, "filterRuleHistory"), fetchers),
+		NewFilter(testInf(db, "filterRule"), testInf(db, "filterRuleHistory"), fetchers)
+}
+
+func TestUpdateRuleSetNumbersConcurrentEdits(t *testing.T) {
+	filter, _ := testFilter(t)
+	dns, account := "tw.hjwzw.com", "admin"
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rules := []entity.FilterRule{{Type: entity.FilterRuleLiteral, Pattern: "ad"}}
+			if _, err := filter.UpdateRuleSet(&dns, rules, &account); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	history, err := filter.GetRuleSetHistory(&dns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := []int{}
+	for _, ruleSet := range history {
+		versions = append(versions, ruleSet.Version)
+	}
+	sort.Ints(versions)
+	for i, version := range versions {
+		if version != i+1 {
+			t.Fatalf("versions %v, want 1 to 8", versions)
+		}
+	}
+	if current, _ := filter.GetRuleSet(&dns); current.Version != 8 {
+		t.Errorf("current version %d, want 8", current.Version)
+	}
+}
+
+func TestApplyPicksUpEditsOfOtherInstances(t *testing.T) {
+	filter, other := testFilter(t)
+	dns, account, content := "tw.hjwzw.com", "admin", "<p>ad</p>"
+	filter.UpdateRuleSet(&dns, []entity.FilterRule{{Type: entity.FilterRuleLiteral, Pattern: "ad", Replacement: "x"}}, &account)
+	if got := *filter.Apply(&dns, &content); got != "<p>x</p>" {
+		t.Fatalf("got %q", got)
+	}
+
+	other.UpdateRuleSet(&dns, []entity.FilterRule{{Type: entity.FilterRuleLiteral, Pattern: "ad", Replacement: "y"}}, &account)
+	if got := *filter.Apply(&dns, &content); got != "<p>x</p>" {
+		t.Errorf("rechecked early, got %q", got)
+	}
+	// Once the recheck is due the edit is seen.
+	filter.mutex.Lock()
+	cached := filter.compiled[dns]
+	cached.checked = time.Now().Add(-ruleSetRecheck)
+	filter.compiled[dns] = cached
+	filter.mutex.Unlock()
+	if got := *filter.Apply(&dns, &content); got != "<p>y</p>" {
+		t.Errorf("got %q after the recheck", got)
+	}
+}
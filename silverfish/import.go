@@ -0,0 +1,132 @@
+package silverfish
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+
+	entity "silverfish/silverfish/entity"
+	usecase "silverfish/silverfish/usecase"
+)
+
+const (
+	importWorkers = 4
+	maxImportURLs = 5000
+)
+
+// Importer export — adds many books at once, e.g. to seed a new
+// deployment.
+type Importer struct {
+	novel *Novel
+	comic *Comic
+	jobs  *Jobs
+}
+
+// NewImporter export
+func NewImporter(novel *Novel, comic *Comic, jobs *Jobs) *Importer {
+	i := new(Importer)
+	i.novel = novel
+	i.comic = comic
+	i.jobs = jobs
+	return i
+}
+
+// Start export — imports `urls` in a background job whose results form
+// the per-URL report. URLs are trimmed and deduplicated like those of an
+// uploaded list, whichever API they came through.
+func (i *Importer) Start(urls []string, by *string) (*entity.Job, error) {
+	urls = usecase.NormalizeImportList(urls)
+	if err := checkImportList(urls); err != nil {
+		return nil, err
+	}
+	return i.jobs.Start(entity.JobKindImport, fmt.Sprintf("%d URLs", len(urls)), by, func(progress *JobProgress) error {
+		progress.SetTotal(len(urls))
+		i.Run(urls, importWorkers, progress.Record)
+		return nil
+	})
+}
+
+// Run export — imports `urls` through a pool of `workers` crawlers and
+// returns the report in input order. `onResult`, if given, sees each
+// result as soon as it is known.
+func (i *Importer) Run(urls []string, workers int, onResult func(entity.JobResult)) []entity.JobResult {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]entity.JobResult, len(urls))
+	indexes := make(chan int)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				result := i.importOne(urls[index])
+				results[index] = result
+				if onResult != nil {
+					mutex.Lock()
+					onResult(result)
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for index := range urls {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+func (i *Importer) importOne(bookURL string) entity.JobResult {
+	result := entity.JobResult{Item: bookURL, Status: entity.ImportUnsupported}
+	if parsed, err := url.Parse(bookURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return result
+	}
+	var id, title string
+	var added bool
+	var err error
+	switch {
+	case i.novel.IsSupported(&bookURL):
+		result.Type = entity.AuditTargetNovel
+		err = runRecovered(func() error {
+			novel, isNew, err := i.novel.addNovelByURL(&bookURL)
+			if err == nil {
+				id, title, added = novel.NovelID, novel.Title, isNew
+			}
+			return err
+		})
+	case i.comic.IsSupported(&bookURL):
+		result.Type = entity.AuditTargetComic
+		err = runRecovered(func() error {
+			comic, isNew, err := i.comic.addComicByURL(&bookURL)
+			if err == nil {
+				id, title, added = comic.ComicID, comic.Title, isNew
+			}
+			return err
+		})
+	default:
+		return result
+	}
+	switch {
+	case err != nil:
+		result.Status, result.Error = entity.ImportFailed, err.Error()
+	case added:
+		result.Status, result.ID, result.Title = entity.ImportAdded, id, title
+	default:
+		result.Status, result.ID, result.Title = entity.ImportDuplicate, id, title
+	}
+	return result
+}
+
+func checkImportList(urls []string) error {
+	if len(urls) == 0 {
+		return InvalidArgumentError("No URLs to import")
+	}
+	if len(urls) > maxImportURLs {
+		return InvalidArgumentError("At most %d URLs per import", maxImportURLs)
+	}
+	return nil
+}
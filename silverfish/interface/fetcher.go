@@ -14,6 +14,9 @@ type INovelFetcher interface {
 
 	IsSplit(doc *goquery.Document) bool
 	Filter(raw *string) *string
+	DefaultFilterRules() []entity.FilterRule
+	Sanitize(raw *string) *string
+	SanitizeStats() map[string]int64
 
 	GetChapterURL(novel *entity.Novel, index int) *string
 	CrawlNovel(url *string) (*entity.Novel, error)
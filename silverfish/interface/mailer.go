@@ -0,0 +1,6 @@
+package interf
+
+// IMailer export
+type IMailer interface {
+	Send(to, subject, body string) error
+}
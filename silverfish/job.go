@@ -0,0 +1,135 @@
+package silverfish
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Jobs export
+type Jobs struct {
+	jobInf *entity.MongoInf
+}
+
+// NewJobs export
+func NewJobs(jobInf *entity.MongoInf) *Jobs {
+	j := new(Jobs)
+	j.jobInf = jobInf
+	return j
+}
+
+// JobProgress export — handed to a job's work to report on its items.
+type JobProgress struct {
+	jobs  *Jobs
+	jobID string
+}
+
+// SetTotal export
+func (p *JobProgress) SetTotal(total int) {
+	p.jobs.jobInf.Update(bson.M{"jobID": p.jobID}, bson.M{"$set": bson.M{"total": total}})
+}
+
+// Step export — records the outcome of one item; `id` is what the item
+// resolved to, if anything.
+func (p *JobProgress) Step(item, id string, err error) {
+	result := entity.JobResult{Item: item, ID: id}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	p.Record(result)
+}
+
+// Record export — Step for results carrying more than an error. Results
+// with an Error count as failed.
+func (p *JobProgress) Record(result entity.JobResult) {
+	counter := "done"
+	if result.Error != "" {
+		counter = "failed"
+	}
+	p.jobs.jobInf.Update(bson.M{"jobID": p.jobID}, bson.M{
+		"$inc":  bson.M{counter: 1},
+		"$push": bson.M{"results": result},
+	})
+}
+
+// Start export — runs `work` in the background and returns the job to
+// poll. An error returned by `work` fails the whole job.
+func (j *Jobs) Start(kind, target string, by *string, work func(progress *JobProgress) error) (*entity.Job, error) {
+	job := &entity.Job{
+		ID:              *RandomStr(24),
+		Kind:            kind,
+		Target:          target,
+		Status:          entity.JobStatusRunning,
+		Results:         []entity.JobResult{},
+		CreatedBy:       *by,
+		CreatedDatetime: time.Now(),
+	}
+	if err := j.jobInf.Insert(job); err != nil {
+		return nil, err
+	}
+	logrus.Printf("Job %s <%s %s> started by %s", job.ID, kind, target, *by)
+	go func() {
+		status, message := entity.JobStatusDone, ""
+		if err := runRecovered(func() error { return work(&JobProgress{jobs: j, jobID: job.ID}) }); err != nil {
+			status, message = entity.JobStatusFailed, err.Error()
+		}
+		j.jobInf.Update(bson.M{"jobID": job.ID}, bson.M{"$set": bson.M{
+			"status":           status,
+			"error":            message,
+			"finishedDatetime": time.Now(),
+		}})
+		logrus.Printf("Job %s <%s %s> %s", job.ID, kind, target, status)
+	}()
+	return job, nil
+}
+
+// GetJob export
+func (j *Jobs) GetJob(jobID *string) (*entity.Job, error) {
+	result, err := j.jobInf.FindOne(bson.M{"jobID": *jobID}, &entity.Job{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Job not exists")
+		}
+		return nil, err
+	}
+	return result.(*entity.Job), nil
+}
+
+// GetJobs export — the latest `limit` jobs without their per-item
+// results.
+func (j *Jobs) GetJobs(limit int64) ([]entity.Job, error) {
+	result, err := j.jobInf.FindPage(bson.M{}, bson.M{"results": 0}, bson.D{{Key: "createdDatetime", Value: -1}}, 0, limit, &[]entity.Job{})
+	if err != nil {
+		return nil, err
+	}
+	return *result.(*[]entity.Job), nil
+}
+
+// AbandonRunning export — jobs still running at startup died with the
+// previous process.
+func (j *Jobs) AbandonRunning() error {
+	_, err := j.jobInf.UpdateAll(bson.M{"status": entity.JobStatusRunning}, bson.M{"$set": bson.M{
+		"status":           entity.JobStatusFailed,
+		"error":            "Interrupted by restart",
+		"finishedDatetime": time.Now(),
+	}})
+	return err
+}
+
+// runRecovered turns a panic of `fn` into an error. Fetchers built on
+// rod panic on failure, which outside an HTTP handler would bring the
+// whole process down.
+func runRecovered(fn func() error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = UpstreamError(fmt.Errorf("Crawler panicked: %v", recovered))
+		}
+	}()
+	return fn()
+}
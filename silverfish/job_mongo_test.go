@@ -0,0 +1,182 @@
+//go:build mongo
+
+package silverfish
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+	interf "silverfish/silverfish/interface"
+)
+
+// waitJob polls `jobID` until it stops running.
+func waitJob(t *testing.T, jobs *Jobs, jobID string) *entity.Job {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		job, err := jobs.GetJob(&jobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != entity.JobStatusRunning {
+			return job
+		}
+	}
+	t.Fatalf("job %s still running", jobID)
+	return nil
+}
+
+func TestJobProgress(t *testing.T) {
+	db := testDatabase(t)
+	jobs := NewJobs(testInf(db, "job"))
+	admin := "admin"
+
+	job, err := jobs.Start(entity.JobKindRecrawlSource, "example.com", &admin, func(progress *JobProgress) error {
+		progress.SetTotal(3)
+		progress.Step("a", "1", nil)
+		progress.Step("b", "", errors.New("gone"))
+		progress.Record(entity.JobResult{Item: "c", Status: entity.ImportAdded})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != entity.JobStatusRunning || job.CreatedBy != admin {
+		t.Errorf("started job: %+v", job)
+	}
+	done := waitJob(t, jobs, job.ID)
+	if done.Status != entity.JobStatusDone || done.Total != 3 || done.Done != 2 || done.Failed != 1 {
+		t.Errorf("finished job: %+v", done)
+	}
+	if len(done.Results) != 3 || done.Results[1].Error != "gone" || done.FinishedDatetime.IsZero() {
+		t.Errorf("results: %+v", done.Results)
+	}
+
+	// Listing leaves the per-item results out.
+	listed, err := jobs.GetJobs(10)
+	if err != nil || len(listed) != 1 || len(listed[0].Results) != 0 {
+		t.Errorf("listed %+v, %v", listed, err)
+	}
+	unknown := "unknown"
+	if _, err := jobs.GetJob(&unknown); errorCode(err) != entity.ErrorNotFound {
+		t.Errorf("unknown job: got %v, want NotFoundError", err)
+	}
+}
+
+func TestJobFailure(t *testing.T) {
+	db := testDatabase(t)
+	jobs := NewJobs(testInf(db, "job"))
+	admin := "admin"
+	works := map[string]func(progress *JobProgress) error{
+		"source gone":                  func(*JobProgress) error { return errors.New("source gone") },
+		"Crawler panicked: no browser": func(*JobProgress) error { panic("no browser") },
+	}
+	for message, work := range works {
+		job, err := jobs.Start(entity.JobKindRecrawlSource, "example.com", &admin, work)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if failed := waitJob(t, jobs, job.ID); failed.Status != entity.JobStatusFailed || failed.Error != message {
+			t.Errorf("got %s %q, want failed %q", failed.Status, failed.Error, message)
+		}
+	}
+}
+
+func TestAbandonRunning(t *testing.T) {
+	db := testDatabase(t)
+	jobInf := testInf(db, "job")
+	jobs := NewJobs(jobInf)
+	jobInf.Insert(
+		&entity.Job{ID: "running", Status: entity.JobStatusRunning},
+		&entity.Job{ID: "done", Status: entity.JobStatusDone},
+	)
+	if err := jobs.AbandonRunning(); err != nil {
+		t.Fatal(err)
+	}
+	for jobID, want := range map[string]string{"running": entity.JobStatusFailed, "done": entity.JobStatusDone} {
+		if job, _ := jobs.GetJob(&jobID); job.Status != want {
+			t.Errorf("%s: got %s, want %s", jobID, job.Status, want)
+		}
+	}
+}
+
+type refreshNovelFetcher struct {
+	interf.INovelFetcher
+	fetched int
+}
+
+func (f *refreshNovelFetcher) DefaultFilterRules() []entity.FilterRule { return nil }
+
+func (f *refreshNovelFetcher) Sanitize(raw *string) *string { return raw }
+
+func (f *refreshNovelFetcher) FetchNovelChapter(novel *entity.Novel, index int) (*string, error) {
+	f.fetched++
+	content := fmt.Sprintf("fetch %d", f.fetched)
+	return &content, nil
+}
+
+type refreshComicFetcher struct {
+	interf.IComicFetcher
+}
+
+func (refreshComicFetcher) FetchComicChapter(comic *entity.Comic, index int) ([]string, error) {
+	return []string{fmt.Sprintf("https://img.example.com/%d.jpg", index)}, nil
+}
+
+func TestRefreshChapter(t *testing.T) {
+	db := testDatabase(t)
+	audit := NewAudit(testInf(db, "audit"))
+	novelFetcher := &refreshNovelFetcher{}
+	novelFetchers := map[string]interf.INovelFetcher{"novel.example.com": novelFetcher}
+	filter := NewFilter(testInf(db, "filterRule"), testInf(db, "filterRuleHistory"), novelFetchers)
+	novelInf, comicInf := testInf(db, "novel"), testInf(db, "comic")
+	novelSer := NewNovel(nil, audit, nil, filter, novelInf, testInf(db, "novelChapter"), novelFetchers, 0)
+	comicSer := NewComic(nil, audit, nil, comicInf, map[string]interf.IComicFetcher{"comic.example.com": refreshComicFetcher{}}, 0)
+	novelInf.Insert(&entity.Novel{NovelID: "n", DNS: "novel.example.com", Chapters: []entity.NovelChapter{{Title: "one", URL: "1"}}})
+	comicInf.Insert(&entity.Comic{ComicID: "c", DNS: "comic.example.com", Chapters: []entity.ComicChapter{{Title: "one"}, {Title: "two"}}})
+	admin, novelID, comicID, first, second := "admin", "n", "c", "0", "1"
+
+	// The cached content is dropped and fetched again.
+	if content, _ := novelSer.GetNovelChapter(&novelID, &first); *content != "fetch 1" {
+		t.Fatalf("got %q", *content)
+	}
+	if content, err := novelSer.RefreshChapter(&novelID, &first, &admin); err != nil || *content != "fetch 2" {
+		t.Errorf("refreshed novel chapter: got %v, %v", content, err)
+	}
+	if content, _ := novelSer.GetNovelChapter(&novelID, &first); *content != "fetch 2" {
+		t.Errorf("refreshed content isn't cached: got %q", *content)
+	}
+
+	images, err := comicSer.RefreshChapter(&comicID, &second, &admin)
+	if err != nil || len(images) != 1 {
+		t.Fatalf("refreshed comic chapter: got %v, %v", images, err)
+	}
+	result, _ := comicInf.FindOne(map[string]string{"comicID": comicID}, &entity.Comic{})
+	if stored := result.(*entity.Comic).Chapters[1].ImageURL; len(stored) != 1 || stored[0] != images[0] {
+		t.Errorf("stored images %v, want %v", stored, images)
+	}
+
+	for _, targetType := range []string{entity.AuditTargetNovel, entity.AuditTargetComic} {
+		targetID := ""
+		entries, _ := audit.GetEntries(&targetType, &targetID, 10)
+		if len(entries) != 1 || entries[0].Action != entity.AuditActionRefresh || entries[0].Account != admin {
+			t.Errorf("%s audit: %+v", targetType, entries)
+		}
+	}
+
+	bad, outOfRange, unknown := "x", "5", "unknown"
+	if _, err := comicSer.RefreshChapter(&comicID, &bad, &admin); errorCode(err) != entity.ErrorInvalidArgument {
+		t.Errorf("bad index: got %v", err)
+	}
+	if _, err := comicSer.RefreshChapter(&comicID, &outOfRange, &admin); errorCode(err) != entity.ErrorInvalidArgument {
+		t.Errorf("index out of range: got %v", err)
+	}
+	if _, err := comicSer.RefreshChapter(&unknown, &first, &admin); errorCode(err) != entity.ErrorNotFound {
+		t.Errorf("unknown comic: got %v", err)
+	}
+	if _, err := novelSer.RefreshChapter(&unknown, &first, &admin); errorCode(err) != entity.ErrorNotFound {
+		t.Errorf("unknown novel: got %v", err)
+	}
+}
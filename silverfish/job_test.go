@@ -0,0 +1,22 @@
+package silverfish
+
+import (
+	"errors"
+	"testing"
+
+	entity "silverfish/silverfish/entity"
+)
+
+func TestRunRecovered(t *testing.T) {
+	if err := runRecovered(func() error { return nil }); err != nil {
+		t.Errorf("nil: got %v", err)
+	}
+	failed := errors.New("failed")
+	if err := runRecovered(func() error { return failed }); err != failed {
+		t.Errorf("error: got %v, want it passed through", err)
+	}
+	err := runRecovered(func() error { panic("navigation failed") })
+	if errorCode(err) != entity.ErrorUpstream || err.Error() != "Crawler panicked: navigation failed" {
+		t.Errorf("panic: got %v, want an UpstreamError", err)
+	}
+}
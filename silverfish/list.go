@@ -0,0 +1,153 @@
+package silverfish
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// listSortFields maps the `sort` options of the list endpoints to the
+// stored field, and whether it runs largest / newest first by default.
+var listSortFields = map[string]struct {
+	field string
+	desc  bool
+}{
+	"title":      {"title", false},
+	"author":     {"author", false},
+	"lastUpdate": {"lastUpdateDatetime", true},
+	"added":      {"addedDatetime", true},
+	"popularity": {"popularity", true},
+}
+
+// listPlan is a ListQuery translated to Mongo: `filter` selects the whole
+// result set (for the total count), `selector` additionally skips
+// everything up to the cursor. Paging is keyset-based on the sort field
+// with the book ID as tie-breaker, so it stays stable while books are
+// added or recrawled between requests.
+type listPlan struct {
+	filter   bson.M
+	selector bson.M
+	sort     bson.D
+	field    string
+	idKey    string
+	limit    int
+	// scope is what a cursor of this plan is valid for: the sort, its
+	// order and the filters. Resuming with other ones would skip or
+	// repeat books, so such cursors are refused.
+	scope string
+}
+
+type listCursor struct {
+	Scope string        `bson:"s"`
+	Value bson.RawValue `bson:"v"`
+	ID    string        `bson:"id"`
+}
+
+func newListPlan(query *entity.ListQuery, shouldFetchDisable bool, idKey string) (*listPlan, error) {
+	if query.Sort == "" {
+		query.Sort = "title"
+	}
+	sortField, ok := listSortFields[query.Sort]
+	if !ok {
+		return nil, InvalidArgumentError("Unknown sort %s", query.Sort)
+	}
+	desc := sortField.desc
+	switch query.Order {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		return nil, InvalidArgumentError("Unknown order %s", query.Order)
+	}
+	if query.Limit < 0 {
+		return nil, InvalidArgumentError("Invalid limit")
+	}
+
+	plan := &listPlan{field: sortField.field, idKey: idKey, limit: query.Limit}
+	plan.filter = bson.M{}
+	if !shouldFetchDisable {
+		plan.filter["isEnable"] = true
+	} else if query.Enabled != nil {
+		plan.filter["isEnable"] = *query.Enabled
+	}
+	if query.Source != "" {
+		plan.filter["dns"] = query.Source
+	}
+	if !query.UpdatedSince.IsZero() {
+		plan.filter["lastUpdateDatetime"] = bson.M{"$gte": query.UpdatedSince}
+	}
+
+	direction, compare := 1, "$gt"
+	if desc {
+		direction, compare = -1, "$lt"
+	}
+	plan.sort = bson.D{{Key: plan.field, Value: direction}, {Key: idKey, Value: direction}}
+	plan.scope = fmt.Sprintf("%s %d %s", query.Sort, direction, filterKey(plan.filter))
+
+	plan.selector = plan.filter
+	if query.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+		if err != nil {
+			return nil, InvalidArgumentError("Invalid cursor")
+		}
+		cursor := new(listCursor)
+		if err := bson.Unmarshal(raw, cursor); err != nil {
+			return nil, InvalidArgumentError("Invalid cursor")
+		}
+		if cursor.Scope != plan.scope {
+			return nil, InvalidArgumentError("Cursor is of another sort, order or filter")
+		}
+		plan.selector = bson.M{"$and": bson.A{plan.filter, bson.M{"$or": bson.A{
+			bson.M{plan.field: bson.M{compare: cursor.Value}},
+			bson.M{plan.field: cursor.Value, idKey: bson.M{compare: cursor.ID}},
+		}}}}
+	}
+	return plan, nil
+}
+
+// fetchLimit asks for one extra record to learn whether a next page exists.
+func (plan *listPlan) fetchLimit() int64 {
+	if plan.limit == 0 {
+		return 0
+	}
+	return int64(plan.limit + 1)
+}
+
+// filterKey writes `filter` the same way whatever its map order.
+func filterKey(filter bson.M) string {
+	keys := []string{}
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := ""
+	for _, key := range keys {
+		result += fmt.Sprintf("%s=%v;", key, filter[key])
+	}
+	return result
+}
+
+// cursorAfter builds the cursor resuming right after `last`, the last
+// book of the current page.
+func (plan *listPlan) cursorAfter(last interface{}) (string, error) {
+	raw, err := bson.Marshal(last)
+	if err != nil {
+		return "", err
+	}
+	doc := bson.Raw(raw)
+	cursor, err := bson.Marshal(&listCursor{
+		Scope: plan.scope,
+		Value: doc.Lookup(plan.field),
+		ID:    doc.Lookup(plan.idKey).StringValue(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(cursor), nil
+}
@@ -0,0 +1,145 @@
+package silverfish
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestListPlan(t *testing.T) {
+	enabled := false
+	cases := []struct {
+		name               string
+		query              entity.ListQuery
+		shouldFetchDisable bool
+		filter             bson.M
+		sort               bson.D
+	}{
+		{"title by default", entity.ListQuery{}, false,
+			bson.M{"isEnable": true}, bson.D{{Key: "title", Value: 1}, {Key: "novelID", Value: 1}}},
+		{"newest first by default", entity.ListQuery{Sort: "lastUpdate"}, false,
+			bson.M{"isEnable": true}, bson.D{{Key: "lastUpdateDatetime", Value: -1}, {Key: "novelID", Value: -1}}},
+		{"order overrides", entity.ListQuery{Sort: "popularity", Order: "asc"}, false,
+			bson.M{"isEnable": true}, bson.D{{Key: "popularity", Value: 1}, {Key: "novelID", Value: 1}}},
+		{"hidden books for curators", entity.ListQuery{Order: "desc"}, true,
+			bson.M{}, bson.D{{Key: "title", Value: -1}, {Key: "novelID", Value: -1}}},
+		{"enabled only counts for curators", entity.ListQuery{Enabled: &enabled}, false,
+			bson.M{"isEnable": true}, bson.D{{Key: "title", Value: 1}, {Key: "novelID", Value: 1}}},
+		{"filters", entity.ListQuery{Enabled: &enabled, Source: "example.com", UpdatedSince: time.Unix(0, 0)}, true,
+			bson.M{"isEnable": false, "dns": "example.com", "lastUpdateDatetime": bson.M{"$gte": time.Unix(0, 0)}},
+			bson.D{{Key: "title", Value: 1}, {Key: "novelID", Value: 1}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := newListPlan(&tc.query, tc.shouldFetchDisable, "novelID")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(plan.filter, tc.filter) {
+				t.Errorf("filter %v, want %v", plan.filter, tc.filter)
+			}
+			if !reflect.DeepEqual(plan.sort, tc.sort) {
+				t.Errorf("sort %v, want %v", plan.sort, tc.sort)
+			}
+		})
+	}
+}
+
+func TestListPlanRejects(t *testing.T) {
+	for name, query := range map[string]entity.ListQuery{
+		"unknown sort":      {Sort: "rating"},
+		"unknown order":     {Order: "up"},
+		"negative limit":    {Limit: -1},
+		"cursor not base64": {Cursor: "%%%"},
+		"cursor not bson":   {Cursor: "bm90IGJzb24"},
+	} {
+		if _, err := newListPlan(&query, false, "novelID"); errorCode(err) != entity.ErrorInvalidArgument {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestListPlanFetchLimit(t *testing.T) {
+	for limit, want := range map[int]int64{0: 0, 1: 2, 100: 101} {
+		plan, err := newListPlan(&entity.ListQuery{Limit: limit}, false, "novelID")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := plan.fetchLimit(); got != want {
+			t.Errorf("limit %d fetches %d, want %d", limit, got, want)
+		}
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	query := &entity.ListQuery{Sort: "popularity", Source: "example.com", Limit: 20}
+	plan, err := newListPlan(query, false, "novelID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := plan.cursorAfter(entity.NovelInfo{NovelID: "b", Popularity: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next page resumes after the last book: lower popularity, or the
+	// same popularity and an ID after it.
+	next, err := newListPlan(&entity.ListQuery{Sort: "popularity", Source: "example.com", Limit: 20, Cursor: cursor}, false, "novelID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resume := next.selector["$and"].(bson.A)[1].(bson.M)["$or"].(bson.A)
+	var after struct {
+		Popularity struct {
+			Lt int32 `bson:"$lt"`
+		} `bson:"popularity"`
+	}
+	var tie struct {
+		Popularity int32 `bson:"popularity"`
+		NovelID    struct {
+			Lt string `bson:"$lt"`
+		} `bson:"novelID"`
+	}
+	for i, target := range []interface{}{&after, &tie} {
+		raw, err := bson.Marshal(resume[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bson.Unmarshal(raw, target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after.Popularity.Lt != 7 || tie.Popularity != 7 || tie.NovelID.Lt != "b" {
+		t.Errorf("resumes with %+v or %+v", after, tie)
+	}
+	if !reflect.DeepEqual(next.selector["$and"].(bson.A)[0], next.filter) {
+		t.Error("the next page lost the filters")
+	}
+
+	// The cursor only resumes the listing it came from.
+	hidden := false
+	for name, other := range map[string]struct {
+		query              entity.ListQuery
+		shouldFetchDisable bool
+	}{
+		"sort":         {entity.ListQuery{Sort: "title", Source: "example.com"}, false},
+		"order":        {entity.ListQuery{Sort: "popularity", Order: "asc", Source: "example.com"}, false},
+		"source":       {entity.ListQuery{Sort: "popularity", Source: "example.org"}, false},
+		"since":        {entity.ListQuery{Sort: "popularity", Source: "example.com", UpdatedSince: time.Unix(0, 0)}, false},
+		"hidden books": {entity.ListQuery{Sort: "popularity", Source: "example.com"}, true},
+		"enabled":      {entity.ListQuery{Sort: "popularity", Source: "example.com", Enabled: &hidden}, true},
+	} {
+		other.query.Cursor = cursor
+		if _, err := newListPlan(&other.query, other.shouldFetchDisable, "novelID"); errorCode(err) != entity.ErrorInvalidArgument {
+			t.Errorf("cursor reused with another %s: got %v", name, err)
+		}
+	}
+	// Explicitly asking for the default order is the same listing.
+	if _, err := newListPlan(&entity.ListQuery{Sort: "popularity", Order: "desc", Source: "example.com", Cursor: cursor}, false, "novelID"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build mongo
+
+// Database suite: exercises the services against a real MongoDB, for
+// what the atomic updates and indexes guarantee.
+//
+// Gated by `-tags=mongo` so normal `go test ./...` stays offline-safe.
+// Set SILVERFISH_TEST_DB_HOST (default localhost:27017); every test runs
+// in a database of its own, dropped afterwards.
+package silverfish
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"silverfish/migration"
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDatabase connects to the test server and returns a fresh,
+// migrated database.
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+	host := os.Getenv("SILVERFISH_TEST_DB_HOST")
+	if host == "" {
+		host = "localhost:27017"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://"+host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database(fmt.Sprintf("silverfish_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	if _, err := migration.New(db).Run(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func testInf(db *mongo.Database, name string) *entity.MongoInf {
+	return entity.NewMongoInf(db.Collection(name))
+}
+
+func testAuth(db *mongo.Database) *Auth {
+	hashSalt := "salt"
+	return NewAuth(&hashSalt, testInf(db, "user"), testInf(db, "session"), testInf(db, "apiToken"))
+}
@@ -5,17 +5,23 @@ import (
 	"fmt"
 	"silverfish/silverfish/entity"
 	interf "silverfish/silverfish/interface"
+	usecase "silverfish/silverfish/usecase"
 	"strconv"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Novel export
 type Novel struct {
 	auth          *Auth
+	audit         *Audit
+	jobs          *Jobs
+	filter        *Filter
 	novelInf      *entity.MongoInf
+	chapterInf    *entity.MongoInf
 	novelFetchers map[string]interf.INovelFetcher
 	crawlDuration int
 }
@@ -23,13 +29,20 @@ type Novel struct {
 // NewNovel export
 func NewNovel(
 	auth *Auth,
-	novelInf *entity.MongoInf,
+	audit *Audit,
+	jobs *Jobs,
+	filter *Filter,
+	novelInf, chapterInf *entity.MongoInf,
 	novelFetchers map[string]interf.INovelFetcher,
 	crawlDuration int,
 ) *Novel {
 	n := new(Novel)
 	n.auth = auth
+	n.audit = audit
+	n.jobs = jobs
+	n.filter = filter
 	n.novelInf = novelInf
+	n.chapterInf = chapterInf
 	n.novelFetchers = novelFetchers
 	n.crawlDuration = crawlDuration
 	return n
@@ -44,46 +57,226 @@ func (n *Novel) GetFetcherNameLists() []string {
 	return names
 }
 
-// GetNovels export
-func (n *Novel) GetNovels(shouldFetchDisable bool) (*[]entity.NovelInfo, error) {
-	selector := bson.M{"isEnable": true}
-	if shouldFetchDisable {
-		selector = nil
+// GetSanitizeStats export
+func (n *Novel) GetSanitizeStats() map[string]map[string]int64 {
+	stats := map[string]map[string]int64{}
+	for domain, fetcher := range n.novelFetchers {
+		stats[domain] = fetcher.SanitizeStats()
 	}
-	result, err := n.novelInf.FindSelectAll(selector, bson.M{
-		"isEnable": 1, "novelID": 1, "coverUrl": 1, "title": 1, "author": 1, "lastCrawlTime": 1}, &[]entity.NovelInfo{})
-	return result.(*[]entity.NovelInfo), err
+	return stats
+}
+
+// GetNovels export — filters, sort and paging are pushed down to Mongo.
+// The returned page carries the total match count and, when more
+// results follow, the cursor of the next page.
+func (n *Novel) GetNovels(shouldFetchDisable bool, query *entity.ListQuery) (*[]entity.NovelInfo, *entity.ListPage, error) {
+	plan, err := newListPlan(query, shouldFetchDisable, "novelID")
+	if err != nil {
+		return nil, nil, err
+	}
+	total, err := n.novelInf.Count(plan.filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := n.novelInf.FindPage(plan.selector, bson.M{
+		"isEnable": 1, "novelID": 1, "coverUrl": 1, "title": 1, "author": 1, "lastCrawlTime": 1,
+		"addedDatetime": 1, "lastUpdateDatetime": 1, "popularity": 1}, plan.sort, 0, plan.fetchLimit(), &[]entity.NovelInfo{})
+	if err != nil {
+		return nil, nil, err
+	}
+	novels := result.(*[]entity.NovelInfo)
+	page := &entity.ListPage{Total: total}
+	if plan.limit > 0 && len(*novels) > plan.limit {
+		*novels = (*novels)[:plan.limit]
+		page.NextCursor, err = plan.cursorAfter((*novels)[plan.limit-1])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return novels, page, nil
+}
+
+// GetDuplicates export — groups of novels whose title and author match
+// once script, case and punctuation are normalized away, e.g. the same
+// book added from a Simplified and a Traditional source.
+func (n *Novel) GetDuplicates() ([][]entity.NovelInfo, error) {
+	result, _, err := n.GetNovels(true, &entity.ListQuery{})
+	if err != nil {
+		return nil, err
+	}
+	groups := map[string][]entity.NovelInfo{}
+	keys := []string{}
+	for _, info := range *result {
+		key := usecase.NormalizeTitle(info.Title) + "/" + usecase.NormalizeTitle(info.Author)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], info)
+	}
+	duplicates := [][]entity.NovelInfo{}
+	for _, key := range keys {
+		if len(groups[key]) > 1 {
+			duplicates = append(duplicates, groups[key])
+		}
+	}
+	return duplicates, nil
 }
 
 // GetNovelByID export
 func (n *Novel) GetNovelByID(novelID *string) (*entity.Novel, error) {
 	result, err := n.novelInf.FindOne(bson.M{"novelID": *novelID}, &entity.Novel{})
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Novel not exists")
+		}
 		return nil, err
 	}
 
 	novel := result.(*entity.Novel)
 	if time.Since(novel.LastCrawlTime).Minutes() > float64(n.crawlDuration) {
-		lastCrawlTime := novel.LastCrawlTime
-		novel, err = n.novelFetchers[novel.DNS].UpdateNovelInfo(novel)
-		if err != nil {
-			logrus.Print(err.Error())
-			return nil, err
+		return n.refresh(novel)
+	}
+
+	return novel, nil
+}
+
+// GetNovelsByIDs export — many novels in one query, without their
+// chapters and as stored, i.e. not recrawled when stale. IDs not in the
+// library are left out.
+func (n *Novel) GetNovelsByIDs(novelIDs []string) ([]entity.Novel, error) {
+	result, err := n.novelInf.FindSelectAll(bson.M{"novelID": bson.M{"$in": novelIDs}},
+		bson.M{"chapters": 0, "searchTokens": 0}, &[]entity.Novel{})
+	if err != nil {
+		return nil, err
+	}
+	return *result.(*[]entity.Novel), nil
+}
+
+// GetNovelChapterTitles export — the chapter titles of many novels in
+// one query, keyed by novel ID. Hidden novels are left out unless
+// `shouldFetchDisable`.
+func (n *Novel) GetNovelChapterTitles(shouldFetchDisable bool, novelIDs []string) (map[string][]string, error) {
+	filter := bson.M{"novelID": bson.M{"$in": novelIDs}}
+	if !shouldFetchDisable {
+		filter["isEnable"] = true
+	}
+	result, err := n.novelInf.FindSelectAll(filter,
+		bson.M{"novelID": 1, "chapters.title": 1}, &[]entity.Novel{})
+	if err != nil {
+		return nil, err
+	}
+	titles := map[string][]string{}
+	for _, novel := range *result.(*[]entity.Novel) {
+		titles[novel.NovelID] = make([]string, len(novel.Chapters))
+		for i, chapter := range novel.Chapters {
+			titles[novel.NovelID][i] = chapter.Title
 		}
-		n.novelInf.Update(bson.M{"novelID": *novelID}, novel)
-		logrus.Printf("Updated novel <novel_id: %s, title: %s> since %s", novel.NovelID, novel.Title, lastCrawlTime)
 	}
+	return titles, nil
+}
+
+// refresh crawls `novel` from its source again and stores the result.
+func (n *Novel) refresh(novel *entity.Novel) (*entity.Novel, error) {
+	fetcher, ok := n.novelFetchers[novel.DNS]
+	if !ok {
+		return nil, NotFoundError("No such fetcher")
+	}
+	lastCrawlTime := novel.LastCrawlTime
+	chapterCount := len(novel.Chapters)
+	isEnable := novel.IsEnable
+	novel, err := fetcher.UpdateNovelInfo(novel)
+	if err != nil {
+		logrus.Print(err.Error())
+		return nil, UpstreamError(err)
+	}
+	// Fetchers rebuild the info from the source page; keep the
+	// library's visibility and curator edits.
+	novel.IsEnable = isEnable
+	novel.ApplyOverrides()
+	novel.SearchTokens = usecase.SearchTokens(novel.Title, novel.Author, novel.Description)
+	if len(novel.Chapters) > chapterCount {
+		novel.LastUpdateDatetime = time.Now()
+	}
+	if err := n.novelInf.Update(bson.M{"novelID": novel.NovelID}, novel); err != nil {
+		return nil, err
+	}
+	logrus.Printf("Updated novel <novel_id: %s, title: %s> since %s", novel.NovelID, novel.Title, lastCrawlTime)
+	return novel, nil
+}
 
+// Recrawl export — updates the novel from its source now instead of
+// waiting for the crawl duration to pass.
+func (n *Novel) Recrawl(novelID *string, by *string) (*entity.Novel, error) {
+	result, err := n.novelInf.FindOne(bson.M{"novelID": *novelID}, &entity.Novel{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Novel not exists")
+		}
+		return nil, err
+	}
+	novel, err := n.refresh(result.(*entity.Novel))
+	if err != nil {
+		return nil, err
+	}
+	n.audit.Record(by, entity.AuditActionRecrawl, entity.AuditTargetNovel, *novelID, nil)
 	return novel, nil
 }
 
+// RecrawlSource export — recrawls every novel from `dns` in a background
+// job, pausing between books to go easy on the source.
+func (n *Novel) RecrawlSource(dns *string, by *string) (*entity.Job, error) {
+	if _, ok := n.novelFetchers[*dns]; !ok {
+		return nil, NotFoundError("No such fetcher")
+	}
+	return n.jobs.Start(entity.JobKindRecrawlSource, *dns, by, func(progress *JobProgress) error {
+		result, err := n.novelInf.FindSelectAll(bson.M{"dns": *dns}, bson.M{"novelID": 1, "title": 1}, &[]entity.Novel{})
+		if err != nil {
+			return err
+		}
+		novels := *result.(*[]entity.Novel)
+		progress.SetTotal(len(novels))
+		for i, novel := range novels {
+			if i > 0 {
+				time.Sleep(recrawlInterval)
+			}
+			err := runRecovered(func() error {
+				_, err := n.Recrawl(&novel.NovelID, by)
+				return err
+			})
+			progress.Step(novel.Title, novel.NovelID, err)
+		}
+		return nil
+	})
+}
+
+// RefreshChapter export — drops the cached content of a chapter and
+// fetches it again.
+func (n *Novel) RefreshChapter(novelID, chapterIndex *string, by *string) (*string, error) {
+	index, err := strconv.Atoi(*chapterIndex)
+	if err != nil {
+		return nil, InvalidArgumentError("Invalid chapter index")
+	}
+	if _, err := n.chapterInf.RemoveAll(bson.M{"novelID": *novelID, "index": index}); err != nil {
+		return nil, err
+	}
+	content, err := n.GetNovelChapter(novelID, chapterIndex)
+	if err != nil {
+		return nil, err
+	}
+	n.audit.Record(by, entity.AuditActionRefresh, entity.AuditTargetNovel, *novelID, map[string]entity.AuditChange{
+		"chapter": {To: *chapterIndex},
+	})
+	return content, nil
+}
+
 // RemoveNovelByID export
 func (n *Novel) RemoveNovelByID(novelID *string) error {
 	err := n.novelInf.Remove(bson.M{"novelID": *novelID})
 	if err != nil {
 		return err
 	}
-	err = n.auth.userInf.Update(bson.M{
+	n.chapterInf.RemoveAll(bson.M{"novelID": *novelID})
+	_, err = n.auth.userInf.UpdateAll(bson.M{
 		fmt.Sprintf(`bookmark.novel.%s`, *novelID): bson.M{
 			"$exists": true,
 		},
@@ -92,49 +285,153 @@ func (n *Novel) RemoveNovelByID(novelID *string) error {
 			fmt.Sprintf(`bookmark.novel.%s`, *novelID): "",
 		},
 	})
-	if err != nil && err.Error() != "not found" {
-		return err
+	return err
+}
+
+// AddNovelByURL export — `added` is false when the novel was in the library
+// already.
+func (n *Novel) AddNovelByURL(novelURL *string) (record *entity.Novel, added bool, err error) {
+	return n.addNovelByURL(novelURL)
+}
+
+// IsSupported export — whether a fetcher handles `novelURL`.
+func (n *Novel) IsSupported(novelURL *string) bool {
+	for _, fetcher := range n.novelFetchers {
+		if fetcher.Match(novelURL) {
+			return true
+		}
 	}
-	return nil
+	return false
 }
 
-// AddNovelByURL export
-func (n *Novel) AddNovelByURL(novelURL *string) (*entity.Novel, error) {
-	result, err := n.novelInf.FindOne(bson.M{"novelURL": *novelURL}, &entity.Novel{})
-	if err != nil {
-		for _, v := range n.novelFetchers {
-			if v.Match(novelURL) {
-				record, err := v.CrawlNovel(novelURL)
-				if err != nil {
-					logrus.Print(err.Error())
-					return nil, err
-				}
-				n.novelInf.Upsert(bson.M{"novelID": record.NovelID}, record)
-				return record, nil
+// addNovelByURL crawls the novel at `novelURL` into the library; `added` is
+// false when it was there already.
+func (n *Novel) addNovelByURL(novelURL *string) (*entity.Novel, bool, error) {
+	result, err := n.novelInf.FindOne(bson.M{"url": *novelURL}, &entity.Novel{})
+	if err == nil {
+		return result.(*entity.Novel), false, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, false, err
+	}
+	for _, v := range n.novelFetchers {
+		if v.Match(novelURL) {
+			record, err := v.CrawlNovel(novelURL)
+			if err != nil {
+				logrus.Print(err.Error())
+				return nil, false, UpstreamError(err)
 			}
+			record.SearchTokens = usecase.SearchTokens(record.Title, record.Author, record.Description)
+			record.AddedDatetime = time.Now()
+			record.LastUpdateDatetime = record.AddedDatetime
+			n.novelInf.Upsert(bson.M{"novelID": record.NovelID}, record)
+			return record, true, nil
 		}
-		return nil, errors.New("No suit fetcher")
-
 	}
-
-	return result.(*entity.Novel), nil
+	return nil, false, InvalidArgumentError("No suit fetcher")
 }
 
 // GetNovelChapter export
 func (n *Novel) GetNovelChapter(novelID, chapterIndex *string) (*string, error) {
+	record, content, err := n.FetchRawNovelChapter(novelID, chapterIndex)
+	if err != nil {
+		return nil, err
+	}
+	fetcher := n.novelFetchers[record.DNS]
+	return fetcher.Sanitize(n.filter.Apply(&record.DNS, content)), nil
+}
+
+// FetchRawNovelChapter export — chapter content as the fetcher returns
+// it, before filter rules and sanitizing. Used to preview filter rules.
+func (n *Novel) FetchRawNovelChapter(novelID, chapterIndex *string) (*entity.Novel, *string, error) {
 	index, err := strconv.Atoi(*chapterIndex)
 	if err != nil {
-		return nil, errors.New("Invalid chapter index")
+		return nil, nil, InvalidArgumentError("Invalid chapter index")
 	}
 	query, err := n.novelInf.FindOne(bson.M{"novelID": novelID}, &entity.Novel{})
 	if err != nil {
-		return nil, err
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil, NotFoundError("Novel not exists")
+		}
+		return nil, nil, err
 	}
 	record := query.(*entity.Novel)
 	if index < 0 || index >= len((*record).Chapters) {
-		return nil, errors.New("Wrong Index")
+		return nil, nil, InvalidArgumentError("Wrong Index")
 	} else if val, ok := n.novelFetchers[(*record).DNS]; ok {
-		return val.FetchNovelChapter(record, index)
+		chapterURL := record.Chapters[index].URL
+		cached, err := n.chapterInf.FindOne(bson.M{"novelID": record.NovelID, "index": index, "url": chapterURL}, &entity.NovelChapterCache{})
+		if err == nil {
+			return record, &cached.(*entity.NovelChapterCache).Content, nil
+		}
+		content, err := val.FetchNovelChapter(record, index)
+		if err != nil {
+			return nil, nil, UpstreamError(err)
+		}
+		if *content != "" {
+			n.chapterInf.Upsert(bson.M{"novelID": record.NovelID, "index": index}, &entity.NovelChapterCache{
+				NovelID:         record.NovelID,
+				Index:           index,
+				URL:             chapterURL,
+				Content:         *content,
+				FetchedDatetime: time.Now(),
+			})
+		}
+		return record, content, nil
+	}
+	return nil, nil, NotFoundError("No such fetcher")
+}
+
+// SetEnable export — shows or hides the novel for readers.
+func (n *Novel) SetEnable(novelID *string, enable bool, by *string) error {
+	result, err := n.novelInf.FindSelectOne(bson.M{"novelID": *novelID}, bson.M{"isEnable": 1}, &entity.Novel{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return NotFoundError("Novel not exists")
+		}
+		return err
+	}
+	if result.(*entity.Novel).IsEnable == enable {
+		return nil
+	}
+	if err := n.novelInf.Update(bson.M{"novelID": *novelID}, bson.M{
+		"$set": bson.M{"isEnable": enable},
+	}); err != nil {
+		return err
+	}
+	n.audit.Record(by, enableAction(enable), entity.AuditTargetNovel, *novelID, nil)
+	return nil
+}
+
+// UpdateOverrides export — `fields` maps override names (title, author,
+// description, coverUrl) to values; an empty value clears the override
+// and the crawled value returns on the next update, which is due at once.
+func (n *Novel) UpdateOverrides(novelID *string, fields map[string]string, by *string) (*entity.Novel, error) {
+	result, err := n.novelInf.FindOne(bson.M{"novelID": *novelID}, &entity.Novel{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Novel not exists")
+		}
+		return nil, err
 	}
-	return nil, errors.New("No such fetcher'")
+	novel := result.(*entity.Novel)
+	overrides, changes, cleared, err := mergeOverrides(novel.Overrides, fields)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return novel, nil
+	}
+	novel.Overrides = overrides
+	novel.ApplyOverrides()
+	novel.SearchTokens = usecase.SearchTokens(novel.Title, novel.Author, novel.Description)
+	if cleared {
+		novel.LastCrawlTime = time.Time{}
+	}
+	update := overridesUpdate(overrides, novel.Title, novel.Author, novel.Description, novel.CoverURL, novel.SearchTokens, cleared)
+	if err := n.novelInf.Update(bson.M{"novelID": *novelID}, update); err != nil {
+		return nil, err
+	}
+	n.audit.Record(by, entity.AuditActionOverride, entity.AuditTargetNovel, *novelID, changes)
+	return novel, nil
 }
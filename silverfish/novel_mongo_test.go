@@ -0,0 +1,36 @@
+//go:build mongo
+
+package silverfish
+
+import (
+	"reflect"
+	"testing"
+
+	entity "silverfish/silverfish/entity"
+)
+
+func TestChapterTitlesLeaveHiddenNovelsOut(t *testing.T) {
+	db := testDatabase(t)
+	novelInf := testInf(db, "novel")
+	novelSer := NewNovel(nil, nil, nil, nil, novelInf, testInf(db, "chapter"), nil, 0)
+	novelInf.Insert(
+		&entity.Novel{NovelID: "shown", IsEnable: true, Chapters: []entity.NovelChapter{{Title: "one"}}},
+		&entity.Novel{NovelID: "hidden", Chapters: []entity.NovelChapter{{Title: "two"}}},
+	)
+
+	ids := []string{"shown", "hidden"}
+	titles, err := novelSer.GetNovelChapterTitles(false, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]string{"shown": {"one"}}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("for readers got %v, want %v", titles, want)
+	}
+	titles, err = novelSer.GetNovelChapterTitles(true, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]string{"shown": {"one"}, "hidden": {"two"}}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("for curators got %v, want %v", titles, want)
+	}
+}
@@ -0,0 +1,243 @@
+package silverfish
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+	usecase "silverfish/silverfish/usecase"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+// OIDC export — single sign-on through an OpenID Connect provider. It
+// only vouches for who the user is; sessions are ordinary Auth sessions.
+type OIDC struct {
+	auth     *Auth
+	stateInf *entity.MongoInf
+	client   *usecase.OIDCClient
+}
+
+// NewOIDC export — a nil `config` leaves single sign-on disabled.
+func NewOIDC(auth *Auth, stateInf *entity.MongoInf, config *usecase.OIDCConfig) *OIDC {
+	o := new(OIDC)
+	o.auth = auth
+	o.stateInf = stateInf
+	if config != nil {
+		o.client = usecase.NewOIDCClient(config)
+	}
+	return o
+}
+
+// IsEnabled export
+func (o *OIDC) IsEnabled() bool { return o.client != nil }
+
+// GetIssuer export
+func (o *OIDC) GetIssuer() string {
+	if o.client == nil {
+		return ""
+	}
+	return o.client.Config().Issuer
+}
+
+// Authorize export — starts a login and returns the IdP URL to send the
+// user to. With `linkAccount` the resulting identity is linked to that
+// (already logged-in) account instead.
+func (o *OIDC) Authorize(linkAccount *string) (*string, error) {
+	if o.client == nil {
+		return nil, ForbiddenError("Single sign-on is not enabled")
+	}
+	verifier, challenge := usecase.NewPKCE()
+	state := &entity.OIDCState{
+		State:        usecase.RandomURLString(24),
+		Nonce:        usecase.RandomURLString(24),
+		CodeVerifier: verifier,
+		ExpireTS:     time.Now().Add(oidcStateTTL),
+	}
+	if linkAccount != nil {
+		state.LinkAccount = *linkAccount
+	}
+	authURL, err := o.client.AuthorizationURL(state.State, state.Nonce, challenge)
+	if err != nil {
+		// Only fails on the IdP's discovery document.
+		return nil, UpstreamError(err)
+	}
+	if err := o.stateInf.Insert(state); err != nil {
+		return nil, err
+	}
+	return &authURL, nil
+}
+
+// Callback export — finishes a login with the `code` and `state` the IdP
+// redirected back with, and returns the signed-in user.
+func (o *OIDC) Callback(state, code *string) (*entity.User, error) {
+	if o.client == nil {
+		return nil, ForbiddenError("Single sign-on is not enabled")
+	}
+	result, err := o.stateInf.FindOne(bson.M{"state": *state}, &entity.OIDCState{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, UnauthenticatedError("Unknown or used login state")
+		}
+		return nil, err
+	}
+	pending := result.(*entity.OIDCState)
+	o.stateInf.Remove(bson.M{"state": *state})
+	if time.Now().After(pending.ExpireTS) {
+		return nil, UnauthenticatedError("Login state expired")
+	}
+
+	idToken, err := o.client.Exchange(*code, pending.CodeVerifier)
+	if err != nil {
+		logrus.Printf("OIDC code exchange failed: %s", err.Error())
+		return nil, &Error{Code: entity.ErrorUpstream, Message: "Single sign-on failed", Cause: err}
+	}
+	claims, err := o.client.VerifyIDToken(idToken, pending.Nonce)
+	if err != nil {
+		logrus.Printf("OIDC id_token rejected: %s", err.Error())
+		return nil, UnauthenticatedError("Single sign-on failed")
+	}
+
+	user, err := o.resolveUser(claims, pending.LinkAccount)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ForbiddenError("Account disabled")
+	}
+	config := o.client.Config()
+	if config.AdminGroup != "" {
+		isAdmin := false
+		for _, group := range usecase.ClaimStrings(claims, config.GroupsClaim) {
+			isAdmin = isAdmin || group == config.AdminGroup
+		}
+		// Membership grants admin and leaving the group demotes to
+		// reader; curators are managed locally and left as they are.
+		if role := user.GetRole(); isAdmin && role != entity.RoleAdmin {
+			logrus.Printf("OIDC groups set role of %s to %s", user.Account, entity.RoleAdmin)
+			user.SetRole(entity.RoleAdmin)
+		} else if !isAdmin && role == entity.RoleAdmin {
+			logrus.Printf("OIDC groups set role of %s to %s", user.Account, entity.RoleReader)
+			user.SetRole(entity.RoleReader)
+		}
+	}
+	user.LastLoginDatetime = time.Now()
+	if _, err := o.auth.userInf.Upsert(bson.M{"account": user.Account}, user); err != nil {
+		return nil, err
+	}
+	return &entity.User{
+		IsAdmin:           user.IsAdmin,
+		Role:              user.GetRole(),
+		Account:           user.Account,
+		RegisterDatetime:  user.RegisterDatetime,
+		LastLoginDatetime: user.LastLoginDatetime,
+		Bookmark:          user.Bookmark,
+		Preference:        user.Preference,
+		OIDC:              user.OIDC,
+	}, nil
+}
+
+// resolveUser finds the account linked to the token's identity, links it
+// to `linkAccount`, or provisions a new one. Accounts are never matched
+// by name or email, which would let the IdP take over local accounts.
+func (o *OIDC) resolveUser(claims map[string]interface{}, linkAccount string) (*entity.User, error) {
+	config := o.client.Config()
+	subject, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+	identity := &entity.OIDCIdentity{
+		Issuer:         config.Issuer,
+		Subject:        subject,
+		Email:          email,
+		LinkedDatetime: time.Now(),
+	}
+
+	result, err := o.auth.userInf.FindOne(bson.M{"oidc.issuer": identity.Issuer, "oidc.subject": identity.Subject}, &entity.User{})
+	if err == nil {
+		user := result.(*entity.User)
+		if linkAccount != "" && linkAccount != user.Account {
+			return nil, InvalidArgumentError("Identity is linked to another account")
+		}
+		user.OIDC.Email = email
+		return user, nil
+	} else if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	if linkAccount != "" {
+		result, err := o.auth.userInf.FindOne(bson.M{"account": linkAccount}, &entity.User{})
+		if err != nil {
+			return nil, NotFoundError("Account not exists")
+		}
+		user := result.(*entity.User)
+		if user.OIDC != nil {
+			return nil, InvalidArgumentError("Account is linked to another identity")
+		}
+		user.OIDC = identity
+		logrus.Printf("Linked %s to OIDC subject %s", user.Account, subject)
+		return user, nil
+	}
+
+	if !config.AutoProvision {
+		return nil, UnauthenticatedError("No account is linked to this identity")
+	}
+	account, err := o.freeAccountName(claims)
+	if err != nil {
+		return nil, err
+	}
+	if email != "" {
+		// Emails are unique; one another account already uses is left
+		// for the user to sort out.
+		if count, err := o.auth.userInf.Count(bson.M{"email": email}); err != nil {
+			return nil, err
+		} else if count > 0 {
+			email = ""
+		}
+	}
+	registerTime := time.Now()
+	logrus.Printf("Provisioned %s for OIDC subject %s", account, subject)
+	return &entity.User{
+		Role:              entity.RoleReader,
+		Account:           account,
+		Email:             email,
+		RegisterDatetime:  registerTime,
+		LastLoginDatetime: registerTime,
+		Bookmark:          &entity.Bookmark{},
+		OIDC:              identity,
+	}, nil
+}
+
+// freeAccountName picks an account name from the token's
+// preferred_username (or email) that isn't taken yet.
+func (o *OIDC) freeAccountName(claims map[string]interface{}) (string, error) {
+	base, _ := claims["preferred_username"].(string)
+	if base == "" {
+		email, _ := claims["email"].(string)
+		base = strings.SplitN(email, "@", 2)[0]
+	}
+	if base = strings.TrimSpace(base); base == "" {
+		base = "user"
+	}
+	for i := 1; i <= 20; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, i)
+		}
+		if i == 20 {
+			candidate = base + "-" + *RandomStr(8)
+		}
+		count, err := o.auth.userInf.Count(bson.M{"account": candidate})
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+	return "", UpstreamError(fmt.Errorf("No free account name for %s", base))
+}
@@ -0,0 +1,79 @@
+package silverfish
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Current argon2id parameters. Stored hashes carry their own parameters,
+// so raising these only makes logins rehash; old hashes keep verifying.
+const (
+	argon2Memory  uint32 = 64 * 1024
+	argon2Time    uint32 = 3
+	argon2Threads uint8  = 2
+	argon2SaltLen        = 16
+	argon2KeyLen  uint32 = 32
+)
+
+// HashPassword export — argon2id with a per-user random salt, encoded in
+// the PHC string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+func HashPassword(password *string) (*string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key := argon2.IDKey([]byte(*password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	encoded := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+	return &encoded, nil
+}
+
+// VerifyPassword export — checks `password` against a stored hash of any
+// scheme we've used. Anything not in PHC format is the legacy
+// SHA512Str(password, hashSalt). `rehash` reports that the stored hash
+// should be replaced by a fresh HashPassword.
+func VerifyPassword(password, stored, legacySalt *string) (ok bool, rehash bool) {
+	if !strings.HasPrefix(*stored, "$") {
+		legacy := SHA512Str(password, legacySalt)
+		return subtle.ConstantTimeCompare([]byte(*legacy), []byte(*stored)) == 1, true
+	}
+
+	var version int
+	var memory, iterations uint32
+	var threads uint8
+	parts := strings.Split(*stored, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false
+	}
+	candidate := argon2.IDKey([]byte(*password), salt, iterations, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return false, false
+	}
+	rehash = memory != argon2Memory || iterations != argon2Time || threads != argon2Threads ||
+		len(salt) != argon2SaltLen || uint32(len(key)) != argon2KeyLen
+	return true, rehash
+}
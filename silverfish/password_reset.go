@@ -0,0 +1,199 @@
+package silverfish
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+	interf "silverfish/silverfish/interface"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	minPasswordLength     = 8
+	adminPasswordResetTTL = 24 * time.Hour
+	emailPasswordResetTTL = time.Hour
+	passwordResetByEmail  = "email"
+)
+
+func validatePassword(password *string) error {
+	if len([]rune(*password)) < minPasswordLength {
+		return InvalidArgumentError("Password should be at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+// ChangePassword export — every other session of the account is signed
+// out. Accounts without a password yet (e.g. single sign-on only) may set
+// one without `oldPassword`.
+func (a *Auth) ChangePassword(account, oldPassword, newPassword, keepSessionID *string) error {
+	result, err := a.userInf.FindOne(bson.M{"account": *account}, &entity.User{})
+	if err != nil {
+		return NotFoundError("Account not exists")
+	}
+	user := result.(*entity.User)
+	if user.Password != "" {
+		if ok, _ := VerifyPassword(oldPassword, &user.Password, a.hashSalt); !ok {
+			return UnauthenticatedError("Password wrong")
+		}
+	}
+	if err := a.setPassword(account, newPassword); err != nil {
+		return err
+	}
+	_, err = a.RevokeSessions(account, keepSessionID)
+	return err
+}
+
+func (a *Auth) setPassword(account, password *string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return a.userInf.Update(bson.M{"account": *account}, bson.M{
+		"$set": bson.M{"password": *hashedPassword},
+	})
+}
+
+// PasswordReset export — one-time reset tokens, issued by admins or
+// mailed to the account's address.
+type PasswordReset struct {
+	auth     *Auth
+	resetInf *entity.MongoInf
+	mailer   interf.IMailer
+	resetURL string
+}
+
+// NewPasswordReset export — without a `mailer` only admins can issue
+// resets. `resetURL` is the page mailed to users, with `{token}` standing
+// for the token.
+func NewPasswordReset(auth *Auth, resetInf *entity.MongoInf, mailer interf.IMailer, resetURL string) *PasswordReset {
+	pr := new(PasswordReset)
+	pr.auth = auth
+	pr.resetInf = resetInf
+	pr.mailer = mailer
+	pr.resetURL = resetURL
+	return pr
+}
+
+// IsEmailEnabled export
+func (pr *PasswordReset) IsEmailEnabled() bool { return pr.mailer != nil }
+
+// Issue export — replaces any outstanding reset of `account`. Disabled
+// accounts get none.
+func (pr *PasswordReset) Issue(account, issuedBy *string, ttl time.Duration) (*string, *entity.PasswordReset, error) {
+	if disabled, err := pr.isDisabled(account); err != nil {
+		return nil, nil, err
+	} else if disabled {
+		return nil, nil, ForbiddenError("Account disabled")
+	}
+	if _, err := pr.resetInf.RemoveAll(bson.M{"account": *account}); err != nil {
+		return nil, nil, err
+	}
+	token := RandomToken()
+	reset := &entity.PasswordReset{
+		TokenHash: *SHA256Str(token),
+		Account:   *account,
+		IssuedBy:  *issuedBy,
+		ExpireTS:  time.Now().Add(ttl),
+	}
+	if err := pr.resetInf.Insert(reset); err != nil {
+		return nil, nil, err
+	}
+	logrus.Printf("Password reset of %s issued by %s", *account, *issuedBy)
+	return token, reset, nil
+}
+
+// IssueByAdmin export
+func (pr *PasswordReset) IssueByAdmin(account, admin *string) (*string, *entity.PasswordReset, error) {
+	return pr.Issue(account, admin, adminPasswordResetTTL)
+}
+
+// Request export — mails a reset link to the account registered with
+// `email`. It never tells whether such an account exists or is disabled;
+// failures are only logged.
+func (pr *PasswordReset) Request(email *string) error {
+	if pr.mailer == nil {
+		return ForbiddenError("Password reset by email is not enabled")
+	}
+	address := strings.TrimSpace(*email)
+	if address == "" {
+		return InvalidArgumentError("Field email should not be empty")
+	}
+	result, err := pr.auth.userInf.FindOne(bson.M{"email": address}, &entity.User{})
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			logrus.Printf("Failed to look up password reset of %s: %s", address, err.Error())
+		}
+		return nil
+	}
+	user := result.(*entity.User)
+	issuedBy := passwordResetByEmail
+	token, _, err := pr.Issue(&user.Account, &issuedBy, emailPasswordResetTTL)
+	if err != nil {
+		logrus.Printf("Failed to issue password reset of %s: %s", user.Account, err.Error())
+		return nil
+	}
+	link := strings.ReplaceAll(pr.resetURL, "{token}", *token)
+	go func() {
+		err := pr.mailer.Send(address, "Reset your Silverfish password", fmt.Sprintf(
+			"Someone asked to reset the password of your Silverfish account %s.\n\n"+
+				"Open the link below within %d minutes to choose a new one:\n%s\n\n"+
+				"If it wasn't you, just ignore this mail.\n",
+			user.Account, int(emailPasswordResetTTL.Minutes()), link))
+		if err != nil {
+			logrus.Printf("Failed to mail password reset of %s: %s", user.Account, err.Error())
+		}
+	}()
+	return nil
+}
+
+// Reset export — spends a reset token. All sessions of the account are
+// signed out.
+func (pr *PasswordReset) Reset(token, newPassword *string) error {
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+	// Deleting as we find makes the token single-use even when two
+	// resets race; an expired one is spent all the same.
+	result, err := pr.resetInf.FindOneAndDelete(bson.M{"tokenHash": *SHA256Str(token)}, &entity.PasswordReset{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return InvalidArgumentError("Invalid or used reset token")
+		}
+		return err
+	}
+	reset := result.(*entity.PasswordReset)
+	if reset.IsExpired() {
+		return InvalidArgumentError("Reset token expired")
+	}
+	// A token issued before the account was disabled is as good as
+	// unknown; answering otherwise would tell the account is disabled.
+	if disabled, err := pr.isDisabled(&reset.Account); err != nil || disabled {
+		return InvalidArgumentError("Invalid or used reset token")
+	}
+	if err := pr.auth.setPassword(&reset.Account, newPassword); err != nil {
+		return err
+	}
+	_, err = pr.auth.RevokeSessions(&reset.Account, nil)
+	logrus.Printf("Password of %s reset", reset.Account)
+	return err
+}
+
+func (pr *PasswordReset) isDisabled(account *string) (bool, error) {
+	result, err := pr.auth.userInf.FindSelectOne(bson.M{"account": *account}, bson.M{"disabled": 1}, &entity.User{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, NotFoundError("Account not exists")
+		}
+		return false, err
+	}
+	return result.(*entity.User).Disabled, nil
+}
@@ -0,0 +1,177 @@
+//go:build mongo
+
+package silverfish
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPasswordResetSpendsTokenOnce(t *testing.T) {
+	db := testDatabase(t)
+	auth := testAuth(db)
+	pr := NewPasswordReset(auth, testInf(db, "passwordReset"), nil, "")
+	account, admin, oldPassword := "reader", "admin", "old password"
+	auth.Register(false, &account, &oldPassword)
+
+	token, reset, err := pr.IssueByAdmin(&account, &admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.TokenHash != *SHA256Str(token) || reset.TokenHash == *token {
+		t.Error("token should be stored hashed")
+	}
+
+	// Two resets racing for the token: exactly one wins.
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			password := "new password"
+			errs[i] = pr.Reset(token, &password)
+		}(i)
+	}
+	wg.Wait()
+	if (errs[0] == nil) == (errs[1] == nil) {
+		t.Fatalf("got %v and %v, want exactly one success", errs[0], errs[1])
+	}
+	for _, err := range errs {
+		if err != nil && errorCode(err) != entity.ErrorInvalidArgument {
+			t.Errorf("losing reset: got %v, want InvalidArgumentError", err)
+		}
+	}
+	newPassword := "new password"
+	if _, err := auth.Login(&account, &newPassword); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+}
+
+func TestPasswordResetExpiry(t *testing.T) {
+	db := testDatabase(t)
+	auth := testAuth(db)
+	resetInf := testInf(db, "passwordReset")
+	pr := NewPasswordReset(auth, resetInf, nil, "")
+	account, admin, password := "reader", "admin", "old password"
+	auth.Register(false, &account, &password)
+
+	token, _, err := pr.Issue(&account, &admin, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetInf.Update(bson.M{"account": account}, bson.M{"$set": bson.M{"expireTS": time.Now().Add(-time.Second)}})
+	newPassword := "new password"
+	if err := pr.Reset(token, &newPassword); err == nil {
+		t.Fatal("expired token accepted")
+	}
+	if count, _ := resetInf.Count(bson.M{"account": account}); count != 0 {
+		t.Error("expired token should be spent")
+	}
+	if _, err := auth.Login(&account, &password); err != nil {
+		t.Errorf("old password should still work: %v", err)
+	}
+}
+
+func TestPasswordResetReissue(t *testing.T) {
+	db := testDatabase(t)
+	auth := testAuth(db)
+	pr := NewPasswordReset(auth, testInf(db, "passwordReset"), nil, "")
+	account, admin, password := "reader", "admin", "old password"
+	auth.Register(false, &account, &password)
+
+	first, _, _ := pr.IssueByAdmin(&account, &admin)
+	second, _, err := pr.IssueByAdmin(&account, &admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPassword := "new password"
+	if err := pr.Reset(first, &newPassword); err == nil {
+		t.Error("replaced token accepted")
+	}
+	if err := pr.Reset(second, &newPassword); err != nil {
+		t.Error(err)
+	}
+
+	nobody := "nobody"
+	if _, _, err := pr.IssueByAdmin(&nobody, &admin); errorCode(err) != entity.ErrorNotFound {
+		t.Errorf("unknown account: got %v, want NotFoundError", err)
+	}
+}
+
+type discardMailer struct{}
+
+func (discardMailer) Send(to, subject, body string) error { return nil }
+
+func TestPasswordResetDisabledAccount(t *testing.T) {
+	db := testDatabase(t)
+	auth, userInf, resetInf := testAuth(db), testInf(db, "user"), testInf(db, "passwordReset")
+	pr := NewPasswordReset(auth, resetInf, discardMailer{}, "https://example.com/reset/{token}")
+	account, admin, password, email := "reader", "admin", "old password", "reader@example.com"
+	auth.Register(false, &account, &password)
+	userInf.Update(bson.M{"account": account}, bson.M{"$set": bson.M{"email": email}})
+	token, _, err := pr.IssueByAdmin(&account, &admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userInf.Update(bson.M{"account": account}, bson.M{"$set": bson.M{"disabled": true}})
+
+	// Spending answers as for an unknown token.
+	newPassword, unknown := "new password", "unknown"
+	spent, missing := pr.Reset(token, &newPassword), pr.Reset(&unknown, &newPassword)
+	if spent == nil || missing == nil || spent.Error() != missing.Error() {
+		t.Errorf("got %v, want %v", spent, missing)
+	}
+	if _, _, err := pr.IssueByAdmin(&account, &admin); errorCode(err) != entity.ErrorForbidden {
+		t.Errorf("issue: got %v, want ForbiddenError", err)
+	}
+	// Requesting by email answers as for an unknown address.
+	if err := pr.Request(&email); err != nil {
+		t.Error(err)
+	}
+	if count, _ := resetInf.Count(bson.M{"account": account}); count != 0 {
+		t.Error("a disabled account got a reset token")
+	}
+	userInf.Update(bson.M{"account": account}, bson.M{"$set": bson.M{"disabled": false}})
+	if _, err := auth.Login(&account, &password); err != nil {
+		t.Errorf("password changed through a refused reset: %v", err)
+	}
+}
+
+func TestUpdateEmailUnique(t *testing.T) {
+	db := testDatabase(t)
+	auth := testAuth(db)
+	user := NewUser(testInf(db, "user"), testInf(db, "novel"), testInf(db, "comic"))
+	password := "password"
+	for _, account := range []string{"a", "b", "c"} {
+		auth.Register(false, &account, &password)
+	}
+	a, b, c, email, empty := "a", "b", "c", "reader@example.com", ""
+	if err := user.UpdateEmail(&a, &email); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.UpdateEmail(&b, &email); err == nil {
+		t.Error("email taken by another account accepted")
+	}
+	if err := user.UpdateEmail(&a, &email); err != nil {
+		t.Errorf("setting the same email again: %v", err)
+	}
+	// Any number of accounts may have none.
+	if err := user.UpdateEmail(&b, &empty); err != nil {
+		t.Error(err)
+	}
+	if err := user.UpdateEmail(&c, &empty); err != nil {
+		t.Error(err)
+	}
+
+	// The index holds even when the count check is skipped.
+	err := testInf(db, "user").Update(bson.M{"account": c}, bson.M{"$set": bson.M{"email": email}})
+	if err == nil {
+		t.Error("duplicate email written past the unique index")
+	}
+}
@@ -0,0 +1,83 @@
+package silverfish
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestHashPasswordRoundTrip(t *testing.T) {
+	password, wrong, salt := "correct horse", "battery staple", "pepper"
+	hashed, err := HashPassword(&password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(*hashed, "$argon2id$v=19$m=65536,t=3,p=2$") {
+		t.Errorf("unexpected encoding %q", *hashed)
+	}
+	if ok, rehash := VerifyPassword(&password, hashed, &salt); !ok || rehash {
+		t.Errorf("got ok=%v rehash=%v, want ok and no rehash", ok, rehash)
+	}
+	if ok, _ := VerifyPassword(&wrong, hashed, &salt); ok {
+		t.Error("wrong password accepted")
+	}
+	again, _ := HashPassword(&password)
+	if *again == *hashed {
+		t.Error("salt is not random")
+	}
+}
+
+// TestLegacyPasswordRehash follows what Login does with an account
+// still on SHA512Str: it verifies, asks for a rehash, and the new hash
+// verifies without one.
+func TestLegacyPasswordRehash(t *testing.T) {
+	password, wrong, salt := "correct horse", "battery staple", "pepper"
+	legacy := SHA512Str(&password, &salt)
+	ok, rehash := VerifyPassword(&password, legacy, &salt)
+	if !ok || !rehash {
+		t.Fatalf("got ok=%v rehash=%v, want ok and rehash", ok, rehash)
+	}
+	if ok, _ := VerifyPassword(&wrong, legacy, &salt); ok {
+		t.Error("wrong password accepted")
+	}
+	otherSalt := "salt"
+	if ok, _ := VerifyPassword(&password, legacy, &otherSalt); ok {
+		t.Error("password accepted under another salt")
+	}
+
+	hashed, err := HashPassword(&password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, rehash := VerifyPassword(&password, hashed, &salt); !ok || rehash {
+		t.Errorf("rehashed password: got ok=%v rehash=%v", ok, rehash)
+	}
+}
+
+func TestVerifyPasswordOldParameters(t *testing.T) {
+	password, salt := "correct horse", []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, 1, 32*1024, 1, argon2KeyLen)
+	stored := "$argon2id$v=19$m=32768,t=1,p=1$" +
+		base64.RawStdEncoding.EncodeToString(salt) + "$" + base64.RawStdEncoding.EncodeToString(key)
+	if ok, rehash := VerifyPassword(&password, &stored, nil); !ok || !rehash {
+		t.Errorf("got ok=%v rehash=%v, want ok and rehash", ok, rehash)
+	}
+}
+
+func TestVerifyPasswordMalformed(t *testing.T) {
+	password := "correct horse"
+	for _, stored := range []string{
+		"$argon2i$v=19$m=65536,t=3,p=2$c2FsdA$a2V5",
+		"$argon2id$v=16$m=65536,t=3,p=2$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=3,p=2$c2FsdA$a2V5",
+		"$argon2id$v=19$m=65536,t=3,p=2$!!$a2V5",
+		"$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$",
+		"$argon2id$v=19$m=65536,t=3,p=2",
+	} {
+		if ok, rehash := VerifyPassword(&password, &stored, nil); ok || rehash {
+			t.Errorf("%q: got ok=%v rehash=%v", stored, ok, rehash)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package silverfish
+
+import (
+	entity "silverfish/silverfish/entity"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GetRole export
+func (a *Auth) GetRole(account *string) (string, error) {
+	result, err := a.userInf.FindOne(bson.M{"account": *account}, &entity.User{})
+	if err != nil {
+		return "", NotFoundError("Account not exists")
+	}
+	return result.(*entity.User).GetRole(), nil
+}
+
+// GetPermissions export — what `session` may do: the permissions of the
+// account's role, or none for API tokens lacking the admin scope.
+func (a *Auth) GetPermissions(session *entity.Session) (string, []string, error) {
+	role, err := a.GetRole(session.GetAccount())
+	if err != nil {
+		return "", nil, err
+	}
+	return role, sessionPermissions(role, session), nil
+}
+
+func sessionPermissions(role string, session *entity.Session) []string {
+	if !session.HasScope(entity.ScopeAdmin) {
+		return []string{}
+	}
+	return entity.RolePermissions[role]
+}
+
+// HasPermission export
+func (a *Auth) HasPermission(session *entity.Session, permission string) bool {
+	_, permissions, err := a.GetPermissions(session)
+	if err != nil {
+		return false
+	}
+	return hasPermission(permissions, permission)
+}
+
+func hasPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize export — resolves `sessionToken` and checks it holds
+// `permission`; the single place handlers enforce authorization.
+func (a *Auth) Authorize(sessionToken *string, permission string) (*entity.Session, error) {
+	session, err := a.GetSession(sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if !a.HasPermission(session, permission) {
+		return nil, ForbiddenError("Permission %s required", permission)
+	}
+	return session, nil
+}
+
+// SetRole export — `by` is the account making the change. Admins can't
+// demote themselves, nor the last other admin, so an instance always
+// keeps one.
+func (a *Auth) SetRole(account *string, role string, by *string) error {
+	if !entity.IsRole(role) {
+		return InvalidArgumentError("Unknown role %s", role)
+	}
+	if *account == *by && role != entity.RoleAdmin {
+		return ForbiddenError("Admins can't demote themselves")
+	}
+	result, err := a.userInf.FindOne(bson.M{"account": *account}, &entity.User{})
+	if err != nil {
+		return NotFoundError("Account not exists")
+	}
+	user := result.(*entity.User)
+	previous := user.GetRole()
+	demote := previous == entity.RoleAdmin && role != entity.RoleAdmin
+	if demote {
+		if others, err := a.countOtherAdmins(account); err != nil {
+			return err
+		} else if others == 0 {
+			return ForbiddenError("Can't demote the last admin")
+		}
+	}
+	user.SetRole(role)
+	if err := a.userInf.Update(bson.M{"account": *account}, bson.M{
+		"$set": bson.M{"role": user.Role, "isAdmin": user.IsAdmin},
+	}); err != nil {
+		return err
+	}
+	if demote {
+		// Two admins demoting each other at once both pass the count
+		// above; whoever sees no admin left afterwards backs out.
+		if others, err := a.countOtherAdmins(account); err != nil || others == 0 {
+			user.SetRole(previous)
+			a.userInf.Update(bson.M{"account": *account}, bson.M{
+				"$set": bson.M{"role": user.Role, "isAdmin": user.IsAdmin},
+			})
+			if err != nil {
+				return err
+			}
+			return ForbiddenError("Can't demote the last admin")
+		}
+	}
+	logrus.Printf("Role of %s changed from %s to %s by %s", *account, previous, role, *by)
+	return nil
+}
+
+// countOtherAdmins counts the enabled admins besides `account`.
+func (a *Auth) countOtherAdmins(account *string) (int64, error) {
+	return a.userInf.Count(bson.M{
+		"role":     entity.RoleAdmin,
+		"disabled": bson.M{"$ne": true},
+		"account":  bson.M{"$ne": *account},
+	})
+}
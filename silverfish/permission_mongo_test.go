@@ -0,0 +1,46 @@
+//go:build mongo
+
+package silverfish
+
+import (
+	"sync"
+	"testing"
+
+	entity "silverfish/silverfish/entity"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSetRoleKeepsAnAdmin(t *testing.T) {
+	db := testDatabase(t)
+	auth := testAuth(db)
+	a, b, password := "a", "b", "password"
+	auth.Register(true, &a, &password)
+	auth.Register(true, &b, &password)
+
+	// Demoting each other at once, at most one of them may succeed.
+	var wg sync.WaitGroup
+	for _, pair := range [][2]*string{{&a, &b}, {&b, &a}} {
+		wg.Add(1)
+		go func(target, by *string) {
+			defer wg.Done()
+			auth.SetRole(target, entity.RoleReader, by)
+		}(pair[0], pair[1])
+	}
+	wg.Wait()
+	if admins, _ := testInf(db, "user").Count(bson.M{"role": entity.RoleAdmin}); admins == 0 {
+		t.Fatal("no admin left")
+	}
+
+	// With one admin left, nobody can demote them.
+	auth.SetRole(&a, entity.RoleAdmin, &b)
+	if err := auth.SetRole(&b, entity.RoleCurator, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.SetRole(&a, entity.RoleReader, &b); errorCode(err) != entity.ErrorForbidden {
+		t.Errorf("demoting the last admin: got %v, want ForbiddenError", err)
+	}
+	if err := auth.SetRole(&a, entity.RoleReader, &a); errorCode(err) != entity.ErrorForbidden {
+		t.Errorf("self demotion: got %v, want ForbiddenError", err)
+	}
+}
@@ -0,0 +1,60 @@
+package silverfish
+
+import (
+	"testing"
+
+	entity "silverfish/silverfish/entity"
+)
+
+func TestRolePermissions(t *testing.T) {
+	cases := []struct {
+		permission string
+		reader     bool
+		curator    bool
+		admin      bool
+	}{
+		{entity.PermissionBookAdd, false, true, true},
+		{entity.PermissionBookRecrawl, false, true, true},
+		{entity.PermissionBookEdit, false, true, true},
+		{entity.PermissionBookDelete, false, false, true},
+		{entity.PermissionFilterManage, false, true, true},
+		{entity.PermissionUserManage, false, false, true},
+		{entity.PermissionSystemManage, false, false, true},
+	}
+	login := &entity.Session{Account: "someone"}
+	for _, tc := range cases {
+		for role, want := range map[string]bool{
+			entity.RoleReader:  tc.reader,
+			entity.RoleCurator: tc.curator,
+			entity.RoleAdmin:   tc.admin,
+		} {
+			if got := hasPermission(sessionPermissions(role, login), tc.permission); got != want {
+				t.Errorf("%s %s: got %v, want %v", role, tc.permission, got, want)
+			}
+		}
+	}
+	if got := sessionPermissions("owner", login); len(got) != 0 {
+		t.Errorf("unknown role: got %v", got)
+	}
+}
+
+func TestAPITokenPermissions(t *testing.T) {
+	cases := []struct {
+		scopes []string
+		want   int
+	}{
+		{[]string{}, 0},
+		{[]string{entity.ScopeLibraryRead, entity.ScopeBookmarkWrite}, 0},
+		{[]string{entity.ScopeAdmin}, len(entity.RolePermissions[entity.RoleAdmin])},
+	}
+	for _, tc := range cases {
+		session := &entity.Session{Account: "someone", APIToken: &entity.APIToken{Scopes: tc.scopes}}
+		permissions := sessionPermissions(entity.RoleAdmin, session)
+		if len(permissions) != tc.want {
+			t.Errorf("scopes %v: got %v", tc.scopes, permissions)
+		}
+		if permissions == nil {
+			t.Errorf("scopes %v: permissions should be an empty list, not nil", tc.scopes)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package silverfish
+
+import (
+	"sort"
+	"strings"
+
+	entity "silverfish/silverfish/entity"
+	usecase "silverfish/silverfish/usecase"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Search export
+type Search struct {
+	novelInf *entity.MongoInf
+	comicInf *entity.MongoInf
+}
+
+// NewSearch export
+func NewSearch(novelInf, comicInf *entity.MongoInf) *Search {
+	s := new(Search)
+	s.novelInf = novelInf
+	s.comicInf = comicInf
+	return s
+}
+
+var searchProjection = bson.M{
+	"isEnable": 1, "novelID": 1, "comicID": 1, "dns": 1, "title": 1, "author": 1,
+	"description": 1, "coverUrl": 1, "lastCrawlTime": 1, "searchTokens": 1,
+}
+
+// Search export — candidates are fetched from Mongo through the
+// multikey index on `searchTokens`; scoring, sorting and paging happen
+// here since Mongo's own text index can't tokenize Chinese.
+func (s *Search) Search(query *entity.SearchQuery) (*entity.SearchPage, error) {
+	tokens := usecase.SearchQueryTokens(query.Keyword)
+	if len(tokens) == 0 {
+		return nil, InvalidArgumentError("Field q should not be empty")
+	}
+	selector := bson.M{"searchTokens": bson.M{"$in": tokens}}
+	if query.Enabled != nil {
+		selector["isEnable"] = *query.Enabled
+	}
+	if query.Source != "" {
+		selector["dns"] = query.Source
+	}
+
+	candidates := []entity.SearchResult{}
+	if query.Type == "" || query.Type == "novel" {
+		result, err := s.novelInf.FindSelectAll(selector, searchProjection, &[]entity.SearchResult{})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range *result.(*[]entity.SearchResult) {
+			r.Type, r.ID = "novel", r.NovelID
+			candidates = append(candidates, r)
+		}
+	}
+	if query.Type == "" || query.Type == "comic" {
+		result, err := s.comicInf.FindSelectAll(selector, searchProjection, &[]entity.SearchResult{})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range *result.(*[]entity.SearchResult) {
+			r.Type, r.ID = "comic", r.ComicID
+			candidates = append(candidates, r)
+		}
+	}
+
+	results := []entity.SearchResult{}
+	keyword := usecase.NormalizeTitle(query.Keyword)
+	for _, candidate := range candidates {
+		if score := scoreSearchResult(&candidate, tokens, keyword); score > 0 {
+			candidate.Score = score
+			results = append(results, candidate)
+		}
+	}
+	if query.Sort == "lastCrawlTime" {
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].LastCrawlTime.After(results[j].LastCrawlTime)
+		})
+	} else {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	}
+
+	page := &entity.SearchPage{Total: len(results), Page: query.Page, Size: query.Size}
+	start := (query.Page - 1) * query.Size
+	if start > len(results) {
+		start = len(results)
+	}
+	end := start + query.Size
+	if end > len(results) {
+		end = len(results)
+	}
+	page.Results = results[start:end]
+	return page, nil
+}
+
+// scoreSearchResult is the share of query tokens the book carries, plus
+// a bonus when the whole keyword appears in the title. Books matching
+// less than half the tokens score 0 — with bigrams a single shared
+// token is mostly noise.
+func scoreSearchResult(result *entity.SearchResult, tokens []string, keyword string) float64 {
+	carried := map[string]bool{}
+	for _, token := range result.SearchTokens {
+		carried[token] = true
+	}
+	matched := 0
+	for _, token := range tokens {
+		if carried[token] {
+			matched++
+		}
+	}
+	if matched*2 < len(tokens) {
+		return 0
+	}
+	score := float64(matched) / float64(len(tokens))
+	if keyword != "" && strings.Contains(usecase.NormalizeTitle(result.Title), keyword) {
+		score++
+	}
+	return score
+}
+
+// Reindex export — fills `searchTokens` on books crawled before the index
+// existed, or on every book when `force` is set (e.g. after the
+// tokenizer changes). Returns how many books were indexed.
+func (s *Search) Reindex(force bool) (int, error) {
+	selector := bson.M{"searchTokens": nil}
+	if force {
+		selector = bson.M{}
+	}
+	count := 0
+	for _, target := range []struct {
+		inf *entity.MongoInf
+		key string
+	}{{s.novelInf, "novelID"}, {s.comicInf, "comicID"}} {
+		result, err := target.inf.FindSelectAll(selector, searchProjection, &[]entity.SearchResult{})
+		if err != nil {
+			return count, err
+		}
+		for _, r := range *result.(*[]entity.SearchResult) {
+			id := r.NovelID
+			if target.key == "comicID" {
+				id = r.ComicID
+			}
+			err := target.inf.Update(bson.M{target.key: id}, bson.M{
+				"$set": bson.M{"searchTokens": usecase.SearchTokens(r.Title, r.Author, r.Description)},
+			})
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	if count > 0 {
+		logrus.Printf("Search index rebuilt for %d books", count)
+	}
+	return count, nil
+}
@@ -8,11 +8,20 @@ import (
 
 // Silverfish export
 type Silverfish struct {
-	Auth  *Auth
-	Admin *Admin
-	User  *User
-	Novel *Novel
-	Comic *Comic
+	Auth          *Auth
+	Admin         *Admin
+	User          *User
+	Novel         *Novel
+	Comic         *Comic
+	Filter        *Filter
+	Search        *Search
+	Audit         *Audit
+	Jobs          *Jobs
+	Importer      *Importer
+	Backup        *Backup
+	Throttle      *Throttle
+	OIDC          *OIDC
+	PasswordReset *PasswordReset
 }
 
 // New export
@@ -20,6 +29,11 @@ func New(
 	hashSalt *string,
 	crawlDuration int,
 	userInf, novelInf, comicInf, sessionInf *entity.MongoInf,
+	filterInf, filterHistoryInf *entity.MongoInf,
+	loginAttemptInf, apiTokenInf *entity.MongoInf,
+	oidcStateInf *entity.MongoInf, oidcConfig *usecase.OIDCConfig,
+	passwordResetInf *entity.MongoInf, mailer interf.IMailer, passwordResetURL string,
+	auditInf, jobInf, novelChapterInf *entity.MongoInf,
 ) *Silverfish {
 	sf := new(Silverfish)
 	novelFetchers := map[string]interf.INovelFetcher{
@@ -30,18 +44,26 @@ func New(
 	comicFetchers := map[string]interf.IComicFetcher{
 		"www.mangabz.com": usecase.NewFetcherMangabz("www.mangabz.com"),
 		"www.baozimh.com": usecase.NewFetcherBaozimh("www.baozimh.com"),
-		// jmd8.com 301-redirects to 91jmd.com (the new canonical host).
-		// Keep the old entry so existing comic records (DNS=jmd8.com)
-		// still resolve their fetcher; they pay one redirect per chapter
-		// request until re-crawled. New URLs should use 91jmd.com directly.
+		// jmd8.com 301-redirects to 91jmd.com (the new canonical host) and
+		// migration 0013 moves stored comics over. The old entry stays so
+		// links pasted with the old host still resolve a fetcher.
 		"jmd8.com":  usecase.NewFetcherJmd8("jmd8.com"),
 		"91jmd.com": usecase.NewFetcherJmd8("91jmd.com"),
 	}
 
-	sf.Auth = NewAuth(hashSalt, userInf, sessionInf)
-	sf.Novel = NewNovel(sf.Auth, novelInf, novelFetchers, crawlDuration)
-	sf.Comic = NewComic(sf.Auth, comicInf, comicFetchers, crawlDuration)
-	sf.Admin = NewAdmin(userInf)
-	sf.User = NewUser(userInf)
+	sf.Auth = NewAuth(hashSalt, userInf, sessionInf, apiTokenInf)
+	sf.Filter = NewFilter(filterInf, filterHistoryInf, novelFetchers)
+	sf.Audit = NewAudit(auditInf)
+	sf.Jobs = NewJobs(jobInf)
+	sf.Novel = NewNovel(sf.Auth, sf.Audit, sf.Jobs, sf.Filter, novelInf, novelChapterInf, novelFetchers, crawlDuration)
+	sf.Comic = NewComic(sf.Auth, sf.Audit, sf.Jobs, comicInf, comicFetchers, crawlDuration)
+	sf.Importer = NewImporter(sf.Novel, sf.Comic, sf.Jobs)
+	sf.Backup = NewBackup(sf.Auth, userInf, novelInf, comicInf, novelChapterInf)
+	sf.Search = NewSearch(novelInf, comicInf)
+	sf.Throttle = NewThrottle(loginAttemptInf)
+	sf.OIDC = NewOIDC(sf.Auth, oidcStateInf, oidcConfig)
+	sf.PasswordReset = NewPasswordReset(sf.Auth, passwordResetInf, mailer, passwordResetURL)
+	sf.Admin = NewAdmin(sf.Auth, userInf)
+	sf.User = NewUser(userInf, novelInf, comicInf)
 	return sf
 }
@@ -0,0 +1,186 @@
+package silverfish
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Throttling parameters. The first few failures are free; after that each
+// further try has to wait twice as long as the previous one, and a
+// subject hitting its lockout threshold is refused outright for a while.
+// IPs get a higher threshold since several users may share one.
+const (
+	throttleFreeFailures   = 3
+	throttleMaxDelay       = time.Minute
+	throttleAccountLockout = 10
+	throttleIPLockout      = 50
+	throttleLockDuration   = 15 * time.Minute
+	throttleMemory         = 24 * time.Hour
+)
+
+// ThrottleError export — returned while a login has to wait; RetryAfter
+// is how long.
+type ThrottleError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (te *ThrottleError) Error() string {
+	if te.Locked {
+		return fmt.Sprintf("Too many failed logins, locked for %d seconds", int(math.Ceil(te.RetryAfter.Seconds())))
+	}
+	return fmt.Sprintf("Too many failed logins, retry in %d seconds", int(math.Ceil(te.RetryAfter.Seconds())))
+}
+
+// ErrorCode export
+func (te *ThrottleError) ErrorCode() string {
+	return entity.ErrorTooManyRequests
+}
+
+// HTTPStatus export
+func (te *ThrottleError) HTTPStatus() int {
+	return http.StatusTooManyRequests
+}
+
+// Throttle export
+type Throttle struct {
+	attemptInf *entity.MongoInf
+}
+
+// NewThrottle export
+func NewThrottle(attemptInf *entity.MongoInf) *Throttle {
+	t := new(Throttle)
+	t.attemptInf = attemptInf
+	return t
+}
+
+func (t *Throttle) findAttempt(subject string, value *string) (*entity.LoginAttempt, error) {
+	result, err := t.attemptInf.FindOne(bson.M{"subject": subject, "value": *value}, &entity.LoginAttempt{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return result.(*entity.LoginAttempt), nil
+}
+
+// waitFor is how long `attempt` still has to wait before its next try.
+func waitFor(attempt *entity.LoginAttempt) *ThrottleError {
+	if attempt == nil {
+		return nil
+	}
+	if attempt.IsLocked() {
+		return &ThrottleError{RetryAfter: time.Until(attempt.LockedUntil), Locked: true}
+	}
+	if attempt.Failures < throttleFreeFailures {
+		return nil
+	}
+	delay := time.Second << uint(attempt.Failures-throttleFreeFailures)
+	if delay > throttleMaxDelay || delay <= 0 {
+		delay = throttleMaxDelay
+	}
+	if wait := time.Until(attempt.LastFailure.Add(delay)); wait > 0 {
+		return &ThrottleError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Check export — call before verifying a password. Returns a
+// *ThrottleError while the account or the IP has to wait.
+func (t *Throttle) Check(account, ip *string) error {
+	for subject, value := range map[string]*string{
+		entity.LoginSubjectAccount: account,
+		entity.LoginSubjectIP:      ip,
+	} {
+		attempt, err := t.findAttempt(subject, value)
+		if err != nil {
+			return err
+		}
+		if wait := waitFor(attempt); wait != nil {
+			return wait
+		}
+	}
+	return nil
+}
+
+// Fail export — records a failed login of `account` from `ip`. The
+// counter is bumped in Mongo, so concurrent failures all count, and the
+// lock decided from the count the bump returned.
+func (t *Throttle) Fail(account, ip *string) {
+	for subject, value := range map[string]*string{
+		entity.LoginSubjectAccount: account,
+		entity.LoginSubjectIP:      ip,
+	} {
+		selector := bson.M{"subject": subject, "value": *value}
+		now := time.Now()
+		result, err := t.attemptInf.FindOneAndUpdate(selector, bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"lastFailure": now, "expireTS": now.Add(throttleMemory)},
+		}, true, &entity.LoginAttempt{})
+		if err != nil {
+			logrus.Printf("Failed to record login attempt of %s %s: %s", subject, *value, err.Error())
+			continue
+		}
+		attempt := result.(*entity.LoginAttempt)
+		if !reachesLockout(subject, attempt.Failures) {
+			continue
+		}
+		err = t.attemptInf.Update(selector, bson.M{"$set": bson.M{"lockedUntil": now.Add(throttleLockDuration)}})
+		if err != nil {
+			logrus.Printf("Failed to lock %s %s: %s", subject, *value, err.Error())
+			continue
+		}
+		logrus.Printf("Locked %s %s after %d failed logins", subject, *value, attempt.Failures)
+	}
+}
+
+// reachesLockout reports whether the `failures`th failure locks the
+// subject: every multiple of its threshold does.
+func reachesLockout(subject string, failures int) bool {
+	threshold := throttleAccountLockout
+	if subject == entity.LoginSubjectIP {
+		threshold = throttleIPLockout
+	}
+	return failures > 0 && failures%threshold == 0
+}
+
+// Succeed export — a successful login clears the account's record. The
+// IP's record is kept, so one valid account can't reset the counter of
+// an IP guessing at others.
+func (t *Throttle) Succeed(account *string) {
+	t.attemptInf.Remove(bson.M{"subject": entity.LoginSubjectAccount, "value": *account})
+}
+
+// GetAttempts export — every recorded subject, most recent failure first.
+func (t *Throttle) GetAttempts() ([]entity.LoginAttempt, error) {
+	result, err := t.attemptInf.FindAll(bson.M{}, &[]entity.LoginAttempt{})
+	if err != nil {
+		return nil, err
+	}
+	attempts := *result.(*[]entity.LoginAttempt)
+	sort.Slice(attempts, func(i, j int) bool { return attempts[i].LastFailure.After(attempts[j].LastFailure) })
+	return attempts, nil
+}
+
+// Unlock export — forgets the failures of an account or IP.
+func (t *Throttle) Unlock(subject string, value *string, admin *string) error {
+	if subject != entity.LoginSubjectAccount && subject != entity.LoginSubjectIP {
+		return InvalidArgumentError("Unknown subject")
+	}
+	if _, err := t.attemptInf.RemoveAll(bson.M{"subject": subject, "value": *value}); err != nil {
+		return err
+	}
+	logrus.Printf("Login throttle of %s %s cleared by %s", subject, *value, *admin)
+	return nil
+}
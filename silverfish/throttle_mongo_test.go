@@ -0,0 +1,74 @@
+//go:build mongo
+
+package silverfish
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	entity "silverfish/silverfish/entity"
+)
+
+func TestThrottleCountsConcurrentFailures(t *testing.T) {
+	db := testDatabase(t)
+	throttle := NewThrottle(testInf(db, "loginAttempt"))
+	account, ip := "reader", "203.0.113.9"
+
+	var wg sync.WaitGroup
+	for i := 0; i < throttleAccountLockout; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			throttle.Fail(&account, &ip)
+		}()
+	}
+	wg.Wait()
+
+	attempts, err := throttle.GetAttempts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("got %d records, want one for the account and one for the IP", len(attempts))
+	}
+	for _, attempt := range attempts {
+		if attempt.Failures != throttleAccountLockout {
+			t.Errorf("%s: got %d failures, want %d", attempt.Subject, attempt.Failures, throttleAccountLockout)
+		}
+	}
+	wait, ok := throttle.Check(&account, &ip).(*ThrottleError)
+	if !ok || !wait.Locked {
+		t.Errorf("got %v, want the account locked", wait)
+	}
+}
+
+func TestThrottleLocksIPAcrossAccounts(t *testing.T) {
+	db := testDatabase(t)
+	throttle := NewThrottle(testInf(db, "loginAttempt"))
+	ip := "203.0.113.9"
+
+	// Spread over enough accounts that none of them locks by itself.
+	for i := 0; i < throttleIPLockout; i++ {
+		account := fmt.Sprintf("reader%d", i)
+		throttle.Fail(&account, &ip)
+	}
+
+	fresh := "fresh"
+	wait, ok := throttle.Check(&fresh, &ip).(*ThrottleError)
+	if !ok || !wait.Locked {
+		t.Fatalf("got %v, want the IP locked", wait)
+	}
+	other := "198.51.100.7"
+	if err := throttle.Check(&fresh, &other); err != nil {
+		t.Errorf("another IP: got %v", err)
+	}
+
+	admin := "admin"
+	if err := throttle.Unlock(entity.LoginSubjectIP, &ip, &admin); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Check(&fresh, &ip); err != nil {
+		t.Errorf("after unlock: got %v", err)
+	}
+}
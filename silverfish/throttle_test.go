@@ -0,0 +1,83 @@
+package silverfish
+
+import (
+	"testing"
+	"time"
+
+	entity "silverfish/silverfish/entity"
+)
+
+func TestReachesLockout(t *testing.T) {
+	cases := []struct {
+		subject  string
+		failures int
+		want     bool
+	}{
+		{entity.LoginSubjectAccount, 0, false},
+		{entity.LoginSubjectAccount, 9, false},
+		{entity.LoginSubjectAccount, 10, true},
+		{entity.LoginSubjectAccount, 11, false},
+		{entity.LoginSubjectAccount, 20, true},
+		// IPs are shared, so they get the higher threshold.
+		{entity.LoginSubjectIP, 10, false},
+		{entity.LoginSubjectIP, 49, false},
+		{entity.LoginSubjectIP, 50, true},
+		{entity.LoginSubjectIP, 100, true},
+	}
+	for _, tc := range cases {
+		if got := reachesLockout(tc.subject, tc.failures); got != tc.want {
+			t.Errorf("reachesLockout(%s, %d) = %v, want %v", tc.subject, tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestWaitForProgressiveDelay(t *testing.T) {
+	if wait := waitFor(nil); wait != nil {
+		t.Errorf("no record: got %v", wait)
+	}
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{6, 8 * time.Second},
+		{9, time.Minute},
+		{200, time.Minute},
+	}
+	for _, tc := range cases {
+		wait := waitFor(&entity.LoginAttempt{Failures: tc.failures, LastFailure: time.Now()})
+		if tc.want == 0 {
+			if wait != nil {
+				t.Errorf("%d failures: got %v, want no wait", tc.failures, wait)
+			}
+			continue
+		}
+		if wait == nil || wait.Locked || wait.RetryAfter > tc.want || wait.RetryAfter < tc.want-time.Second {
+			t.Errorf("%d failures: got %+v, want about %s", tc.failures, wait, tc.want)
+		}
+	}
+
+	// The delay runs from the last failure.
+	if wait := waitFor(&entity.LoginAttempt{Failures: 4, LastFailure: time.Now().Add(-time.Hour)}); wait != nil {
+		t.Errorf("delay elapsed: got %v", wait)
+	}
+}
+
+func TestWaitForLock(t *testing.T) {
+	wait := waitFor(&entity.LoginAttempt{Failures: 10, LockedUntil: time.Now().Add(throttleLockDuration)})
+	if wait == nil || !wait.Locked || wait.RetryAfter <= throttleLockDuration-time.Second {
+		t.Errorf("got %+v, want locked for %s", wait, throttleLockDuration)
+	}
+	// An expired lock leaves only the progressive delay.
+	wait = waitFor(&entity.LoginAttempt{
+		Failures:    10,
+		LastFailure: time.Now().Add(-throttleLockDuration),
+		LockedUntil: time.Now().Add(-time.Second),
+	})
+	if wait != nil {
+		t.Errorf("expired lock: got %+v", wait)
+	}
+}
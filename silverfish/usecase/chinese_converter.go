@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"bufio"
+	"embed"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Script values accepted by ConvertScript. An empty script means "leave
+// the text as the upstream served it".
+const (
+	ScriptOriginal    = ""
+	ScriptSimplified  = "hans"
+	ScriptTraditional = "hant"
+)
+
+//go:embed dict/*.txt
+var chineseDict embed.FS
+
+type scriptTable struct {
+	chars        map[rune]rune
+	phrases      map[string]string
+	maxPhraseLen int
+}
+
+var (
+	chineseDictOnce sync.Once
+	toTraditional   *scriptTable
+	toSimplified    *scriptTable
+)
+
+// loadChineseDict builds both directions from the embedded tables. The
+// Traditional → Simplified character table is the inverse of the
+// Simplified → Traditional one: every traditional form maps back to the
+// simplified character it was listed under.
+func loadChineseDict() {
+	toTraditional = &scriptTable{chars: map[rune]rune{}, phrases: map[string]string{}}
+	toSimplified = &scriptTable{chars: map[rune]rune{}, phrases: map[string]string{}}
+
+	readDictPairs("dict/st_characters.txt", func(fields []string) {
+		simplified, _ := utf8.DecodeRuneInString(fields[0])
+		for i, field := range fields[1:] {
+			traditional, _ := utf8.DecodeRuneInString(field)
+			if i == 0 {
+				toTraditional.chars[simplified] = traditional
+			}
+			if _, ok := toSimplified.chars[traditional]; !ok {
+				toSimplified.chars[traditional] = simplified
+			}
+		}
+	})
+	readDictPairs("dict/st_phrases.txt", toTraditional.addPhrase)
+	readDictPairs("dict/ts_phrases.txt", toSimplified.addPhrase)
+}
+
+func (st *scriptTable) addPhrase(fields []string) {
+	st.phrases[fields[0]] = fields[1]
+	if length := utf8.RuneCountInString(fields[0]); length > st.maxPhraseLen {
+		st.maxPhraseLen = length
+	}
+}
+
+func readDictPairs(name string, handle func(fields []string)) {
+	file, err := chineseDict.Open(name)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) >= 2 {
+			handle(fields)
+		}
+	}
+}
+
+// convert does forward maximum matching: at each position try the
+// longest phrase first, fall back to the single-character table.
+func (st *scriptTable) convert(text string) string {
+	runes := []rune(text)
+	var builder strings.Builder
+	builder.Grow(len(text))
+	for i := 0; i < len(runes); {
+		matched := false
+		for length := st.maxPhraseLen; length > 1; length-- {
+			if i+length > len(runes) {
+				continue
+			}
+			if phrase, ok := st.phrases[string(runes[i:i+length])]; ok {
+				builder.WriteString(phrase)
+				i += length
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		if r, ok := st.chars[runes[i]]; ok {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteRune(runes[i])
+		}
+		i++
+	}
+	return builder.String()
+}
+
+// ParseScript export — accepts our own `hans` / `hant` plus the locale
+// spellings browsers and readers tend to send. Unknown values mean
+// "no conversion".
+func ParseScript(script string) string {
+	switch strings.ToLower(strings.TrimSpace(script)) {
+	case "hans", "zh-hans", "zh-cn", "zh-sg", "simplified", "s":
+		return ScriptSimplified
+	case "hant", "zh-hant", "zh-tw", "zh-hk", "zh-mo", "traditional", "t":
+		return ScriptTraditional
+	default:
+		return ScriptOriginal
+	}
+}
+
+// ConvertScript export
+func ConvertScript(text, script string) string {
+	chineseDictOnce.Do(loadChineseDict)
+	switch script {
+	case ScriptSimplified:
+		return toSimplified.convert(text)
+	case ScriptTraditional:
+		return toTraditional.convert(text)
+	default:
+		return text
+	}
+}
+
+// NormalizeTitle export — folds a title into a comparison key: Simplified
+// script, lower case, no whitespace or punctuation. Two sources serving
+// the same book in different scripts end up with the same key.
+func NormalizeTitle(title string) string {
+	simplified := ConvertScript(title, ScriptSimplified)
+	var builder strings.Builder
+	for _, r := range simplified {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			builder.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"testing"
+)
+
+func TestConvertScript(t *testing.T) {
+	cases := []struct {
+		script string
+		text   string
+		want   string
+	}{
+		// One-to-many characters take their default form...
+		{ScriptTraditional, "发现", "發現"},
+		{ScriptTraditional, "以后", "以後"},
+		{ScriptTraditional, "干部", "幹部"},
+		{ScriptTraditional, "松树", "松樹"},
+		// ...unless a phrase says otherwise, longest phrase first.
+		{ScriptTraditional, "头发很长", "頭髮很長"},
+		{ScriptTraditional, "白发苍苍", "白髮蒼蒼"},
+		{ScriptTraditional, "皇后", "皇后"},
+		{ScriptTraditional, "前仆后继", "前仆後繼"},
+		{ScriptTraditional, "干净", "乾淨"},
+		{ScriptTraditional, "面条", "麵條"},
+		{ScriptTraditional, "一只猫", "一隻貓"},
+		{ScriptTraditional, "这只是", "這只是"},
+		{ScriptTraditional, "放松", "放鬆"},
+		{ScriptTraditional, "一见钟情", "一見鍾情"},
+		{ScriptTraditional, "台风", "颱風"},
+		// Traditional → Simplified folds every form back.
+		{ScriptSimplified, "頭髮", "头发"},
+		{ScriptSimplified, "以後", "以后"},
+		{ScriptSimplified, "乾淨", "干净"},
+		{ScriptSimplified, "臺灣", "台湾"},
+		{ScriptSimplified, "看著", "看着"},
+		{ScriptSimplified, "著名", "著名"},
+		{ScriptSimplified, "顯著", "显著"},
+		{ScriptSimplified, "乾隆", "乾隆"},
+		// Text outside the tables passes through.
+		{ScriptTraditional, "第1章 Hello", "第1章 Hello"},
+		{ScriptOriginal, "头发", "头发"},
+	}
+	for _, tc := range cases {
+		if got := ConvertScript(tc.text, tc.script); got != tc.want {
+			t.Errorf("ConvertScript(%q, %q) = %q, want %q", tc.text, tc.script, got, tc.want)
+		}
+	}
+}
+
+func TestConvertScriptRoundTrip(t *testing.T) {
+	for _, text := range []string{"头发很长", "以后再说", "干净的面条", "轻松一下", "钟表店里", "皇后与太后"} {
+		if got := ConvertScript(ConvertScript(text, ScriptTraditional), ScriptSimplified); got != text {
+			t.Errorf("%q came back as %q", text, got)
+		}
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	for input, want := range map[string]string{
+		"hans": ScriptSimplified, "zh-CN": ScriptSimplified, " Simplified ": ScriptSimplified,
+		"hant": ScriptTraditional, "zh-TW": ScriptTraditional, "zh-hk": ScriptTraditional,
+		"": ScriptOriginal, "en": ScriptOriginal,
+	} {
+		if got := ParseScript(input); got != want {
+			t.Errorf("ParseScript(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	entity "silverfish/silverfish/entity"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/sirupsen/logrus"
+)
+
+type compiledRule struct {
+	rule     entity.FilterRule
+	regex    *regexp.Regexp
+	selector cascadia.Selector
+}
+
+// ContentFilter export — a compiled, ordered list of filter rules. Build
+// one per rule set version rather than per chapter; regexes and
+// selectors are compiled once here.
+type ContentFilter struct {
+	rules []compiledRule
+}
+
+// NewContentFilter export — returns an error naming the first rule that
+// doesn't compile, so admins get feedback before the rules go live.
+func NewContentFilter(rules []entity.FilterRule) (*ContentFilter, error) {
+	cf := new(ContentFilter)
+	for i, rule := range rules {
+		compiled := compiledRule{rule: rule}
+		if rule.Count < 0 {
+			return nil, fmt.Errorf("Rule %d has negative count", i)
+		}
+		switch rule.Type {
+		case entity.FilterRuleSelector:
+			selector, err := cascadia.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("Rule %d has invalid selector: %s", i, err.Error())
+			}
+			compiled.selector = selector
+		case entity.FilterRuleRegex:
+			regex, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("Rule %d has invalid regex: %s", i, err.Error())
+			}
+			compiled.regex = regex
+		case entity.FilterRuleLiteral, entity.FilterRuleParagraph:
+			if rule.Pattern == "" {
+				return nil, fmt.Errorf("Rule %d has empty pattern", i)
+			}
+		default:
+			return nil, fmt.Errorf("Rule %d has unknown type: %s", i, rule.Type)
+		}
+		cf.rules = append(cf.rules, compiled)
+	}
+	return cf, nil
+}
+
+// Apply export
+func (cf *ContentFilter) Apply(raw *string) *string {
+	str := *raw
+	for _, rule := range cf.rules {
+		switch rule.rule.Type {
+		case entity.FilterRuleLiteral:
+			count := rule.rule.Count
+			if count == 0 {
+				count = -1
+			}
+			str = strings.Replace(str, rule.rule.Pattern, rule.rule.Replacement, count)
+		case entity.FilterRuleRegex:
+			str = replaceRegex(rule.regex, str, rule.rule.Replacement, rule.rule.Count)
+		case entity.FilterRuleSelector:
+			str = editFragment(str, func(doc *goquery.Document) {
+				doc.FindMatcher(rule.selector).Remove()
+			})
+		case entity.FilterRuleParagraph:
+			str = editFragment(str, func(doc *goquery.Document) {
+				doc.Find("p, div").Each(func(i int, s *goquery.Selection) {
+					if s.Find("p, div").Length() == 0 && strings.Contains(s.Text(), rule.rule.Pattern) {
+						s.Remove()
+					}
+				})
+			})
+		}
+	}
+	return &str
+}
+
+// replaceRegex is Regexp.ReplaceAllString replacing the first `count`
+// matches only, or every match for 0.
+func replaceRegex(regex *regexp.Regexp, str, replacement string, count int) string {
+	if count == 0 {
+		return regex.ReplaceAllString(str, replacement)
+	}
+	var builder strings.Builder
+	last := 0
+	for _, match := range regex.FindAllStringSubmatchIndex(str, count) {
+		builder.WriteString(str[last:match[0]])
+		builder.Write(regex.ExpandString(nil, replacement, str, match))
+		last = match[1]
+	}
+	builder.WriteString(str[last:])
+	return builder.String()
+}
+
+// editFragment parses a chapter fragment, lets `edit` mutate the DOM and
+// renders the body back out. On parse failure the input is returned
+// untouched; a broken filter shouldn't blank a chapter.
+func editFragment(raw string, edit func(doc *goquery.Document)) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(raw))
+	if err != nil {
+		logrus.Printf("ContentFilter failed to parse content: %s", err.Error())
+		return raw
+	}
+	edit(doc)
+	output, err := doc.Find("body").Html()
+	if err != nil {
+		logrus.Printf("ContentFilter failed to render content: %s", err.Error())
+		return raw
+	}
+	return output
+}
@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"testing"
+
+	entity "silverfish/silverfish/entity"
+)
+
+func TestContentFilterApply(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules []entity.FilterRule
+		raw   string
+		want  string
+	}{
+		{
+			"literal replaces every match",
+			[]entity.FilterRule{{Type: entity.FilterRuleLiteral, Pattern: "ad", Replacement: ""}},
+			"<p>ad a ad</p>", "<p> a </p>",
+		},
+		{
+			"literal count",
+			[]entity.FilterRule{{Type: entity.FilterRuleLiteral, Pattern: "ad", Replacement: "x", Count: 1}},
+			"ad ad ad", "x ad ad",
+		},
+		{
+			"regex with groups",
+			[]entity.FilterRule{{Type: entity.FilterRuleRegex, Pattern: `第(\d+)頁`, Replacement: "[$1]"}},
+			"第1頁 第2頁", "[1] [2]",
+		},
+		{
+			"regex count",
+			[]entity.FilterRule{{Type: entity.FilterRuleRegex, Pattern: `a+`, Replacement: "-", Count: 2}},
+			"a aa aaa", "- - aaa",
+		},
+		{
+			"selector removes elements",
+			[]entity.FilterRule{{Type: entity.FilterRuleSelector, Pattern: "div.ad"}},
+			`<p>a</p><div class="ad">buy</div><p>b</p>`, "<p>a</p><p>b</p>",
+		},
+		{
+			"paragraph removes innermost blocks containing the pattern",
+			[]entity.FilterRule{{Type: entity.FilterRuleParagraph, Pattern: "記住"}},
+			"<div><p>a</p><p>請記住網址</p></div>", "<div><p>a</p></div>",
+		},
+		{
+			"rules run in order",
+			[]entity.FilterRule{
+				{Type: entity.FilterRuleLiteral, Pattern: "a", Replacement: "b"},
+				{Type: entity.FilterRuleLiteral, Pattern: "b", Replacement: "c"},
+			},
+			"ab", "cc",
+		},
+		{
+			"no rules",
+			nil,
+			"<p>a</p>", "<p>a</p>",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			contentFilter, err := NewContentFilter(tc.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := *contentFilter.Apply(&tc.raw); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestContentFilterRejectsInvalidRules(t *testing.T) {
+	for name, rule := range map[string]entity.FilterRule{
+		"regex":          {Type: entity.FilterRuleRegex, Pattern: "("},
+		"selector":       {Type: entity.FilterRuleSelector, Pattern: "p["},
+		"empty literal":  {Type: entity.FilterRuleLiteral},
+		"empty pattern":  {Type: entity.FilterRuleParagraph},
+		"unknown type":   {Type: "xpath", Pattern: "//p"},
+		"negative count": {Type: entity.FilterRuleLiteral, Pattern: "a", Count: -1},
+	} {
+		if _, err := NewContentFilter([]entity.FilterRule{rule}); err == nil {
+			t.Errorf("%s rule accepted", name)
+		}
+	}
+}
+
+// TestHjwzwDefaultRules checks the migrated rules still do what the
+// fetcher's hand-written filter did, banner replaced once included.
+func TestHjwzwDefaultRules(t *testing.T) {
+	contentFilter, err := NewContentFilter(NewFetcherHjwzw("tw.hjwzw.com").DefaultFilterRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := "<p>banner</p><p>a讀好書,請記住讀書客唯一地址()</p><p>b讀好書,請記住讀書客唯一地址()</p><p>\n</p>"
+	want := "<p>a</p><p>b讀好書,請記住讀書客唯一地址()</p>"
+	if got := *contentFilter.Apply(&raw); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
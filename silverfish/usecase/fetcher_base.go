@@ -9,6 +9,8 @@ import (
 	"regexp"
 	"strings"
 
+	entity "silverfish/silverfish/entity"
+
 	"github.com/PuerkitoBio/goquery"
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
@@ -23,9 +25,10 @@ import (
 
 // Fetcher export
 type Fetcher struct {
-	tls    bool
-	dns    *string
-	dnsReg *regexp.Regexp
+	tls       bool
+	dns       *string
+	dnsReg    *regexp.Regexp
+	sanitizer *Sanitizer
 }
 
 // NewFetcher export
@@ -33,11 +36,38 @@ func (f *Fetcher) NewFetcher(tls bool, dns *string) {
 	f.dns = dns
 	f.tls = tls
 	f.dnsReg = regexp.MustCompile("https?://.*?/")
+	f.sanitizer = NewSanitizer(NewNovelSanitizePolicy())
+}
+
+// SetSanitizePolicy export — lets a fetcher loosen or tighten the
+// default allow-list for its own upstream.
+func (f *Fetcher) SetSanitizePolicy(policy *SanitizePolicy) {
+	f.sanitizer = NewSanitizer(policy)
+}
+
+// Sanitize export
+func (f *Fetcher) Sanitize(raw *string) *string {
+	return f.sanitizer.Sanitize(raw)
+}
+
+// DefaultFilterRules export — rules used for this fetcher until an
+// admin saves a rule set of their own. Most upstreams need none.
+func (f *Fetcher) DefaultFilterRules() []entity.FilterRule {
+	return []entity.FilterRule{}
+}
+
+// SanitizeStats export
+func (f *Fetcher) SanitizeStats() map[string]int64 {
+	return f.sanitizer.Stats()
 }
 
 // Match export
 func (f *Fetcher) Match(url *string) bool {
-	getDNS := f.dnsReg.FindAllString(*url, 1)[0]
+	found := f.dnsReg.FindAllString(*url, 1)
+	if len(found) == 0 {
+		return false
+	}
+	getDNS := found[0]
 	if strings.Contains(getDNS, "https://") {
 		getDNS = strings.Replace(getDNS, "https://", "", 1)
 	} else {
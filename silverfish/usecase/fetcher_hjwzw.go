@@ -34,19 +34,23 @@ func (fh *FetcherHjwzw) IsSplit(doc *goquery.Document) bool {
 	return false
 }
 
-// Filter export
+// Filter export — ad sentences are stripped by the rule engine, see
+// DefaultFilterRules.
 func (fh *FetcherHjwzw) Filter(raw *string) *string {
-	str := *raw
-	index := strings.Index(str, "</p>")
-	if index == -1 {
-		return &str
+	return raw
+}
+
+// DefaultFilterRules export — the first <p> is the site's own
+// "remember our address" banner; the literals are ad sentences it
+// splices into chapter text.
+func (fh *FetcherHjwzw) DefaultFilterRules() []entity.FilterRule {
+	return []entity.FilterRule{
+		{Type: entity.FilterRuleRegex, Pattern: `(?s)^.*?</p>`, Replacement: ""},
+		{Type: entity.FilterRuleLiteral, Pattern: "讀好書,請記住讀書客唯一地址()</p>", Replacement: "</p>", Count: 1},
+		{Type: entity.FilterRuleLiteral, Pattern: "緊張時放松自己，煩惱時安慰自己，開心時別忘了祝福自己!", Replacement: ""},
+		{Type: entity.FilterRuleLiteral, Pattern: "<p>\n</p>", Replacement: ""},
+		{Type: entity.FilterRuleLiteral, Pattern: "<p>\n<br/></p>", Replacement: ""},
 	}
-	str = str[index+4:]
-	str = strings.Replace(str, "讀好書,請記住讀書客唯一地址()</p>", "</p>", 1)
-	str = strings.Replace(str, "緊張時放松自己，煩惱時安慰自己，開心時別忘了祝福自己!", "", -1)
-	str = strings.Replace(str, "<p>\n</p>", "", -1)
-	str = strings.Replace(str, "<p>\n<br/></p>", "", -1)
-	return &str
 }
 
 // CrawlNovel export
@@ -21,6 +21,9 @@ type FetcherTtkan struct {
 func NewFetcherTtkan(dns string) *FetcherTtkan {
 	ft := new(FetcherTtkan)
 	ft.NewFetcher(true, &dns)
+	// ttkan wraps its mid-body ad slots in <center>; drop them whole
+	// rather than unwrapping in case FetchNovelChapter misses one.
+	ft.SetSanitizePolicy(NewNovelSanitizePolicy().Drop("center"))
 	return ft
 }
 
@@ -42,12 +45,28 @@ func (ft *FetcherTtkan) IsSplit(doc *goquery.Document) bool {
 	return false
 }
 
-// Filter export — chapter HTML scrubbing happens via goquery in
-// FetchNovelChapter, so this is a pass-through.
+// Filter export — chapter HTML scrubbing happens via the rule engine,
+// see DefaultFilterRules, so this is a pass-through.
 func (ft *FetcherTtkan) Filter(raw *string) *string {
 	return raw
 }
 
+// DefaultFilterRules export — `div.content` wraps the chapter body AND
+// page chrome that follows it: a `div#div_content_end` sentinel and then
+// a `div.social_share_frame` widget (multiple amp-social-share children).
+// The page itself uses #div_content_end to mark "real content ends
+// here", so chop everything from there onward, then strip in-content
+// junk (bookmark anchor at the top, the mid-body
+// `<center><div class="mobadsq"></div></center>` ad block, and any stray
+// amp-* / script nodes).
+func (ft *FetcherTtkan) DefaultFilterRules() []entity.FilterRule {
+	return []entity.FilterRule{
+		{Type: entity.FilterRuleSelector, Pattern: "#div_content_end ~ *"},
+		{Type: entity.FilterRuleSelector, Pattern: "#div_content_end"},
+		{Type: entity.FilterRuleSelector, Pattern: "a.anchor_bookmark, center, amp-img, amp-analytics, script"},
+	}
+}
+
 // CrawlNovel export
 func (ft *FetcherTtkan) CrawlNovel(url *string) (*entity.Novel, error) {
 	doc, docErr := ft.FetchDoc(url)
@@ -151,17 +170,6 @@ func (ft *FetcherTtkan) FetchNovelChapter(novel *entity.Novel, index int) (*stri
 	}
 
 	content := doc.Find("div.content").First()
-	// `div.content` wraps the chapter body AND page chrome that follows
-	// it: a `div#div_content_end` sentinel and then a
-	// `div.social_share_frame` widget (multiple amp-social-share
-	// children). The page itself uses #div_content_end to mark "real
-	// content ends here", so chop everything from there onward, then
-	// strip in-content junk (bookmark anchor at the top, the mid-body
-	// `<center><div class="mobadsq"></div></center>` ad block, and
-	// any stray amp-* / script nodes).
-	content.Find("#div_content_end").NextAll().Remove()
-	content.Find("#div_content_end").Remove()
-	content.Find("a.anchor_bookmark, center, amp-img, amp-analytics, script").Remove()
 	html, _ := content.Html()
 	return ft.Filter(&html), nil
 }
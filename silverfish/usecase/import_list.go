@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Import list formats
+const (
+	ImportFormatText = "text"
+	ImportFormatCSV  = "csv"
+	ImportFormatJSON = "json"
+)
+
+// ParseImportList export — reads book URLs from `data`:
+//   - text: one URL per line, blank lines and `#` comments skipped
+//   - csv: the `url` column, or the first column without such a header
+//   - json: an array of URLs or of objects with a `url` field, or an
+//     object holding such an array under `urls`
+//
+// An empty `format` guesses from the content. Duplicates are dropped,
+// keeping the order of first appearance.
+func ParseImportList(data []byte, format string) ([]string, error) {
+	if format == "" {
+		format = guessImportFormat(data)
+	}
+	var urls []string
+	var err error
+	switch format {
+	case ImportFormatText:
+		urls = parseImportText(data)
+	case ImportFormatCSV:
+		urls, err = parseImportCSV(data)
+	case ImportFormatJSON:
+		urls, err = parseImportJSON(data)
+	default:
+		return nil, fmt.Errorf("Unknown import format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NormalizeImportList(urls), nil
+}
+
+// NormalizeImportList export — trims `urls` and drops blank and repeated
+// ones, keeping the order of first appearance.
+func NormalizeImportList(urls []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, url := range urls {
+		url = strings.TrimSpace(url)
+		if url == "" || seen[url] {
+			continue
+		}
+		seen[url] = true
+		unique = append(unique, url)
+	}
+	return unique
+}
+
+func guessImportFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return ImportFormatJSON
+	}
+	firstLine := string(trimmed)
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if strings.Contains(firstLine, ",") {
+		return ImportFormatCSV
+	}
+	return ImportFormatText
+}
+
+func parseImportText(data []byte) []string {
+	urls := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls
+}
+
+func parseImportCSV(data []byte) ([]string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CSV: %s", err.Error())
+	}
+	if len(records) == 0 {
+		return []string{}, nil
+	}
+	column := 0
+	for i, header := range records[0] {
+		if strings.EqualFold(strings.TrimSpace(header), "url") {
+			column = i
+			records = records[1:]
+			break
+		}
+	}
+	urls := []string{}
+	for _, record := range records {
+		if column < len(record) {
+			urls = append(urls, record[column])
+		}
+	}
+	return urls, nil
+}
+
+func parseImportJSON(data []byte) ([]string, error) {
+	var wrapper struct {
+		URLs json.RawMessage `json:"urls"`
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, fmt.Errorf("Invalid JSON: %s", err.Error())
+		}
+		if wrapper.URLs == nil {
+			return nil, errors.New("JSON object should hold the URLs under urls")
+		}
+		data = wrapper.URLs
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("Invalid JSON: %s", err.Error())
+	}
+	urls := []string{}
+	for _, item := range items {
+		var url string
+		if err := json.Unmarshal(item, &url); err == nil {
+			urls = append(urls, url)
+			continue
+		}
+		var entry struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal(item, &entry); err != nil {
+			return nil, errors.New("JSON entries should be URLs or objects with a url")
+		}
+		urls = append(urls, entry.URL)
+	}
+	return urls, nil
+}
@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseImportList(t *testing.T) {
+	want := []string{"https://a.example/book/1", "https://b.example/book/2"}
+	cases := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"text", "", "# seed list\nhttps://a.example/book/1\n\n  https://b.example/book/2  \nhttps://a.example/book/1\n"},
+		{"csv with header", "", "title,url\nOne,https://a.example/book/1\nTwo,https://b.example/book/2\n"},
+		{"csv without header", ImportFormatCSV, "https://a.example/book/1\nhttps://b.example/book/2\n"},
+		{"json strings", "", `["https://a.example/book/1", "https://b.example/book/2"]`},
+		{"json objects", "", `[{"url": "https://a.example/book/1"}, {"url": "https://b.example/book/2", "note": "x"}]`},
+		{"json wrapper", "", `{"urls": ["https://a.example/book/1", "https://b.example/book/2"]}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseImportList([]byte(tc.data), tc.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+
+	if _, err := ParseImportList([]byte(`{"books": []}`), ""); err == nil {
+		t.Error("JSON object without urls accepted")
+	}
+	if _, err := ParseImportList([]byte("x"), "xml"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestNormalizeImportList(t *testing.T) {
+	got := NormalizeImportList([]string{" https://a.example/book/1", "", "https://b.example/book/2\n", "https://a.example/book/1", "  "})
+	want := []string{"https://a.example/book/1", "https://b.example/book/2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := NormalizeImportList(nil); got == nil || len(got) != 0 {
+		t.Errorf("got %v, want an empty list", got)
+	}
+}
@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPMailer export — plain-text mail through an SMTP relay. STARTTLS is
+// used whenever the server offers it; credentials are optional, e.g. for
+// a local relay or mail sink.
+type SMTPMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer export
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	sm := new(SMTPMailer)
+	sm.addr = net.JoinHostPort(host, fmt.Sprint(port))
+	sm.host = host
+	sm.username = username
+	sm.password = password
+	sm.from = from
+	return sm
+}
+
+// Send export
+func (sm *SMTPMailer) Send(to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid mail header")
+	}
+	var auth smtp.Auth
+	if sm.username != "" {
+		auth = smtp.PlainAuth("", sm.username, sm.password, sm.host)
+	}
+	message := strings.Join([]string{
+		"From: " + sm.from,
+		"To: " + to,
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: 8bit",
+		"",
+		strings.ReplaceAll(body, "\n", "\r\n"),
+	}, "\r\n")
+	return smtp.SendMail(sm.addr, auth, sm.from, []string{to}, []byte(message))
+}
@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// smtpSink accepts one message without authentication and hands its
+// envelope recipient and data to `received`.
+func smtpSink(t *testing.T, received chan<- [2]string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 sink ready")
+		recipient, data := "", []string{}
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 sink")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				recipient = strings.Trim(strings.TrimSpace(line)[8:], "<>")
+				reply("250 ok")
+			case command == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data = append(data, line)
+				}
+				received <- [2]string{recipient, strings.Join(data, "")}
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener
+}
+
+func TestSMTPMailerAgainstLocalSink(t *testing.T) {
+	received := make(chan [2]string, 1)
+	listener := smtpSink(t, received)
+	defer listener.Close()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	mailer := NewSMTPMailer(host, portNumber, "", "", "silverfish@example.com")
+	if err := mailer.Send("reader@example.com", "Reset your password", "Open https://example.com/reset?token=abc"); err != nil {
+		t.Fatal(err)
+	}
+	message := <-received
+	if message[0] != "reader@example.com" {
+		t.Errorf("mail sent to %q", message[0])
+	}
+	if !strings.Contains(message[1], "Subject: Reset your password") ||
+		!strings.Contains(message[1], "token=abc") {
+		t.Errorf("unexpected message:\n%s", message[1])
+	}
+	if err := mailer.Send("reader@example.com\r\nBcc: x@example.com", "hi", "body"); err == nil {
+		t.Error("header injection accepted")
+	}
+}
@@ -0,0 +1,336 @@
+package usecase
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCConfig export
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// GroupsClaim names the ID token claim listing the user's groups;
+	// members of AdminGroup become admins, everyone else loses admin.
+	// An empty AdminGroup leaves IsAdmin alone.
+	GroupsClaim string
+	AdminGroup  string
+	// AutoProvision creates accounts for unknown identities; otherwise
+	// they have to be linked from a logged-in account first.
+	AutoProvision bool
+}
+
+// OIDCDiscovery export — the parts of the discovery document we use.
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCClient export — a relying party for the authorization-code flow
+// with PKCE. Only RS256-signed ID tokens are accepted.
+type OIDCClient struct {
+	config     *OIDCConfig
+	httpClient *http.Client
+	mutex      sync.Mutex
+	discovery  *OIDCDiscovery
+	keys       map[string]*rsa.PublicKey
+	keysAt     time.Time
+}
+
+// jwksMinRefresh keeps a token with an unknown `kid` from making us
+// hammer the IdP's JWKS endpoint.
+const jwksMinRefresh = time.Minute
+
+// NewOIDCClient export
+func NewOIDCClient(config *OIDCConfig) *OIDCClient {
+	oc := new(OIDCClient)
+	oc.config = config
+	oc.httpClient = &http.Client{Timeout: 10 * time.Second}
+	oc.keys = map[string]*rsa.PublicKey{}
+	return oc
+}
+
+// Config export
+func (oc *OIDCClient) Config() *OIDCConfig { return oc.config }
+
+func (oc *OIDCClient) getJSON(target string, v interface{}) error {
+	res, err := oc.httpClient.Get(target)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", target, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// Discover export — fetched once, then cached.
+func (oc *OIDCClient) Discover() (*OIDCDiscovery, error) {
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+	if oc.discovery != nil {
+		return oc.discovery, nil
+	}
+	discovery := new(OIDCDiscovery)
+	wellKnown := strings.TrimSuffix(oc.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := oc.getJSON(wellKnown, discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != oc.config.Issuer {
+		return nil, fmt.Errorf("discovery issuer %s does not match %s", discovery.Issuer, oc.config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+	oc.discovery = discovery
+	return discovery, nil
+}
+
+// NewPKCE export — a code verifier and its S256 challenge.
+func NewPKCE() (verifier, challenge string) {
+	verifier = RandomURLString(32)
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RandomURLString export — `size` random bytes, URL-safe encoded.
+func RandomURLString(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// AuthorizationURL export
+func (oc *OIDCClient) AuthorizationURL(state, nonce, codeChallenge string) (string, error) {
+	discovery, err := oc.Discover()
+	if err != nil {
+		return "", err
+	}
+	target, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	query := target.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", oc.config.ClientID)
+	query.Set("redirect_uri", oc.config.RedirectURL)
+	query.Set("scope", strings.Join(oc.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	target.RawQuery = query.Encode()
+	return target.String(), nil
+}
+
+// Exchange export — trades an authorization code for the raw ID token.
+func (oc *OIDCClient) Exchange(code, codeVerifier string) (string, error) {
+	discovery, err := oc.Discover()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {oc.config.RedirectURL},
+		"client_id":     {oc.config.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if oc.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(oc.config.ClientID), url.QueryEscape(oc.config.ClientSecret))
+	}
+	res, err := oc.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	result := struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("token endpoint answered %s", res.Status)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("token endpoint refused: %s %s", result.Error, result.ErrorDescription)
+	}
+	if result.IDToken == "" {
+		return "", errors.New("token endpoint returned no id_token")
+	}
+	return result.IDToken, nil
+}
+
+// publicKey looks `kid` up in the cached JWKS, refetching it once when the
+// IdP may have rotated keys.
+func (oc *OIDCClient) publicKey(kid string) (*rsa.PublicKey, error) {
+	discovery, err := oc.Discover()
+	if err != nil {
+		return nil, err
+	}
+	oc.mutex.Lock()
+	defer oc.mutex.Unlock()
+	if key, ok := oc.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(oc.keysAt) < jwksMinRefresh {
+		return nil, fmt.Errorf("unknown signing key %s", kid)
+	}
+	jwks := struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}{}
+	if err := oc.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	oc.keys = map[string]*rsa.PublicKey{}
+	oc.keysAt = time.Now()
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		oc.keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if key, ok := oc.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %s", kid)
+}
+
+// VerifyIDToken export — checks signature, issuer, audience, expiry and
+// nonce, and returns the token's claims.
+func (oc *OIDCClient) VerifyIDToken(raw, nonce string) (map[string]interface{}, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed id_token")
+	}
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported id_token algorithm %s", header.Alg)
+	}
+	key, err := oc.publicKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed id_token signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, errors.New("invalid id_token signature")
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != oc.config.Issuer {
+		return nil, errors.New("id_token issuer mismatch")
+	}
+	if !audienceContains(claims["aud"], oc.config.ClientID) {
+		return nil, errors.New("id_token audience mismatch")
+	}
+	const leeway = time.Minute
+	exp, ok := claims["exp"].(float64)
+	if !ok || time.Now().Add(-leeway).After(time.Unix(int64(exp), 0)) {
+		return nil, errors.New("id_token expired")
+	}
+	if iat, ok := claims["iat"].(float64); ok && time.Unix(int64(iat), 0).After(time.Now().Add(leeway)) {
+		return nil, errors.New("id_token issued in the future")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, errors.New("id_token has no subject")
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("malformed id_token")
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return errors.New("malformed id_token")
+	}
+	return nil
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ClaimStrings export — a claim as a list of strings, accepting a single
+// string too (some IdPs send one group that way).
+func ClaimStrings(claims map[string]interface{}, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := []string{}
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockIdP is a minimal OpenID provider: discovery, JWKS, and a token
+// endpoint that redeems the one code it issued if the PKCE verifier
+// matches the challenge it saw.
+type mockIdP struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	claims    map[string]interface{}
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "the-code" ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != idp.challenge {
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, idp.claims)})
+	})
+	idp.server = httptest.NewServer(mux)
+	idp.claims = map[string]interface{}{
+		"iss":    idp.server.URL,
+		"aud":    "silverfish",
+		"sub":    "user-1",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"iat":    time.Now().Unix(),
+		"groups": []string{"readers", "library-admins"},
+	}
+	return idp
+}
+
+func (idp *mockIdP) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signing))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDCClientAgainstMockIdP(t *testing.T) {
+	idp := newMockIdP(t)
+	defer idp.server.Close()
+	client := NewOIDCClient(&OIDCConfig{
+		Issuer:      idp.server.URL,
+		ClientID:    "silverfish",
+		RedirectURL: "https://silverfish.example/oidc/callback",
+		Scopes:      []string{"openid", "groups"},
+		GroupsClaim: "groups",
+	})
+
+	verifier, challenge := NewPKCE()
+	authURL, err := client.AuthorizationURL("the-state", "the-nonce", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ := url.Parse(authURL)
+	query := parsed.Query()
+	if !strings.HasPrefix(authURL, idp.server.URL+"/authorize?") ||
+		query.Get("code_challenge") != challenge || query.Get("code_challenge_method") != "S256" ||
+		query.Get("state") != "the-state" || query.Get("nonce") != "the-nonce" {
+		t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+	idp.challenge = challenge
+	idp.claims["nonce"] = "the-nonce"
+
+	if _, err := client.Exchange("the-code", "wrong-verifier"); err == nil {
+		t.Error("exchange with a wrong PKCE verifier succeeded")
+	}
+	idToken, err := client.Exchange("the-code", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := client.VerifyIDToken(idToken, "the-nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims["sub"] != "user-1" || len(ClaimStrings(claims, "groups")) != 2 {
+		t.Errorf("unexpected claims %v", claims)
+	}
+
+	if _, err := client.VerifyIDToken(idToken, "other-nonce"); err == nil {
+		t.Error("id_token with a wrong nonce accepted")
+	}
+	parts := strings.Split(idToken, ".")
+	forged, _ := json.Marshal(map[string]interface{}{"iss": idp.server.URL, "aud": "silverfish", "sub": "admin",
+		"exp": time.Now().Add(time.Hour).Unix(), "nonce": "the-nonce"})
+	if _, err := client.VerifyIDToken(parts[0]+"."+base64.RawURLEncoding.EncodeToString(forged)+"."+parts[2], "the-nonce"); err == nil {
+		t.Error("id_token with a forged payload accepted")
+	}
+	for name, mutate := range map[string]func(map[string]interface{}){
+		"expired":        func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"other audience": func(c map[string]interface{}) { c["aud"] = "someone-else" },
+		"other issuer":   func(c map[string]interface{}) { c["iss"] = "https://evil.example" },
+	} {
+		claims := map[string]interface{}{}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		mutate(claims)
+		if _, err := client.VerifyIDToken(idp.sign(t, claims), "the-nonce"); err == nil {
+			t.Errorf("%s id_token accepted", name)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package usecase
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// SanitizePolicy export — allow-list of tags (and per-tag attributes)
+// that survive sanitizing. Anything else is either unwrapped (children
+// kept) or, for tags listed in DropContent, removed with its subtree.
+type SanitizePolicy struct {
+	AllowedTags map[string][]string
+	DropContent map[string]bool
+}
+
+// NewNovelSanitizePolicy export — default policy for chapter bodies: the
+// frontend only needs paragraphs, line breaks and basic emphasis.
+func NewNovelSanitizePolicy() *SanitizePolicy {
+	return &SanitizePolicy{
+		AllowedTags: map[string][]string{
+			"p": {}, "br": {}, "div": {}, "span": {}, "hr": {},
+			"b": {}, "strong": {}, "i": {}, "em": {}, "u": {},
+			"h1": {}, "h2": {}, "h3": {}, "h4": {}, "h5": {}, "h6": {},
+			"blockquote": {}, "ruby": {}, "rt": {}, "rp": {},
+		},
+		DropContent: map[string]bool{
+			"script": true, "style": true, "iframe": true, "frame": true,
+			"object": true, "embed": true, "noscript": true, "form": true,
+			"svg": true, "img": true, "video": true, "audio": true,
+			"link": true, "meta": true, "template": true, "head": true,
+		},
+	}
+}
+
+// Allow export — returns a copy of the policy with extra tags allowed,
+// so a fetcher can loosen the default without mutating the shared one.
+func (sp *SanitizePolicy) Allow(tag string, attrs ...string) *SanitizePolicy {
+	clone := &SanitizePolicy{
+		AllowedTags: map[string][]string{},
+		DropContent: map[string]bool{},
+	}
+	for k, v := range sp.AllowedTags {
+		clone.AllowedTags[k] = v
+	}
+	for k, v := range sp.DropContent {
+		clone.DropContent[k] = v
+	}
+	clone.AllowedTags[tag] = attrs
+	delete(clone.DropContent, tag)
+	return clone
+}
+
+// Drop export — returns a copy of the policy that removes `tag` along
+// with everything inside it.
+func (sp *SanitizePolicy) Drop(tag string) *SanitizePolicy {
+	clone := sp.Allow(tag)
+	delete(clone.AllowedTags, tag)
+	clone.DropContent[tag] = true
+	return clone
+}
+
+// Sanitizer export
+type Sanitizer struct {
+	policy *SanitizePolicy
+	mutex  sync.Mutex
+	stats  map[string]int64
+}
+
+// NewSanitizer export
+func NewSanitizer(policy *SanitizePolicy) *Sanitizer {
+	s := new(Sanitizer)
+	s.policy = policy
+	s.stats = map[string]int64{}
+	return s
+}
+
+// Stats export — how many of each element / attribute have been
+// stripped since start-up. Keys are the tag name, or `@attr` for
+// attributes. A new key showing up is usually an upstream ad change.
+func (s *Sanitizer) Stats() map[string]int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stats := map[string]int64{}
+	for k, v := range s.stats {
+		stats[k] = v
+	}
+	return stats
+}
+
+func (s *Sanitizer) count(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.stats[key]; !ok {
+		logrus.Printf("Sanitizer stripped <%s> for the first time", key)
+	}
+	s.stats[key]++
+}
+
+// Sanitize export
+func (s *Sanitizer) Sanitize(raw *string) *string {
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(*raw), context)
+	if err != nil {
+		logrus.Printf("Sanitizer failed to parse content: %s", err.Error())
+		empty := ""
+		return &empty
+	}
+
+	var buf bytes.Buffer
+	for _, node := range nodes {
+		for _, clean := range s.clean(node) {
+			html.Render(&buf, clean)
+		}
+	}
+	output := buf.String()
+	return &output
+}
+
+// clean returns the nodes that should replace `node` in the output:
+// itself (scrubbed), its children (unwrapped) or nothing (dropped).
+func (s *Sanitizer) clean(node *html.Node) []*html.Node {
+	switch node.Type {
+	case html.TextNode:
+		return []*html.Node{node}
+	case html.CommentNode:
+		s.count("!comment")
+		return nil
+	case html.ElementNode:
+	default:
+		return nil
+	}
+
+	tag := strings.ToLower(node.Data)
+	children := []*html.Node{}
+	if !s.policy.DropContent[tag] {
+		for child := node.FirstChild; child != nil; {
+			next := child.NextSibling
+			node.RemoveChild(child)
+			children = append(children, s.clean(child)...)
+			child = next
+		}
+	}
+
+	allowedAttrs, ok := s.policy.AllowedTags[tag]
+	if !ok {
+		s.count(tag)
+		return children
+	}
+
+	attrs := []html.Attribute{}
+	for _, attr := range node.Attr {
+		if isAllowedAttr(attr, allowedAttrs) {
+			attrs = append(attrs, attr)
+		} else {
+			s.count("@" + strings.ToLower(attr.Key))
+		}
+	}
+	node.Attr = attrs
+	for _, child := range children {
+		node.AppendChild(child)
+	}
+	return []*html.Node{node}
+}
+
+func isAllowedAttr(attr html.Attribute, allowed []string) bool {
+	key := strings.ToLower(attr.Key)
+	if attr.Namespace != "" || strings.HasPrefix(key, "on") {
+		return false
+	}
+	value := strings.ToLower(strings.TrimSpace(attr.Val))
+	if strings.HasPrefix(value, "javascript:") || strings.HasPrefix(value, "data:") {
+		return false
+	}
+	for _, a := range allowed {
+		if key == a {
+			return true
+		}
+	}
+	return false
+}
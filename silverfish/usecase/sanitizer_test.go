@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	linkPolicy := NewNovelSanitizePolicy().Allow("a", "href")
+	cases := []struct {
+		name   string
+		policy *SanitizePolicy
+		raw    string
+		want   string
+	}{
+		{"plain text", nil, "第一章 開始", "第一章 開始"},
+		{"allowed tags kept", nil, "<p>a<br>b</p><b>c</b>", "<p>a<br/>b</p><b>c</b>"},
+		{"script dropped with content", nil, "<p>a</p><script>alert(1)</script>", "<p>a</p>"},
+		{"style dropped with content", nil, "<style>p{display:none}</style><p>a</p>", "<p>a</p>"},
+		{"unknown tags unwrapped", nil, "<p><font color=red>a</font></p>", "<p>a</p>"},
+		{"comments dropped", nil, "<p>a<!-- ad --></p>", "<p>a</p>"},
+		{"event handlers stripped", nil, `<p onclick="x()" onmouseover="y()">a</p>`, "<p>a</p>"},
+		{"attributes not allowed stripped", nil, `<p style="color:red" class="ad">a</p>`, "<p>a</p>"},
+		{"img dropped", nil, `<p>a<img src="x.png" onerror="x()"></p>`, "<p>a</p>"},
+		{"allowed attribute kept", linkPolicy, `<a href="https://example.com/">a</a>`, `<a href="https://example.com/">a</a>`},
+		{"javascript url stripped", linkPolicy, `<a href=" JavaScript:alert(1)">a</a>`, "<a>a</a>"},
+		{"data url stripped", linkPolicy, `<a href="data:text/html,x">a</a>`, "<a>a</a>"},
+		{"handler on allowed tag stripped", linkPolicy, `<a href="/x" onclick="x()">a</a>`, `<a href="/x">a</a>`},
+		{"ttkan drops center", NewFetcherTtkan("www.ttkan.co").sanitizer.policy, "<p>a</p><center>ad</center>", "<p>a</p>"},
+		{"default unwraps center", nil, "<center>a</center>", "a"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := tc.policy
+			if policy == nil {
+				policy = NewNovelSanitizePolicy()
+			}
+			if got := *NewSanitizer(policy).Sanitize(&tc.raw); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSanitizePolicyCopies(t *testing.T) {
+	base := NewNovelSanitizePolicy()
+	base.Allow("a", "href")
+	base.Drop("p")
+	if _, ok := base.AllowedTags["a"]; ok {
+		t.Error("Allow changed the policy it was called on")
+	}
+	if base.DropContent["p"] {
+		t.Error("Drop changed the policy it was called on")
+	}
+}
+
+func TestSanitizerStats(t *testing.T) {
+	s := NewSanitizer(NewNovelSanitizePolicy())
+	raw := `<p onclick="x()">a</p><script></script><script></script>`
+	s.Sanitize(&raw)
+	stats := s.Stats()
+	if stats["script"] != 2 || stats["@onclick"] != 1 {
+		t.Errorf("got %v", stats)
+	}
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchTokens export — tokenizes book text for the search index. Text is
+// folded to Simplified script and lower case first, so a query in either
+// script hits books from either kind of source. Han runs have no word
+// boundaries, so every character is indexed as a unigram and every pair
+// as an overlapping bigram; other letters and digits are indexed as
+// whole words.
+func SearchTokens(texts ...string) []string {
+	return tokenize(true, texts)
+}
+
+// SearchQueryTokens export — tokenizes a search query the way
+// SearchTokens indexes books, except that Han runs of two or more
+// characters give only their bigrams: a one-character query matches the
+// indexed unigram, a longer one isn't diluted by single characters every
+// other book shares.
+func SearchQueryTokens(query string) []string {
+	return tokenize(false, []string{query})
+}
+
+func tokenize(unigrams bool, texts []string) []string {
+	seen := map[string]bool{}
+	tokens := []string{}
+	add := func(token string) {
+		if token != "" && !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	for _, text := range texts {
+		folded := []rune(strings.ToLower(ConvertScript(text, ScriptSimplified)))
+		for i := 0; i < len(folded); {
+			switch {
+			case unicode.Is(unicode.Han, folded[i]):
+				j := i
+				for j < len(folded) && unicode.Is(unicode.Han, folded[j]) {
+					j++
+				}
+				for k := i; k < j; k++ {
+					if unigrams || j-i == 1 {
+						add(string(folded[k]))
+					}
+					if k+1 < j {
+						add(string(folded[k : k+2]))
+					}
+				}
+				i = j
+			case unicode.IsLetter(folded[i]) || unicode.IsNumber(folded[i]):
+				j := i
+				for j < len(folded) && !unicode.Is(unicode.Han, folded[j]) &&
+					(unicode.IsLetter(folded[j]) || unicode.IsNumber(folded[j])) {
+					j++
+				}
+				add(string(folded[i:j]))
+				i = j
+			default:
+				i++
+			}
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchTokens(t *testing.T) {
+	cases := []struct {
+		texts []string
+		want  []string
+	}{
+		{[]string{"龍族"}, []string{"龙", "龙族", "族"}},
+		{[]string{"斗破苍穹"}, []string{"斗", "斗破", "破", "破苍", "苍", "苍穹", "穹"}},
+		{[]string{"Re:從零開始"}, []string{"re", "从", "从零", "零", "零开", "开", "开始", "始"}},
+		{[]string{"第2卷", "江南"}, []string{"第", "2", "卷", "江", "江南", "南"}},
+		{[]string{"Harry Potter", "harry"}, []string{"harry", "potter"}},
+		{[]string{"，。！"}, []string{}},
+	}
+	for _, tc := range cases {
+		if got := SearchTokens(tc.texts...); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SearchTokens(%q) = %q, want %q", tc.texts, got, tc.want)
+		}
+	}
+}
+
+func TestSearchQueryTokens(t *testing.T) {
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"龍", []string{"龙"}},
+		{"龍族", []string{"龙族"}},
+		{"斗破蒼穹", []string{"斗破", "破苍", "苍穹"}},
+		{"Re:從零", []string{"re", "从零"}},
+		{"龍 族", []string{"龙", "族"}},
+		{"  ", []string{}},
+	}
+	for _, tc := range cases {
+		if got := SearchQueryTokens(tc.query); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SearchQueryTokens(%q) = %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+// TestSearchQueryMatchesIndex checks every query token is one the
+// book's index carries, whichever script either side is written in.
+func TestSearchQueryMatchesIndex(t *testing.T) {
+	cases := []struct {
+		title string
+		query string
+	}{
+		{"龍族", "龍"},
+		{"龍族", "龙"},
+		{"龙族", "龍族"},
+		{"斗破苍穹", "鬥破蒼穹"},
+		{"Re:從零開始的異世界生活", "re 异世界"},
+		{"全職高手", "职"},
+	}
+	for _, tc := range cases {
+		indexed := map[string]bool{}
+		for _, token := range SearchTokens(tc.title) {
+			indexed[token] = true
+		}
+		for _, token := range SearchQueryTokens(tc.query) {
+			if !indexed[token] {
+				t.Errorf("%q: query token %q not indexed for %q", tc.query, token, tc.title)
+			}
+		}
+	}
+}
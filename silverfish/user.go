@@ -2,23 +2,31 @@ package silverfish
 
 import (
 	"errors"
+	"net/mail"
 	"strconv"
+	"strings"
 	"time"
 
 	entity "silverfish/silverfish/entity"
+	usecase "silverfish/silverfish/usecase"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // User export
 type User struct {
-	userInf *entity.MongoInf
+	userInf  *entity.MongoInf
+	novelInf *entity.MongoInf
+	comicInf *entity.MongoInf
 }
 
 // NewUser export
-func NewUser(userInf *entity.MongoInf) *User {
+func NewUser(userInf, novelInf, comicInf *entity.MongoInf) *User {
 	u := new(User)
 	u.userInf = userInf
+	u.novelInf = novelInf
+	u.comicInf = comicInf
 	return u
 }
 
@@ -26,8 +34,8 @@ func NewUser(userInf *entity.MongoInf) *User {
 func (u *User) GetUser(account *string) (*entity.User, error) {
 	result, err := u.userInf.FindOne(bson.M{"account": *account}, &entity.User{})
 	if err != nil {
-		if err.Error() == "not found" {
-			return nil, errors.New("Account not exists")
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, NotFoundError("Account not exists")
 		}
 		return nil, err
 	}
@@ -38,47 +46,118 @@ func (u *User) GetUser(account *string) (*entity.User, error) {
 func (u *User) GetUserBookmark(account *string) (*entity.Bookmark, error) {
 	result, err := u.userInf.FindOne(bson.M{"account": account}, &entity.User{})
 	if err != nil {
-		return nil, errors.New("Account not exists")
+		return nil, NotFoundError("Account not exists")
 	}
 	return result.(*entity.User).Bookmark, nil
 }
 
+// GetPreference export
+func (u *User) GetPreference(account *string) (*entity.Preference, error) {
+	result, err := u.userInf.FindSelectOne(bson.M{"account": *account}, bson.M{"preference": 1}, &entity.User{})
+	if err != nil {
+		return nil, NotFoundError("Account not exists")
+	}
+	return &result.(*entity.User).Preference, nil
+}
+
+// UpdatePreference export
+func (u *User) UpdatePreference(account *string, preference *entity.Preference) (*entity.Preference, error) {
+	if preference.Script != "" && usecase.ParseScript(preference.Script) == usecase.ScriptOriginal {
+		return nil, InvalidArgumentError("Unknown script, should be one of hans, hant")
+	}
+	preference.Script = usecase.ParseScript(preference.Script)
+	err := u.userInf.Update(bson.M{"account": *account}, bson.M{
+		"$set": bson.M{"preference": preference},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return preference, nil
+}
+
+// UpdateEmail export — the address password reset mails go to. Empty
+// removes it.
+func (u *User) UpdateEmail(account, email *string) error {
+	address := strings.TrimSpace(*email)
+	if address != "" {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil || parsed.Address != address {
+			return InvalidArgumentError("Invalid email")
+		}
+		count, err := u.userInf.Count(bson.M{"email": address, "account": bson.M{"$ne": *account}})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return InvalidArgumentError("Email is used by another account")
+		}
+	}
+	err := u.userInf.Update(bson.M{"account": *account}, bson.M{
+		"$set": bson.M{"email": address},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		// Taken between the count above and the update.
+		return InvalidArgumentError("Email is used by another account")
+	}
+	return err
+}
+
 // UpdateBookmark export
-func (u *User) UpdateBookmark(bookType string, bookID, account, indexStr *string) {
+func (u *User) UpdateBookmark(bookType string, bookID, account, indexStr *string) error {
 	index, err := strconv.Atoi(*indexStr)
 	if err != nil {
-		return
-	}
-	result, err2 := u.userInf.FindOne(bson.M{"account": *account}, &entity.User{})
-	if err2 == nil {
-		user := result.(*entity.User)
-		if bookType == "Novel" {
-			if val, ok := user.Bookmark.Novel[*bookID]; ok {
-				val.LastReadIndex = index
-				val.LastReadDatetime = time.Now()
-				user.Bookmark.Novel[*bookID] = val
-			} else {
-				user.Bookmark.Novel[*bookID] = &entity.BookmarkEntry{
-					Type:             bookType,
-					ID:               *bookID,
-					LastReadIndex:    index,
-					LastReadDatetime: time.Now(),
-				}
-			}
-		} else {
-			if val, ok := user.Bookmark.Comic[*bookID]; ok {
-				val.LastReadIndex = index
-				val.LastReadDatetime = time.Now()
-				user.Bookmark.Comic[*bookID] = val
-			} else {
-				user.Bookmark.Comic[*bookID] = &entity.BookmarkEntry{
-					Type:             bookType,
-					ID:               *bookID,
-					LastReadIndex:    index,
-					LastReadDatetime: time.Now(),
-				}
-			}
+		return InvalidArgumentError("Invalid chapter index")
+	}
+	result, err := u.userInf.FindOne(bson.M{"account": *account}, &entity.User{})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return NotFoundError("User not exists")
 		}
-		u.userInf.Upsert(bson.M{"account": *account}, user)
+		return err
+	}
+	user := result.(*entity.User)
+	entries, bookInf, idKey := user.Bookmark.Novel, u.novelInf, "novelID"
+	if bookType != "Novel" {
+		entries, bookInf, idKey = user.Bookmark.Comic, u.comicInf, "comicID"
+	}
+	entry, bookmarked := entries[*bookID]
+	if !bookmarked {
+		entry = &entity.BookmarkEntry{Type: bookType, ID: *bookID}
+		entries[*bookID] = entry
+	}
+	entry.LastReadIndex = index
+	entry.LastReadDatetime = time.Now()
+	if _, err := u.userInf.Upsert(bson.M{"account": *account}, user); err != nil {
+		return err
+	}
+	if !bookmarked {
+		bookInf.Update(bson.M{idKey: *bookID}, bson.M{"$inc": bson.M{"popularity": 1}})
+	}
+	return nil
+}
+
+// RemoveBookmark export — forgets the bookmark, and with it the
+// popularity it gave the book.
+func (u *User) RemoveBookmark(bookType string, bookID, account *string) error {
+	bookmark, err := u.GetUserBookmark(account)
+	if err != nil {
+		return err
+	}
+	if bookmark == nil {
+		return NotFoundError("Bookmark not exists")
+	}
+	entries, bookInf, idKey := bookmark.Novel, u.novelInf, "novelID"
+	if bookType == "Comic" {
+		entries, bookInf, idKey = bookmark.Comic, u.comicInf, "comicID"
+	}
+	if _, ok := entries[*bookID]; !ok {
+		return NotFoundError("Bookmark not exists")
+	}
+	if err := u.userInf.Update(bson.M{"account": *account}, bson.M{
+		"$unset": bson.M{"bookmark." + strings.ToLower(bookType) + "." + *bookID: ""},
+	}); err != nil {
+		return err
 	}
+	bookInf.Update(bson.M{idKey: *bookID, "popularity": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"popularity": -1}})
+	return nil
 }
@@ -1,9 +1,12 @@
 package silverfish
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/sha512"
+	"encoding/base64"
 	"encoding/hex"
-	"math/rand"
+	"math/big"
 	"strings"
 )
 
@@ -18,13 +21,35 @@ func SHA512Str(src, hashSalt *string) *string {
 	return &s
 }
 
+// SHA256Str export — unsalted, for high-entropy secrets such as session
+// tokens where only a lookup key is needed.
+func SHA256Str(src *string) *string {
+	h := sha256.Sum256([]byte(*src))
+	s := hex.EncodeToString(h[:])
+	return &s
+}
+
 // RandomStr export
 func RandomStr(length int) *string {
-	output := ""
-	index := 0
-	for i := 0; i < length; i++ {
-		index = rand.Intn(len(dictionary))
-		output += dictionary[index : index+1]
+	output := make([]byte, length)
+	max := big.NewInt(int64(len(dictionary)))
+	for i := range output {
+		index, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		output[i] = dictionary[index.Int64()]
+	}
+	s := string(output)
+	return &s
+}
+
+// RandomToken export — 256 bits from crypto/rand, URL-safe encoded.
+func RandomToken() *string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
 	}
-	return &output
+	s := base64.RawURLEncoding.EncodeToString(buf)
+	return &s
 }
@@ -0,0 +1,44 @@
+package silverfish
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestRandomToken(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		token := *RandomToken()
+		raw, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil || len(raw) != 32 {
+			t.Fatalf("%q isn't 32 URL-safe encoded bytes", token)
+		}
+		if seen[token] {
+			t.Fatalf("%q generated twice", token)
+		}
+		seen[token] = true
+	}
+}
+
+func TestRandomStr(t *testing.T) {
+	for _, length := range []int{0, 1, 24, 64} {
+		s := *RandomStr(length)
+		if len(s) != length {
+			t.Errorf("got %d characters, want %d", len(s), length)
+		}
+		if trimmed := strings.Trim(s, dictionary); trimmed != "" {
+			t.Errorf("%q has characters outside the dictionary", s)
+		}
+	}
+	if *RandomStr(24) == *RandomStr(24) {
+		t.Error("two random strings are equal")
+	}
+}
+
+func TestSHA256Str(t *testing.T) {
+	src := "abc"
+	if got := *SHA256Str(&src); got != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("got %s", got)
+	}
+}